@@ -1,13 +1,16 @@
 tools/mockgen:
 	GOBIN=$(abspath tools) go install github.com/golang/mock/mockgen@v1.6.0
 
+tools/controller-gen:
+	GOBIN=$(abspath tools) go install sigs.k8s.io/controller-tools/cmd/controller-gen@v0.16.5
+
 clean-tools:
 	rm -rf tools
 
 clean: clean-tools
 
 .PHONY: generate
-generate: tools/mockgen
+generate: tools/mockgen tools/controller-gen
 	go generate ./...
 
 ENVTEST = $(abspath tools)/setup-envtest
@@ -18,3 +21,13 @@ $(ENVTEST):
 .PHONY: test-unit
 test-unit: generate $(ENVTEST)
 	KUBEBUILDER_ASSETS="$(shell $(ENVTEST) use $(ENVTEST_K8S_VERSION) -p path)" go run github.com/onsi/ginkgo/v2/ginkgo -p --nodes 4 --cover -r -randomize-all --randomize-suites ./...
+
+COVERAGE_THRESHOLD ?= 80
+# COVERAGE_BADGE is the file the coverage badge is rendered to. It is only set
+# in CI, the badge is not committed.
+COVERAGE_BADGE ?=
+
+.PHONY: test-coverage
+test-coverage: generate ## Fail if the line coverage of pkg/iam is below COVERAGE_THRESHOLD percent, render the badge to COVERAGE_BADGE if set.
+	go test -coverprofile=coverage.out ./pkg/iam/...
+	./hack/check-coverage.sh --coverage-threshold $(COVERAGE_THRESHOLD) $(if $(COVERAGE_BADGE),--badge $(COVERAGE_BADGE)) coverage.out
@@ -0,0 +1,5 @@
+package v1alpha1
+
+//go:generate ../../tools/controller-gen object:headerFile=../../hack/boilerplate.go.txt paths=./...
+//go:generate ../../tools/controller-gen crd paths=./... output:crd:artifacts:config=../../config/crd/bases
+//go:generate cp ../../config/crd/bases/iam.giantswarm.io_irsaconfigs.yaml ../../helm/capa-iam-operator/crds/
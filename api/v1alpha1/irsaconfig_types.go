@@ -0,0 +1,108 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IRSAConfigSpec defines the trust policies of the IRSA roles of a cluster.
+type IRSAConfigSpec struct {
+	// TrustDomains are the OIDC provider domains trusted by the IRSA roles in
+	// addition to the IRSA domain of the cluster. They replace the
+	// aws.giantswarm.io/irsa-trust-domains annotation of the AWSCluster.
+	// +optional
+	TrustDomains []string `json:"trustDomains,omitempty"`
+
+	// ServiceAccounts override the service accounts trusted by the IRSA
+	// roles. Roles without an entry trust their default service account.
+	// +optional
+	// +listType=map
+	// +listMapKey=roleType
+	ServiceAccounts []IRSAServiceAccount `json:"serviceAccounts,omitempty"`
+
+	// HostedZoneIDs restrict the Route 53 records external-dns and
+	// cert-manager may change to the given hosted zones. All hosted zones are
+	// allowed when empty.
+	// +optional
+	// +kubebuilder:validation:items:Pattern=`^[A-Z0-9]{1,32}$`
+	HostedZoneIDs []string `json:"hostedZoneIDs,omitempty"`
+
+	// ExtraConditions are added to the trust policies of all IRSA roles, e.g.
+	// to only allow assuming them from the IP addresses of the cluster.
+	// +optional
+	ExtraConditions []IRSACondition `json:"extraConditions,omitempty"`
+}
+
+// IRSACondition is a condition of the trust policies of the IRSA roles.
+type IRSACondition struct {
+	// Operator is the condition operator, e.g. StringEquals.
+	// +kubebuilder:validation:Enum=StringEquals;StringNotEquals;StringLike;StringNotLike;ArnEquals;ArnNotEquals;ArnLike;ArnNotLike;IpAddress;NotIpAddress;Bool
+	Operator string `json:"operator"`
+
+	// Key is the condition key, e.g. aws:SourceIp.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	// Values of the condition key. The condition is met if any of them
+	// matches.
+	// +kubebuilder:validation:MinItems=1
+	Values []string `json:"values"`
+}
+
+// IRSAServiceAccount is the service account trusted by an IRSA role.
+type IRSAServiceAccount struct {
+	// RoleType is the type of the IRSA role.
+	// +kubebuilder:validation:Enum=route53-role;cert-manager-role;ALBController-Role;ebs-csi-driver-role;efs-csi-driver-role;cluster-autoscaler-role
+	RoleType string `json:"roleType"`
+
+	// Namespace of the service account. It is ignored by the roles of the
+	// AWS Load Balancer Controller and external-dns, which trust service
+	// accounts in any namespace.
+	// +kubebuilder:default=kube-system
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the service account.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=capa-iam-operator
+
+// IRSAConfig configures the IRSA roles of the cluster it is named after. It
+// must be created in the namespace of the cluster.
+type IRSAConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IRSAConfigSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IRSAConfigList contains a list of IRSAConfig
+type IRSAConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IRSAConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IRSAConfig{}, &IRSAConfigList{})
+}
@@ -0,0 +1,463 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+)
+
+const (
+	snsMessageTypeNotification             = "Notification"
+	snsMessageTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+
+	configItemChangeNotification = "ConfigurationItemChangeNotification"
+	configResourceTypeIAMRole    = "AWS::IAM::Role"
+
+	// maxNotificationSize limits the size of the request bodies read by the
+	// AWS Config notification endpoint. SNS messages are at most 256 KiB.
+	maxNotificationSize = 512 * 1024
+	// maxSigningCertSize limits the size of the SNS signing certificates.
+	maxSigningCertSize = 64 * 1024
+)
+
+// rolePolicyProperties are the prefixes of the AWS Config IAM role properties
+// which contain the policies managed by the operator.
+var rolePolicyProperties = []string{
+	"Configuration.AssumeRolePolicyDocument",
+	"Configuration.AttachedManagedPolicies",
+	"Configuration.RolePolicyList",
+}
+
+// snsMessage is the envelope of the messages SNS delivers to HTTP(S)
+// subscriptions.
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	Token            string `json:"Token"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// stringToSign returns the message fields signed by SNS, see
+// https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html.
+func (m snsMessage) stringToSign() string {
+	var fields [][2]string
+	if m.Type == snsMessageTypeNotification {
+		fields = [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}}
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", m.Timestamp}, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+	} else {
+		fields = [][2]string{
+			{"Message", m.Message},
+			{"MessageId", m.MessageID},
+			{"SubscribeURL", m.SubscribeURL},
+			{"Timestamp", m.Timestamp},
+			{"Token", m.Token},
+			{"TopicArn", m.TopicArn},
+			{"Type", m.Type},
+		}
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+// configNotification is the part of an AWS Config configuration item change
+// notification used by the operator.
+type configNotification struct {
+	MessageType       string `json:"messageType"`
+	ConfigurationItem struct {
+		ResourceType string `json:"resourceType"`
+		ResourceName string `json:"resourceName"`
+	} `json:"configurationItem"`
+	ConfigurationItemDiff struct {
+		ChangedProperties map[string]json.RawMessage `json:"changedProperties"`
+	} `json:"configurationItemDiff"`
+}
+
+// AWSConfigNotificationServer is a manager runnable serving an HTTP endpoint
+// for AWS Config notifications delivered by an SNS topic subscription. When a
+// notification reports a policy change on an IAM role managed by the
+// operator, the AWSMachineTemplates owning the role are enqueued, so drift is
+// corrected without waiting for the next resync.
+type AWSConfigNotificationServer struct {
+	Addr   string
+	Client client.Client
+	Events chan<- event.GenericEvent
+	// TopicARNs are the SNS topics messages are accepted from. Messages of
+	// any other topic are rejected.
+	TopicARNs []string
+	// HTTPClient fetches SNS signing certificates and confirms SNS
+	// subscriptions. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	signingCertsMu sync.Mutex
+	signingCerts   map[string]*x509.Certificate
+}
+
+// Start serves the endpoint until the context is cancelled.
+func (s *AWSConfigNotificationServer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("awsconfig-notifications")
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(_ net.Listener) context.Context {
+			return log.IntoContext(ctx, logger)
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "failed to shut down AWS Config notification endpoint")
+		}
+	}()
+
+	logger.Info("serving AWS Config notification endpoint", "addr", s.Addr)
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// NeedLeaderElection makes sure only the leader serves the endpoint, since
+// only its controllers consume the enqueued events.
+func (s *AWSConfigNotificationServer) NeedLeaderElection() bool {
+	return true
+}
+
+// ServeHTTP handles a single SNS message.
+func (s *AWSConfigNotificationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxNotificationSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var message snsMessage
+	err = json.Unmarshal(body, &message)
+	if err != nil {
+		logger.Info("ignoring invalid SNS message", "error", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !slices.Contains(s.TopicARNs, message.TopicArn) {
+		logger.Info("rejecting SNS message of unexpected topic", "topic_arn", message.TopicArn)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	err = s.verifySignature(ctx, message)
+	if err != nil {
+		logger.Info("rejecting SNS message with invalid signature", "topic_arn", message.TopicArn, "error", err.Error())
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch message.Type {
+	case snsMessageTypeSubscriptionConfirmation:
+		err = s.confirmSubscription(ctx, message)
+	case snsMessageTypeNotification:
+		err = s.handleNotification(ctx, message)
+	default:
+		logger.Info("ignoring SNS message", "type", message.Type)
+	}
+	if err != nil {
+		logger.Error(err, "failed to handle SNS message", "type", message.Type, "topic_arn", message.TopicArn)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifySignature verifies the signature of the message with the signing
+// certificate of SNS in the region of the topic.
+func (s *AWSConfigNotificationServer) verifySignature(ctx context.Context, message snsMessage) error {
+	var hash crypto.Hash
+	switch message.SignatureVersion {
+	case "1":
+		// SNS still signs with SHA1 unless the topic is configured otherwise.
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return errors.Errorf("unsupported signature version %q", message.SignatureVersion)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	signingCertURL, err := snsURL(message.TopicArn, message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(signingCertURL.Path, ".pem") {
+		return errors.Errorf("unexpected signing certificate URL %q", message.SigningCertURL)
+	}
+
+	cert, err := s.signingCert(ctx, signingCertURL.String())
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.Errorf("signing certificate %q has no RSA public key", message.SigningCertURL)
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(message.stringToSign()))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(message.stringToSign()))
+		digest = sum[:]
+	}
+
+	return errors.WithStack(rsa.VerifyPKCS1v15(publicKey, hash, digest, signature))
+}
+
+// signingCert returns the certificate at certURL. Certificates are cached, SNS
+// rotates them rarely and publishes new ones under a new URL.
+func (s *AWSConfigNotificationServer) signingCert(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	s.signingCertsMu.Lock()
+	cert, ok := s.signingCerts[certURL]
+	s.signingCertsMu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	resp, err := s.get(ctx, certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching SNS signing certificate failed with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSigningCertSize))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("SNS signing certificate %q is not PEM encoded", certURL)
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	s.signingCertsMu.Lock()
+	if s.signingCerts == nil {
+		s.signingCerts = map[string]*x509.Certificate{}
+	}
+	s.signingCerts[certURL] = cert
+	s.signingCertsMu.Unlock()
+
+	return cert, nil
+}
+
+// confirmSubscription confirms the SNS subscription of the endpoint. Only
+// HTTPS URLs of SNS in the region of the topic are visited.
+func (s *AWSConfigNotificationServer) confirmSubscription(ctx context.Context, message snsMessage) error {
+	logger := log.FromContext(ctx)
+
+	subscribeURL, err := snsURL(message.TopicArn, message.SubscribeURL)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.get(ctx, subscribeURL.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("confirming SNS subscription failed with status %d", resp.StatusCode)
+	}
+
+	logger.Info("confirmed SNS subscription", "topic_arn", message.TopicArn)
+	return nil
+}
+
+func (s *AWSConfigNotificationServer) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return resp, nil
+}
+
+// snsURL parses rawURL and makes sure it is an HTTPS URL of the SNS endpoint
+// in the region of the topic.
+func snsURL(topicArn, rawURL string) (*url.URL, error) {
+	// arn:partition:sns:region:account-id:topic-name
+	parts := strings.Split(topicArn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" || parts[3] == "" {
+		return nil, errors.Errorf("invalid SNS topic ARN %q", topicArn)
+	}
+	domain := "amazonaws.com"
+	if parts[1] == "aws-cn" {
+		domain = "amazonaws.com.cn"
+	}
+	host := fmt.Sprintf("sns.%s.%s", parts[3], domain)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if u.Scheme != "https" || u.Host != host {
+		return nil, errors.Errorf("refusing to visit unexpected SNS URL %q, expected https://%s", rawURL, host)
+	}
+
+	return u, nil
+}
+
+// handleNotification enqueues the AWSMachineTemplates owning the IAM role of a
+// policy change notification.
+func (s *AWSConfigNotificationServer) handleNotification(ctx context.Context, message snsMessage) error {
+	logger := log.FromContext(ctx)
+
+	var notification configNotification
+	err := json.Unmarshal([]byte(message.Message), &notification)
+	if err != nil {
+		logger.Info("ignoring SNS notification which is not an AWS Config notification", "error", err.Error())
+		return nil
+	}
+
+	if notification.MessageType != configItemChangeNotification ||
+		notification.ConfigurationItem.ResourceType != configResourceTypeIAMRole ||
+		!hasRolePolicyChange(notification.ConfigurationItemDiff.ChangedProperties) {
+		return nil
+	}
+
+	roleName := notification.ConfigurationItem.ResourceName
+	logger = logger.WithValues("role_name", roleName)
+
+	awsMachineTemplates := &capa.AWSMachineTemplateList{}
+	err = s.Client.List(ctx, awsMachineTemplates)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for i := range awsMachineTemplates.Items {
+		awsMachineTemplate := &awsMachineTemplates.Items[i]
+		if awsMachineTemplate.DeletionTimestamp != nil || !slices.Contains(managedRoleNames(awsMachineTemplate), roleName) {
+			continue
+		}
+
+		// Drop the last reconcile success, the template would otherwise be
+		// skipped because of MinReconcileAge.
+		if key.GetAnnotation(awsMachineTemplate, key.LastReconcileSuccessAnnotation) != "" {
+			err = patchAnnotations(ctx, s.Client, awsMachineTemplate, map[string]*string{
+				key.LastReconcileSuccessAnnotation: nil,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		logger.Info("IAM role policy changed, enqueuing AWSMachineTemplate", "namespace", awsMachineTemplate.Namespace, "name", awsMachineTemplate.Name)
+		select {
+		case s.Events <- event.GenericEvent{Object: awsMachineTemplate}:
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// hasRolePolicyChange returns true if any of the changed properties is a
+// policy of the IAM role.
+func hasRolePolicyChange(changedProperties map[string]json.RawMessage) bool {
+	for property := range changedProperties {
+		for _, prefix := range rolePolicyProperties {
+			if strings.HasPrefix(property, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// managedRoleNames returns the names of the IAM roles reconciled for the
+// AWSMachineTemplate.
+func managedRoleNames(awsMachineTemplate *capa.AWSMachineTemplate) []string {
+	roleNames := []string{awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile}
+	roleNames = append(roleNames, extraRoleNames(awsMachineTemplate)...)
+
+	if key.IsControlPlaneAWSMachineTemplate(awsMachineTemplate.Labels) {
+		clusterName := awsMachineTemplate.Labels[key.ClusterNameLabel]
+		roleTypes := append(iam.IRSARoleTypes(), iam.KIAMRole, iam.BackupRole, iam.AMPRole, iam.LoggingRole, iam.XRayRole, iam.SecretsRotationRole, iam.KarpenterRole, iam.GatewayAPIRole)
+		for _, roleType := range roleTypes {
+			roleNames = append(roleNames, iam.RoleName(roleType, clusterName))
+		}
+	}
+
+	return roleNames
+}
@@ -0,0 +1,270 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/giantswarm/capa-iam-operator/controllers"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("AWSConfigNotificationServer", func() {
+	const (
+		topicArn       = "arn:aws:sns:eu-west-1:012345678901:config"
+		signingCertURL = "https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-test.pem"
+	)
+
+	var (
+		ctx        context.Context
+		namespace  string
+		events     chan event.GenericEvent
+		server     *controllers.AWSConfigNotificationServer
+		signingKey *rsa.PrivateKey
+		certPEM    []byte
+	)
+
+	// sign signs the SNS message with signature version 2 like SNS does.
+	sign := func(message map[string]string) {
+		keys := []string{"Message", "MessageId", "Subject", "Timestamp", "TopicArn", "Type"}
+		if message["Type"] != "Notification" {
+			keys = []string{"Message", "MessageId", "SubscribeURL", "Timestamp", "Token", "TopicArn", "Type"}
+		}
+		var stringToSign string
+		for _, k := range keys {
+			if v, ok := message[k]; ok {
+				stringToSign += k + "\n" + v + "\n"
+			}
+		}
+		digest := sha256.Sum256([]byte(stringToSign))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, signingKey, crypto.SHA256, digest[:])
+		Expect(err).NotTo(HaveOccurred())
+
+		message["SignatureVersion"] = "2"
+		message["Signature"] = base64.StdEncoding.EncodeToString(signature)
+		if _, ok := message["SigningCertURL"]; !ok {
+			message["SigningCertURL"] = signingCertURL
+		}
+	}
+
+	post := func(message map[string]string) int {
+		body, err := json.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx))
+		return recorder.Code
+	}
+
+	SetupNamespaceBeforeAfterEach(&namespace)
+
+	newAWSMachineTemplate := func(name, role, instanceProfile string) *capa.AWSMachineTemplate {
+		return &capa.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"cluster.x-k8s.io/cluster-name": "test-cluster",
+					"cluster.x-k8s.io/role":         role,
+					"cluster.x-k8s.io/watch-filter": "capi",
+				},
+				Annotations: map[string]string{
+					key.LastReconcileSuccessAnnotation: "2024-01-01T00:00:00Z",
+				},
+			},
+			Spec: capa.AWSMachineTemplateSpec{
+				Template: capa.AWSMachineTemplateResource{
+					Spec: capa.AWSMachineSpec{
+						IAMInstanceProfile: instanceProfile,
+						InstanceType:       "unittest.4xlarge",
+					},
+				},
+			},
+		}
+	}
+
+	postNotification := func(roleName string, changedProperties ...string) int {
+		diff := map[string]interface{}{}
+		for _, property := range changedProperties {
+			diff[property] = map[string]string{"changeType": "UPDATE"}
+		}
+		notification, err := json.Marshal(map[string]interface{}{
+			"messageType": "ConfigurationItemChangeNotification",
+			"configurationItem": map[string]string{
+				"resourceType": "AWS::IAM::Role",
+				"resourceName": roleName,
+			},
+			"configurationItemDiff": map[string]interface{}{
+				"changedProperties": diff,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		message := map[string]string{
+			"Type":      "Notification",
+			"MessageId": "test",
+			"TopicArn":  topicArn,
+			"Message":   string(notification),
+			"Timestamp": "2024-01-01T00:00:00.000Z",
+		}
+		sign(message)
+		return post(message)
+	}
+
+	enqueued := func() []client.ObjectKey {
+		var keys []client.ObjectKey
+		for {
+			select {
+			case e := <-events:
+				if e.Object.GetNamespace() == namespace {
+					keys = append(keys, client.ObjectKeyFromObject(e.Object))
+				}
+			default:
+				return keys
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("control-plane", "control-plane", "control-plane-test-cluster"))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("bastion", "bastion", "bastion-test-cluster"))).To(Succeed())
+
+		var err error
+		signingKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &signingKey.PublicKey, signingKey)
+		Expect(err).NotTo(HaveOccurred())
+		certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+		events = make(chan event.GenericEvent, 100)
+		server = &controllers.AWSConfigNotificationServer{
+			Client:    k8sClient,
+			Events:    events,
+			TopicARNs: []string{topicArn},
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					if req.URL.String() != signingCertURL {
+						return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(certPEM))}, nil
+				}),
+			},
+		}
+	})
+
+	It("enqueues the template owning a role with changed policies", func() {
+		Expect(postNotification("bastion-test-cluster", "Configuration.RolePolicyList.0")).To(Equal(http.StatusOK))
+		Expect(enqueued()).To(ConsistOf(client.ObjectKey{Namespace: namespace, Name: "bastion"}))
+
+		awsMachineTemplate := &capa.AWSMachineTemplate{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "bastion"}, awsMachineTemplate)).To(Succeed())
+		Expect(awsMachineTemplate.Annotations).NotTo(HaveKey(key.LastReconcileSuccessAnnotation))
+	})
+
+	It("enqueues the control plane template for cluster roles", func() {
+		Expect(postNotification("test-cluster-Route53Manager-Role", "Configuration.AssumeRolePolicyDocument")).To(Equal(http.StatusOK))
+		Expect(enqueued()).To(ConsistOf(client.ObjectKey{Namespace: namespace, Name: "control-plane"}))
+	})
+
+	It("ignores changes which are not policy changes", func() {
+		Expect(postNotification("bastion-test-cluster", "Configuration.Tags.0")).To(Equal(http.StatusOK))
+		Expect(enqueued()).To(BeEmpty())
+	})
+
+	It("ignores roles which are not managed", func() {
+		Expect(postNotification("some-other-role", "Configuration.RolePolicyList.0")).To(Equal(http.StatusOK))
+		Expect(enqueued()).To(BeEmpty())
+	})
+
+	It("rejects invalid messages", func() {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not json")))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("does not confirm subscriptions outside of SNS", func() {
+		message := map[string]string{
+			"Type":         "SubscriptionConfirmation",
+			"MessageId":    "test",
+			"TopicArn":     topicArn,
+			"Timestamp":    "2024-01-01T00:00:00.000Z",
+			"Token":        "token",
+			"SubscribeURL": "https://example.com/confirm",
+		}
+		sign(message)
+		Expect(post(message)).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("rejects messages of other topics", func() {
+		message := map[string]string{
+			"Type":      "Notification",
+			"MessageId": "test",
+			"TopicArn":  "arn:aws:sns:eu-west-1:012345678901:other",
+			"Message":   "{}",
+			"Timestamp": "2024-01-01T00:00:00.000Z",
+		}
+		sign(message)
+		Expect(post(message)).To(Equal(http.StatusForbidden))
+	})
+
+	It("rejects messages with an invalid signature", func() {
+		message := map[string]string{
+			"Type":      "Notification",
+			"MessageId": "test",
+			"TopicArn":  topicArn,
+			"Message":   "{}",
+			"Timestamp": "2024-01-01T00:00:00.000Z",
+		}
+		sign(message)
+		message["Message"] = `{"tampered":true}`
+		Expect(post(message)).To(Equal(http.StatusForbidden))
+	})
+
+	It("rejects signing certificates outside of the SNS endpoint of the topic region", func() {
+		message := map[string]string{
+			"Type":           "Notification",
+			"MessageId":      "test",
+			"TopicArn":       topicArn,
+			"Message":        "{}",
+			"Timestamp":      "2024-01-01T00:00:00.000Z",
+			"SigningCertURL": "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem",
+		}
+		sign(message)
+		Expect(post(message)).To(Equal(http.StatusForbidden))
+	})
+})
@@ -18,23 +18,32 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"time"
 
 	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/giantswarm/microerror"
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	expcapa "sigs.k8s.io/cluster-api-provider-aws/v2/exp/api/v1beta2"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	iamv1alpha1 "github.com/giantswarm/capa-iam-operator/api/v1alpha1"
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
 	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
 	"github.com/giantswarm/capa-iam-operator/pkg/iam"
 	"github.com/giantswarm/capa-iam-operator/pkg/key"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+	"github.com/giantswarm/capa-iam-operator/pkg/record"
 )
 
 // AWSMachinePoolReconciler reconciles a AWSMachinePool object
@@ -42,11 +51,52 @@ type AWSMachinePoolReconciler struct {
 	client.Client
 	IAMClientFactory func(awsclientgo.ConfigProvider, string) iamiface.IAMAPI
 	AWSClient        awsclient.AwsClientInterface
+	// ConfigClientFactory enables the AWS Config rule integration when set.
+	ConfigClientFactory iam.ConfigClientFactory
+	// RolePath is the IAM path of created roles and instance profiles.
+	RolePath string
+	// OwnershipTagKey and OwnershipTagValue form the tag marking the IAM
+	// resources created by the operator.
+	OwnershipTagKey   string
+	OwnershipTagValue string
+	// SkipInstanceProfiles leaves instance profiles to be managed externally.
+	SkipInstanceProfiles bool
+	// EnableIRSARole manages the IRSA roles of the cluster of the
+	// AWSMachinePool. They are shared by the whole cluster and deleted
+	// together with the cluster.
+	EnableIRSARole bool
+	// MinReconcileAge skips reconciliation of unchanged objects that were
+	// successfully reconciled less than this duration ago. Zero disables it.
+	MinReconcileAge time.Duration
+	// FinalizerRemovalTimeout removes the finalizer without deleting the IAM
+	// resources once an object has been terminating for longer than this
+	// duration. Zero waits forever.
+	FinalizerRemovalTimeout time.Duration
+	// AuditSink receives an audit event for every IAM mutation when set.
+	AuditSink audit.Sink
+	// IAMManagementAccountRoleARN is assumed to manage the IRSA roles when
+	// set, so they are kept in a separate IAM management account. The other
+	// roles stay in the account of the cluster.
+	IAMManagementAccountRoleARN string
+	// DryRun logs mutating AWS API calls instead of making them.
+	DryRun bool
+	// ExtraPolicyStatementsAllowedServices are the services whose actions may
+	// be granted by the extra policy statements annotation. The annotation is
+	// rejected when it is empty.
+	ExtraPolicyStatementsAllowedServices []string
+	// MaxConcurrentReconciles is the number of AWSMachinePools reconciled in
+	// parallel.
+	MaxConcurrentReconciles int
+	// WrapReconciler is optional. When set, it wraps the reconciler registered
+	// with the manager, e.g. to recover from panics.
+	WrapReconciler func(reconcile.Reconciler) reconcile.Reconciler
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinepools,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinepools/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinepools/finalizers,verbs=update
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=iam.giantswarm.io,resources=irsaconfigs,verbs=get;list;watch
 
 func (r *AWSMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -60,7 +110,7 @@ func (r *AWSMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 	// check if CR got CAPI watch-filter label
 	if !key.HasCapiWatchLabel(awsMachinePool.Labels) {
-		logger.Info(fmt.Sprintf("AWSMachinePool do not have %s=%s label, ignoring CR", key.ClusterWatchFilterLabel, "capi"))
+		logger.Info("AWSMachinePool does not have the watch filter label, ignoring CR", "label", key.ClusterWatchFilterLabel, "value", "capi")
 		// ignoring this CR
 		return ctrl.Result{}, nil
 	}
@@ -78,33 +128,86 @@ func (r *AWSMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		logger.Info("AWSMachinePool has empty .Spec.AWSLaunchTemplate.IamInstanceProfile, not reconciling IAM role")
 		return ctrl.Result{}, nil
 	}
+
+	if finalizerRemovalTimedOut(awsMachinePool, r.FinalizerRemovalTimeout) {
+		return r.reconcileDeleteAfterTimeout(ctx, awsMachinePool)
+	}
+
+	// invalid extra policy statements will not become valid by retrying, so
+	// we do not requeue and wait for the AWSMachinePool to be changed
+	var extraPolicyStatements []json.RawMessage
+	if annotation := key.GetAnnotation(awsMachinePool, key.ExtraPolicyStatementsAnnotation); annotation != "" && awsMachinePool.DeletionTimestamp == nil {
+		if len(r.ExtraPolicyStatementsAllowedServices) == 0 {
+			logger.Info("ignoring extra policy statements as no services are allowed")
+			record.Warnf(awsMachinePool, "InvalidExtraPolicyStatements", "Extra policy statements of annotation %s are not enabled, see --extra-policy-statements-allowed-services", key.ExtraPolicyStatementsAnnotation)
+			return ctrl.Result{}, nil
+		}
+		extraPolicyStatements, err = iam.ParseExtraPolicyStatements(annotation, r.ExtraPolicyStatementsAllowedServices)
+		if err != nil {
+			logger.Error(err, "refusing to reconcile IAM role with invalid extra policy statements")
+			record.Warnf(awsMachinePool, "InvalidExtraPolicyStatements", "Extra policy statements of annotation %s are invalid: %s", key.ExtraPolicyStatementsAnnotation, err)
+			return ctrl.Result{}, nil
+		}
+	}
+
 	awsCluster, err := key.GetAWSClusterByName(ctx, r.Client, clusterName, req.Namespace)
 	if err != nil {
 		return ctrl.Result{}, microerror.Mask(err)
 	}
-	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef.Name)
+
+	irsaConfig, err := getIRSAConfig(ctx, r.Client, req.Namespace, clusterName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	inputs, err := reconcileInputs(awsCluster, irsaConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if awsMachinePool.DeletionTimestamp == nil && reconciledRecently(awsMachinePool, inputs, r.MinReconcileAge) {
+		logger.Info("AWSMachinePool was reconciled recently and did not change, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef)
 	if err != nil {
 		logger.Error(err, "could not get AWSClusterRoleIdentity")
 		return ctrl.Result{}, microerror.Mask(err)
 	}
 
-	awsClientSession, err := r.AWSClient.GetAWSClientSession(awsClusterRoleIdentity.Spec.RoleArn, awsCluster.Spec.Region)
+	awsClientSession, err := r.AWSClient.GetAWSClientSession(key.GetIdentityRoleARN(awsClusterRoleIdentity), awsCluster.Spec.Region)
 	if err != nil {
 		logger.Error(err, "Failed to get aws client session")
 		return ctrl.Result{}, errors.WithStack(err)
 	}
 
+	iamManagementSession, iamManagementAccountID, err := getIAMManagementSession(r.AWSClient, r.IAMManagementAccountRoleARN, awsCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Failed to get aws client session for the IAM management account")
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
 	var iamService *iam.IAMService
 	{
 		c := iam.IAMServiceConfig{
-			AWSSession:       awsClientSession,
-			ClusterName:      clusterName,
-			MainRoleName:     awsMachinePool.Spec.AWSLaunchTemplate.IamInstanceProfile,
-			Log:              logger,
-			RoleType:         iam.NodesRole,
-			Region:           awsCluster.Spec.Region,
-			IAMClientFactory: r.IAMClientFactory,
-			CustomTags:       awsCluster.Spec.AdditionalTags,
+			AWSSession:             awsClientSession,
+			ClusterName:            clusterName,
+			MainRoleName:           awsMachinePool.Spec.AWSLaunchTemplate.IamInstanceProfile,
+			Log:                    logger,
+			RoleType:               iam.NodesRole,
+			Region:                 awsCluster.Spec.Region,
+			Partition:              awsclient.Partition(awsCluster.Spec.Region),
+			IAMClientFactory:       r.IAMClientFactory,
+			ConfigClientFactory:    r.ConfigClientFactory,
+			RolePath:               r.RolePath,
+			OwnershipTagKey:        r.OwnershipTagKey,
+			OwnershipTagValue:      r.OwnershipTagValue,
+			SkipInstanceProfiles:   r.SkipInstanceProfiles,
+			CustomTags:             awsCluster.Spec.AdditionalTags,
+			AuditSink:              r.AuditSink,
+			IAMManagementSession:   iamManagementSession,
+			IAMManagementAccountID: iamManagementAccountID,
+			DryRun:                 r.DryRun,
+			ExtraPolicyStatements:  extraPolicyStatements,
 		}
 		iamService, err = iam.New(c)
 		if err != nil {
@@ -114,12 +217,12 @@ func (r *AWSMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 
 	if awsMachinePool.DeletionTimestamp != nil {
-		return r.reconcileDelete(ctx, awsMachinePool, iamService)
+		return r.reconcileDelete(ctx, awsMachinePool, clusterName, iamService)
 	}
-	return r.reconcileNormal(ctx, awsMachinePool, iamService)
+	return r.reconcileNormal(ctx, awsMachinePool, awsCluster, awsClusterRoleIdentity, clusterName, iamService, inputs)
 }
 
-func (r *AWSMachinePoolReconciler) reconcileDelete(ctx context.Context, awsMachinePool *expcapa.AWSMachinePool, iamService *iam.IAMService) (ctrl.Result, error) {
+func (r *AWSMachinePoolReconciler) reconcileDelete(ctx context.Context, awsMachinePool *expcapa.AWSMachinePool, clusterName string, iamService *iam.IAMService) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	roleUsed, err := isRoleUsedElsewhere(ctx, r.Client, awsMachinePool.Spec.AWSLaunchTemplate.IamInstanceProfile)
@@ -132,6 +235,22 @@ func (r *AWSMachinePoolReconciler) reconcileDelete(ctx context.Context, awsMachi
 		if err != nil {
 			return ctrl.Result{}, errors.WithStack(err)
 		}
+
+	}
+
+	// the IRSA roles are shared by the whole cluster and still used by the
+	// remaining nodes, so they are only deleted together with the cluster
+	if r.EnableIRSARole && controllerutil.ContainsFinalizer(awsMachinePool, key.FinalizerName(iam.NodesRole)) {
+		clusterDeleted, err := isClusterDeleted(ctx, r.Client, awsMachinePool.Namespace, clusterName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if clusterDeleted {
+			err = iamService.DeleteRolesForIRSA()
+			if err != nil {
+				return ctrl.Result{}, errors.WithStack(err)
+			}
+		}
 	}
 
 	err = removeFinalizer(ctx, r.Client, awsMachinePool, iam.NodesRole)
@@ -143,9 +262,30 @@ func (r *AWSMachinePoolReconciler) reconcileDelete(ctx context.Context, awsMachi
 	return ctrl.Result{}, nil
 }
 
-func (r *AWSMachinePoolReconciler) reconcileNormal(ctx context.Context, awsMachinePool *expcapa.AWSMachinePool, iamService *iam.IAMService) (ctrl.Result, error) {
+// reconcileDeleteAfterTimeout removes the finalizer of an AWSMachinePool which
+// has been terminating for longer than FinalizerRemovalTimeout without
+// calling AWS. The IAM role is left behind if it is not used elsewhere.
+func (r *AWSMachinePoolReconciler) reconcileDeleteAfterTimeout(ctx context.Context, awsMachinePool *expcapa.AWSMachinePool) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	roleName := awsMachinePool.Spec.AWSLaunchTemplate.IamInstanceProfile
+	logger.Info("Finalizer removal timeout exceeded, removing finalizer without deleting IAM resources, they may be orphaned", "timeout", r.FinalizerRemovalTimeout, "orphaned_roles", []string{roleName})
+	record.Warnf(awsMachinePool, "FinalizerRemovalTimeout", "Removing finalizer after %s without deleting IAM resources, IAM role %s may be orphaned", r.FinalizerRemovalTimeout, roleName)
+
+	err := removeFinalizer(ctx, r.Client, awsMachinePool, iam.NodesRole)
+	if err != nil {
+		logger.Error(err, "failed to remove finalizer from AWSMachinePool")
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *AWSMachinePoolReconciler) reconcileNormal(ctx context.Context, awsMachinePool *expcapa.AWSMachinePool, awsCluster *capa.AWSCluster, awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, clusterName string, iamService *iam.IAMService, inputs string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
+	roleName := awsMachinePool.Spec.AWSLaunchTemplate.IamInstanceProfile
+
 	// add finalizer to AWSMachinePool
 	if !controllerutil.ContainsFinalizer(awsMachinePool, key.FinalizerName(iam.NodesRole)) {
 		patchHelper, err := patch.NewHelper(awsMachinePool, r.Client)
@@ -162,16 +302,124 @@ func (r *AWSMachinePoolReconciler) reconcileNormal(ctx context.Context, awsMachi
 	}
 
 	err := iamService.ReconcileRole()
+	if !r.DryRun {
+		// the role is not ready in dry-run mode
+		conditionErr := setIAMRoleReadyCondition(ctx, r.Client, awsMachinePool, err)
+		if err == nil && conditionErr != nil {
+			return ctrl.Result{}, conditionErr
+		}
+	}
+	// invalid role names will not become valid by retrying, so we do not
+	// requeue and wait for the AWSMachinePool to be changed instead
+	if iam.IsInvalidRoleName(err) {
+		logger.Error(err, "refusing to reconcile IAM role with invalid name", "role_name", roleName)
+		record.Warnf(awsMachinePool, "InvalidRoleName", "IAM role name %q is invalid: %s", roleName, err)
+		return ctrl.Result{}, nil
+	}
+	// a policy exceeding the size limit will not fit by retrying either
+	if iam.IsPolicyTooLarge(err) {
+		logger.Error(err, "refusing to reconcile IAM role with too large inline policy")
+		record.Warnf(awsMachinePool, "ExtraPolicyStatementsTooLarge", "Extra policy statements of annotation %s exceed the size limit of inline policies: %s", key.ExtraPolicyStatementsAnnotation, err)
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(roleName)
 	if err != nil {
 		return ctrl.Result{}, errors.WithStack(err)
 	}
 
+	if r.EnableIRSARole {
+		err = r.reconcileIRSARoles(ctx, iamService, awsMachinePool, awsCluster, awsClusterRoleIdentity, clusterName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if r.MinReconcileAge > 0 {
+		err = markReconcileSuccess(ctx, r.Client, awsMachinePool, inputs)
+		if err != nil {
+			logger.Error(err, "failed to mark AWSMachinePool as successfully reconciled")
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// reconcileIRSARoles makes sure the IRSA roles of the cluster exist and trust
+// the OIDC provider domains of the cluster.
+func (r *AWSMachinePoolReconciler) reconcileIRSARoles(ctx context.Context, iamService *iam.IAMService, awsMachinePool *expcapa.AWSMachinePool, awsCluster *capa.AWSCluster, awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, clusterName string) error {
+	logger := log.FromContext(ctx)
+	logger.Info("reconciling IRSA roles")
+
+	accountID, err := getClusterAccountID(r.AWSClient, awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, awsCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Could not get account ID")
+		return errors.WithStack(err)
+	}
+
+	baseDomain, err := key.GetBaseDomain(ctx, r.Client, clusterName, awsCluster.Namespace)
+	if err != nil {
+		logger.Error(err, "Could not get base domain")
+		return errors.WithStack(err)
+	}
+
+	irsaDomain := key.IRSADomain(baseDomain, awsCluster.Spec.Region, accountID, clusterName)
+
+	irsaTrustDomains, err := getIRSATrustDomains(ctx, r.Client, awsMachinePool, awsCluster, clusterName, irsaDomain)
+	if err != nil {
+		return err
+	}
+
+	err = applyIRSAConfig(ctx, r.Client, iamService, awsMachinePool, awsCluster.Namespace, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = iamService.ReconcileRolesForIRSA(accountID, irsaTrustDomains)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, roleName := range iamService.IRSARoleNames() {
+		err = iamService.ReconcileRoleTags(roleName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// listClusterAWSMachinePools lists the AWSMachinePools of the cluster.
+func (r *AWSMachinePoolReconciler) listClusterAWSMachinePools(ctx context.Context, namespace, clusterName string) ([]client.Object, error) {
+	awsMachinePools := &expcapa.AWSMachinePoolList{}
+	err := r.List(ctx,
+		awsMachinePools,
+		client.InNamespace(namespace),
+		client.MatchingLabels{key.ClusterNameLabel: clusterName},
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	objects := make([]client.Object, 0, len(awsMachinePools.Items))
+	for i := range awsMachinePools.Items {
+		objects = append(objects, &awsMachinePools.Items[i])
+	}
+	return objects, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AWSMachinePoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&expcapa.AWSMachinePool{}).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(
+			&iamv1alpha1.IRSAConfig{},
+			handler.EnqueueRequestsFromMapFunc(irsaConfigToObjects(r.listClusterAWSMachinePools)),
+		).
+		Complete(metrics.WrapReconciler("awsmachinepool", mgr.GetClient(), func() client.Object { return &expcapa.AWSMachinePool{} }, wrapReconciler(r, r.WrapReconciler)))
 }
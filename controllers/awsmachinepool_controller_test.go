@@ -2,6 +2,10 @@ package controllers_test
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -13,16 +17,19 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	expcapa "sigs.k8s.io/cluster-api-provider-aws/v2/exp/api/v1beta2"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/giantswarm/capa-iam-operator/controllers"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
 	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
 )
 
@@ -364,6 +371,39 @@ var _ = Describe("AWSMachinePoolReconciler", func() {
 					PolicyDocument: aws.String(info.ExpectedPolicyDocument),
 					RoleName:       aws.String(info.ExpectedName),
 				}).Return(&iam.PutRolePolicyOutput{}, nil)
+
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String(info.ExpectedName),
+				}).Return(&iam.ListRoleTagsOutput{Tags: expectedIAMTags}, nil)
+				mockIAMClient.EXPECT().ListInstanceProfileTags(&iam.ListInstanceProfileTagsInput{
+					InstanceProfileName: aws.String(info.ExpectedName),
+				}).Return(&iam.ListInstanceProfileTagsOutput{Tags: expectedIAMTags}, nil)
+			}
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+		})
+	})
+
+	When("a role does not exist and dry-run mode is enabled", func() {
+		BeforeEach(func() {
+			reconciler.DryRun = true
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+		})
+
+		// only read-only calls are expected, so every mutation fails the spec
+		It("does not create the role", func() {
+			for _, info := range expectedRoleStatusesOnSuccess {
+				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
+					RoleName: aws.String(info.ExpectedName),
+				}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+				mockIAMClient.EXPECT().GetRolePolicy(&iam.GetRolePolicyInput{
+					PolicyName: aws.String(info.ExpectedPolicyName),
+					RoleName:   aws.String(info.ExpectedName),
+				}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String(info.ExpectedName),
+				}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
 			}
 
 			_, reconcileErr = reconciler.Reconcile(ctx, req)
@@ -373,57 +413,183 @@ var _ = Describe("AWSMachinePoolReconciler", func() {
 
 	When("a role already exists", func() {
 		BeforeEach(func() {
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
 			for _, info := range expectedRoleStatusesOnSuccess {
 				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
 					RoleName: aws.String(info.ExpectedName),
 				}).MinTimes(1).Return(&iam.GetRoleOutput{
 					Role: &iam.Role{
-						Arn:  aws.String(info.ReturnRoleArn),
-						Tags: expectedIAMTags,
+						Arn:                      aws.String(info.ReturnRoleArn),
+						AssumeRolePolicyDocument: aws.String(url.QueryEscape(info.ExpectedAssumeRolePolicyDocument)),
+						Tags:                     expectedIAMTags,
 					},
 				}, nil)
 			}
 		})
 
-		It("works on the existing role", func() {
-			Skip("TODO The controller is not idempotent to this extent, but should be. Once this is implemented, we should also add test cases for failures in each AWS SDK call")
-
+		It("does not write unchanged policies", func() {
 			for _, info := range expectedRoleStatusesOnSuccess {
-				mockIAMClient.EXPECT().CreateInstanceProfile(&iam.CreateInstanceProfileInput{
-					InstanceProfileName: aws.String(info.ExpectedName),
-					Tags:                expectedIAMTags,
-				}).Return(&iam.CreateInstanceProfileOutput{}, nil)
+				// IAM returns URL-encoded policy documents
+				mockIAMClient.EXPECT().GetRolePolicy(&iam.GetRolePolicyInput{
+					PolicyName: aws.String(info.ExpectedPolicyName),
+					RoleName:   aws.String(info.ExpectedName),
+				}).Return(&iam.GetRolePolicyOutput{
+					PolicyDocument: aws.String(url.QueryEscape(info.ExpectedPolicyDocument)),
+				}, nil)
 
-				mockIAMClient.EXPECT().AddRoleToInstanceProfile(&iam.AddRoleToInstanceProfileInput{
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String(info.ExpectedName),
+				}).Return(&iam.ListRoleTagsOutput{Tags: expectedIAMTags}, nil)
+				mockIAMClient.EXPECT().ListInstanceProfileTags(&iam.ListInstanceProfileTagsInput{
 					InstanceProfileName: aws.String(info.ExpectedName),
-					RoleName:            aws.String(info.ExpectedName),
-				}).Return(&iam.AddRoleToInstanceProfileOutput{}, nil)
+				}).Return(&iam.ListInstanceProfileTagsOutput{Tags: expectedIAMTags}, nil)
+			}
 
-				// Implementation detail: instead of storing the ARN, the controller calls `GetRole` multiple times
-				// from different places. Remove once we don't do this anymore (hence the `MinTimes` call so we
-				// would notice).
-				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
-					RoleName: aws.String(info.ExpectedName),
-				}).MinTimes(1).Return(&iam.GetRoleOutput{
-					Role: &iam.Role{
-						Arn:  aws.String(info.ReturnRoleArn),
-						Tags: expectedIAMTags,
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+		})
+	})
+
+	When("IRSA roles are enabled", func() {
+		BeforeEach(func() {
+			reconciler.EnableIRSARole = true
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+		})
+
+		It("reconciles the IRSA roles of the cluster", func() {
+			trustPolicies := map[string]string{}
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&iam.GetRoleOutput{Role: &iam.Role{}}, nil).AnyTimes()
+			mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+				trustPolicies[*input.RoleName] = *input.PolicyDocument
+				return &iam.UpdateAssumeRolePolicyOutput{}, nil
+			}).AnyTimes()
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil)).AnyTimes()
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&iam.PutRolePolicyOutput{}, nil).AnyTimes()
+			mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&iam.ListRoleTagsOutput{Tags: expectedIAMTags}, nil).AnyTimes()
+			mockIAMClient.EXPECT().ListInstanceProfileTags(gomock.Any()).Return(&iam.ListInstanceProfileTagsOutput{Tags: expectedIAMTags}, nil).AnyTimes()
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			Expect(trustPolicies).To(HaveKey("test-cluster-Route53Manager-Role"))
+			Expect(trustPolicies["test-cluster-Route53Manager-Role"]).To(ContainSubstring("test.gaws.gigantic.io"))
+		})
+
+		When("the AWSMachinePool is deleted", func() {
+			var deletedRoles []string
+
+			BeforeEach(func() {
+				awsMachinePool := &expcapa.AWSMachinePool{}
+				Expect(k8sClient.Get(ctx, req.NamespacedName, awsMachinePool)).To(Succeed())
+				patch := client.MergeFrom(awsMachinePool.DeepCopy())
+				controllerutil.AddFinalizer(awsMachinePool, key.FinalizerName("nodes"))
+				Expect(k8sClient.Patch(ctx, awsMachinePool, patch)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, awsMachinePool)).To(Succeed())
+
+				deletedRoles = nil
+				mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&iam.ListAttachedRolePoliciesOutput{}, nil).AnyTimes()
+				mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&iam.ListRolePoliciesOutput{}, nil).AnyTimes()
+				mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&iam.RemoveRoleFromInstanceProfileOutput{}, nil).AnyTimes()
+				mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&iam.DeleteInstanceProfileOutput{}, nil).AnyTimes()
+				mockIAMClient.EXPECT().DeleteRole(gomock.Any()).DoAndReturn(func(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+					deletedRoles = append(deletedRoles, *input.RoleName)
+					return &iam.DeleteRoleOutput{}, nil
+				}).AnyTimes()
+			})
+
+			It("keeps the IRSA roles of the cluster", func() {
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(BeNil())
+
+				Expect(deletedRoles).To(ConsistOf("the-profile"))
+			})
+
+			It("deletes the IRSA roles together with the cluster", func() {
+				Expect(k8sClient.Delete(ctx, &capi.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-cluster",
+						Namespace: namespace,
 					},
-				}, nil)
+				})).To(Succeed())
 
-				mockIAMClient.EXPECT().ListRolePolicies(&iam.ListRolePoliciesInput{
-					RoleName: aws.String(info.ExpectedName),
-				}).Return(&iam.ListRolePoliciesOutput{}, nil)
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(BeNil())
 
-				mockIAMClient.EXPECT().PutRolePolicy(&iam.PutRolePolicyInput{
-					PolicyName:     aws.String(info.ExpectedPolicyName),
-					PolicyDocument: aws.String(info.ExpectedPolicyDocument),
-					RoleName:       aws.String(info.ExpectedName),
-				}).Return(&iam.PutRolePolicyOutput{}, nil)
+				Expect(deletedRoles).To(ContainElements("the-profile", "test-cluster-Route53Manager-Role"))
+			})
+		})
+	})
+
+	When("the extra policy statements are not enabled", func() {
+		BeforeEach(func() {
+			awsMachinePool := &expcapa.AWSMachinePool{}
+			Expect(k8sClient.Get(ctx, req.NamespacedName, awsMachinePool)).To(Succeed())
+			patch := client.MergeFrom(awsMachinePool.DeepCopy())
+			awsMachinePool.Annotations = map[string]string{
+				key.ExtraPolicyStatementsAnnotation: base64.StdEncoding.EncodeToString([]byte(`[{"Effect": "Allow", "Action": "ec2:DescribeElasticGpus", "Resource": "*"}]`)),
 			}
+			Expect(k8sClient.Patch(ctx, awsMachinePool, patch)).To(Succeed())
+		})
+
+		It("does not call AWS and does not requeue", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+	})
+
+	When("the extra policy statements are invalid", func() {
+		BeforeEach(func() {
+			reconciler.ExtraPolicyStatementsAllowedServices = []string{"ec2"}
+
+			awsMachinePool := &expcapa.AWSMachinePool{}
+			Expect(k8sClient.Get(ctx, req.NamespacedName, awsMachinePool)).To(Succeed())
+			patch := client.MergeFrom(awsMachinePool.DeepCopy())
+			awsMachinePool.Annotations = map[string]string{
+				key.ExtraPolicyStatementsAnnotation: base64.StdEncoding.EncodeToString([]byte(`[{"Effect": "Deny", "Action": "ec2:*", "Resource": "*"}]`)),
+			}
+			Expect(k8sClient.Patch(ctx, awsMachinePool, patch)).To(Succeed())
+		})
+
+		It("does not call AWS and does not requeue", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+	})
+
+	When("the AWSMachinePool is being deleted and AWS is unreachable", func() {
+		BeforeEach(func() {
+			awsMachinePool := &expcapa.AWSMachinePool{}
+			Expect(k8sClient.Get(ctx, req.NamespacedName, awsMachinePool)).To(Succeed())
+			patch := client.MergeFrom(awsMachinePool.DeepCopy())
+			controllerutil.AddFinalizer(awsMachinePool, key.FinalizerName("nodes"))
+			Expect(k8sClient.Patch(ctx, awsMachinePool, patch)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, awsMachinePool)).To(Succeed())
+
+			mockAwsClient.EXPECT().GetAWSClientSession(gomock.Any(), gomock.Any()).Return(nil, errors.New("AWS is unreachable")).AnyTimes()
+		})
+
+		It("keeps the finalizer while the timeout has not passed", func() {
+			reconciler.FinalizerRemovalTimeout = time.Hour
 
 			_, reconcileErr = reconciler.Reconcile(ctx, req)
-			Expect(reconcileErr).To(BeNil())
+			Expect(reconcileErr).To(MatchError(ContainSubstring("AWS is unreachable")))
+
+			awsMachinePool := &expcapa.AWSMachinePool{}
+			Expect(k8sClient.Get(ctx, req.NamespacedName, awsMachinePool)).To(Succeed())
+			Expect(awsMachinePool.Finalizers).To(ContainElement(key.FinalizerName("nodes")))
+		})
+
+		It("removes the finalizer without calling AWS once the timeout has passed", func() {
+			reconciler.FinalizerRemovalTimeout = time.Nanosecond
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			awsMachinePool := &expcapa.AWSMachinePool{}
+			err := k8sClient.Get(ctx, req.NamespacedName, awsMachinePool)
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
 		})
 	})
 })
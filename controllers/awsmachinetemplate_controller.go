@@ -19,6 +19,9 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
@@ -29,17 +32,39 @@ import (
 
 	"k8s.io/apimachinery/pkg/types"
 	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	iamv1alpha1 "github.com/giantswarm/capa-iam-operator/api/v1alpha1"
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
 	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
 	"github.com/giantswarm/capa-iam-operator/pkg/iam"
 	"github.com/giantswarm/capa-iam-operator/pkg/key"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+	"github.com/giantswarm/capa-iam-operator/pkg/record"
 )
 
+// awsClusterNotReadyRequeueAfter is how long to wait before checking again
+// whether the AWSCluster infrastructure became ready. The AWSCluster watch
+// usually enqueues the template earlier.
+const awsClusterNotReadyRequeueAfter = 10 * time.Second
+
+// clusterInfrastructureNotReadyRequeueAfter is how long to wait before
+// checking again whether the infrastructure of the CAPI Cluster is ready.
+const clusterInfrastructureNotReadyRequeueAfter = 30 * time.Second
+
 // AWSMachineTemplateReconciler reconciles a AWSMachineTemplate object
 type AWSMachineTemplateReconciler struct {
 	client.Client
@@ -47,11 +72,103 @@ type AWSMachineTemplateReconciler struct {
 	EnableRoute53Role bool
 	AWSClient         awsclient.AwsClientInterface
 	IAMClientFactory  func(awsclientgo.ConfigProvider, string) iamiface.IAMAPI
+	// CleanupDeprecatedKiamRoles deletes existing KIAM roles of control plane
+	// templates when EnableKiamRole is false.
+	CleanupDeprecatedKiamRoles bool
+	// EnableBackupRole manages the AWS Backup role of the cluster.
+	EnableBackupRole bool
+	// EnableAMPRole manages the Prometheus remote write role for the AMP
+	// workspace annotated on the AWSCluster.
+	EnableAMPRole bool
+	// EnableLoggingRole manages the Fluent Bit role for the log destination
+	// annotated on the AWSCluster.
+	EnableLoggingRole bool
+	// EnableXRayRole manages the X-Ray daemon role of the cluster.
+	EnableXRayRole bool
+	// EnableSecretsRotationRole manages the execution role of the Secrets
+	// Manager rotation Lambda function annotated on the AWSCluster.
+	EnableSecretsRotationRole bool
+	// EnableKarpenterIRSARole manages the Karpenter role for the interruption
+	// queue annotated on the AWSCluster.
+	EnableKarpenterIRSARole bool
+	// EnableGatewayAPIRole manages the AWS Gateway API controller role for the
+	// load balancers of the cluster and the hosted zones annotated on the
+	// AWSCluster.
+	EnableGatewayAPIRole bool
+	// EnableSSOAdminPermissionSet manages an IAM Identity Center permission
+	// set granting cluster admin access, which is assigned to SSOAdminGroupID.
+	EnableSSOAdminPermissionSet bool
+	SSOAdminGroupID             string
+	// SSOAdminAccountRoleARN is assumed to manage the permission set when
+	// set, i.e. a role in the account owning the Identity Center instance.
+	// The cluster session is used otherwise.
+	SSOAdminAccountRoleARN string
+	// SSOAdminRegion is the region of the Identity Center instance. It
+	// defaults to the region of the cluster.
+	SSOAdminRegion string
+	// ConfigClientFactory enables the AWS Config rule integration when set.
+	ConfigClientFactory iam.ConfigClientFactory
+	// RolePath is the IAM path of created roles and instance profiles.
+	RolePath string
+	// OwnershipTagKey and OwnershipTagValue form the tag marking the IAM
+	// resources created by the operator.
+	OwnershipTagKey   string
+	OwnershipTagValue string
+	// SkipInstanceProfiles leaves instance profiles to be managed externally.
+	SkipInstanceProfiles bool
+	// MinReconcileAge skips reconciliation of unchanged objects that were
+	// successfully reconciled less than this duration ago. Zero disables it.
+	MinReconcileAge time.Duration
+	// ClusterReadinessTimeout is the maximum duration to wait for the
+	// AWSCluster to become ready. Zero waits forever.
+	ClusterReadinessTimeout time.Duration
+	// FinalizerRemovalTimeout removes the finalizer without deleting the IAM
+	// resources once an object has been terminating for longer than this
+	// duration. Zero waits forever.
+	FinalizerRemovalTimeout time.Duration
+	// PolicyDriftCheckInterval is how often the IAM policy simulator checks
+	// that the roles of control plane templates are still allowed the actions
+	// of their policies. Zero disables the check.
+	PolicyDriftCheckInterval time.Duration
+	// AuditSink receives an audit event for every IAM mutation when set.
+	AuditSink audit.Sink
+	// IAMManagementAccountRoleARN is assumed to manage the IRSA roles when
+	// set, so they are kept in a separate IAM management account. The other
+	// roles stay in the account of the cluster.
+	IAMManagementAccountRoleARN string
+	// DryRun logs mutating AWS API calls instead of making them.
+	DryRun bool
+	// AWSConfigNotificationAddr is the address of the endpoint receiving AWS
+	// Config notifications about IAM role changes. Disabled when empty.
+	AWSConfigNotificationAddr string
+	// AWSConfigNotificationTopicARNs are the SNS topics the AWS Config
+	// notification endpoint accepts messages from.
+	AWSConfigNotificationTopicARNs []string
+	// RoleCountRefreshInterval is how often the capa_iam_managed_roles_total
+	// gauge is refreshed in the background. It is also refreshed after every
+	// successful reconciliation. Zero disables the gauge.
+	RoleCountRefreshInterval time.Duration
+	// MaxConcurrentReconciles is the number of AWSMachineTemplates reconciled
+	// in parallel. The same template is never reconciled concurrently, but
+	// templates of the same cluster may patch the finalizers of their shared
+	// AWSCluster at the same time. removeFinalizer retries up to
+	// maxPatchAttempts times when such a patch is rejected as invalid.
+	MaxConcurrentReconciles int
+	// AWSCacheTTL is the duration IAM role ARN lookups are cached. Zero
+	// disables the cache.
+	AWSCacheTTL time.Duration
+	// WrapReconciler is optional. When set, it wraps the reconciler registered
+	// with the manager, e.g. to recover from panics.
+	WrapReconciler func(reconcile.Reconciler) reconcile.Reconciler
+
+	managedRolesCounter *ManagedRolesCounter
+	roleARNCaches       roleARNCaches
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinetemplates,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinetemplates/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinetemplates/finalizers,verbs=update
+// +kubebuilder:rbac:groups=iam.giantswarm.io,resources=irsaconfigs,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,7 +186,7 @@ func (r *AWSMachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.R
 	}
 	// check if CR got CAPI watch-filter label
 	if !key.HasCapiWatchLabel(awsMachineTemplate.Labels) {
-		logger.Info(fmt.Sprintf("AWSMachineTemplate do not have %s=%s label, ignoring CR", key.ClusterWatchFilterLabel, "capi"))
+		logger.Info("AWSMachineTemplate does not have the watch filter label, ignoring CR", "label", key.ClusterWatchFilterLabel, "value", "capi")
 		// ignoring this CR
 		return ctrl.Result{}, nil
 	}
@@ -81,7 +198,7 @@ func (r *AWSMachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.R
 	} else if key.IsBastionAWSMachineTemplate(awsMachineTemplate.Labels) {
 		role = iam.BastionRole
 	} else {
-		logger.Info(fmt.Sprintf("AWSMachineTemplate do not have %s=%s or %s=%s label, ignoring CR", key.ClusterRole, iam.ControlPlaneRole, key.ClusterRole, iam.BastionRole))
+		logger.Info("AWSMachineTemplate does not have a supported role label, ignoring CR", "label", key.ClusterRole, "supported_values", []string{iam.ControlPlaneRole, iam.BastionRole})
 		// ignoring this CR
 		return ctrl.Result{}, nil
 	}
@@ -102,29 +219,102 @@ func (r *AWSMachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.R
 	if err != nil {
 		return ctrl.Result{}, microerror.Mask(err)
 	}
-	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef.Name)
+
+	irsaConfig, err := getIRSAConfig(ctx, r.Client, req.Namespace, clusterName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	inputs, err := reconcileInputs(awsCluster, irsaConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	driftCheckDue := role == iam.ControlPlaneRole && policyDriftCheckDue(awsMachineTemplate, r.PolicyDriftCheckInterval)
+	if awsMachineTemplate.DeletionTimestamp == nil && reconciledRecently(awsMachineTemplate, inputs, r.MinReconcileAge) && !driftCheckDue {
+		logger.Info("AWSMachineTemplate was reconciled recently and did not change, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	if awsMachineTemplate.DeletionTimestamp == nil {
+		if !awsCluster.Status.Ready {
+			return r.waitForAWSCluster(ctx, awsMachineTemplate, awsCluster)
+		}
+
+		err = r.clearAWSClusterReadinessWait(ctx, awsMachineTemplate, awsCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		cluster, err := key.GetClusterByName(ctx, r.Client, clusterName, req.Namespace)
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+
+		if !cluster.Status.InfrastructureReady {
+			return r.waitForClusterInfrastructure(ctx, awsCluster)
+		}
+
+		err = r.clearClusterInfrastructureWait(ctx, awsMachineTemplate, awsCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if finalizerRemovalTimedOut(awsMachineTemplate, r.FinalizerRemovalTimeout) {
+		return r.reconcileDeleteAfterTimeout(ctx, awsMachineTemplate, awsCluster, clusterName, req.Namespace, role)
+	}
+
+	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef)
 	if err != nil {
 		logger.Error(err, "could not get AWSClusterRoleIdentity")
 		return ctrl.Result{}, microerror.Mask(err)
 	}
 
-	awsClientSession, err := r.AWSClient.GetAWSClientSession(awsClusterRoleIdentity.Spec.RoleArn, awsCluster.Spec.Region)
+	awsClientSession, err := r.AWSClient.GetAWSClientSession(key.GetIdentityRoleARN(awsClusterRoleIdentity), awsCluster.Spec.Region)
 	if err != nil {
 		logger.Error(err, "Failed to get aws client session")
 		return ctrl.Result{}, err
 	}
 
+	iamManagementSession, iamManagementAccountID, err := getIAMManagementSession(r.AWSClient, r.IAMManagementAccountRoleARN, awsCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Failed to get aws client session for the IAM management account")
+		return ctrl.Result{}, err
+	}
+
+	var ssoAdminSession awsclientgo.ConfigProvider
+	if r.EnableSSOAdminPermissionSet && r.SSOAdminAccountRoleARN != "" {
+		ssoAdminSession, err = r.AWSClient.GetAWSClientSession(r.SSOAdminAccountRoleARN, r.ssoAdminRegion(awsCluster))
+		if err != nil {
+			logger.Error(err, "Failed to get aws client session for the IAM Identity Center account")
+			return ctrl.Result{}, err
+		}
+	}
+
 	var iamService *iam.IAMService
 	{
 		c := iam.IAMServiceConfig{
-			AWSSession:       awsClientSession,
-			ClusterName:      clusterName,
-			MainRoleName:     awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile,
-			Log:              logger,
-			RoleType:         role,
-			Region:           awsCluster.Spec.Region,
-			IAMClientFactory: r.IAMClientFactory,
-			CustomTags:       awsCluster.Spec.AdditionalTags,
+			AWSSession:             awsClientSession,
+			ClusterName:            clusterName,
+			MainRoleName:           awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile,
+			Log:                    logger,
+			RoleType:               role,
+			Region:                 awsCluster.Spec.Region,
+			Partition:              awsclient.Partition(awsCluster.Spec.Region),
+			IAMClientFactory:       r.IAMClientFactory,
+			ConfigClientFactory:    r.ConfigClientFactory,
+			RolePath:               r.RolePath,
+			OwnershipTagKey:        r.OwnershipTagKey,
+			OwnershipTagValue:      r.OwnershipTagValue,
+			SkipInstanceProfiles:   r.SkipInstanceProfiles,
+			CustomTags:             awsCluster.Spec.AdditionalTags,
+			AuditSink:              r.AuditSink,
+			IAMManagementSession:   iamManagementSession,
+			IAMManagementAccountID: iamManagementAccountID,
+			SSOAdminSession:        ssoAdminSession,
+			SSOAdminRegion:         r.ssoAdminRegion(awsCluster),
+			DryRun:                 r.DryRun,
+			RoleARNCache:           r.roleARNCaches.get(awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, r.AWSCacheTTL),
 		}
 		iamService, err = iam.New(c)
 		if err != nil {
@@ -133,12 +323,56 @@ func (r *AWSMachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.R
 		}
 	}
 
+	var result ctrl.Result
 	if awsMachineTemplate.DeletionTimestamp != nil {
-		return r.reconcileDelete(ctx, iamService, awsMachineTemplate, clusterName, req.Namespace, role)
+		result, err = r.reconcileDelete(ctx, iamService, awsMachineTemplate, clusterName, req.Namespace, role)
+	} else {
+		result, err = r.reconcileNormal(ctx, iamService, awsMachineTemplate, awsCluster, clusterName, role, inputs)
+
+		var managedRoleNames []string
+		if err == nil {
+			managedRoleNames = append(r.roleNames(awsMachineTemplate, clusterName, role), extraRoleNames(awsMachineTemplate)...)
+		}
+		r.updateIAMStatus(ctx, iamService, awsMachineTemplate, awsCluster, awsClusterRoleIdentity, clusterName, managedRoleNames, nil, err)
+
+		// invalid role names will not become valid by retrying, so we do not
+		// requeue and wait for the AWSMachineTemplate to be changed instead
+		if iam.IsInvalidRoleName(err) {
+			logger.Error(err, "refusing to reconcile IAM role with invalid name")
+			record.Warnf(awsMachineTemplate, "InvalidRoleName", "IAM role name is invalid: %s", err)
+			invalidRoleNameErr := err
+			err = nil
+			if role == iam.ControlPlaneRole && !r.DryRun {
+				// the main role may have been valid, but the IRSA or extra
+				// roles of the template are not
+				err = setIAMRoleReadyCondition(ctx, r.Client, awsCluster, invalidRoleNameErr)
+			}
+		}
+	}
+	if err == nil {
+		r.refreshManagedRoles(ctx)
 	}
-	return r.reconcileNormal(ctx, iamService, awsMachineTemplate, awsCluster, clusterName, role)
+	return result, err
 }
 
+// refreshManagedRoles updates the capa_iam_managed_roles_total gauge when it
+// is enabled. Failures are only logged, as the gauge is refreshed again
+// periodically.
+func (r *AWSMachineTemplateReconciler) refreshManagedRoles(ctx context.Context) {
+	if r.managedRolesCounter == nil {
+		return
+	}
+
+	err := r.managedRolesCounter.Refresh(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to count managed IAM roles")
+	}
+}
+
+// reconcileDelete deletes the IAM resources of the AWSMachineTemplate and only
+// removes the finalizers from the AWSCluster, the AWSMachineTemplate and the
+// ConfigMap once all of them were deleted in AWS. Otherwise the AWSCluster
+// could be gone before the roles are, leaving them orphaned.
 func (r *AWSMachineTemplateReconciler) reconcileDelete(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, clusterName, namespace, role string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
@@ -147,37 +381,255 @@ func (r *AWSMachineTemplateReconciler) reconcileDelete(ctx context.Context, iamS
 		return ctrl.Result{}, err
 	}
 
-	if !roleUsed {
-		err = iamService.DeleteRole()
+	err = r.deleteIAMResources(ctx, iamService, awsMachineTemplate, clusterName, namespace, role, roleUsed)
+	if err != nil {
+		logger.Error(err, "Failed to delete IAM resources, keeping finalizers")
+		return ctrl.Result{}, errors.Wrap(err, "failed to delete IAM resources in AWS")
+	}
+
+	awsCluster, err := key.GetAWSClusterByName(ctx, r.Client, clusterName, awsMachineTemplate.GetNamespace())
+	if err != nil {
+		logger.Error(err, "failed to get awsCluster")
+		return ctrl.Result{}, err
+	}
+	if !roleUsed && role == iam.ControlPlaneRole && r.EnableRoute53Role {
+		err = r.removeIRSARoleARNAnnotations(ctx, awsCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !roleUsed && role == iam.ControlPlaneRole {
+		err = removeIAMRoleReadyCondition(ctx, r.Client, awsCluster)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+	}
+
+	var deletedRoleNames []string
+	if !roleUsed {
+		deletedRoleNames = r.roleNames(awsMachineTemplate, clusterName, role)
+	}
+	deletedRoleNames = append(deletedRoleNames, extraRoleNames(awsMachineTemplate)...)
+	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef)
+	if err != nil {
+		logger.Error(err, "could not get AWSClusterRoleIdentity")
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+	r.updateIAMStatus(ctx, iamService, awsMachineTemplate, awsCluster, awsClusterRoleIdentity, clusterName, nil, deletedRoleNames, nil)
+
+	return r.removeFinalizers(ctx, awsMachineTemplate, awsCluster, clusterName, namespace)
+}
+
+// updateIAMStatus records the result of a reconciliation in the IAM status
+// ConfigMap of the cluster. The managed roles are shared with the other
+// AWSMachineTemplates of the cluster, so only the given roles are added or
+// removed. Nothing is recorded in dry-run mode, as no roles are changed.
+func (r *AWSMachineTemplateReconciler) updateIAMStatus(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, clusterName string, addRoleNames, removeRoleNames []string, reconcileErr error) {
+	if r.DryRun {
+		return
+	}
+
+	update := iamStatusUpdate{
+		owner:        awsCluster,
+		clusterName:  clusterName,
+		reconcileErr: reconcileErr,
+		// the finalizer is removed together with the one of the AWSCluster
+		addFinalizer: awsMachineTemplate.DeletionTimestamp == nil && awsCluster.DeletionTimestamp == nil,
+	}
+
+	if len(addRoleNames) > 0 || len(removeRoleNames) > 0 {
+		accountID, err := getClusterAccountID(r.AWSClient, awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, awsCluster.Spec.Region)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to get account ID of IAM roles, not updating managed roles in IAM status ConfigMap")
+		} else {
+			update.addRoleARNs = roleARNs(iamService, accountID, addRoleNames)
+			update.removeRoleARNs = roleARNs(iamService, accountID, removeRoleNames)
+		}
+	}
+
+	updateIAMStatus(ctx, r.Client, update)
+}
+
+// deleteIAMResources deletes the IAM roles of the AWSMachineTemplate in AWS.
+// The roles shared with other AWSMachineTemplates are kept, the extra and the
+// stale roles are always deleted.
+func (r *AWSMachineTemplateReconciler) deleteIAMResources(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, clusterName, namespace, role string, roleUsed bool) error {
+	if !roleUsed {
+		err := iamService.DeleteRole()
+		if err != nil {
+			return err
+		}
 		if role == iam.ControlPlaneRole {
 			if r.EnableRoute53Role {
 				err = iamService.DeleteRolesForIRSA()
 				if err != nil {
-					return ctrl.Result{}, err
+					return err
+				}
+			}
+			if r.EnableBackupRole {
+				err = iamService.DeleteBackupRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableAMPRole {
+				err = iamService.DeleteAMPRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableLoggingRole {
+				err = iamService.DeleteLoggingRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableXRayRole {
+				err = iamService.DeleteXRayRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableSecretsRotationRole {
+				err = iamService.DeleteSecretsRotationRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableKarpenterIRSARole {
+				err = iamService.DeleteKarpenterRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableGatewayAPIRole {
+				err = iamService.DeleteGatewayAPIRole()
+				if err != nil {
+					return err
+				}
+			}
+			if r.EnableSSOAdminPermissionSet {
+				awsCluster, err := key.GetAWSClusterByName(ctx, r.Client, clusterName, namespace)
+				if err != nil {
+					return err
+				}
+				accountID, err := r.clusterAccountID(ctx, iamService, awsCluster)
+				if err != nil {
+					return err
+				}
+				err = iamService.DeleteSSOAdminPermissionSet(accountID, key.GetAnnotation(awsMachineTemplate, key.SSOAdminPermissionSetARNAnnotation))
+				if err != nil {
+					return err
 				}
 			}
 		}
 	}
-	// remove finalizer from AWSCluster
-	awsCluster, err := key.GetAWSClusterByName(ctx, r.Client, clusterName, awsMachineTemplate.GetNamespace())
+
+	err := r.deleteExtraRoles(ctx, iamService, awsMachineTemplate)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.deleteStaleRoles(ctx, iamService, awsMachineTemplate)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileDeleteAfterTimeout removes the finalizers of an AWSMachineTemplate
+// which has been terminating for longer than FinalizerRemovalTimeout without
+// calling AWS. The IAM roles which would have been deleted are left behind.
+func (r *AWSMachineTemplateReconciler) reconcileDeleteAfterTimeout(ctx context.Context, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName, namespace, role string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	roleUsed, err := isRoleUsedElsewhere(ctx, r.Client, awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile)
 	if err != nil {
-		logger.Error(err, "failed to get awsCluster")
 		return ctrl.Result{}, err
 	}
-	err = removeFinalizer(ctx, r.Client, awsCluster, iam.ControlPlaneRole)
+
+	var orphanedRoles []string
+	if !roleUsed {
+		orphanedRoles = append(orphanedRoles, r.roleNames(awsMachineTemplate, clusterName, role)...)
+	}
+	orphanedRoles = append(orphanedRoles, extraRoleNames(awsMachineTemplate)...)
+	orphanedRoles = append(orphanedRoles, key.GetRolesToDelete(awsMachineTemplate)...)
+
+	logger.Info("Finalizer removal timeout exceeded, removing finalizers without deleting IAM resources, they may be orphaned", "timeout", r.FinalizerRemovalTimeout, "orphaned_roles", orphanedRoles)
+	record.Warnf(awsMachineTemplate, "FinalizerRemovalTimeout", "Removing finalizers after %s without deleting IAM resources, IAM roles %s may be orphaned", r.FinalizerRemovalTimeout, strings.Join(orphanedRoles, ", "))
+
+	return r.removeFinalizers(ctx, awsMachineTemplate, awsCluster, clusterName, namespace)
+}
+
+// roleNames returns the names of the IAM roles created for the
+// AWSMachineTemplate, without its extra roles. Control plane templates also
+// create the enabled roles of the cluster.
+func (r *AWSMachineTemplateReconciler) roleNames(awsMachineTemplate *capa.AWSMachineTemplate, clusterName, role string) []string {
+	roleNames := []string{awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile}
+	if role != iam.ControlPlaneRole {
+		return roleNames
+	}
+
+	if r.EnableRoute53Role {
+		for _, roleType := range iam.IRSARoleTypes() {
+			roleNames = append(roleNames, iam.RoleName(roleType, clusterName))
+		}
+	}
+	if r.EnableBackupRole {
+		roleNames = append(roleNames, iam.RoleName(iam.BackupRole, clusterName))
+	}
+	if r.EnableAMPRole {
+		roleNames = append(roleNames, iam.RoleName(iam.AMPRole, clusterName))
+	}
+	if r.EnableLoggingRole {
+		roleNames = append(roleNames, iam.RoleName(iam.LoggingRole, clusterName))
+	}
+	if r.EnableXRayRole {
+		roleNames = append(roleNames, iam.RoleName(iam.XRayRole, clusterName))
+	}
+	if r.EnableSecretsRotationRole {
+		roleNames = append(roleNames, iam.RoleName(iam.SecretsRotationRole, clusterName))
+	}
+	if r.EnableKarpenterIRSARole {
+		roleNames = append(roleNames, iam.RoleName(iam.KarpenterRole, clusterName))
+	}
+	if r.EnableGatewayAPIRole {
+		roleNames = append(roleNames, iam.RoleName(iam.GatewayAPIRole, clusterName))
+	}
+	return roleNames
+}
+
+// removeFinalizers removes the finalizers of the operator from the AWSCluster,
+// the AWSMachineTemplate, the IAM status ConfigMap and the cluster-values
+// ConfigMap.
+func (r *AWSMachineTemplateReconciler) removeFinalizers(ctx context.Context, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName, namespace string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	err := removeFinalizer(ctx, r.Client, awsCluster, iam.ControlPlaneRole)
 	if err != nil {
 		logger.Error(err, "Failed to remove finalizer from AWSCluster")
-		return ctrl.Result{}, err
+		return ctrl.Result{}, errors.Wrap(err, "failed to remove finalizer from AWSCluster")
 	}
 
 	// remove finalizer from AWSMachineTemplate
 	err = removeFinalizer(ctx, r.Client, awsMachineTemplate, iam.ControlPlaneRole)
 	if err != nil {
 		logger.Error(err, "Failed to remove finalizer from AWSMachineTemplate")
-		return ctrl.Result{}, err
+		return ctrl.Result{}, errors.Wrap(err, "failed to remove finalizer from AWSMachineTemplate")
+	}
+
+	iamStatus := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: key.IAMStatusConfigMapName(clusterName)}, iamStatus)
+	if client.IgnoreNotFound(err) != nil {
+		logger.Error(err, "Failed to get the IAM status configmap for cluster")
+		return ctrl.Result{}, errors.WithStack(err)
+	} else if err == nil {
+		err = removeFinalizer(ctx, r.Client, iamStatus, iam.ControlPlaneRole)
+		if err != nil {
+			logger.Error(err, "Failed to remove finalizer from IAM status ConfigMap")
+			return ctrl.Result{}, errors.Wrap(err, "failed to remove finalizer from IAM status ConfigMap")
+		}
 	}
 
 	cm := &corev1.ConfigMap{}
@@ -196,15 +648,26 @@ func (r *AWSMachineTemplateReconciler) reconcileDelete(ctx context.Context, iamS
 	err = removeFinalizer(ctx, r.Client, cm, iam.ControlPlaneRole)
 	if err != nil {
 		logger.Error(err, "Failed to remove finalizer from ConfigMap")
-		return ctrl.Result{}, err
+		return ctrl.Result{}, errors.Wrap(err, "failed to remove finalizer from ConfigMap")
 	}
 
 	return ctrl.Result{}, nil
 }
 
-func (r *AWSMachineTemplateReconciler) reconcileNormal(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName, role string) (ctrl.Result, error) {
+func (r *AWSMachineTemplateReconciler) reconcileNormal(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName, role, inputs string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
+	// unsupported extra role types will not become supported by retrying, so
+	// we do not requeue and wait for the AWSMachineTemplate to be changed
+	// instead
+	for _, roleType := range key.GetExtraRoleTypes(awsMachineTemplate) {
+		if !iam.IsMachineRoleType(roleType) {
+			logger.Info("refusing to reconcile IAM roles with unsupported extra role type", "role_type", roleType)
+			record.Warnf(awsMachineTemplate, "InvalidExtraRoleType", "Extra role type %q is not supported, use one of %s, %s or %s", roleType, iam.BastionRole, iam.ControlPlaneRole, iam.NodesRole)
+			return ctrl.Result{}, nil
+		}
+	}
+
 	// add finalizer to AWSMachineTemplate
 	if !controllerutil.ContainsFinalizer(awsMachineTemplate, key.FinalizerName(iam.ControlPlaneRole)) {
 		patchHelper, err := patch.NewHelper(awsMachineTemplate, r.Client)
@@ -236,50 +699,1026 @@ func (r *AWSMachineTemplateReconciler) reconcileNormal(ctx context.Context, iamS
 	}
 
 	err := iamService.ReconcileRole()
+	if role == iam.ControlPlaneRole && !r.DryRun {
+		// bastion templates share the AWSCluster, so only the control plane
+		// role is reported there, and it is not ready in dry-run mode
+		conditionErr := setIAMRoleReadyCondition(ctx, r.Client, awsCluster, err)
+		if err == nil && conditionErr != nil {
+			return ctrl.Result{}, conditionErr
+		}
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = iamService.ReconcileRoleTags(awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcileExtraRoles(ctx, iamService, awsMachineTemplate)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	err = r.reconcileRoleRename(ctx, iamService, awsMachineTemplate)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if role == iam.ControlPlaneRole && r.EnableBackupRole {
+		err = iamService.ReconcileBackupRole()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if role == iam.ControlPlaneRole && r.EnableSSOAdminPermissionSet {
+		accountID, err := r.clusterAccountID(ctx, iamService, awsCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		knownARN := key.GetAnnotation(awsMachineTemplate, key.SSOAdminPermissionSetARNAnnotation)
+		permissionSetARN, err := iamService.ReconcileSSOAdminPermissionSet(r.SSOAdminGroupID, accountID, knownARN)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// nothing is created in dry-run mode
+		if permissionSetARN != knownARN && permissionSetARN != "" {
+			err = patchAnnotations(ctx, r.Client, awsMachineTemplate, map[string]*string{
+				key.SSOAdminPermissionSetARNAnnotation: &permissionSetARN,
+			})
+			if err != nil {
+				logger.Error(err, "failed to record IAM Identity Center permission set ARN")
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	if role == iam.ControlPlaneRole && !r.EnableKiamRole && r.CleanupDeprecatedKiamRoles {
+		err = r.cleanupDeprecatedKiamRole(ctx, iamService, awsMachineTemplate)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	if role == iam.ControlPlaneRole {
 		// route53 role depends on KIAM role
 		if r.EnableRoute53Role {
 			logger.Info("reconciling IRSA roles")
-			identityRefName := awsCluster.Spec.IdentityRef.Name
-			awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, identityRefName)
+			accountID, irsaTrustDomains, err := r.irsaTrustDomains(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
 			if err != nil {
-				logger.Error(err, "could not get AWSClusterRoleIdentity")
-				return ctrl.Result{}, errors.WithStack(err)
+				return ctrl.Result{}, err
 			}
 
-			accountID, err := key.GetAWSAccountID(awsClusterRoleIdentity)
+			err = applyIRSAConfig(ctx, r.Client, iamService, awsMachineTemplate, awsCluster.Namespace, clusterName)
 			if err != nil {
-				logger.Error(err, "Could not get account ID")
-				return ctrl.Result{}, errors.WithStack(err)
+				return ctrl.Result{}, err
 			}
 
-			baseDomain, err := key.GetBaseDomain(ctx, r.Client, clusterName, awsCluster.Namespace)
+			err = iamService.ReconcileRolesForIRSA(accountID, irsaTrustDomains)
 			if err != nil {
-				logger.Error(err, "Could not get base domain")
 				return ctrl.Result{}, errors.WithStack(err)
 			}
 
-			irsaDomain := key.IRSADomain(baseDomain, awsCluster.Spec.Region, accountID, clusterName)
+			for _, roleName := range iamService.IRSARoleNames() {
+				err = iamService.ReconcileRoleTags(roleName)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+
+			if !r.DryRun {
+				err = r.setIRSARoleARNAnnotations(ctx, iamService, awsCluster)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+		}
+
+		if r.EnableAMPRole {
+			err = r.reconcileAMPRole(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 
-			irsaTrustDomains := key.GetIRSATrustDomains(awsMachineTemplate, awsCluster, irsaDomain)
+		if r.EnableLoggingRole {
+			err = r.reconcileLoggingRole(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 
-			err = iamService.ReconcileRolesForIRSA(accountID, irsaTrustDomains)
+		if r.EnableXRayRole {
+			err = r.reconcileXRayRole(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
 			if err != nil {
-				return ctrl.Result{}, errors.WithStack(err)
+				return ctrl.Result{}, err
+			}
+		}
+
+		if r.EnableSecretsRotationRole {
+			err = r.reconcileSecretsRotationRole(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		if r.EnableKarpenterIRSARole {
+			err = r.reconcileKarpenterRole(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		if r.EnableGatewayAPIRole {
+			err = r.reconcileGatewayAPIRole(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		if policyDriftCheckDue(awsMachineTemplate, r.PolicyDriftCheckInterval) {
+			err = r.checkPolicyDrift(ctx, iamService, awsMachineTemplate, awsCluster)
+			if err != nil {
+				return ctrl.Result{}, err
 			}
 		}
 	}
 
+	if r.MinReconcileAge > 0 {
+		err = markReconcileSuccess(ctx, r.Client, awsMachineTemplate, inputs)
+		if err != nil {
+			logger.Error(err, "failed to mark AWSMachineTemplate as successfully reconciled")
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+	}
+
+	if role == iam.ControlPlaneRole && r.PolicyDriftCheckInterval > 0 {
+		return ctrl.Result{RequeueAfter: r.PolicyDriftCheckInterval}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *AWSMachineTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&capa.AWSMachineTemplate{}).
-		Complete(r)
+// checkPolicyDrift simulates the actions the IAM roles of the cluster are
+// expected to be allowed and reports denied actions with the
+// PolicyDriftDetected condition on the AWSCluster and a warning event. Denied
+// actions are usually caused by SCPs or permission boundaries outside of the
+// control of the operator, so they are not fixed automatically.
+func (r *AWSMachineTemplateReconciler) checkPolicyDrift(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster) error {
+	logger := log.FromContext(ctx)
+
+	denied, err := iamService.DetectPolicyDrift(r.EnableRoute53Role)
+	if err != nil {
+		logger.Error(err, "failed to check IAM policy drift")
+		return errors.WithStack(err)
+	}
+
+	patchHelper, err := patch.NewHelper(awsCluster, r.Client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	resolved := false
+	if len(denied) > 0 {
+		deniedActions := make([]string, 0, len(denied))
+		for _, d := range denied {
+			deniedActions = append(deniedActions, fmt.Sprintf("%s on %s (%s)", d.Action, d.RoleName, d.Decision))
+		}
+		message := fmt.Sprintf("IAM policy simulator denies actions expected to be allowed: %s", strings.Join(deniedActions, ", "))
+
+		logger.Info("detected IAM policy drift", "denied_actions", deniedActions)
+		conditions.Set(awsCluster, &capi.Condition{
+			Type:    key.PolicyDriftDetectedCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ActionsDenied",
+			Message: message,
+		})
+	} else if conditions.Has(awsCluster, key.PolicyDriftDetectedCondition) {
+		conditions.Delete(awsCluster, key.PolicyDriftDetectedCondition)
+		resolved = true
+	}
+
+	err = patchHelper.Patch(ctx, awsCluster, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.PolicyDriftDetectedCondition}})
+	if err != nil {
+		logger.Error(err, "failed to update condition on AWSCluster", "condition", key.PolicyDriftDetectedCondition)
+		return errors.WithStack(err)
+	}
+
+	if len(denied) > 0 {
+		record.Warnf(awsMachineTemplate, "PolicyDriftDetected", "IAM policy simulator denies %d actions expected to be allowed, check the SCPs and permission boundaries of the account, see condition %s of the AWSCluster", len(denied), key.PolicyDriftDetectedCondition)
+	} else if resolved {
+		record.Event(awsMachineTemplate, "PolicyDriftResolved", "IAM policy simulator allows all checked actions again")
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	err = patchAnnotations(ctx, r.Client, awsMachineTemplate, map[string]*string{key.LastPolicyDriftCheckAnnotation: &now})
+	if err != nil {
+		logger.Error(err, "failed to update annotation on AWSMachineTemplate", "annotation", key.LastPolicyDriftCheckAnnotation)
+		return err
+	}
+
+	return nil
+}
+
+// ssoAdminRegion returns the region of the IAM Identity Center instance.
+func (r *AWSMachineTemplateReconciler) ssoAdminRegion(awsCluster *capa.AWSCluster) string {
+	if r.SSOAdminRegion != "" {
+		return r.SSOAdminRegion
+	}
+	return awsCluster.Spec.Region
+}
+
+// irsaTrustDomains returns the AWS account ID of the cluster and the OIDC
+// provider domains the IRSA roles trust. The additional domains are taken from
+// the IRSAConfig of the cluster, falling back to the annotations when there is
+// none.
+func (r *AWSMachineTemplateReconciler) irsaTrustDomains(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) (string, []string, error) {
+	logger := log.FromContext(ctx)
+
+	accountID, err := r.clusterAccountID(ctx, iamService, awsCluster)
+	if err != nil {
+		return "", nil, err
+	}
+
+	baseDomain, err := key.GetBaseDomain(ctx, r.Client, clusterName, awsCluster.Namespace)
+	if err != nil {
+		logger.Error(err, "Could not get base domain")
+		return "", nil, errors.WithStack(err)
+	}
+
+	irsaDomain := key.IRSADomain(baseDomain, awsCluster.Spec.Region, accountID, clusterName)
+
+	irsaTrustDomains, err := getIRSATrustDomains(ctx, r.Client, awsMachineTemplate, awsCluster, clusterName, irsaDomain)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return accountID, irsaTrustDomains, nil
+}
+
+// reconcileAMPRole reconciles the Prometheus remote write role for the AMP
+// workspace annotated on the AWSCluster. Nothing is done if the AWSCluster is
+// not annotated.
+func (r *AWSMachineTemplateReconciler) reconcileAMPRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	workspaceARN := key.GetAnnotation(awsCluster, key.AMPWorkspaceARNAnnotation)
+	if workspaceARN == "" {
+		logger.Info("AWSCluster has no AMP workspace ARN annotation, not reconciling AMP role", "annotation", key.AMPWorkspaceARNAnnotation)
+		return nil
+	}
+	if err := iam.ValidateAMPWorkspaceARN(workspaceARN); err != nil {
+		logger.Error(err, "refusing to reconcile AMP role with invalid workspace ARN", "workspace_arn", workspaceARN)
+		record.Warnf(awsMachineTemplate, "InvalidAMPWorkspaceARN", "AMP workspace ARN %q of annotation %s is invalid: %s", workspaceARN, key.AMPWorkspaceARNAnnotation, err)
+		return nil
+	}
+
+	logger.Info("reconciling AMP role")
+	accountID, irsaTrustDomains, err := r.irsaTrustDomains(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = iamService.ReconcileAMPRole(accountID, irsaTrustDomains, workspaceARN)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.AMPRole, clusterName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileLoggingRole reconciles the Fluent Bit role for the log destination
+// annotated on the AWSCluster. Nothing is done if the AWSCluster is not
+// annotated.
+func (r *AWSMachineTemplateReconciler) reconcileLoggingRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	destinationType := key.GetAnnotation(awsCluster, key.LoggingDestinationAnnotation)
+	target := key.GetAnnotation(awsCluster, key.LoggingTargetAnnotation)
+	if destinationType == "" && target == "" {
+		logger.Info("AWSCluster has no log destination annotations, not reconciling logging role", "annotations", []string{key.LoggingDestinationAnnotation, key.LoggingTargetAnnotation})
+		return nil
+	}
+	if err := iam.ValidateLoggingDestination(destinationType, target); err != nil {
+		logger.Error(err, "refusing to reconcile logging role with invalid log destination", "destination", destinationType, "target", target)
+		record.Warnf(awsMachineTemplate, "InvalidLoggingDestination", "Log destination of annotations %s and %s is invalid: %s", key.LoggingDestinationAnnotation, key.LoggingTargetAnnotation, err)
+		return nil
+	}
+
+	logger.Info("reconciling logging role")
+	accountID, irsaTrustDomains, err := r.irsaTrustDomains(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = iamService.ReconcileLoggingRole(accountID, irsaTrustDomains, destinationType, target)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.LoggingRole, clusterName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileXRayRole reconciles the X-Ray daemon role for the service account
+// annotated on the AWSCluster, which defaults to observability/xray-daemon.
+func (r *AWSMachineTemplateReconciler) reconcileXRayRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	namespace := key.GetAnnotation(awsCluster, key.XRayNamespaceAnnotation)
+	if namespace == "" {
+		namespace = iam.DefaultXRayNamespace
+	}
+	serviceAccount := key.GetAnnotation(awsCluster, key.XRayServiceAccountAnnotation)
+	if serviceAccount == "" {
+		serviceAccount = iam.DefaultXRayServiceAccount
+	}
+
+	logger.Info("reconciling X-Ray role", "namespace", namespace, "service_account", serviceAccount)
+	accountID, irsaTrustDomains, err := r.irsaTrustDomains(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = iamService.ReconcileXRayRole(accountID, irsaTrustDomains, namespace, serviceAccount)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.XRayRole, clusterName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileKarpenterRole reconciles the Karpenter role for the interruption
+// queue and node role annotated on the AWSCluster. Nothing is done if the
+// AWSCluster is not annotated.
+func (r *AWSMachineTemplateReconciler) reconcileKarpenterRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	queueARN := key.GetAnnotation(awsCluster, key.KarpenterQueueARNAnnotation)
+	if queueARN == "" {
+		logger.Info("AWSCluster has no Karpenter queue ARN annotation, not reconciling Karpenter role", "annotation", key.KarpenterQueueARNAnnotation)
+		return nil
+	}
+	if err := iam.ValidateKarpenterQueueARN(queueARN); err != nil {
+		logger.Error(err, "refusing to reconcile Karpenter role with invalid queue ARN", "queue_arn", queueARN)
+		record.Warnf(awsMachineTemplate, "InvalidKarpenterQueueARN", "Karpenter queue ARN %q of annotation %s is invalid: %s", queueARN, key.KarpenterQueueARNAnnotation, err)
+		return nil
+	}
+	nodeRoleName := key.GetAnnotation(awsCluster, key.KarpenterNodeRoleAnnotation)
+	if err := key.ValidateRoleName(nodeRoleName); err != nil {
+		logger.Error(err, "refusing to reconcile Karpenter role with invalid node role name", "role_name", nodeRoleName)
+		record.Warnf(awsMachineTemplate, "InvalidKarpenterNodeRole", "Karpenter node role name %q of annotation %s is invalid: %s", nodeRoleName, key.KarpenterNodeRoleAnnotation, err)
+		return nil
+	}
+
+	logger.Info("reconciling Karpenter role")
+	accountID, irsaTrustDomains, err := r.irsaTrustDomains(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = iamService.ReconcileKarpenterRole(accountID, irsaTrustDomains, queueARN, nodeRoleName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.KarpenterRole, clusterName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileGatewayAPIRole reconciles the AWS Gateway API controller role for
+// the load balancers of the cluster and the hosted zones annotated on the
+// AWSCluster.
+func (r *AWSMachineTemplateReconciler) reconcileGatewayAPIRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	var hostedZoneIDs []string
+	if annotation := key.GetAnnotation(awsCluster, key.GatewayAPIHostedZoneIDsAnnotation); annotation != "" {
+		hostedZoneIDs = strings.Split(annotation, ",")
+	}
+	for _, id := range hostedZoneIDs {
+		if err := iam.ValidateHostedZoneID(id); err != nil {
+			logger.Error(err, "refusing to reconcile Gateway API role with invalid hosted zone ID", "hosted_zone_id", id)
+			record.Warnf(awsMachineTemplate, "InvalidGatewayAPIHostedZoneID", "Hosted zone ID %q of annotation %s is invalid: %s", id, key.GatewayAPIHostedZoneIDsAnnotation, err)
+			return nil
+		}
+	}
+
+	logger.Info("reconciling Gateway API role", "hosted_zone_ids", hostedZoneIDs)
+	accountID, irsaTrustDomains, err := r.irsaTrustDomains(ctx, iamService, awsMachineTemplate, awsCluster, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = iamService.ReconcileGatewayAPIRole(accountID, irsaTrustDomains, hostedZoneIDs)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.GatewayAPIRole, clusterName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileSecretsRotationRole reconciles the execution role of the Secrets
+// Manager rotation Lambda function annotated on the AWSCluster. Nothing is done
+// if the AWSCluster is not annotated.
+func (r *AWSMachineTemplateReconciler) reconcileSecretsRotationRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	lambdaARN := key.GetAnnotation(awsCluster, key.RotationLambdaARNAnnotation)
+	if lambdaARN == "" {
+		logger.Info("AWSCluster has no rotation Lambda ARN annotation, not reconciling secrets rotation role", "annotation", key.RotationLambdaARNAnnotation)
+		return nil
+	}
+	if err := iam.ValidateRotationLambdaARN(lambdaARN); err != nil {
+		logger.Error(err, "refusing to reconcile secrets rotation role with invalid Lambda ARN", "lambda_arn", lambdaARN)
+		record.Warnf(awsMachineTemplate, "InvalidRotationLambdaARN", "Rotation Lambda ARN %q of annotation %s is invalid: %s", lambdaARN, key.RotationLambdaARNAnnotation, err)
+		return nil
+	}
+
+	logger.Info("reconciling secrets rotation role")
+	err := iamService.ReconcileSecretsRotationRole(lambdaARN)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.SecretsRotationRole, clusterName))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// clusterAccountID returns the AWS account ID of the cluster of the
+// AWSCluster.
+func (r *AWSMachineTemplateReconciler) clusterAccountID(ctx context.Context, iamService *iam.IAMService, awsCluster *capa.AWSCluster) (string, error) {
+	logger := log.FromContext(ctx)
+
+	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef)
+	if err != nil {
+		logger.Error(err, "could not get AWSClusterRoleIdentity")
+		return "", errors.WithStack(err)
+	}
+
+	accountID, err := getClusterAccountID(r.AWSClient, awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, awsCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Could not get account ID")
+		return "", errors.WithStack(err)
+	}
+	return accountID, nil
+}
+
+// cleanupDeprecatedKiamRole deletes the KIAM role created by previous
+// releases once KIAM is disabled. The deletion is recorded in an annotation so
+// that AWS is not queried again on every reconciliation.
+func (r *AWSMachineTemplateReconciler) cleanupDeprecatedKiamRole(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate) error {
+	logger := log.FromContext(ctx)
+
+	if key.GetAnnotation(awsMachineTemplate, key.DeprecatedKiamRoleDeletedAnnotation) != "" {
+		return nil
+	}
+
+	deleted, err := iamService.CleanupDeprecatedKiamRole()
+	if err != nil {
+		return err
+	}
+
+	patchHelper, err := patch.NewHelper(awsMachineTemplate, r.Client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	annotations := awsMachineTemplate.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key.DeprecatedKiamRoleDeletedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	awsMachineTemplate.SetAnnotations(annotations)
+	err = patchHelper.Patch(ctx, awsMachineTemplate)
+	if err != nil {
+		logger.Error(err, "failed to update annotation on AWSMachineTemplate", "annotation", key.DeprecatedKiamRoleDeletedAnnotation)
+		return errors.WithStack(err)
+	}
+
+	if deleted {
+		record.Event(awsMachineTemplate, "DeprecatedKiamRoleDeleted", "Deleted the deprecated KIAM IAM role of the cluster")
+	}
+	return nil
+}
+
+// setIRSARoleARNAnnotations publishes the ARNs of the IRSA roles on the
+// AWSCluster, so that they can be consumed by tools mirroring the object.
+func (r *AWSMachineTemplateReconciler) setIRSARoleARNAnnotations(ctx context.Context, iamService *iam.IAMService, awsCluster *capa.AWSCluster) error {
+	logger := log.FromContext(ctx)
+
+	arns, err := iamService.IRSARoleARNs()
+	if err != nil {
+		logger.Error(err, "failed to get ARNs of IRSA roles")
+		return err
+	}
+
+	annotations := map[string]*string{}
+	for roleType, arn := range arns {
+		arn := arn
+		if key.GetAnnotation(awsCluster, key.IRSARoleARNAnnotation(roleType)) != arn {
+			annotations[key.IRSARoleARNAnnotation(roleType)] = &arn
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	err = patchAnnotations(ctx, r.Client, awsCluster, annotations)
+	if err != nil {
+		logger.Error(err, "failed to set IRSA role ARN annotations on AWSCluster")
+		return err
+	}
+	return nil
+}
+
+// removeIRSARoleARNAnnotations removes the annotations set by
+// setIRSARoleARNAnnotations.
+func (r *AWSMachineTemplateReconciler) removeIRSARoleARNAnnotations(ctx context.Context, awsCluster *capa.AWSCluster) error {
+	annotations := map[string]*string{}
+	for _, roleType := range iam.IRSARoleTypes() {
+		if _, ok := awsCluster.GetAnnotations()[key.IRSARoleARNAnnotation(roleType)]; ok {
+			annotations[key.IRSARoleARNAnnotation(roleType)] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	err := patchAnnotations(ctx, r.Client, awsCluster, annotations)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "failed to remove IRSA role ARN annotations from AWSCluster")
+		return err
+	}
+	return nil
+}
+
+// reconcileExtraRoles creates the IAM roles of the role types listed in the
+// extra-role-types annotation. Roles of types which were removed from the
+// annotation are scheduled for deletion by reconcileRoleRename.
+func (r *AWSMachineTemplateReconciler) reconcileExtraRoles(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate) error {
+	logger := log.FromContext(ctx)
+
+	clusterName := awsMachineTemplate.Labels[key.ClusterNameLabel]
+	extraRoleTypes := key.GetExtraRoleTypes(awsMachineTemplate)
+	currentRoleNames := extraRoleNames(awsMachineTemplate)
+
+	for _, roleType := range extraRoleTypes {
+		err := iamService.ReconcileExtraRole(roleType)
+		if err != nil {
+			return err
+		}
+
+		err = iamService.ReconcileRoleTags(iam.RoleName(roleType, clusterName))
+		if err != nil {
+			return err
+		}
+	}
+
+	rolesToDelete := key.GetRolesToDelete(awsMachineTemplate)
+	for _, roleType := range key.GetLastExtraRoleTypes(awsMachineTemplate) {
+		roleName := iam.RoleName(roleType, clusterName)
+		if !slices.Contains(extraRoleTypes, roleType) && !slices.Contains(rolesToDelete, roleName) {
+			logger.Info("extra role type was removed, scheduling its role for deletion", "role_type", roleType, "role_name", roleName)
+			rolesToDelete = append(rolesToDelete, roleName)
+		}
+	}
+	rolesToDelete = slices.DeleteFunc(rolesToDelete, func(roleName string) bool { return slices.Contains(currentRoleNames, roleName) })
+
+	annotations := map[string]*string{}
+	for annotation, value := range map[string]string{
+		key.LastExtraRoleTypesAnnotation: strings.Join(extraRoleTypes, ","),
+		key.RolesToDeleteAnnotation:      strings.Join(rolesToDelete, ","),
+	} {
+		value := value
+		if key.GetAnnotation(awsMachineTemplate, annotation) == value {
+			continue
+		}
+		if value == "" {
+			annotations[annotation] = nil
+		} else {
+			annotations[annotation] = &value
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	err := patchAnnotations(ctx, r.Client, awsMachineTemplate, annotations)
+	if err != nil {
+		logger.Error(err, "failed to update extra role annotations on AWSMachineTemplate")
+		return err
+	}
+
+	return nil
+}
+
+// deleteExtraRoles deletes the IAM roles of the extra role types which are not
+// used by any other object.
+func (r *AWSMachineTemplateReconciler) deleteExtraRoles(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate) error {
+	logger := log.FromContext(ctx)
+
+	for _, roleName := range extraRoleNames(awsMachineTemplate) {
+		roleUsed, err := isRoleUsedElsewhere(ctx, r.Client, roleName)
+		if err != nil {
+			return err
+		}
+		if roleUsed {
+			logger.Info("extra IAM role is used by another object, not deleting it", "role_name", roleName)
+			continue
+		}
+
+		err = iamService.DeleteRoleByName(roleName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileRoleRename detects a change of the IAM instance profile name and
+// deletes roles which are not referenced anymore. The previous role name is
+// tracked in an annotation, since the old name is lost after the rename.
+func (r *AWSMachineTemplateReconciler) reconcileRoleRename(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate) error {
+	logger := log.FromContext(ctx)
+
+	currentRoleName := awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile
+	lastRoleName := key.GetAnnotation(awsMachineTemplate, key.LastRoleNameAnnotation)
+
+	rolesToDelete := key.GetRolesToDelete(awsMachineTemplate)
+	if lastRoleName != "" && lastRoleName != currentRoleName && !slices.Contains(rolesToDelete, lastRoleName) {
+		logger.Info("IAM instance profile was renamed, scheduling previous role for deletion", "previous_role_name", lastRoleName)
+		rolesToDelete = append(rolesToDelete, lastRoleName)
+	}
+	rolesToDelete = slices.DeleteFunc(rolesToDelete, func(roleName string) bool { return roleName == currentRoleName })
+
+	patchHelper, err := patch.NewHelper(awsMachineTemplate, r.Client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	setRoleAnnotations(awsMachineTemplate, currentRoleName, rolesToDelete)
+
+	remainingRoles, deleteErr := r.deleteStaleRoles(ctx, iamService, awsMachineTemplate)
+	setRoleAnnotations(awsMachineTemplate, currentRoleName, remainingRoles)
+
+	err = patchHelper.Patch(ctx, awsMachineTemplate)
+	if err != nil {
+		logger.Error(err, "failed to update role annotations on AWSMachineTemplate")
+		return errors.WithStack(err)
+	}
+
+	return deleteErr
+}
+
+// deleteStaleRoles deletes the roles listed in the roles-to-delete annotation
+// which are not used by any other object. It returns the roles which could
+// not be deleted.
+func (r *AWSMachineTemplateReconciler) deleteStaleRoles(ctx context.Context, iamService *iam.IAMService, awsMachineTemplate *capa.AWSMachineTemplate) ([]string, error) {
+	logger := log.FromContext(ctx)
+
+	var remainingRoles []string
+	for _, roleName := range key.GetRolesToDelete(awsMachineTemplate) {
+		roleUsed, err := isRoleUsedElsewhere(ctx, r.Client, roleName)
+		if err != nil {
+			return key.GetRolesToDelete(awsMachineTemplate), err
+		}
+		if roleUsed {
+			logger.Info("stale IAM role is used by another object, not deleting it", "role_name", roleName)
+			continue
+		}
+
+		err = iamService.DeleteRoleByName(roleName)
+		if err != nil {
+			logger.Error(err, "failed to delete stale IAM role", "role_name", roleName)
+			remainingRoles = append(remainingRoles, roleName)
+			continue
+		}
+	}
+
+	if len(remainingRoles) > 0 {
+		return remainingRoles, fmt.Errorf("failed to delete stale IAM roles %v", remainingRoles)
+	}
+	return nil, nil
+}
+
+func setRoleAnnotations(awsMachineTemplate *capa.AWSMachineTemplate, lastRoleName string, rolesToDelete []string) {
+	annotations := awsMachineTemplate.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key.LastRoleNameAnnotation] = lastRoleName
+	if len(rolesToDelete) > 0 {
+		annotations[key.RolesToDeleteAnnotation] = strings.Join(rolesToDelete, ",")
+	} else {
+		delete(annotations, key.RolesToDeleteAnnotation)
+	}
+	awsMachineTemplate.SetAnnotations(annotations)
+}
+
+// awsClusterToAWSMachineTemplates maps an AWSCluster to the AWSMachineTemplates
+// of the same cluster, so they get reconciled as soon as the cluster is ready.
+func (r *AWSMachineTemplateReconciler) awsClusterToAWSMachineTemplates(ctx context.Context, obj client.Object) []reconcile.Request {
+	logger := log.FromContext(ctx)
+
+	clusterName := obj.GetLabels()[key.ClusterNameLabel]
+	if clusterName == "" {
+		return nil
+	}
+
+	awsMachineTemplates, err := r.listClusterAWSMachineTemplates(ctx, obj.GetNamespace(), clusterName)
+	if err != nil {
+		logger.Error(err, "failed to list AWSMachineTemplates for AWSCluster", "cluster", clusterName)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(awsMachineTemplates))
+	for _, mt := range awsMachineTemplates {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(mt)})
+	}
+	return requests
+}
+
+// listClusterAWSMachineTemplates lists the AWSMachineTemplates of the cluster.
+func (r *AWSMachineTemplateReconciler) listClusterAWSMachineTemplates(ctx context.Context, namespace, clusterName string) ([]client.Object, error) {
+	awsMachineTemplates := &capa.AWSMachineTemplateList{}
+	err := r.List(ctx,
+		awsMachineTemplates,
+		client.InNamespace(namespace),
+		client.MatchingLabels{key.ClusterNameLabel: clusterName},
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	objects := make([]client.Object, 0, len(awsMachineTemplates.Items))
+	for i := range awsMachineTemplates.Items {
+		objects = append(objects, &awsMachineTemplates.Items[i])
+	}
+	return objects, nil
+}
+
+// waitForAWSCluster requeues until the AWSCluster becomes ready. Once
+// ClusterReadinessTimeout has passed since the AWSCluster was first seen not
+// being ready, it sets the ClusterReadinessTimeout condition of the AWSCluster
+// to true and stops requeueing until the condition is removed.
+func (r *AWSMachineTemplateReconciler) waitForAWSCluster(ctx context.Context, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if r.ClusterReadinessTimeout <= 0 {
+		logger.Info("AWSCluster is not ready yet, requeuing", "requeue_after", awsClusterNotReadyRequeueAfter)
+		return ctrl.Result{RequeueAfter: awsClusterNotReadyRequeueAfter}, nil
+	}
+
+	if conditions.Has(awsCluster, key.ClusterReadinessTimeoutCondition) {
+		logger.Info("Timed out waiting for AWSCluster to become ready, not requeuing until the condition is removed", "condition", key.ClusterReadinessTimeoutCondition)
+		return ctrl.Result{}, nil
+	}
+
+	notReadySince, err := time.Parse(time.RFC3339, key.GetAnnotation(awsMachineTemplate, key.AWSClusterNotReadySinceAnnotation))
+	if err != nil {
+		err = r.setAWSClusterNotReadySince(ctx, awsMachineTemplate, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("AWSCluster is not ready yet, requeuing", "requeue_after", awsClusterNotReadyRequeueAfter)
+		return ctrl.Result{RequeueAfter: awsClusterNotReadyRequeueAfter}, nil
+	}
+
+	if time.Since(notReadySince) < r.ClusterReadinessTimeout {
+		logger.Info("AWSCluster is not ready yet, requeuing", "requeue_after", awsClusterNotReadyRequeueAfter, "not_ready_since", notReadySince)
+		return ctrl.Result{RequeueAfter: awsClusterNotReadyRequeueAfter}, nil
+	}
+
+	// Remove the annotation first, so that the timeout starts over once the
+	// condition is cleared.
+	err = r.setAWSClusterNotReadySince(ctx, awsMachineTemplate, "")
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	patchHelper, err := patch.NewHelper(awsCluster, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+	message := fmt.Sprintf("AWSCluster did not become ready within %s", r.ClusterReadinessTimeout)
+	conditions.MarkTrueWithNegativePolarity(awsCluster, key.ClusterReadinessTimeoutCondition, "AWSClusterNotReady", capi.ConditionSeverityError, "%s", message)
+	err = patchHelper.Patch(ctx, awsCluster, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.ClusterReadinessTimeoutCondition}})
+	if err != nil {
+		logger.Error(err, "failed to set condition on AWSCluster", "condition", key.ClusterReadinessTimeoutCondition)
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	record.Warnf(awsMachineTemplate, "ClusterReadinessTimeout", "%s, not requeuing until condition %s is removed from the AWSCluster", message, key.ClusterReadinessTimeoutCondition)
+	logger.Info("Timed out waiting for AWSCluster to become ready", "timeout", r.ClusterReadinessTimeout)
+	return ctrl.Result{}, nil
+}
+
+// clearAWSClusterReadinessWait removes the state kept by waitForAWSCluster
+// once the AWSCluster is ready.
+func (r *AWSMachineTemplateReconciler) clearAWSClusterReadinessWait(ctx context.Context, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster) error {
+	logger := log.FromContext(ctx)
+
+	if key.GetAnnotation(awsMachineTemplate, key.AWSClusterNotReadySinceAnnotation) != "" {
+		err := r.setAWSClusterNotReadySince(ctx, awsMachineTemplate, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	if conditions.Has(awsCluster, key.ClusterReadinessTimeoutCondition) {
+		patchHelper, err := patch.NewHelper(awsCluster, r.Client)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		conditions.Delete(awsCluster, key.ClusterReadinessTimeoutCondition)
+		err = patchHelper.Patch(ctx, awsCluster, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.ClusterReadinessTimeoutCondition}})
+		if err != nil {
+			logger.Error(err, "failed to remove condition from AWSCluster", "condition", key.ClusterReadinessTimeoutCondition)
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// waitForClusterInfrastructure marks the AWSCluster with the
+// ClusterInfrastructureReady condition and requeues until the infrastructure
+// of the CAPI Cluster is ready.
+func (r *AWSMachineTemplateReconciler) waitForClusterInfrastructure(ctx context.Context, awsCluster *capa.AWSCluster) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if !conditions.IsFalse(awsCluster, key.ClusterInfrastructureReadyCondition) {
+		patchHelper, err := patch.NewHelper(awsCluster, r.Client)
+		if err != nil {
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+		conditions.MarkFalse(awsCluster, key.ClusterInfrastructureReadyCondition, "WaitingForClusterInfrastructure", capi.ConditionSeverityInfo, "Waiting for the Cluster infrastructure to be ready before reconciling IAM roles")
+		err = patchHelper.Patch(ctx, awsCluster, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.ClusterInfrastructureReadyCondition}})
+		if err != nil {
+			logger.Error(err, "failed to set condition on AWSCluster", "condition", key.ClusterInfrastructureReadyCondition)
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+	}
+
+	logger.Info("Cluster infrastructure is not ready yet, requeuing", "requeue_after", clusterInfrastructureNotReadyRequeueAfter)
+	return ctrl.Result{RequeueAfter: clusterInfrastructureNotReadyRequeueAfter}, nil
+}
+
+// clearClusterInfrastructureWait removes the condition set by
+// waitForClusterInfrastructure once the Cluster infrastructure is ready.
+func (r *AWSMachineTemplateReconciler) clearClusterInfrastructureWait(ctx context.Context, awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster) error {
+	logger := log.FromContext(ctx)
+
+	if !conditions.Has(awsCluster, key.ClusterInfrastructureReadyCondition) {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(awsCluster, r.Client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	conditions.Delete(awsCluster, key.ClusterInfrastructureReadyCondition)
+	err = patchHelper.Patch(ctx, awsCluster, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.ClusterInfrastructureReadyCondition}})
+	if err != nil {
+		logger.Error(err, "failed to remove condition from AWSCluster", "condition", key.ClusterInfrastructureReadyCondition)
+		return errors.WithStack(err)
+	}
+
+	record.Event(awsMachineTemplate, "ClusterInfrastructureReady", "Cluster infrastructure is ready, reconciling IAM roles")
+	logger.Info("Cluster infrastructure is ready, resuming reconciliation")
+	return nil
+}
+
+// setAWSClusterNotReadySince sets the not-ready-since annotation, or removes
+// it if value is empty.
+func (r *AWSMachineTemplateReconciler) setAWSClusterNotReadySince(ctx context.Context, awsMachineTemplate *capa.AWSMachineTemplate, value string) error {
+	patchHelper, err := patch.NewHelper(awsMachineTemplate, r.Client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	annotations := awsMachineTemplate.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if value == "" {
+		delete(annotations, key.AWSClusterNotReadySinceAnnotation)
+	} else {
+		annotations[key.AWSClusterNotReadySinceAnnotation] = value
+	}
+	awsMachineTemplate.SetAnnotations(annotations)
+
+	err = patchHelper.Patch(ctx, awsMachineTemplate)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to update annotation on AWSMachineTemplate", "annotation", key.AWSClusterNotReadySinceAnnotation)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// awsClusterReadinessChanged only lets through AWSCluster updates which flip
+// .status.ready from false to true, or which remove the
+// ClusterReadinessTimeout condition to resume waiting for the AWSCluster.
+func awsClusterReadinessChanged() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldAWSCluster, ok := e.ObjectOld.(*capa.AWSCluster)
+			if !ok {
+				return false
+			}
+			newAWSCluster, ok := e.ObjectNew.(*capa.AWSCluster)
+			if !ok {
+				return false
+			}
+			if !oldAWSCluster.Status.Ready && newAWSCluster.Status.Ready {
+				return true
+			}
+			return conditions.Has(oldAWSCluster, key.ClusterReadinessTimeoutCondition) && !conditions.Has(newAWSCluster, key.ClusterReadinessTimeoutCondition)
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AWSMachineTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	terminatingTemplates := make(chan event.GenericEvent)
+	err := mgr.Add(&TerminatingAWSMachineTemplatesEnqueuer{
+		Client: mgr.GetClient(),
+		Events: terminatingTemplates,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if r.RoleCountRefreshInterval > 0 {
+		r.managedRolesCounter = &ManagedRolesCounter{
+			Client:          mgr.GetClient(),
+			RefreshInterval: r.RoleCountRefreshInterval,
+		}
+		err = mgr.Add(r.managedRolesCounter)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&capa.AWSMachineTemplate{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(
+			&capa.AWSCluster{},
+			handler.EnqueueRequestsFromMapFunc(r.awsClusterToAWSMachineTemplates),
+			builder.WithPredicates(awsClusterReadinessChanged()),
+		).
+		Watches(
+			&iamv1alpha1.IRSAConfig{},
+			handler.EnqueueRequestsFromMapFunc(irsaConfigToObjects(r.listClusterAWSMachineTemplates)),
+		).
+		WatchesRawSource(source.Channel(terminatingTemplates, &handler.EnqueueRequestForObject{}))
+
+	if r.AWSConfigNotificationAddr != "" {
+		driftedTemplates := make(chan event.GenericEvent)
+		err = mgr.Add(&AWSConfigNotificationServer{
+			Addr:      r.AWSConfigNotificationAddr,
+			Client:    mgr.GetClient(),
+			Events:    driftedTemplates,
+			TopicARNs: r.AWSConfigNotificationTopicARNs,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		b = b.WatchesRawSource(source.Channel(driftedTemplates, &handler.EnqueueRequestForObject{}))
+	}
+
+	return b.Complete(metrics.WrapReconciler("awsmachinetemplate", mgr.GetClient(), func() client.Object { return &capa.AWSMachineTemplate{} }, wrapReconciler(r, r.WrapReconciler)))
 }
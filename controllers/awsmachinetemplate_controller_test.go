@@ -2,6 +2,11 @@ package controllers_test
 
 import (
 	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -16,11 +21,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	iamv1alpha1 "github.com/giantswarm/capa-iam-operator/api/v1alpha1"
 	"github.com/giantswarm/capa-iam-operator/controllers"
 	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
 )
@@ -36,6 +43,8 @@ var _ = Describe("AWSMachineTemplateReconciler", func() {
 		req           ctrl.Request
 		namespace     string
 		sess          *session.Session
+		awsCluster    *capa.AWSCluster
+		cluster       *capi.Cluster
 	)
 
 	SetupNamespaceBeforeAfterEach(&namespace)
@@ -96,7 +105,7 @@ var _ = Describe("AWSMachineTemplateReconciler", func() {
 			},
 		})
 
-		err = k8sClient.Create(ctx, &capa.AWSCluster{
+		awsCluster = &capa.AWSCluster{
 			ObjectMeta: metav1.ObjectMeta{
 				Labels: map[string]string{
 					"cluster.x-k8s.io/cluster-name": "test-cluster",
@@ -111,10 +120,15 @@ var _ = Describe("AWSMachineTemplateReconciler", func() {
 				},
 				Region: "eu-west-1",
 			},
-		})
+		}
+		err = k8sClient.Create(ctx, awsCluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		awsCluster.Status.Ready = true
+		err = k8sClient.Status().Update(ctx, awsCluster)
 		Expect(err).NotTo(HaveOccurred())
 
-		err = k8sClient.Create(ctx, &capi.Cluster{
+		cluster = &capi.Cluster{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "test-cluster",
 				Namespace: namespace,
@@ -124,7 +138,12 @@ var _ = Describe("AWSMachineTemplateReconciler", func() {
 					Host: "testcluster-apiserver-123456789.eu-west-2.elb.amazonaws.com",
 				},
 			},
-		})
+		}
+		err = k8sClient.Create(ctx, cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		cluster.Status.InfrastructureReady = true
+		err = k8sClient.Status().Update(ctx, cluster)
 		Expect(err).NotTo(HaveOccurred())
 
 		err = k8sClient.Create(ctx, &corev1.ConfigMap{
@@ -348,18 +367,224 @@ var _ = Describe("AWSMachineTemplateReconciler", func() {
 		},
 	}
 
-	When("a role does not exist", func() {
+	When("the AWSCluster is not ready yet", func() {
 		BeforeEach(func() {
-			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
-			for _, info := range expectedRoleStatusesOnSuccess {
-				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
-					RoleName: aws.String(info.ExpectedName),
-				}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+			awsCluster.Status.Ready = false
+			err := k8sClient.Status().Update(ctx, awsCluster)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("requeues without calling AWS", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(10 * time.Second))
+		})
+
+		When("a cluster readiness timeout is configured", func() {
+			BeforeEach(func() {
+				reconciler.ClusterReadinessTimeout = 2 * time.Hour
+			})
+
+			It("records when the AWSCluster was first seen not ready and requeues", func() {
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(10 * time.Second))
+
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err = k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(awsMachineTemplate.Annotations).To(HaveKey("capa-iam-operator.giantswarm.io/awscluster-not-ready-since"))
+			})
+
+			When("the timeout has passed", func() {
+				BeforeEach(func() {
+					awsMachineTemplate := &capa.AWSMachineTemplate{}
+					err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+					Expect(err).NotTo(HaveOccurred())
+
+					awsMachineTemplate.Annotations = map[string]string{
+						"capa-iam-operator.giantswarm.io/awscluster-not-ready-since": time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339),
+					}
+					err = k8sClient.Update(ctx, awsMachineTemplate)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("sets the ClusterReadinessTimeout condition and stops requeueing", func() {
+					result, err := reconciler.Reconcile(ctx, req)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(BeZero())
+					Expect(result.Requeue).To(BeFalse())
+
+					updatedAWSCluster := &capa.AWSCluster{}
+					err = k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+					Expect(err).NotTo(HaveOccurred())
+					condition := conditions.Get(updatedAWSCluster, "ClusterReadinessTimeout")
+					Expect(condition).NotTo(BeNil())
+					Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+					Expect(condition.Reason).To(Equal("AWSClusterNotReady"))
+					Expect(condition.Severity).To(Equal(capi.ConditionSeverityError))
+
+					// Subsequent reconciliations do not requeue either
+					result, err = reconciler.Reconcile(ctx, req)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(BeZero())
+					Expect(result.Requeue).To(BeFalse())
+
+					// Removing the condition resumes waiting for the AWSCluster
+					conditions.Delete(updatedAWSCluster, "ClusterReadinessTimeout")
+					err = k8sClient.Status().Update(ctx, updatedAWSCluster)
+					Expect(err).NotTo(HaveOccurred())
+
+					result, err = reconciler.Reconcile(ctx, req)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(10 * time.Second))
+				})
+			})
+		})
+	})
+
+	When("the Cluster infrastructure is not ready yet", func() {
+		BeforeEach(func() {
+			cluster.Status.InfrastructureReady = false
+			err := k8sClient.Status().Update(ctx, cluster)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sets the ClusterInfrastructureReady condition and requeues without calling AWS", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			condition := conditions.Get(updatedAWSCluster, "ClusterInfrastructureReady")
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(condition.Severity).To(Equal(capi.ConditionSeverityInfo))
+		})
+
+		When("the Cluster infrastructure becomes ready", func() {
+			BeforeEach(func() {
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+
+				cluster.Status.InfrastructureReady = true
+				err = k8sClient.Status().Update(ctx, cluster)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockAwsClient.EXPECT().GetAWSClientSession(gomock.Any(), gomock.Any()).Return(nil, errors.New("stop after the readiness checks")).AnyTimes()
+			})
+
+			It("removes the condition and resumes reconciliation", func() {
+				_, err := reconciler.Reconcile(ctx, req)
+				Expect(err).To(MatchError(ContainSubstring("stop after the readiness checks")))
+
+				updatedAWSCluster := &capa.AWSCluster{}
+				err = k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conditions.Has(updatedAWSCluster, "ClusterInfrastructureReady")).To(BeFalse())
+			})
+		})
+	})
+
+	When("the AWSMachineTemplate was reconciled recently and did not change", func() {
+		BeforeEach(func() {
+			reconciler.MinReconcileAge = 10 * time.Minute
+
+			awsMachineTemplate := &capa.AWSMachineTemplate{}
+			err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
+
+			currentAWSCluster := &capa.AWSCluster{}
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), currentAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			inputs, err := controllers.ReconcileInputs(currentAWSCluster, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			awsMachineTemplate.Annotations = map[string]string{
+				"capa-iam-operator.giantswarm.io/last-reconcile-success":    time.Now().UTC().Format(time.RFC3339),
+				"capa-iam-operator.giantswarm.io/last-reconcile-generation": strconv.FormatInt(awsMachineTemplate.Generation, 10),
+				"capa-iam-operator.giantswarm.io/last-reconcile-inputs":     inputs,
 			}
+			err = k8sClient.Update(ctx, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("creates the role", func() {
-			for _, info := range expectedRoleStatusesOnSuccess {
+		It("returns early without calling AWS", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+
+		When("the AWSCluster changed since", func() {
+			BeforeEach(func() {
+				currentAWSCluster := &capa.AWSCluster{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), currentAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+
+				currentAWSCluster.Annotations = map[string]string{
+					"capa-iam-operator.giantswarm.io/karpenter-queue-arn": "arn:aws:sqs:eu-west-1:012345678901:karpenter",
+				}
+				err = k8sClient.Update(ctx, currentAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockAwsClient.EXPECT().GetAWSClientSession(gomock.Any(), gomock.Any()).Return(nil, errors.New("stop after the skip check")).AnyTimes()
+			})
+
+			It("does not skip the reconciliation", func() {
+				_, err := reconciler.Reconcile(ctx, req)
+				Expect(err).To(MatchError(ContainSubstring("stop after the skip check")))
+			})
+		})
+
+		When("only the IRSA role ARN annotations of the AWSCluster changed since", func() {
+			BeforeEach(func() {
+				currentAWSCluster := &capa.AWSCluster{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), currentAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+
+				currentAWSCluster.Annotations = map[string]string{
+					"capa-iam-operator.giantswarm.io/irsa-route53-role-arn": "arn:aws:iam::012345678901:role/test-cluster-Route53Manager-Role",
+				}
+				err = k8sClient.Update(ctx, currentAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns early without calling AWS", func() {
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(BeZero())
+			})
+		})
+	})
+
+	When("the IAM instance profile uses a reserved name", func() {
+		BeforeEach(func() {
+			awsMachineTemplate := &capa.AWSMachineTemplate{}
+			err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
+
+			awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile = "aws-the-profile"
+			err = k8sClient.Update(ctx, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+		})
+
+		It("does not create any role and does not requeue", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+	})
+
+	When("a role does not exist", func() {
+		var roleInfos []RoleInfo
+
+		expectRolesCreated := func() {
+			for _, info := range roleInfos {
 				mockIAMClient.EXPECT().CreateRole(&iam.CreateRoleInput{
 					AssumeRolePolicyDocument: aws.String(info.ExpectedAssumeRolePolicyDocument),
 					RoleName:                 aws.String(info.ExpectedName),
@@ -403,62 +628,485 @@ var _ = Describe("AWSMachineTemplateReconciler", func() {
 					PolicyDocument: aws.String(info.ExpectedPolicyDocument),
 					RoleName:       aws.String(info.ExpectedName),
 				}).Return(&iam.PutRolePolicyOutput{}, nil)
+
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String(info.ExpectedName),
+				}).Return(&iam.ListRoleTagsOutput{Tags: expectedIAMTags}, nil)
+				mockIAMClient.EXPECT().ListInstanceProfileTags(&iam.ListInstanceProfileTagsInput{
+					InstanceProfileName: aws.String(info.ExpectedName),
+				}).Return(&iam.ListInstanceProfileTagsOutput{Tags: expectedIAMTags}, nil)
+			}
+		}
+
+		BeforeEach(func() {
+			roleInfos = expectedRoleStatusesOnSuccess
+
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+			for _, info := range expectedRoleStatusesOnSuccess {
+				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
+					RoleName: aws.String(info.ExpectedName),
+				}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+			}
+		})
+
+		It("creates the role", func() {
+			expectRolesCreated()
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+		})
+
+		When("an IRSAConfig exists for the cluster", func() {
+			BeforeEach(func() {
+				err := k8sClient.Create(ctx, &iamv1alpha1.IRSAConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-cluster",
+						Namespace: namespace,
+					},
+					Spec: iamv1alpha1.IRSAConfigSpec{
+						ServiceAccounts: []iamv1alpha1.IRSAServiceAccount{
+							{
+								RoleType:  "cert-manager-role",
+								Namespace: "cert-manager",
+								Name:      "cert-manager",
+							},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				// ignored in favour of the IRSAConfig
+				patchedAWSCluster := awsCluster.DeepCopy()
+				patchedAWSCluster.Annotations = map[string]string{"aws.giantswarm.io/irsa-trust-domains": "oidc.other.example.com"}
+				err = k8sClient.Patch(ctx, patchedAWSCluster, client.MergeFrom(awsCluster))
+				Expect(err).NotTo(HaveOccurred())
+
+				roleInfos = nil
+				for _, info := range expectedRoleStatusesOnSuccess {
+					if info.ExpectedName == certManagerRoleInfo.ExpectedName {
+						info.ExpectedAssumeRolePolicyDocument = strings.Replace(info.ExpectedAssumeRolePolicyDocument, "system:serviceaccount:kube-system:cert-manager-app", "system:serviceaccount:cert-manager:cert-manager", 1)
+					}
+					roleInfos = append(roleInfos, info)
+				}
+			})
+
+			It("uses the IRSAConfig instead of the annotations", func() {
+				expectRolesCreated()
+
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(BeNil())
+			})
+		})
+
+		It("sets the IAMRoleReady condition on the AWSCluster", func() {
+			expectRolesCreated()
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conditions.IsTrue(updatedAWSCluster, "IAMRoleReady")).To(BeTrue())
+		})
+
+		It("publishes the IRSA role ARNs on the AWSCluster", func() {
+			expectRolesCreated()
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedAWSCluster.Annotations).To(HaveKeyWithValue("capa-iam-operator.giantswarm.io/irsa-route53-role-arn", externalDnsRoleInfo.ReturnRoleArn))
+			Expect(updatedAWSCluster.Annotations).To(HaveKeyWithValue("capa-iam-operator.giantswarm.io/irsa-cert-manager-role-arn", certManagerRoleInfo.ReturnRoleArn))
+			Expect(updatedAWSCluster.Annotations).To(HaveKeyWithValue("capa-iam-operator.giantswarm.io/irsa-albcontroller-role-arn", ALBControllerRoleInfo.ReturnRoleArn))
+		})
+
+		It("writes the IAM status ConfigMap of the cluster", func() {
+			expectRolesCreated()
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			cm := &corev1.ConfigMap{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "test-cluster-iam-status"}, cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("lastReconcileResult", "success"))
+			Expect(cm.Data).To(HaveKeyWithValue("lastError", ""))
+			Expect(cm.Data).To(HaveKey("lastReconcileTime"))
+			Expect(strings.Split(cm.Data["managedRoles"], ",")).To(ContainElements(
+				"arn:aws:iam::012345678901:role/the-profile",
+				"arn:aws:iam::012345678901:role/test-cluster-Route53Manager-Role",
+			))
+			Expect(cm.Finalizers).To(ContainElement("capa-iam-operator.finalizers.giantswarm.io/control-plane"))
+			Expect(cm.OwnerReferences).To(ContainElement(HaveField("Name", awsCluster.Name)))
+		})
+
+		When("a policy drift check is configured", func() {
+			BeforeEach(func() {
+				reconciler.PolicyDriftCheckInterval = time.Hour
+
+				mockIAMClient.EXPECT().SimulatePrincipalPolicy(gomock.Any()).DoAndReturn(func(input *iam.SimulatePrincipalPolicyInput) (*iam.SimulatePolicyResponse, error) {
+					response := &iam.SimulatePolicyResponse{}
+					if *input.PolicySourceArn == "arn:aws:iam::12345678:role/the-profile" {
+						response.EvaluationResults = []*iam.EvaluationResult{
+							{
+								EvalActionName: aws.String("autoscaling:DescribeTags"),
+								EvalDecision:   aws.String(iam.PolicyEvaluationDecisionTypeExplicitDeny),
+							},
+						}
+					}
+					return response, nil
+				}).AnyTimes()
+			})
+
+			It("sets the PolicyDriftDetected condition and requeues after the interval", func() {
+				expectRolesCreated()
+
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(time.Hour))
+
+				updatedAWSCluster := &capa.AWSCluster{}
+				err = k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+				condition := conditions.Get(updatedAWSCluster, "PolicyDriftDetected")
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+				Expect(condition.Message).To(ContainSubstring("autoscaling:DescribeTags on the-profile (explicitDeny)"))
+
+				updatedAWSMachineTemplate := &capa.AWSMachineTemplate{}
+				err = k8sClient.Get(ctx, req.NamespacedName, updatedAWSMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(updatedAWSMachineTemplate.Annotations).To(HaveKey("capa-iam-operator.giantswarm.io/last-policy-drift-check"))
+			})
+		})
+
+		When("the IAM instance profile was renamed", func() {
+			BeforeEach(func() {
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+
+				awsMachineTemplate.Annotations = map[string]string{
+					"capa-iam-operator.giantswarm.io/last-role-name": "the-old-profile",
+				}
+				err = k8sClient.Update(ctx, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("creates the new role and deletes the previous one", func() {
+				expectRolesCreated()
+
+				mockIAMClient.EXPECT().ListAttachedRolePolicies(&iam.ListAttachedRolePoliciesInput{
+					RoleName: aws.String("the-old-profile"),
+				}).Return(&iam.ListAttachedRolePoliciesOutput{}, nil)
+				mockIAMClient.EXPECT().ListRolePolicies(&iam.ListRolePoliciesInput{
+					RoleName: aws.String("the-old-profile"),
+				}).Return(&iam.ListRolePoliciesOutput{}, nil)
+				mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(&iam.RemoveRoleFromInstanceProfileInput{
+					InstanceProfileName: aws.String("the-old-profile"),
+					RoleName:            aws.String("the-old-profile"),
+				}).Return(&iam.RemoveRoleFromInstanceProfileOutput{}, nil)
+				mockIAMClient.EXPECT().DeleteInstanceProfile(&iam.DeleteInstanceProfileInput{
+					InstanceProfileName: aws.String("the-old-profile"),
+				}).Return(&iam.DeleteInstanceProfileOutput{}, nil)
+				mockIAMClient.EXPECT().DeleteRole(&iam.DeleteRoleInput{
+					RoleName: aws.String("the-old-profile"),
+				}).Return(&iam.DeleteRoleOutput{}, nil)
+
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(BeNil())
+
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(awsMachineTemplate.Annotations).To(HaveKeyWithValue("capa-iam-operator.giantswarm.io/last-role-name", "the-profile"))
+				Expect(awsMachineTemplate.Annotations).NotTo(HaveKey("capa-iam-operator.giantswarm.io/roles-to-delete"))
+			})
+		})
+
+		When("extra role types are annotated", func() {
+			extraRoleNames := []string{"test-cluster-nodes", "test-cluster-bastion"}
+
+			BeforeEach(func() {
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+
+				awsMachineTemplate.Annotations = map[string]string{
+					"capa-iam-operator.giantswarm.io/extra-role-types": "nodes, bastion",
+				}
+				err = k8sClient.Update(ctx, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("creates a role for every extra role type", func() {
+				expectRolesCreated()
+
+				var createdRoles, policyRoles []string
+				for _, roleName := range extraRoleNames {
+					mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
+						RoleName: aws.String(roleName),
+					}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+					mockIAMClient.EXPECT().CreateInstanceProfile(&iam.CreateInstanceProfileInput{
+						InstanceProfileName: aws.String(roleName),
+						Tags:                expectedIAMTags,
+					}).Return(&iam.CreateInstanceProfileOutput{}, nil)
+					mockIAMClient.EXPECT().AddRoleToInstanceProfile(&iam.AddRoleToInstanceProfileInput{
+						InstanceProfileName: aws.String(roleName),
+						RoleName:            aws.String(roleName),
+					}).Return(&iam.AddRoleToInstanceProfileOutput{}, nil)
+					mockIAMClient.EXPECT().GetRolePolicy(&iam.GetRolePolicyInput{
+						PolicyName: aws.String("control-plane-test-cluster-policy"),
+						RoleName:   aws.String(roleName),
+					}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+					mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+						RoleName: aws.String(roleName),
+					}).Return(&iam.ListRoleTagsOutput{Tags: expectedIAMTags}, nil)
+					mockIAMClient.EXPECT().ListInstanceProfileTags(&iam.ListInstanceProfileTagsInput{
+						InstanceProfileName: aws.String(roleName),
+					}).Return(&iam.ListInstanceProfileTagsOutput{Tags: expectedIAMTags}, nil)
+				}
+				mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+					Expect(*input.AssumeRolePolicyDocument).To(Equal(expectedRoleStatusesOnSuccess[0].ExpectedAssumeRolePolicyDocument))
+					createdRoles = append(createdRoles, *input.RoleName)
+					return &iam.CreateRoleOutput{}, nil
+				}).Times(len(extraRoleNames))
+				mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+					policyRoles = append(policyRoles, *input.RoleName)
+					return &iam.PutRolePolicyOutput{}, nil
+				}).Times(len(extraRoleNames))
+
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(BeNil())
+				Expect(createdRoles).To(ConsistOf(extraRoleNames))
+				Expect(policyRoles).To(ConsistOf(extraRoleNames))
+
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(awsMachineTemplate.Annotations).To(HaveKeyWithValue("capa-iam-operator.giantswarm.io/last-extra-role-types", "nodes,bastion"))
+			})
+		})
+	})
+
+	When("reconciling the role fails", func() {
+		BeforeEach(func() {
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+			mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
+				RoleName: aws.String("the-profile"),
+			}).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+			mockIAMClient.EXPECT().CreateRole(gomock.Any()).Return(nil, awserr.New(iam.ErrCodeLimitExceededException, "unit test", nil))
+		})
+
+		It("sets the IAMRoleReady condition on the AWSCluster to false", func() {
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(HaveOccurred())
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			condition := conditions.Get(updatedAWSCluster, "IAMRoleReady")
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("ReconcileError"))
+			Expect(condition.Message).To(ContainSubstring(iam.ErrCodeLimitExceededException))
+		})
+
+		It("records the error in the IAM status ConfigMap of the cluster", func() {
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "test-cluster-iam-status"}, cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("lastReconcileResult", "failure"))
+			Expect(cm.Data["lastError"]).To(ContainSubstring(iam.ErrCodeLimitExceededException))
+		})
+	})
+
+	When("the AWSMachineTemplate is deleted", func() {
+		var (
+			deletedRoles  []string
+			deleteRoleErr error
+		)
+
+		BeforeEach(func() {
+			deletedRoles = nil
+			deleteRoleErr = nil
+
+			awsCluster.Annotations = map[string]string{
+				"capa-iam-operator.giantswarm.io/irsa-route53-role-arn": externalDnsRoleInfo.ReturnRoleArn,
+				"unrelated": "annotation",
 			}
+			awsCluster.Finalizers = []string{"capa-iam-operator.finalizers.giantswarm.io/control-plane"}
+			err := k8sClient.Update(ctx, awsCluster)
+			Expect(err).NotTo(HaveOccurred())
+
+			awsMachineTemplate := &capa.AWSMachineTemplate{}
+			err = k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
+			awsMachineTemplate.Finalizers = []string{"capa-iam-operator.finalizers.giantswarm.io/control-plane"}
+			err = k8sClient.Update(ctx, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Delete(ctx, awsMachineTemplate)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&iam.ListAttachedRolePoliciesOutput{}, nil).AnyTimes()
+			mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&iam.ListRolePoliciesOutput{}, nil).AnyTimes()
+			mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&iam.RemoveRoleFromInstanceProfileOutput{}, nil).AnyTimes()
+			mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&iam.DeleteInstanceProfileOutput{}, nil).AnyTimes()
+			mockIAMClient.EXPECT().DeleteRole(gomock.Any()).DoAndReturn(func(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+				if deleteRoleErr != nil {
+					return nil, deleteRoleErr
+				}
+				deletedRoles = append(deletedRoles, *input.RoleName)
+				return &iam.DeleteRoleOutput{}, nil
+			}).AnyTimes()
+		})
 
+		It("removes the finalizer from the AWSCluster", func() {
 			_, reconcileErr = reconciler.Reconcile(ctx, req)
 			Expect(reconcileErr).To(BeNil())
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedAWSCluster.Finalizers).NotTo(ContainElement("capa-iam-operator.finalizers.giantswarm.io/control-plane"))
+		})
+
+		It("removes the role and the finalizer from the IAM status ConfigMap", func() {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-cluster-iam-status",
+					Namespace:  namespace,
+					Finalizers: []string{"capa-iam-operator.finalizers.giantswarm.io/control-plane"},
+				},
+				Data: map[string]string{
+					"managedRoles": "arn:aws:iam::012345678901:role/other-role,arn:aws:iam::012345678901:role/the-profile",
+				},
+			}
+			err := k8sClient.Create(ctx, cm)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("managedRoles", "arn:aws:iam::012345678901:role/other-role"))
+			Expect(cm.Finalizers).To(BeEmpty())
+		})
+
+		It("removes the IAMRoleReady condition from the AWSCluster", func() {
+			conditions.MarkTrue(awsCluster, "IAMRoleReady")
+			err := k8sClient.Status().Update(ctx, awsCluster)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conditions.Has(updatedAWSCluster, "IAMRoleReady")).To(BeFalse())
+		})
+
+		It("removes the IRSA role ARN annotations from the AWSCluster", func() {
+			_, reconcileErr = reconciler.Reconcile(ctx, req)
+			Expect(reconcileErr).To(BeNil())
+
+			updatedAWSCluster := &capa.AWSCluster{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedAWSCluster.Annotations).NotTo(HaveKey("capa-iam-operator.giantswarm.io/irsa-route53-role-arn"))
+			Expect(updatedAWSCluster.Annotations).To(HaveKeyWithValue("unrelated", "annotation"))
+		})
+
+		When("extra role types are annotated", func() {
+			BeforeEach(func() {
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err := k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+
+				awsMachineTemplate.Annotations = map[string]string{
+					"capa-iam-operator.giantswarm.io/extra-role-types": "nodes,bastion",
+				}
+				err = k8sClient.Update(ctx, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("deletes the primary and the extra roles", func() {
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(BeNil())
+
+				Expect(deletedRoles).To(ContainElements("the-profile", "test-cluster-nodes", "test-cluster-bastion"))
+			})
+		})
+
+		When("deleting a role fails in AWS", func() {
+			BeforeEach(func() {
+				deleteRoleErr = awserr.New(iam.ErrCodeServiceFailureException, "test", nil)
+			})
+
+			It("returns the AWS error and keeps the finalizers", func() {
+				_, reconcileErr = reconciler.Reconcile(ctx, req)
+				Expect(reconcileErr).To(MatchError(ContainSubstring("failed to delete IAM resources in AWS")))
+
+				var awsErr awserr.Error
+				Expect(errors.As(reconcileErr, &awsErr)).To(BeTrue())
+				Expect(awsErr.Code()).To(Equal(iam.ErrCodeServiceFailureException))
+
+				updatedAWSCluster := &capa.AWSCluster{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(awsCluster), updatedAWSCluster)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(updatedAWSCluster.Finalizers).To(ContainElement("capa-iam-operator.finalizers.giantswarm.io/control-plane"))
+				Expect(updatedAWSCluster.Annotations).To(HaveKey("capa-iam-operator.giantswarm.io/irsa-route53-role-arn"))
+
+				awsMachineTemplate := &capa.AWSMachineTemplate{}
+				err = k8sClient.Get(ctx, req.NamespacedName, awsMachineTemplate)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(awsMachineTemplate.Finalizers).To(ContainElement("capa-iam-operator.finalizers.giantswarm.io/control-plane"))
+			})
 		})
 	})
 
 	When("a role already exists", func() {
 		BeforeEach(func() {
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
 			for _, info := range expectedRoleStatusesOnSuccess {
 				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
 					RoleName: aws.String(info.ExpectedName),
 				}).MinTimes(1).Return(&iam.GetRoleOutput{
 					Role: &iam.Role{
-						Arn:  aws.String(info.ReturnRoleArn),
-						Tags: expectedIAMTags,
+						Arn:                      aws.String(info.ReturnRoleArn),
+						AssumeRolePolicyDocument: aws.String(url.QueryEscape(info.ExpectedAssumeRolePolicyDocument)),
+						Tags:                     expectedIAMTags,
 					},
 				}, nil)
 			}
 		})
 
-		It("works on the existing role", func() {
-			Skip("TODO The controller is not idempotent to this extent, but should be. Once this is implemented, we should also add test cases for failures in each AWS SDK call")
-
+		It("does not write unchanged policies", func() {
 			for _, info := range expectedRoleStatusesOnSuccess {
-				mockIAMClient.EXPECT().CreateInstanceProfile(&iam.CreateInstanceProfileInput{
-					InstanceProfileName: aws.String(info.ExpectedName),
-					Tags:                expectedIAMTags,
-				}).Return(&iam.CreateInstanceProfileOutput{}, nil)
-
-				mockIAMClient.EXPECT().AddRoleToInstanceProfile(&iam.AddRoleToInstanceProfileInput{
-					InstanceProfileName: aws.String(info.ExpectedName),
-					RoleName:            aws.String(info.ExpectedName),
-				}).Return(&iam.AddRoleToInstanceProfileOutput{}, nil)
-
-				// Implementation detail: instead of storing the ARN, the controller calls `GetRole` multiple times
-				// from different places. Remove once we don't do this anymore (hence the `MinTimes` call so we
-				// would notice).
-				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
-					RoleName: aws.String(info.ExpectedName),
-				}).MinTimes(1).Return(&iam.GetRoleOutput{
-					Role: &iam.Role{
-						Arn:  aws.String(info.ReturnRoleArn),
-						Tags: expectedIAMTags,
-					},
+				// IAM returns URL-encoded policy documents
+				mockIAMClient.EXPECT().GetRolePolicy(&iam.GetRolePolicyInput{
+					PolicyName: aws.String(info.ExpectedPolicyName),
+					RoleName:   aws.String(info.ExpectedName),
+				}).Return(&iam.GetRolePolicyOutput{
+					PolicyDocument: aws.String(url.QueryEscape(info.ExpectedPolicyDocument)),
 				}, nil)
 
-				mockIAMClient.EXPECT().ListRolePolicies(&iam.ListRolePoliciesInput{
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
 					RoleName: aws.String(info.ExpectedName),
-				}).Return(&iam.ListRolePoliciesOutput{}, nil)
-
-				mockIAMClient.EXPECT().PutRolePolicy(&iam.PutRolePolicyInput{
-					PolicyName:     aws.String(info.ExpectedPolicyName),
-					PolicyDocument: aws.String(info.ExpectedPolicyDocument),
-					RoleName:       aws.String(info.ExpectedName),
-				}).Return(&iam.PutRolePolicyOutput{}, nil)
+				}).Return(&iam.ListRoleTagsOutput{Tags: expectedIAMTags}, nil)
+				mockIAMClient.EXPECT().ListInstanceProfileTags(&iam.ListInstanceProfileTagsInput{
+					InstanceProfileName: aws.String(info.ExpectedName),
+				}).Return(&iam.ListInstanceProfileTagsOutput{Tags: expectedIAMTags}, nil)
 			}
 
 			_, reconcileErr = reconciler.Reconcile(ctx, req)
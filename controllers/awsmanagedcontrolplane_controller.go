@@ -18,22 +18,31 @@ package controllers
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/giantswarm/microerror"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	eks "sigs.k8s.io/cluster-api-provider-aws/v2/controlplane/eks/api/v1beta2"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	iamv1alpha1 "github.com/giantswarm/capa-iam-operator/api/v1alpha1"
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
 	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
 	"github.com/giantswarm/capa-iam-operator/pkg/iam"
 	"github.com/giantswarm/capa-iam-operator/pkg/key"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+	"github.com/giantswarm/capa-iam-operator/pkg/record"
 )
 
 // AWSManagedControlPlaneReconciler reconciles a AWSManagedControlPlane object
@@ -41,6 +50,39 @@ type AWSManagedControlPlaneReconciler struct {
 	client.Client
 	AWSClient        awsclient.AwsClientInterface
 	IAMClientFactory func(awsclientgo.ConfigProvider, string) iamiface.IAMAPI
+	// ConfigClientFactory enables the AWS Config rule integration when set.
+	ConfigClientFactory iam.ConfigClientFactory
+	// RolePath is the IAM path of created roles and instance profiles.
+	RolePath string
+	// OwnershipTagKey and OwnershipTagValue form the tag marking the IAM
+	// resources created by the operator.
+	OwnershipTagKey   string
+	OwnershipTagValue string
+	// SkipInstanceProfiles leaves instance profiles to be managed externally.
+	SkipInstanceProfiles bool
+	// FinalizerRemovalTimeout removes the finalizer without deleting the IAM
+	// resources once an object has been terminating for longer than this
+	// duration. Zero waits forever.
+	FinalizerRemovalTimeout time.Duration
+	// AuditSink receives an audit event for every IAM mutation when set.
+	AuditSink audit.Sink
+	// IAMManagementAccountRoleARN is assumed to manage the IRSA roles when
+	// set, so they are kept in a separate IAM management account. The other
+	// roles stay in the account of the cluster.
+	IAMManagementAccountRoleARN string
+	// DryRun logs mutating AWS API calls instead of making them.
+	DryRun bool
+	// MaxConcurrentReconciles is the number of AWSManagedControlPlanes
+	// reconciled in parallel.
+	MaxConcurrentReconciles int
+	// AWSCacheTTL is the duration IAM role ARN lookups are cached. Zero
+	// disables the cache.
+	AWSCacheTTL time.Duration
+	// WrapReconciler is optional. When set, it wraps the reconciler registered
+	// with the manager, e.g. to recover from panics.
+	WrapReconciler func(reconcile.Reconciler) reconcile.Reconciler
+
+	roleARNCaches roleARNCaches
 }
 
 func (r *AWSManagedControlPlaneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -59,6 +101,22 @@ func (r *AWSManagedControlPlaneReconciler) Reconcile(ctx context.Context, req ct
 		return ctrl.Result{}, microerror.Mask(err)
 	}
 
+	if finalizerRemovalTimedOut(eksCluster, r.FinalizerRemovalTimeout) {
+		var orphanedRoles []string
+		for _, roleType := range iam.IRSARoleTypes() {
+			orphanedRoles = append(orphanedRoles, iam.RoleName(roleType, clusterName))
+		}
+		logger.Info("Finalizer removal timeout exceeded, removing finalizer without deleting IAM resources, they may be orphaned", "timeout", r.FinalizerRemovalTimeout, "orphaned_roles", orphanedRoles)
+		record.Warnf(eksCluster, "FinalizerRemovalTimeout", "Removing finalizer after %s without deleting IAM resources, IAM roles %s may be orphaned", r.FinalizerRemovalTimeout, strings.Join(orphanedRoles, ", "))
+
+		err = removeFinalizer(ctx, r.Client, eksCluster, iam.IRSARole)
+		if err != nil {
+			logger.Error(err, "failed to remove finalizer on AWSManagedControlPlane")
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	if eksCluster.Spec.RoleName == nil {
 		logger.Info("AWSManagedControlPlane has empty .spec.RoleName, waiting for role creation")
 		return ctrl.Result{
@@ -67,29 +125,46 @@ func (r *AWSManagedControlPlaneReconciler) Reconcile(ctx context.Context, req ct
 		}, nil
 	}
 
-	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, eksCluster.Spec.IdentityRef.Name)
+	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, eksCluster.Spec.IdentityRef)
 	if err != nil {
 		logger.Error(err, "could not get AWSClusterRoleIdentity")
 		return ctrl.Result{}, microerror.Mask(err)
 	}
 
-	awsClientSession, err := r.AWSClient.GetAWSClientSession(awsClusterRoleIdentity.Spec.RoleArn, eksCluster.Spec.Region)
+	awsClientSession, err := r.AWSClient.GetAWSClientSession(key.GetIdentityRoleARN(awsClusterRoleIdentity), eksCluster.Spec.Region)
 	if err != nil {
 		logger.Error(err, "Failed to get aws client session")
 		return ctrl.Result{}, microerror.Mask(err)
 	}
 
+	iamManagementSession, iamManagementAccountID, err := getIAMManagementSession(r.AWSClient, r.IAMManagementAccountRoleARN, eksCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Failed to get aws client session for the IAM management account")
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
 	var iamService *iam.IAMService
 	{
 		c := iam.IAMServiceConfig{
-			AWSSession:       awsClientSession,
-			ClusterName:      clusterName,
-			MainRoleName:     *eksCluster.Spec.RoleName,
-			Log:              logger,
-			RoleType:         iam.IRSARole,
-			Region:           eksCluster.Spec.Region,
-			IAMClientFactory: r.IAMClientFactory,
-			CustomTags:       eksCluster.Spec.AdditionalTags,
+			AWSSession:             awsClientSession,
+			ClusterName:            clusterName,
+			MainRoleName:           *eksCluster.Spec.RoleName,
+			Log:                    logger,
+			RoleType:               iam.IRSARole,
+			Region:                 eksCluster.Spec.Region,
+			Partition:              awsclient.Partition(eksCluster.Spec.Region),
+			IAMClientFactory:       r.IAMClientFactory,
+			ConfigClientFactory:    r.ConfigClientFactory,
+			RolePath:               r.RolePath,
+			OwnershipTagKey:        r.OwnershipTagKey,
+			OwnershipTagValue:      r.OwnershipTagValue,
+			SkipInstanceProfiles:   r.SkipInstanceProfiles,
+			CustomTags:             eksCluster.Spec.AdditionalTags,
+			AuditSink:              r.AuditSink,
+			IAMManagementSession:   iamManagementSession,
+			IAMManagementAccountID: iamManagementAccountID,
+			DryRun:                 r.DryRun,
+			RoleARNCache:           r.roleARNCaches.get(awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, r.AWSCacheTTL),
 		}
 		iamService, err = iam.New(c)
 		if err != nil {
@@ -104,6 +179,11 @@ func (r *AWSManagedControlPlaneReconciler) Reconcile(ctx context.Context, req ct
 			return ctrl.Result{}, microerror.Mask(err)
 		}
 
+		err = removeIAMRoleReadyCondition(ctx, r.Client, eksCluster)
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+
 		err = removeFinalizer(ctx, r.Client, eksCluster, iam.IRSARole)
 		if err != nil {
 			logger.Error(err, "failed to remove finalizer on AWSManagedControlPlane")
@@ -126,41 +206,139 @@ func (r *AWSManagedControlPlaneReconciler) Reconcile(ctx context.Context, req ct
 			logger.Info("successfully added finalizer to AWSManagedControlPlane", "finalizer_name", iam.IRSARole)
 		}
 
-		accountID, err := key.GetAWSAccountID(awsClusterRoleIdentity)
-		if err != nil {
-			logger.Error(err, "Could not get account ID")
+		err = r.reconcileIRSARoles(ctx, iamService, eksCluster, awsClusterRoleIdentity, clusterName)
+		r.updateIAMStatus(ctx, iamService, eksCluster, awsClusterRoleIdentity, clusterName, err)
+		// invalid role names will not become valid by retrying, they are
+		// reported in the IAMRoleReady condition instead
+		if iam.IsInvalidRoleName(err) {
+			logger.Error(err, "refusing to reconcile IRSA role with invalid name")
+			record.Warnf(eksCluster, "InvalidRoleName", "IAM role name is invalid: %s", err)
+			return ctrl.Result{}, nil
+		} else if err != nil {
 			return ctrl.Result{}, microerror.Mask(err)
 		}
+	}
+
+	return ctrl.Result{
+		Requeue:      true,
+		RequeueAfter: 5 * time.Minute,
+	}, nil
+}
 
-		eksOpenIdDomain, err := iamService.GetIRSAOpenIDForEKS(eksCluster.Name)
+// reconcileIRSARoles makes sure the IRSA roles of the cluster exist and trust
+// the OIDC provider domains of the EKS cluster.
+func (r *AWSManagedControlPlaneReconciler) reconcileIRSARoles(ctx context.Context, iamService *iam.IAMService, eksCluster *eks.AWSManagedControlPlane, awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, clusterName string) error {
+	logger := log.FromContext(ctx)
+
+	accountID, err := getClusterAccountID(r.AWSClient, awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, eksCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Could not get account ID")
+		return microerror.Mask(err)
+	}
+
+	eksOpenIdDomain, err := iamService.GetIRSAOpenIDForEKS(eksCluster.Name)
+	if err != nil {
+		logger.Error(err, "failed to fetch EKS OpenConnectID URL")
+		return microerror.Mask(err)
+	}
+
+	eksRoleARN, err := iamService.GetRoleARN(*eksCluster.Spec.RoleName)
+	if err != nil {
+		logger.Error(err, "failed to fetch EKS role name ARN")
+
+		return microerror.Mask(err)
+	}
+
+	iamService.SetPrincipalRoleARN(eksRoleARN)
+
+	// additional domains are trusted e.g. while migrating to a new issuer
+	irsaTrustDomains, err := getIRSATrustDomains(ctx, r.Client, eksCluster, eksCluster, clusterName, eksOpenIdDomain)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = applyIRSAConfig(ctx, r.Client, iamService, eksCluster, eksCluster.Namespace, clusterName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = iamService.ReconcileRolesForIRSA(accountID, irsaTrustDomains)
+	var conditionErr error
+	if !r.DryRun {
+		// the roles are not ready in dry-run mode
+		conditionErr = setIAMRoleReadyCondition(ctx, r.Client, eksCluster, err)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if conditionErr != nil {
+		return microerror.Mask(conditionErr)
+	}
+
+	for _, roleName := range iamService.IRSARoleNames() {
+		err = iamService.ReconcileRoleTags(roleName)
 		if err != nil {
-			logger.Error(err, "failed to fetch EKS OpenConnectID URL")
-			return ctrl.Result{}, microerror.Mask(err)
+			return microerror.Mask(err)
 		}
+	}
 
-		eksRoleARN, err := iamService.GetRoleARN(*eksCluster.Spec.RoleName)
-		if err != nil {
-			logger.Error(err, "failed to fetch EKS role name ARN")
+	return nil
+}
 
-			return ctrl.Result{}, microerror.Mask(err)
-		}
+// updateIAMStatus records the result of the reconciliation of the IRSA roles
+// in the IAM status ConfigMap of the cluster. Nothing is recorded in dry-run
+// mode, as no roles are changed.
+func (r *AWSManagedControlPlaneReconciler) updateIAMStatus(ctx context.Context, iamService *iam.IAMService, eksCluster *eks.AWSManagedControlPlane, awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, clusterName string, reconcileErr error) {
+	if r.DryRun {
+		return
+	}
 
-		iamService.SetPrincipalRoleARN(eksRoleARN)
-		err = iamService.ReconcileRolesForIRSA(accountID, []string{eksOpenIdDomain})
+	update := iamStatusUpdate{
+		owner:        eksCluster,
+		clusterName:  clusterName,
+		reconcileErr: reconcileErr,
+	}
+
+	if reconcileErr == nil {
+		accountID, err := getClusterAccountID(r.AWSClient, awsClusterRoleIdentity, r.IAMManagementAccountRoleARN, eksCluster.Spec.Region)
 		if err != nil {
-			return ctrl.Result{}, microerror.Mask(err)
+			log.FromContext(ctx).Error(err, "failed to get account ID of IAM roles, not updating managed roles in IAM status ConfigMap")
+		} else {
+			update.addRoleARNs = roleARNs(iamService, accountID, iamService.IRSARoleNames())
 		}
 	}
 
-	return ctrl.Result{
-		Requeue:      true,
-		RequeueAfter: 5 * time.Minute,
-	}, nil
+	updateIAMStatus(ctx, r.Client, update)
+}
+
+// listClusterAWSManagedControlPlanes lists the AWSManagedControlPlanes of
+// the cluster.
+func (r *AWSManagedControlPlaneReconciler) listClusterAWSManagedControlPlanes(ctx context.Context, namespace, clusterName string) ([]client.Object, error) {
+	eksClusters := &eks.AWSManagedControlPlaneList{}
+	err := r.List(ctx,
+		eksClusters,
+		client.InNamespace(namespace),
+		client.MatchingLabels{key.ClusterNameLabel: clusterName},
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	objects := make([]client.Object, 0, len(eksClusters.Items))
+	for i := range eksClusters.Items {
+		objects = append(objects, &eksClusters.Items[i])
+	}
+	return objects, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AWSManagedControlPlaneReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&eks.AWSManagedControlPlane{}).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(
+			&iamv1alpha1.IRSAConfig{},
+			handler.EnqueueRequestsFromMapFunc(irsaConfigToObjects(r.listClusterAWSManagedControlPlanes)),
+		).
+		Complete(metrics.WrapReconciler("awsmanagedcontrolplane", mgr.GetClient(), func() client.Object { return &eks.AWSManagedControlPlane{} }, wrapReconciler(r, r.WrapReconciler)))
 }
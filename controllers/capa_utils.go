@@ -2,20 +2,40 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/giantswarm/microerror"
 	"github.com/pkg/errors"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
 	errutils "k8s.io/apimachinery/pkg/util/errors"
 	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	expcapa "sigs.k8s.io/cluster-api-provider-aws/v2/exp/api/v1beta2"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	iamv1alpha1 "github.com/giantswarm/capa-iam-operator/api/v1alpha1"
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+	"github.com/giantswarm/capa-iam-operator/pkg/cache"
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
 	"github.com/giantswarm/capa-iam-operator/pkg/key"
+	"github.com/giantswarm/capa-iam-operator/pkg/record"
 )
 
 const maxPatchAttempts = 5
@@ -35,6 +55,9 @@ func isRoleUsedElsewhere(ctx context.Context, ctrlClient client.Client, roleName
 		if mt.DeletionTimestamp == nil && mt.Spec.Template.Spec.IAMInstanceProfile == roleName {
 			return true, err
 		}
+		if mt.DeletionTimestamp == nil && slices.Contains(extraRoleNames(&mt), roleName) {
+			return true, err
+		}
 	}
 
 	var awsMachinePools expcapa.AWSMachinePoolList
@@ -54,6 +77,206 @@ func isRoleUsedElsewhere(ctx context.Context, ctrlClient client.Client, roleName
 	return false, err
 }
 
+// extraRoleNames returns the names of the IAM roles created for the extra role
+// types of the AWSMachineTemplate.
+func extraRoleNames(awsMachineTemplate *capa.AWSMachineTemplate) []string {
+	clusterName := awsMachineTemplate.Labels[key.ClusterNameLabel]
+
+	var roleNames []string
+	for _, roleType := range key.GetExtraRoleTypes(awsMachineTemplate) {
+		roleNames = append(roleNames, iam.RoleName(roleType, clusterName))
+	}
+	return roleNames
+}
+
+// getIAMManagementSession returns a session assuming the role of the IAM
+// management account together with the ID of the account, or nil if no such
+// role is configured.
+func getIAMManagementSession(awsClient awsclient.AwsClientInterface, roleARN, region string) (awsclientgo.ConfigProvider, string, error) {
+	if roleARN == "" {
+		return nil, "", nil
+	}
+
+	a, err := awsarn.Parse(roleARN)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	session, err := awsClient.GetAWSClientSession(roleARN, region)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, a.AccountID, nil
+}
+
+// getClusterAccountID returns the AWS account ID of the cluster. When the IAM
+// roles are managed in a separate IAM management account, it is looked up
+// with the cluster session, since the AWSClusterRoleIdentity may belong to
+// another account. The same applies to clusters using the controller's own
+// credentials, which have no AWSClusterRoleIdentity.
+func getClusterAccountID(awsClient awsclient.AwsClientInterface, awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, iamManagementAccountRoleARN, region string) (string, error) {
+	if iamManagementAccountRoleARN == "" && awsClusterRoleIdentity != nil {
+		return key.GetAWSAccountID(awsClusterRoleIdentity)
+	}
+
+	identity, err := awsClient.GetCallerIdentity(key.GetIdentityRoleARN(awsClusterRoleIdentity), region)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	if identity.Account == nil {
+		return "", errors.Errorf("caller identity of role %q does not have an account", key.GetIdentityRoleARN(awsClusterRoleIdentity))
+	}
+
+	return *identity.Account, nil
+}
+
+// getIRSAConfig returns the IRSAConfig named after the cluster, or nil if
+// there is none or the IRSAConfig CRD is not installed.
+func getIRSAConfig(ctx context.Context, k8sClient client.Client, namespace, clusterName string) (*iamv1alpha1.IRSAConfig, error) {
+	irsaConfig := &iamv1alpha1.IRSAConfig{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: clusterName}, irsaConfig)
+	if k8serrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return irsaConfig, nil
+}
+
+// getIRSATrustDomains returns the OIDC provider domains the IRSA roles of the
+// cluster trust, starting with irsaDomain. The additional domains are taken
+// from the IRSAConfig of the cluster, falling back to the annotations of the
+// cluster and of obj when there is none, so that all controllers writing the
+// IRSA roles agree on them.
+func getIRSATrustDomains(ctx context.Context, k8sClient client.Client, obj, cluster client.Object, clusterName, irsaDomain string) ([]string, error) {
+	irsaConfig, err := getIRSAConfig(ctx, k8sClient, cluster.GetNamespace(), clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if irsaConfig != nil {
+		return key.MergeIRSATrustDomains(irsaDomain, irsaConfig.Spec.TrustDomains), nil
+	}
+
+	return key.GetIRSATrustDomains(obj, cluster, irsaDomain), nil
+}
+
+// applyIRSAConfig configures the IRSA roles of iamService with the service
+// accounts, hosted zones and extra trust policy conditions of the IRSAConfig
+// of the cluster. Invalid settings are reported as warning events on obj and
+// skipped.
+func applyIRSAConfig(ctx context.Context, k8sClient client.Client, iamService *iam.IAMService, obj client.Object, namespace, clusterName string) error {
+	irsaConfig, err := getIRSAConfig(ctx, k8sClient, namespace, clusterName)
+	if err != nil {
+		return err
+	}
+	if irsaConfig == nil {
+		return nil
+	}
+
+	for _, serviceAccount := range irsaConfig.Spec.ServiceAccounts {
+		saNamespace := serviceAccount.Namespace
+		if saNamespace == "" {
+			saNamespace = "kube-system"
+		}
+		err = iamService.SetIRSAServiceAccount(serviceAccount.RoleType, saNamespace, serviceAccount.Name)
+		if iam.IsInvalidIRSARoleType(err) {
+			record.Warnf(obj, "InvalidIRSAConfig", "IRSAConfig %s/%s: %s", irsaConfig.Namespace, irsaConfig.Name, err)
+			continue
+		} else if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	err = iamService.SetIRSAHostedZoneIDs(irsaConfig.Spec.HostedZoneIDs)
+	if iam.IsInvalidIRSAConfig(err) {
+		record.Warnf(obj, "InvalidIRSAConfig", "IRSAConfig %s/%s: %s", irsaConfig.Namespace, irsaConfig.Name, err)
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var extraConditions []iam.TrustPolicyCondition
+	for _, condition := range irsaConfig.Spec.ExtraConditions {
+		extraConditions = append(extraConditions, iam.TrustPolicyCondition{
+			Operator: condition.Operator,
+			Key:      condition.Key,
+			Values:   condition.Values,
+		})
+	}
+	err = iamService.SetIRSAExtraConditions(extraConditions)
+	if iam.IsInvalidIRSAConfig(err) {
+		record.Warnf(obj, "InvalidIRSAConfig", "IRSAConfig %s/%s: %s", irsaConfig.Namespace, irsaConfig.Name, err)
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// wrapReconciler returns r wrapped by wrapper, or r itself if wrapper is nil.
+func wrapReconciler(r reconcile.Reconciler, wrapper func(reconcile.Reconciler) reconcile.Reconciler) reconcile.Reconciler {
+	if wrapper == nil {
+		return r
+	}
+	return wrapper(r)
+}
+
+// irsaConfigToObjects returns a handler that maps an IRSAConfig to the
+// objects of the cluster it is named after, which list returns.
+func irsaConfigToObjects(list func(ctx context.Context, namespace, clusterName string) ([]client.Object, error)) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		logger := log.FromContext(ctx)
+
+		objects, err := list(ctx, o.GetNamespace(), o.GetName())
+		if err != nil {
+			logger.Error(err, "failed to list objects of IRSAConfig", "irsaConfig", client.ObjectKeyFromObject(o))
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, object := range objects {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(object)})
+		}
+		return requests
+	}
+}
+
+// isClusterDeleted returns whether the CAPI Cluster with the given name is
+// being deleted or is already gone.
+func isClusterDeleted(ctx context.Context, k8sClient client.Client, namespace, clusterName string) (bool, error) {
+	cluster := &capi.Cluster{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: clusterName}, cluster)
+	if k8serrors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return cluster.DeletionTimestamp != nil, nil
+}
+
+// roleARNCaches holds a cache of IAM role ARN lookups per combination of IAM
+// sessions. Role names are only unique within an AWS account, so the roles of
+// different accounts must not share a cache.
+type roleARNCaches struct {
+	caches sync.Map
+}
+
+// get returns the cache of the IAM sessions of the cluster, which are the
+// session of the cluster and the one of the IAM management account if
+// configured.
+func (c *roleARNCaches) get(awsClusterRoleIdentity *capa.AWSClusterRoleIdentity, iamManagementAccountRoleARN string, ttl time.Duration) *cache.TTLCache[string] {
+	sessionRoleARNs := key.GetIdentityRoleARN(awsClusterRoleIdentity) + "," + iamManagementAccountRoleARN
+
+	// Load first, so that no cache is allocated on every reconciliation
+	roleARNCache, ok := c.caches.Load(sessionRoleARNs)
+	if !ok {
+		roleARNCache, _ = c.caches.LoadOrStore(sessionRoleARNs, cache.NewTTLCache[string](ttl))
+	}
+	return roleARNCache.(*cache.TTLCache[string])
+}
+
 func removeFinalizer(ctx context.Context, k8sClient client.Client, object client.Object, role string) error {
 	logger := log.FromContext(ctx)
 
@@ -82,7 +305,7 @@ func removeFinalizer(ctx context.Context, k8sClient client.Client, object client
 		})
 
 		if invalidErr != nil && i < maxPatchAttempts {
-			logger.Info(fmt.Sprintf("patching object failed, trying again: %s", err.Error()))
+			logger.Info("patching object failed, trying again", "error", err.Error(), "attempt", i)
 			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
 				return microerror.Mask(err)
 			}
@@ -99,3 +322,183 @@ func removeFinalizer(ctx context.Context, k8sClient client.Client, object client
 
 	return fmt.Errorf("failed to remove finalizer after %d retries", maxPatchAttempts)
 }
+
+// conditionsObject is an object with CAPI conditions.
+type conditionsObject interface {
+	client.Object
+	conditions.Setter
+}
+
+// setIAMRoleReadyCondition sets the IAMRoleReady condition of the object to
+// true if reconcileErr is nil and to false with the error as message
+// otherwise. Invalid role names are reported with their own reason, as they
+// need to be fixed by the user.
+func setIAMRoleReadyCondition(ctx context.Context, k8sClient client.Client, object conditionsObject, reconcileErr error) error {
+	logger := log.FromContext(ctx)
+
+	patchHelper, err := patch.NewHelper(object, k8sClient)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if reconcileErr == nil {
+		conditions.MarkTrue(object, key.IAMRoleReadyCondition)
+	} else if iam.IsInvalidRoleName(reconcileErr) {
+		conditions.MarkFalse(object, key.IAMRoleReadyCondition, key.InvalidRoleNameReason, capi.ConditionSeverityError, "%s", reconcileErr)
+	} else {
+		conditions.MarkFalse(object, key.IAMRoleReadyCondition, key.IAMRoleReconcileErrorReason, capi.ConditionSeverityError, "%s", reconcileErr)
+	}
+
+	err = patchHelper.Patch(ctx, object, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.IAMRoleReadyCondition}})
+	if err != nil {
+		logger.Error(err, "failed to update condition", "condition", key.IAMRoleReadyCondition)
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// removeIAMRoleReadyCondition removes the IAMRoleReady condition of the
+// object once its IAM roles are deleted.
+func removeIAMRoleReadyCondition(ctx context.Context, k8sClient client.Client, object conditionsObject) error {
+	logger := log.FromContext(ctx)
+
+	if !conditions.Has(object, key.IAMRoleReadyCondition) {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(object, k8sClient)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	conditions.Delete(object, key.IAMRoleReadyCondition)
+	err = patchHelper.Patch(ctx, object, patch.WithOwnedConditions{Conditions: []capi.ConditionType{key.IAMRoleReadyCondition}})
+	if err != nil {
+		logger.Error(err, "failed to remove condition", "condition", key.IAMRoleReadyCondition)
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// finalizerRemovalTimedOut returns true if the object has been terminating for
+// longer than timeout. A timeout of zero disables the check.
+func finalizerRemovalTimedOut(object client.Object, timeout time.Duration) bool {
+	deletionTimestamp := object.GetDeletionTimestamp()
+	if timeout <= 0 || deletionTimestamp == nil {
+		return false
+	}
+
+	return time.Since(deletionTimestamp.Time) > timeout
+}
+
+// reconcileInputs returns a fingerprint of the objects besides the reconciled
+// object which the IAM roles depend on: the spec and annotations of the
+// AWSCluster and the spec of the IRSAConfig of the cluster. The IRSA role ARN
+// annotations are left out, as the operator writes them itself.
+func reconcileInputs(awsCluster *capa.AWSCluster, irsaConfig *iamv1alpha1.IRSAConfig) (string, error) {
+	inputs := struct {
+		AWSClusterGeneration  int64             `json:"awsClusterGeneration"`
+		AWSClusterAnnotations map[string]string `json:"awsClusterAnnotations"`
+		IRSAConfigGeneration  int64             `json:"irsaConfigGeneration"`
+	}{
+		AWSClusterGeneration:  awsCluster.Generation,
+		AWSClusterAnnotations: map[string]string{},
+	}
+	for annotation, value := range awsCluster.Annotations {
+		if !key.IsIRSARoleARNAnnotation(annotation) {
+			inputs.AWSClusterAnnotations[annotation] = value
+		}
+	}
+	if irsaConfig != nil {
+		inputs.IRSAConfigGeneration = irsaConfig.Generation
+	}
+
+	// map keys are sorted by json.Marshal, so the fingerprint is stable
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// reconciledRecently returns true if the object was fully reconciled less than
+// minAge ago and neither its generation nor the given inputs changed since
+// then. A minAge of zero disables the check.
+func reconciledRecently(object client.Object, inputs string, minAge time.Duration) bool {
+	if minAge <= 0 {
+		return false
+	}
+
+	lastSuccess := key.GetAnnotation(object, key.LastReconcileSuccessAnnotation)
+	if lastSuccess == "" {
+		return false
+	}
+	lastSuccessTime, err := time.Parse(time.RFC3339, lastSuccess)
+	if err != nil {
+		return false
+	}
+
+	if key.GetAnnotation(object, key.LastReconcileGenerationAnnotation) != strconv.FormatInt(object.GetGeneration(), 10) {
+		return false
+	}
+	if key.GetAnnotation(object, key.LastReconcileInputsAnnotation) != inputs {
+		return false
+	}
+
+	return time.Since(lastSuccessTime) < minAge
+}
+
+// policyDriftCheckDue returns true if the policy drift check of the object
+// did not run within the last interval. An interval of zero disables the
+// check.
+func policyDriftCheckDue(object client.Object, interval time.Duration) bool {
+	if interval <= 0 {
+		return false
+	}
+
+	lastCheckTime, err := time.Parse(time.RFC3339, key.GetAnnotation(object, key.LastPolicyDriftCheckAnnotation))
+	if err != nil {
+		return true
+	}
+
+	return time.Since(lastCheckTime) >= interval
+}
+
+// markReconcileSuccess records the time, generation and inputs of a
+// successful full reconciliation on the object.
+func markReconcileSuccess(ctx context.Context, k8sClient client.Client, object client.Object, inputs string) error {
+	patchHelper, err := patch.NewHelper(object, k8sClient)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key.LastReconcileSuccessAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	annotations[key.LastReconcileGenerationAnnotation] = strconv.FormatInt(object.GetGeneration(), 10)
+	annotations[key.LastReconcileInputsAnnotation] = inputs
+	object.SetAnnotations(annotations)
+
+	return errors.WithStack(patchHelper.Patch(ctx, object))
+}
+
+// patchAnnotations sets the given annotations on the object with a JSON merge
+// patch, which only touches the given keys. A nil value removes the
+// annotation.
+func patchAnnotations(ctx context.Context, k8sClient client.Client, object client.Object, annotations map[string]*string) error {
+	patchData, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(k8sClient.Patch(ctx, object, client.RawPatch(types.MergePatchType, patchData)))
+}
@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+// clusterGCFinalizerRole is the role part of the finalizer name the
+// ClusterReconciler adds to clusters.
+const clusterGCFinalizerRole = "cluster-gc"
+
+// clusterGCMinRequeueAfter and clusterGCMaxRequeueAfter bound how often the
+// roles of a terminating cluster are garbage collected while its AWSCluster
+// still exists, see clusterGCRequeueAfter.
+const (
+	clusterGCMinRequeueAfter = time.Minute
+	clusterGCMaxRequeueAfter = 15 * time.Minute
+)
+
+// awsClusterRequeueAfter is how long the IAM roles of a cluster wait for its
+// AWSCluster to be created.
+const awsClusterRequeueAfter = time.Minute
+
+// ClusterReconciler garbage collects the IAM roles of deleted clusters when
+// EnableClusterGC is set. The roles are normally deleted by the finalizers of
+// the AWSMachineTemplates, which do not run if the templates are removed
+// without them, e.g. when their namespace is force-deleted. While a cluster is
+// terminating, all roles tagged as owned by the cluster which are not used by
+// another object are deleted, until the AWSCluster is gone.
+//
+// The ClusterReconciler also manages the read-only role of clusters, which is
+// not tied to any machine template and deleted together with the cluster, and
+// creates the service-linked roles needed by CAPA in the cluster account.
+type ClusterReconciler struct {
+	client.Client
+	AWSClient        awsclient.AwsClientInterface
+	IAMClientFactory func(awsclientgo.ConfigProvider, string) iamiface.IAMAPI
+	// ConfigClientFactory enables the AWS Config rule integration when set.
+	ConfigClientFactory iam.ConfigClientFactory
+	// RolePath is the IAM path of created roles and instance profiles.
+	RolePath string
+	// OwnershipTagKey and OwnershipTagValue form the tag marking the IAM
+	// resources created by the operator.
+	OwnershipTagKey   string
+	OwnershipTagValue string
+	// SkipInstanceProfiles leaves instance profiles to be managed externally.
+	SkipInstanceProfiles bool
+	// AuditSink receives an audit event for every IAM mutation when set.
+	AuditSink audit.Sink
+	// IAMManagementAccountRoleARN is assumed to manage the IRSA roles when
+	// set, so they are kept in a separate IAM management account. The other
+	// roles stay in the account of the cluster.
+	IAMManagementAccountRoleARN string
+	// DryRun logs mutating AWS API calls instead of making them.
+	DryRun bool
+	// EnableReadOnlyRole creates a read-only role for every cluster which is
+	// trusted by ReadOnlyRoleTrustedPrincipal.
+	EnableReadOnlyRole           bool
+	ReadOnlyRoleTrustedPrincipal string
+	// CreateServiceLinkedRoles creates the service-linked roles needed by
+	// CAPA in the account of every cluster.
+	CreateServiceLinkedRoles bool
+	// EnableClusterGC adds a finalizer to every Cluster with an AWSCluster to
+	// garbage collect the IAM roles of the cluster while it terminates.
+	EnableClusterGC bool
+	// WrapReconciler is optional. When set, it wraps the reconciler registered
+	// with the manager, e.g. to recover from panics.
+	WrapReconciler func(reconcile.Reconciler) reconcile.Reconciler
+}
+
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters/finalizers,verbs=update
+
+func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	cluster := &capi.Cluster{}
+	if err := r.Get(ctx, req.NamespacedName, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			// the cluster is gone, its metrics are not updated anymore
+			metrics.DeleteClusterSeries(req.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	infrastructureRef := cluster.Spec.InfrastructureRef
+	if infrastructureRef == nil || infrastructureRef.Kind != "AWSCluster" {
+		// Only clusters with an AWSCluster have roles managed by the
+		// AWSMachineTemplate reconciler.
+		if !controllerutil.ContainsFinalizer(cluster, key.FinalizerName(clusterGCFinalizerRole)) {
+			return ctrl.Result{}, nil
+		}
+		err := removeFinalizer(ctx, r.Client, cluster, clusterGCFinalizerRole)
+		if err != nil {
+			logger.Error(err, "failed to remove finalizer on Cluster")
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if cluster.DeletionTimestamp == nil {
+		if r.needsFinalizer() && !controllerutil.ContainsFinalizer(cluster, key.FinalizerName(clusterGCFinalizerRole)) {
+			patchHelper, err := patch.NewHelper(cluster, r.Client)
+			if err != nil {
+				return ctrl.Result{}, microerror.Mask(err)
+			}
+			controllerutil.AddFinalizer(cluster, key.FinalizerName(clusterGCFinalizerRole))
+			err = patchHelper.Patch(ctx, cluster)
+			if err != nil {
+				logger.Error(err, "failed to add finalizer on Cluster")
+				return ctrl.Result{}, microerror.Mask(err)
+			}
+			logger.Info("successfully added finalizer to Cluster", "finalizer_name", clusterGCFinalizerRole)
+		} else if !r.needsFinalizer() && controllerutil.ContainsFinalizer(cluster, key.FinalizerName(clusterGCFinalizerRole)) {
+			// the finalizer was added before garbage collection was disabled
+			err := removeFinalizer(ctx, r.Client, cluster, clusterGCFinalizerRole)
+			if err != nil {
+				logger.Error(err, "failed to remove finalizer on Cluster")
+				return ctrl.Result{}, microerror.Mask(err)
+			}
+		}
+		if r.EnableReadOnlyRole || r.CreateServiceLinkedRoles {
+			return r.reconcileNormal(ctx, cluster)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(cluster, key.FinalizerName(clusterGCFinalizerRole)) {
+		return ctrl.Result{}, nil
+	}
+
+	awsCluster := &capa.AWSCluster{}
+	err := r.Get(ctx, types.NamespacedName{Name: infrastructureRef.Name, Namespace: cluster.Namespace}, awsCluster)
+	if apierrors.IsNotFound(err) {
+		// Without the AWSCluster there is nothing left to garbage collect
+		// the roles with.
+		err = removeFinalizer(ctx, r.Client, cluster, clusterGCFinalizerRole)
+		if err != nil {
+			logger.Error(err, "failed to remove finalizer on Cluster")
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	if !r.EnableClusterGC {
+		return r.reconcileDelete(ctx, cluster, awsCluster)
+	}
+
+	err = r.deleteOrphanedRoles(ctx, cluster, awsCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: clusterGCRequeueAfter(cluster)}, nil
+}
+
+// needsFinalizer returns whether the finalizer is added to clusters, so that
+// the roles of the cluster are deleted when it terminates.
+func (r *ClusterReconciler) needsFinalizer() bool {
+	return r.EnableClusterGC || r.EnableReadOnlyRole
+}
+
+// clusterGCRequeueAfter returns when the roles of the terminating cluster are
+// garbage collected next. The interval grows with the time the cluster has
+// been terminating, since the roles are normally deleted by the finalizers of
+// the templates early on, and is bounded by clusterGCMinRequeueAfter and
+// clusterGCMaxRequeueAfter.
+func clusterGCRequeueAfter(cluster *capi.Cluster) time.Duration {
+	requeueAfter := time.Since(cluster.DeletionTimestamp.Time)
+	if requeueAfter < clusterGCMinRequeueAfter {
+		return clusterGCMinRequeueAfter
+	}
+	if requeueAfter > clusterGCMaxRequeueAfter {
+		return clusterGCMaxRequeueAfter
+	}
+	return requeueAfter
+}
+
+// reconcileDelete deletes the read-only role of the terminating cluster when
+// garbage collection is disabled and removes the finalizer.
+func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *capi.Cluster, awsCluster *capa.AWSCluster) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if r.EnableReadOnlyRole {
+		iamService, err := r.newIAMService(ctx, cluster, awsCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		err = iamService.DeleteReadOnlyRole()
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+	}
+
+	err := removeFinalizer(ctx, r.Client, cluster, clusterGCFinalizerRole)
+	if err != nil {
+		logger.Error(err, "failed to remove finalizer on Cluster")
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deleteOrphanedRoles deletes the IAM roles owned by the cluster which are
+// not used by an AWSMachineTemplate or AWSMachinePool.
+func (r *ClusterReconciler) deleteOrphanedRoles(ctx context.Context, cluster *capi.Cluster, awsCluster *capa.AWSCluster) error {
+	logger := log.FromContext(ctx)
+
+	iamService, err := r.newIAMService(ctx, cluster, awsCluster)
+	if err != nil {
+		return err
+	}
+
+	roleNames, err := iamService.ListClusterRoles()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, roleName := range roleNames {
+		roleUsed, err := isRoleUsedElsewhere(ctx, r.Client, roleName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if roleUsed {
+			continue
+		}
+
+		logger.Info("deleting orphaned IAM role of terminating cluster", "role_name", roleName)
+		err = iamService.DeleteRoleByName(roleName)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// reconcileNormal makes sure the service-linked roles of the cluster account
+// and the read-only role of the cluster exist, depending on which of them are
+// enabled.
+func (r *ClusterReconciler) reconcileNormal(ctx context.Context, cluster *capi.Cluster) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	awsCluster := &capa.AWSCluster{}
+	err := r.Get(ctx, types.NamespacedName{Name: cluster.Spec.InfrastructureRef.Name, Namespace: cluster.Namespace}, awsCluster)
+	if apierrors.IsNotFound(err) {
+		logger.Info("AWSCluster does not exist yet, waiting to reconcile IAM roles")
+		return ctrl.Result{RequeueAfter: awsClusterRequeueAfter}, nil
+	} else if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	iamService, err := r.newIAMService(ctx, cluster, awsCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// CAPA needs the service-linked roles as soon as it creates the VPC,
+	// so they are created first
+	if r.CreateServiceLinkedRoles {
+		err = iamService.CreateServiceLinkedRoles()
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+	}
+
+	if r.EnableReadOnlyRole {
+		err = r.reconcileReadOnlyRole(iamService, cluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileReadOnlyRole makes sure the read-only role of the cluster exists.
+// It is deleted once the cluster terminates, by deleteOrphanedRoles when
+// garbage collection is enabled.
+func (r *ClusterReconciler) reconcileReadOnlyRole(iamService *iam.IAMService, cluster *capi.Cluster) error {
+	err := iamService.ReconcileReadOnlyRole(r.ReadOnlyRoleTrustedPrincipal)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = iamService.ReconcileRoleTags(iam.RoleName(iam.ReadOnlyRole, cluster.Name))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// newIAMService returns an IAM service for the cluster-wide roles of the
+// cluster.
+func (r *ClusterReconciler) newIAMService(ctx context.Context, cluster *capi.Cluster, awsCluster *capa.AWSCluster) (*iam.IAMService, error) {
+	logger := log.FromContext(ctx)
+
+	awsClusterRoleIdentity, err := key.GetAWSClusterRoleIdentity(ctx, r.Client, awsCluster.Spec.IdentityRef)
+	if err != nil {
+		logger.Error(err, "could not get AWSClusterRoleIdentity")
+		return nil, microerror.Mask(err)
+	}
+
+	awsClientSession, err := r.AWSClient.GetAWSClientSession(key.GetIdentityRoleARN(awsClusterRoleIdentity), awsCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Failed to get aws client session")
+		return nil, microerror.Mask(err)
+	}
+
+	iamManagementSession, iamManagementAccountID, err := getIAMManagementSession(r.AWSClient, r.IAMManagementAccountRoleARN, awsCluster.Spec.Region)
+	if err != nil {
+		logger.Error(err, "Failed to get aws client session for the IAM management account")
+		return nil, microerror.Mask(err)
+	}
+
+	c := iam.IAMServiceConfig{
+		AWSSession: awsClientSession,
+		// The service only manages cluster-wide roles, the main role name is
+		// not used.
+		ClusterName:            cluster.Name,
+		MainRoleName:           cluster.Name,
+		Log:                    logger,
+		RoleType:               iam.ControlPlaneRole,
+		Region:                 awsCluster.Spec.Region,
+		Partition:              awsclient.Partition(awsCluster.Spec.Region),
+		IAMClientFactory:       r.IAMClientFactory,
+		ConfigClientFactory:    r.ConfigClientFactory,
+		RolePath:               r.RolePath,
+		OwnershipTagKey:        r.OwnershipTagKey,
+		OwnershipTagValue:      r.OwnershipTagValue,
+		SkipInstanceProfiles:   r.SkipInstanceProfiles,
+		CustomTags:             awsCluster.Spec.AdditionalTags,
+		AuditSink:              r.AuditSink,
+		IAMManagementSession:   iamManagementSession,
+		IAMManagementAccountID: iamManagementAccountID,
+		DryRun:                 r.DryRun,
+	}
+	iamService, err := iam.New(c)
+	if err != nil {
+		logger.Error(err, "Failed to generate IAM service")
+		return nil, microerror.Mask(err)
+	}
+
+	return iamService, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&capi.Cluster{}).
+		Complete(metrics.WrapReconciler("cluster", mgr.GetClient(), func() client.Object { return &capi.Cluster{} }, wrapReconciler(r, r.WrapReconciler)))
+}
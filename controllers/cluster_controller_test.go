@@ -0,0 +1,406 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsclientupstream "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/giantswarm/capa-iam-operator/controllers"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("ClusterReconciler", func() {
+	var (
+		ctx           context.Context
+		mockCtrl      *gomock.Controller
+		mockAwsClient *mocks.MockAwsClientInterface
+		mockIAMClient *mocks.MockIAMAPI
+		reconciler    *controllers.ClusterReconciler
+		req           ctrl.Request
+		namespace     string
+		cluster       *capi.Cluster
+	)
+
+	const finalizer = "capa-iam-operator.finalizers.giantswarm.io/cluster-gc"
+
+	SetupNamespaceBeforeAfterEach(&namespace)
+
+	BeforeEach(func() {
+		logger := zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true))
+		ctx = log.IntoContext(context.Background(), logger)
+
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockAwsClient = mocks.NewMockAwsClientInterface(mockCtrl)
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		reconciler = &controllers.ClusterReconciler{
+			Client:    k8sClient,
+			AWSClient: mockAwsClient,
+			IAMClientFactory: func(session awsclientupstream.ConfigProvider, region string) iamiface.IAMAPI {
+				return mockIAMClient
+			},
+		}
+
+		cluster = &capi.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: namespace,
+			},
+			Spec: capi.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: capa.GroupVersion.String(),
+					Kind:       "AWSCluster",
+					Name:       "test-cluster",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+		req = ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      "test-cluster",
+				Namespace: namespace,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("adds the finalizer to the Cluster when garbage collection is enabled", func() {
+		reconciler.EnableClusterGC = true
+
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, req.NamespacedName, cluster)).To(Succeed())
+		Expect(cluster.Finalizers).To(ContainElement(finalizer))
+	})
+
+	It("does not add the finalizer when garbage collection is disabled", func() {
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, req.NamespacedName, cluster)).To(Succeed())
+		Expect(cluster.Finalizers).NotTo(ContainElement(finalizer))
+	})
+
+	It("removes the finalizer added before garbage collection was disabled", func() {
+		cluster.Finalizers = []string{finalizer}
+		Expect(k8sClient.Update(ctx, cluster)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, req.NamespacedName, cluster)).To(Succeed())
+		Expect(cluster.Finalizers).NotTo(ContainElement(finalizer))
+	})
+
+	When("the Cluster does not use an AWSCluster", func() {
+		BeforeEach(func() {
+			cluster.Spec.InfrastructureRef.Kind = "AWSManagedCluster"
+			Expect(k8sClient.Update(ctx, cluster)).To(Succeed())
+		})
+
+		It("does not add the finalizer", func() {
+			_, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, req.NamespacedName, cluster)).To(Succeed())
+			Expect(cluster.Finalizers).NotTo(ContainElement(finalizer))
+		})
+	})
+
+	When("the read-only role is enabled", func() {
+		BeforeEach(func() {
+			reconciler.EnableReadOnlyRole = true
+			reconciler.ReadOnlyRoleTrustedPrincipal = "arn:aws:iam::012345678901:role/sre"
+		})
+
+		When("the AWSCluster does not exist yet", func() {
+			It("waits for the AWSCluster without calling AWS", func() {
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).NotTo(BeZero())
+			})
+		})
+
+		When("the AWSCluster exists", func() {
+			var trustPolicy string
+
+			BeforeEach(func() {
+				Expect(k8sClient.Create(ctx, &capa.AWSClusterRoleIdentity{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-gc",
+					},
+					Spec: capa.AWSClusterRoleIdentitySpec{
+						AWSRoleSpec: capa.AWSRoleSpec{
+							RoleArn: "arn:aws:iam::012345678901:role/giantswarm-test-capa-controller",
+						},
+						AWSClusterIdentitySpec: capa.AWSClusterIdentitySpec{
+							AllowedNamespaces: &capa.AllowedNamespaces{},
+						},
+					},
+				})).To(Or(Succeed(), MatchError(ContainSubstring("already exists"))))
+
+				Expect(k8sClient.Create(ctx, &capa.AWSCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							"cluster.x-k8s.io/cluster-name": "test-cluster",
+						},
+						Name:      "test-cluster",
+						Namespace: namespace,
+					},
+					Spec: capa.AWSClusterSpec{
+						IdentityRef: &capa.AWSIdentityReference{
+							Name: "test-gc",
+							Kind: "AWSClusterRoleIdentity",
+						},
+						Region: "eu-west-1",
+					},
+				})).To(Succeed())
+
+				sess, err := session.NewSession(&aws.Config{
+					Region: aws.String("eu-west-1")},
+				)
+				Expect(err).NotTo(HaveOccurred())
+				mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+
+				mockIAMClient.EXPECT().GetRole(&iam.GetRoleInput{
+					RoleName: aws.String("test-cluster-ReadOnly"),
+				}).Return(&iam.GetRoleOutput{Role: &iam.Role{}}, nil)
+				mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+					trustPolicy = *input.PolicyDocument
+					return &iam.UpdateAssumeRolePolicyOutput{}, nil
+				})
+				mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "unit test", nil))
+				mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&iam.PutRolePolicyOutput{}, nil)
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String("test-cluster-ReadOnly"),
+				}).Return(&iam.ListRoleTagsOutput{}, nil)
+				mockIAMClient.EXPECT().ListInstanceProfileTags(&iam.ListInstanceProfileTagsInput{
+					InstanceProfileName: aws.String("test-cluster-ReadOnly"),
+				}).Return(&iam.ListInstanceProfileTagsOutput{}, nil)
+			})
+
+			It("reconciles the read-only role trusting the configured principal", func() {
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(BeZero())
+
+				Expect(trustPolicy).To(ContainSubstring(`"AWS": "arn:aws:iam::012345678901:role/sre"`))
+			})
+		})
+	})
+
+	When("creating service-linked roles is enabled", func() {
+		var createdRoles []string
+
+		BeforeEach(func() {
+			reconciler.CreateServiceLinkedRoles = true
+
+			Expect(k8sClient.Create(ctx, &capa.AWSClusterRoleIdentity{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-gc",
+				},
+				Spec: capa.AWSClusterRoleIdentitySpec{
+					AWSRoleSpec: capa.AWSRoleSpec{
+						RoleArn: "arn:aws:iam::012345678901:role/giantswarm-test-capa-controller",
+					},
+					AWSClusterIdentitySpec: capa.AWSClusterIdentitySpec{
+						AllowedNamespaces: &capa.AllowedNamespaces{},
+					},
+				},
+			})).To(Or(Succeed(), MatchError(ContainSubstring("already exists"))))
+
+			Expect(k8sClient.Create(ctx, &capa.AWSCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"cluster.x-k8s.io/cluster-name": "test-cluster",
+					},
+					Name:      "test-cluster",
+					Namespace: namespace,
+				},
+				Spec: capa.AWSClusterSpec{
+					IdentityRef: &capa.AWSIdentityReference{
+						Name: "test-gc",
+						Kind: "AWSClusterRoleIdentity",
+					},
+					Region: "eu-west-1",
+				},
+			})).To(Succeed())
+
+			sess, err := session.NewSession(&aws.Config{
+				Region: aws.String("eu-west-1")},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+
+			createdRoles = nil
+			mockIAMClient.EXPECT().CreateServiceLinkedRole(gomock.Any()).DoAndReturn(func(input *iam.CreateServiceLinkedRoleInput) (*iam.CreateServiceLinkedRoleOutput, error) {
+				if *input.AWSServiceName == "elasticloadbalancing.amazonaws.com" {
+					return nil, awserr.New(iam.ErrCodeInvalidInputException, "Service role name AWSServiceRoleForElasticLoadBalancing has been taken in this account, please try a different suffix.", nil)
+				}
+				createdRoles = append(createdRoles, *input.AWSServiceName)
+				return &iam.CreateServiceLinkedRoleOutput{}, nil
+			}).Times(3)
+		})
+
+		It("creates the missing service-linked roles", func() {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(createdRoles).To(ConsistOf("autoscaling.amazonaws.com", "transitgateway.amazonaws.com"))
+		})
+	})
+
+	When("the Cluster is deleted", func() {
+		BeforeEach(func() {
+			reconciler.EnableClusterGC = true
+			_, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Delete(ctx, cluster)).To(Succeed())
+		})
+
+		When("the AWSCluster is gone", func() {
+			It("removes the finalizer without calling AWS", func() {
+				_, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = k8sClient.Get(ctx, req.NamespacedName, cluster)
+				if err == nil {
+					Expect(cluster.Finalizers).NotTo(ContainElement(finalizer))
+				} else {
+					Expect(client.IgnoreNotFound(err)).To(Succeed())
+				}
+			})
+		})
+
+		When("the AWSCluster still exists", func() {
+			BeforeEach(func() {
+				Expect(k8sClient.Create(ctx, &capa.AWSClusterRoleIdentity{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test-gc",
+					},
+					Spec: capa.AWSClusterRoleIdentitySpec{
+						AWSRoleSpec: capa.AWSRoleSpec{
+							RoleArn: "arn:aws:iam::012345678901:role/giantswarm-test-capa-controller",
+						},
+						AWSClusterIdentitySpec: capa.AWSClusterIdentitySpec{
+							AllowedNamespaces: &capa.AllowedNamespaces{},
+						},
+					},
+				})).To(Or(Succeed(), MatchError(ContainSubstring("already exists"))))
+
+				Expect(k8sClient.Create(ctx, &capa.AWSCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							"cluster.x-k8s.io/cluster-name": "test-cluster",
+						},
+						Name:      "test-cluster",
+						Namespace: namespace,
+					},
+					Spec: capa.AWSClusterSpec{
+						IdentityRef: &capa.AWSIdentityReference{
+							Name: "test-gc",
+							Kind: "AWSClusterRoleIdentity",
+						},
+						Region: "eu-west-1",
+					},
+				})).To(Succeed())
+
+				// The control plane template still uses its role.
+				Expect(k8sClient.Create(ctx, &capa.AWSMachineTemplate{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							"cluster.x-k8s.io/cluster-name": "test-cluster",
+							"cluster.x-k8s.io/role":         "control-plane",
+						},
+						Name:      "control-plane",
+						Namespace: namespace,
+					},
+					Spec: capa.AWSMachineTemplateSpec{
+						Template: capa.AWSMachineTemplateResource{
+							Spec: capa.AWSMachineSpec{
+								IAMInstanceProfile: "control-plane-test-cluster",
+								InstanceType:       "unittest.4xlarge",
+							},
+						},
+					},
+				})).To(Succeed())
+
+				sess, err := session.NewSession(&aws.Config{
+					Region: aws.String("eu-west-1")},
+				)
+				Expect(err).NotTo(HaveOccurred())
+				mockAwsClient.EXPECT().GetAWSClientSession("arn:aws:iam::012345678901:role/giantswarm-test-capa-controller", "eu-west-1").Return(sess, nil)
+
+				ownedTags := []*iam.Tag{
+					{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+					{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")},
+				}
+				mockIAMClient.EXPECT().ListRoles(&iam.ListRolesInput{
+					PathPrefix: aws.String("/"),
+				}).Return(&iam.ListRolesOutput{
+					Roles: []*iam.Role{
+						{RoleName: aws.String("control-plane-test-cluster")},
+						{RoleName: aws.String("bastion-test-cluster")},
+					},
+				}, nil)
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String("control-plane-test-cluster"),
+				}).Return(&iam.ListRoleTagsOutput{Tags: ownedTags}, nil)
+				mockIAMClient.EXPECT().ListRoleTags(&iam.ListRoleTagsInput{
+					RoleName: aws.String("bastion-test-cluster"),
+				}).Return(&iam.ListRoleTagsOutput{Tags: ownedTags}, nil)
+			})
+
+			It("deletes the orphaned roles and keeps the finalizer", func() {
+				mockIAMClient.EXPECT().ListAttachedRolePolicies(&iam.ListAttachedRolePoliciesInput{
+					RoleName: aws.String("bastion-test-cluster"),
+				}).Return(&iam.ListAttachedRolePoliciesOutput{}, nil)
+				mockIAMClient.EXPECT().ListRolePolicies(&iam.ListRolePoliciesInput{
+					RoleName: aws.String("bastion-test-cluster"),
+				}).Return(&iam.ListRolePoliciesOutput{}, nil)
+				mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(&iam.RemoveRoleFromInstanceProfileInput{
+					InstanceProfileName: aws.String("bastion-test-cluster"),
+					RoleName:            aws.String("bastion-test-cluster"),
+				}).Return(&iam.RemoveRoleFromInstanceProfileOutput{}, nil)
+				mockIAMClient.EXPECT().DeleteInstanceProfile(&iam.DeleteInstanceProfileInput{
+					InstanceProfileName: aws.String("bastion-test-cluster"),
+				}).Return(&iam.DeleteInstanceProfileOutput{}, nil)
+				mockIAMClient.EXPECT().DeleteRole(&iam.DeleteRoleInput{
+					RoleName: aws.String("bastion-test-cluster"),
+				}).Return(&iam.DeleteRoleOutput{}, nil)
+
+				result, err := reconciler.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				// the cluster just started terminating
+				Expect(result.RequeueAfter).To(Equal(time.Minute))
+
+				Expect(k8sClient.Get(ctx, req.NamespacedName, cluster)).To(Succeed())
+				Expect(cluster.Finalizers).To(ContainElement(finalizer))
+			})
+		})
+	})
+})
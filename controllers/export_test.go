@@ -0,0 +1,5 @@
+package controllers
+
+// ReconcileInputs exposes reconcileInputs to the tests, which need to
+// compute the fingerprint of a recently reconciled object.
+var ReconcileInputs = reconcileInputs
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+)
+
+// Keys of the IAM status ConfigMap of a cluster.
+const (
+	iamStatusLastReconcileTimeKey   = "lastReconcileTime"
+	iamStatusLastReconcileResultKey = "lastReconcileResult"
+	iamStatusManagedRolesKey        = "managedRoles"
+	iamStatusLastErrorKey           = "lastError"
+
+	iamStatusResultSuccess = "success"
+	iamStatusResultFailure = "failure"
+)
+
+// iamStatusUpdate describes a change of the IAM status ConfigMap of a cluster.
+type iamStatusUpdate struct {
+	// owner is the AWSCluster or AWSManagedControlPlane of the cluster, which
+	// garbage collects the ConfigMap.
+	owner       client.Object
+	clusterName string
+	// addRoleARNs and removeRoleARNs are merged into the managed roles, as
+	// the roles of a cluster are reconciled by several objects.
+	addRoleARNs    []string
+	removeRoleARNs []string
+	reconcileErr   error
+	// addFinalizer keeps the ConfigMap until the finalizers of the cluster
+	// are removed.
+	addFinalizer bool
+}
+
+// updateIAMStatus creates or updates the <cluster>-iam-status ConfigMap in the
+// namespace of the owner. The ConfigMap is only written when its content
+// changes, lastReconcileTime included, so that unchanged reconciliations do
+// not cause writes. Updates are patches with optimistic locking, as the roles
+// of a cluster are reconciled by several objects, and are retried on
+// conflicts. The ConfigMap is only informational, so failures are logged and
+// do not fail the reconciliation.
+func updateIAMStatus(ctx context.Context, k8sClient client.Client, update iamStatusUpdate) {
+	logger := log.FromContext(ctx)
+
+	name := key.IAMStatusConfigMapName(update.clusterName)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: update.owner.GetNamespace(), Name: name}, cm)
+		if k8serrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: update.owner.GetNamespace(),
+				},
+			}
+			err = applyIAMStatusUpdate(k8sClient, cm, update)
+			if err != nil {
+				return err
+			}
+			err = k8sClient.Create(ctx, cm)
+			if k8serrors.IsAlreadyExists(err) {
+				// created concurrently, retry with the existing ConfigMap
+				return k8serrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return errors.WithStack(err)
+		} else if err != nil {
+			return errors.WithStack(err)
+		}
+
+		original := cm.DeepCopy()
+		err = applyIAMStatusUpdate(k8sClient, cm, update)
+		if err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(original, cm) {
+			return nil
+		}
+
+		return errors.WithStack(k8sClient.Patch(ctx, cm, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})))
+	})
+	if err != nil {
+		logger.Error(err, "failed to update IAM status ConfigMap", "configmap", name)
+	}
+}
+
+// applyIAMStatusUpdate changes the ConfigMap according to the update.
+// lastReconcileTime is only set when anything else changes.
+func applyIAMStatusUpdate(k8sClient client.Client, cm *corev1.ConfigMap, update iamStatusUpdate) error {
+	original := cm.DeepCopy()
+
+	err := controllerutil.SetOwnerReference(update.owner, cm, k8sClient.Scheme())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if update.addFinalizer {
+		controllerutil.AddFinalizer(cm, key.FinalizerName(iam.ControlPlaneRole))
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	if update.reconcileErr == nil {
+		cm.Data[iamStatusLastReconcileResultKey] = iamStatusResultSuccess
+		cm.Data[iamStatusLastErrorKey] = ""
+	} else {
+		cm.Data[iamStatusLastReconcileResultKey] = iamStatusResultFailure
+		cm.Data[iamStatusLastErrorKey] = update.reconcileErr.Error()
+	}
+	cm.Data[iamStatusManagedRolesKey] = mergeRoleARNs(cm.Data[iamStatusManagedRolesKey], update.addRoleARNs, update.removeRoleARNs)
+
+	if !equality.Semantic.DeepEqual(original, cm) {
+		cm.Data[iamStatusLastReconcileTimeKey] = time.Now().UTC().Format(time.RFC3339)
+	}
+	return nil
+}
+
+// mergeRoleARNs adds and removes role ARNs from a comma-separated list of
+// role ARNs and returns the sorted result.
+func mergeRoleARNs(current string, add, remove []string) string {
+	var arns []string
+	if current != "" {
+		arns = strings.Split(current, ",")
+	}
+	for _, arn := range add {
+		if !slices.Contains(arns, arn) {
+			arns = append(arns, arn)
+		}
+	}
+	arns = slices.DeleteFunc(arns, func(arn string) bool {
+		return slices.Contains(remove, arn)
+	})
+	slices.Sort(arns)
+	return strings.Join(arns, ",")
+}
+
+// roleARNs returns the ARNs of the roles with the given names in the account
+// of the cluster, or in the IAM management account for IRSA roles when
+// configured.
+func roleARNs(iamService *iam.IAMService, accountID string, roleNames []string) []string {
+	var arns []string
+	for _, roleName := range roleNames {
+		arns = append(arns, iamService.RoleARN(accountID, roleName))
+	}
+	return arns
+}
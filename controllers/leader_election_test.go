@@ -0,0 +1,92 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/kubectl/pkg/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+var _ = Describe("Leader election", func() {
+	var (
+		namespace string
+		ctx       context.Context
+		cancel    context.CancelFunc
+	)
+
+	SetupNamespaceBeforeAfterEach(&namespace)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	// startManager starts a manager with the same leader election options
+	// as main.go and returns a channel which is closed once it is elected.
+	startManager := func(leaderElectionID string) <-chan struct{} {
+		leaseDuration := 4 * time.Second
+		renewDeadline := 3 * time.Second
+		retryPeriod := 500 * time.Millisecond
+
+		mgr, err := ctrl.NewManager(testEnv.Config, ctrl.Options{
+			Scheme: scheme.Scheme,
+			Metrics: metricsserver.Options{
+				BindAddress: "0",
+			},
+			HealthProbeBindAddress:        "0",
+			LeaderElection:                true,
+			LeaderElectionID:              leaderElectionID,
+			LeaderElectionNamespace:       namespace,
+			LeaderElectionReleaseOnCancel: true,
+			LeaseDuration:                 &leaseDuration,
+			RenewDeadline:                 &renewDeadline,
+			RetryPeriod:                   &retryPeriod,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		go func(mgr manager.Manager) {
+			defer GinkgoRecover()
+			Expect(mgr.Start(ctx)).To(Succeed())
+		}(mgr)
+
+		return mgr.Elected()
+	}
+
+	isClosed := func(c <-chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	It("elects only one of two managers with the same ID", func() {
+		first := startManager("e3428bb4.giantswarm.io")
+		second := startManager("e3428bb4.giantswarm.io")
+
+		Eventually(func() bool {
+			return isClosed(first) || isClosed(second)
+		}, 10*time.Second).Should(BeTrue())
+		Consistently(func() bool {
+			return isClosed(first) && isClosed(second)
+		}, 5*time.Second).Should(BeFalse())
+	})
+
+	It("elects managers with different IDs independently", func() {
+		first := startManager("tenant-a.giantswarm.io")
+		second := startManager("tenant-b.giantswarm.io")
+
+		Eventually(func() bool {
+			return isClosed(first) && isClosed(second)
+		}, 10*time.Second).Should(BeTrue())
+	})
+})
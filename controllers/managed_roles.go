@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+// ManagedRolesCounter is a manager runnable which periodically updates the
+// capa_iam_managed_roles_total gauge with the number of AWSMachineTemplates
+// reconciled by the AWSMachineTemplateReconciler, by role type.
+type ManagedRolesCounter struct {
+	Client          client.Client
+	RefreshInterval time.Duration
+}
+
+// Refresh lists the AWSMachineTemplates and updates the gauge. Role types
+// without templates are reported as zero, so that the gauge does not keep
+// the count of deleted templates.
+func (c *ManagedRolesCounter) Refresh(ctx context.Context) error {
+	awsMachineTemplates := &capa.AWSMachineTemplateList{}
+	err := c.Client.List(ctx, awsMachineTemplates)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	counts := map[string]int{
+		iam.ControlPlaneRole: 0,
+		iam.BastionRole:      0,
+	}
+	for _, awsMachineTemplate := range awsMachineTemplates.Items {
+		if !key.HasCapiWatchLabel(awsMachineTemplate.Labels) || awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile == "" {
+			continue
+		}
+		if key.IsControlPlaneAWSMachineTemplate(awsMachineTemplate.Labels) {
+			counts[iam.ControlPlaneRole]++
+		} else if key.IsBastionAWSMachineTemplate(awsMachineTemplate.Labels) {
+			counts[iam.BastionRole]++
+		}
+	}
+
+	for roleType, count := range counts {
+		metrics.ManagedRoles.WithLabelValues(roleType).Set(float64(count))
+	}
+	return nil
+}
+
+// Start refreshes the gauge every RefreshInterval until the context is done.
+// Failed refreshes are retried with the next tick.
+func (c *ManagedRolesCounter) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("managed-roles-counter")
+
+	ticker := time.NewTicker(c.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		err := c.Refresh(ctx)
+		if err != nil {
+			logger.Error(err, "failed to count managed IAM roles")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leader reports the gauge, so that
+// the roles are not counted once per replica.
+func (c *ManagedRolesCounter) NeedLeaderElection() bool {
+	return true
+}
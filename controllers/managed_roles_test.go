@@ -0,0 +1,80 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+
+	"github.com/giantswarm/capa-iam-operator/controllers"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+var _ = Describe("ManagedRolesCounter", func() {
+	var (
+		ctx       context.Context
+		namespace string
+		counter   *controllers.ManagedRolesCounter
+	)
+
+	SetupNamespaceBeforeAfterEach(&namespace)
+
+	newAWSMachineTemplate := func(name string, labels map[string]string) *capa.AWSMachineTemplate {
+		return &capa.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    labels,
+			},
+			Spec: capa.AWSMachineTemplateSpec{
+				Template: capa.AWSMachineTemplateResource{
+					Spec: capa.AWSMachineSpec{
+						IAMInstanceProfile: name,
+						InstanceType:       "unittest.4xlarge",
+					},
+				},
+			},
+		}
+	}
+
+	gauge := func(roleType string) float64 {
+		return testutil.ToFloat64(metrics.ManagedRoles.WithLabelValues(roleType))
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		counter = &controllers.ManagedRolesCounter{
+			Client: k8sClient,
+		}
+	})
+
+	It("counts the templates with the watch label by role type", func() {
+		// templates of other specs are counted as well
+		Expect(counter.Refresh(ctx)).To(Succeed())
+		controlPlaneBefore := gauge("control-plane")
+		bastionBefore := gauge("bastion")
+
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("control-plane-1", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/role":         "control-plane",
+		}))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("control-plane-2", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/role":         "control-plane",
+		}))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("bastion", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/role":         "bastion",
+		}))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("unwatched", map[string]string{
+			"cluster.x-k8s.io/role": "control-plane",
+		}))).To(Succeed())
+
+		Expect(counter.Refresh(ctx)).To(Succeed())
+		Expect(gauge("control-plane")).To(Equal(controlPlaneBefore + 2))
+		Expect(gauge("bastion")).To(Equal(bastionBefore + 1))
+	})
+})
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// TerminatingAWSMachineTemplatesEnqueuer is a manager runnable which enqueues
+// all terminating AWSMachineTemplates once at startup. This finishes the
+// deletion of templates whose cleanup was interrupted, e.g. by an operator
+// crash, without waiting for the next resync.
+type TerminatingAWSMachineTemplatesEnqueuer struct {
+	Client client.Client
+	Events chan<- event.GenericEvent
+}
+
+// Start lists the AWSMachineTemplates and sends an event for every terminating
+// one. It returns once all events were sent.
+func (e *TerminatingAWSMachineTemplatesEnqueuer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("terminating-awsmachinetemplates")
+
+	awsMachineTemplates := &capa.AWSMachineTemplateList{}
+	err := e.Client.List(ctx, awsMachineTemplates)
+	if err != nil {
+		logger.Error(err, "failed to list AWSMachineTemplates")
+		return errors.WithStack(err)
+	}
+
+	for i := range awsMachineTemplates.Items {
+		awsMachineTemplate := &awsMachineTemplates.Items[i]
+		if awsMachineTemplate.DeletionTimestamp == nil {
+			continue
+		}
+
+		logger.Info("enqueuing terminating AWSMachineTemplate", "namespace", awsMachineTemplate.Namespace, "name", awsMachineTemplate.Name)
+		select {
+		case e.Events <- event.GenericEvent{Object: awsMachineTemplate}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// NeedLeaderElection makes sure only the leader enqueues the templates, since
+// only its controllers are running.
+func (e *TerminatingAWSMachineTemplatesEnqueuer) NeedLeaderElection() bool {
+	return true
+}
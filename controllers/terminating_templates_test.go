@@ -0,0 +1,81 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/giantswarm/capa-iam-operator/controllers"
+)
+
+var _ = Describe("TerminatingAWSMachineTemplatesEnqueuer", func() {
+	var (
+		ctx       context.Context
+		namespace string
+		events    chan event.GenericEvent
+		enqueuer  *controllers.TerminatingAWSMachineTemplatesEnqueuer
+	)
+
+	SetupNamespaceBeforeAfterEach(&namespace)
+
+	newAWSMachineTemplate := func(name string) *capa.AWSMachineTemplate {
+		return &capa.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  namespace,
+				Finalizers: []string{"capa-iam-operator.finalizers.giantswarm.io/control-plane"},
+			},
+			Spec: capa.AWSMachineTemplateSpec{
+				Template: capa.AWSMachineTemplateResource{
+					Spec: capa.AWSMachineSpec{
+						IAMInstanceProfile: "the-profile",
+						InstanceType:       "unittest.4xlarge",
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		terminating := newAWSMachineTemplate("terminating")
+		Expect(k8sClient.Create(ctx, terminating)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, terminating)).To(Succeed())
+
+		Expect(k8sClient.Create(ctx, newAWSMachineTemplate("active"))).To(Succeed())
+
+		events = make(chan event.GenericEvent, 100)
+		enqueuer = &controllers.TerminatingAWSMachineTemplatesEnqueuer{
+			Client: k8sClient,
+			Events: events,
+		}
+	})
+
+	AfterEach(func() {
+		for _, name := range []string{"terminating", "active"} {
+			awsMachineTemplate := &capa.AWSMachineTemplate{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, awsMachineTemplate)).To(Succeed())
+			awsMachineTemplate.Finalizers = nil
+			Expect(k8sClient.Update(ctx, awsMachineTemplate)).To(Succeed())
+		}
+	})
+
+	It("enqueues only terminating templates", func() {
+		Expect(enqueuer.Start(ctx)).To(Succeed())
+		close(events)
+
+		var enqueued []client.ObjectKey
+		for e := range events {
+			if e.Object.GetNamespace() == namespace {
+				enqueued = append(enqueued, client.ObjectKeyFromObject(e.Object))
+			}
+		}
+		Expect(enqueued).To(ConsistOf(client.ObjectKey{Namespace: namespace, Name: "terminating"}))
+	})
+})
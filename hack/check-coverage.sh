@@ -0,0 +1,70 @@
+#!/usr/bin/env bash
+#
+# Checks the total statement coverage of a Go coverage profile against a
+# threshold and optionally renders it as an SVG badge.
+#
+# Usage: check-coverage.sh [--coverage-threshold PERCENT] [--badge FILE] PROFILE
+
+set -euo pipefail
+
+threshold=80
+badge=""
+
+while [[ $# -gt 0 ]]; do
+	case "$1" in
+	--coverage-threshold)
+		threshold="$2"
+		shift 2
+		;;
+	--badge)
+		badge="$2"
+		shift 2
+		;;
+	-*)
+		echo "unknown flag $1" >&2
+		exit 2
+		;;
+	*)
+		break
+		;;
+	esac
+done
+
+if [[ $# -ne 1 ]]; then
+	echo "usage: $0 [--coverage-threshold PERCENT] [--badge FILE] PROFILE" >&2
+	exit 2
+fi
+
+coverage=$(go tool cover -func="$1" | awk '/^total:/ { sub("%", "", $NF); print $NF }')
+
+if [[ -n "$badge" ]]; then
+	color=$(awk -v c="$coverage" -v t="$threshold" 'BEGIN { if (c >= 90) print "#4c1"; else if (c >= t) print "#a3c51c"; else print "#e05d44" }')
+	cat >"$badge" <<EOF
+<svg xmlns="http://www.w3.org/2000/svg" width="104" height="20" role="img" aria-label="coverage: ${coverage}%">
+  <title>coverage: ${coverage}%</title>
+  <linearGradient id="s" x2="0" y2="100%">
+    <stop offset="0" stop-color="#bbb" stop-opacity=".1"/>
+    <stop offset="1" stop-opacity=".1"/>
+  </linearGradient>
+  <clipPath id="r">
+    <rect width="104" height="20" rx="3" fill="#fff"/>
+  </clipPath>
+  <g clip-path="url(#r)">
+    <rect width="61" height="20" fill="#555"/>
+    <rect x="61" width="43" height="20" fill="${color}"/>
+    <rect width="104" height="20" fill="url(#s)"/>
+  </g>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="30.5" y="14">coverage</text>
+    <text x="81.5" y="14">${coverage}%</text>
+  </g>
+</svg>
+EOF
+fi
+
+if awk -v c="$coverage" -v t="$threshold" 'BEGIN { exit !(c < t) }'; then
+	echo "coverage ${coverage}% is below the threshold of ${threshold}%" >&2
+	exit 1
+fi
+
+echo "coverage ${coverage}% meets the threshold of ${threshold}%"
@@ -17,14 +17,22 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 
 	"github.com/aws/aws-sdk-go/aws"
 	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
 	awsiam "github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -36,13 +44,21 @@ import (
 	expcapa "sigs.k8s.io/cluster-api-provider-aws/v2/exp/api/v1beta2"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	iamv1alpha1 "github.com/giantswarm/capa-iam-operator/api/v1alpha1"
 	"github.com/giantswarm/capa-iam-operator/controllers"
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
 	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+	"github.com/giantswarm/capa-iam-operator/pkg/config"
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/record"
+	"github.com/giantswarm/capa-iam-operator/pkg/recovery"
+	"github.com/giantswarm/capa-iam-operator/webhooks"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -58,36 +74,232 @@ func init() {
 	_ = capa.AddToScheme(scheme)
 	_ = eks.AddToScheme(scheme)
 	_ = expcapa.AddToScheme(scheme)
+	_ = iamv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableKiamRole bool
+	var cleanupDeprecatedKiamRoles bool
 	var enableIRSARole bool
 	var enableLeaderElection bool
+	var leaderElectionID string
+	var leaderElectionNamespace string
 	var enableRoute53Role bool
+	var enableBackupRole bool
+	var enableAMPRole bool
+	var enableLoggingRole bool
+	var enableXRayRole bool
+	var enableSecretsRotationRole bool
+	var enableKarpenterIRSARole bool
+	var enableGatewayAPIRole bool
+	var enableIRSARoleMachinePool bool
+	var enableReadOnlyRole bool
+	var readOnlyRoleTrustedPrincipal string
+	var enableClusterGC bool
+	var createServiceLinkedRoles bool
+	var awsConfigEnabled bool
+	var iamRolePath string
+	var ownershipTagKey string
+	var ownershipTagValue string
+	var manageInstanceProfiles bool
+	var minReconcileAge time.Duration
+	var clusterReadinessTimeout time.Duration
+	var policyDriftCheckInterval time.Duration
+	var roleCountRefreshInterval time.Duration
+	var finalizerRemovalTimeout time.Duration
+	var cloudWatchAuditLogGroup string
+	var auditLogFile string
+	var auditLogMaxSizeMB int
+	var auditLogMaxBackups int
+	var auditLogMaxAgeDays int
+	var awsConfigWebhookAddr string
+	var awsConfigTopicARNs string
+	var extraPolicyStatementsAllowedServices string
+	var iamManagementAccountRoleARN string
+	var dryRun bool
+	var awsThrottlingMaxRetries int
+	var awsThrottlingMaxBackoff time.Duration
+	var maxConcurrentReconcilesAWSMachineTemplate int
+	var maxConcurrentReconcilesAWSMachinePool int
+	var maxConcurrentReconcilesAWSManagedControlPlane int
+	var awsCacheTTL time.Duration
+	var configFile string
+	var configSecret string
+	var enableSSOAdminPermissionSet bool
+	var ssoAdminGroupID string
+	var ssoAdminAccountRoleARN string
+	var ssoAdminRegion string
+	var enableAWSMachineTemplateWebhook bool
 	var probeAddr string
+	flag.StringVar(&configFile, "config-file", "",
+		"Path of a YAML file setting any of the other flags, keyed by flag name. Flags set on the command line take precedence.")
+	flag.StringVar(&configSecret, "config-secret", "",
+		"Secret given as namespace/name whose "+config.SecretKey+" key holds JSON setting any of the other flags like --config-file. Flags set on the command line or in the config file take precedence.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableKiamRole, "enable-kiam-role", true,
 		"Enable creation and management of KIAM role for kiam app.")
+	flag.BoolVar(&cleanupDeprecatedKiamRoles, "cleanup-deprecated-kiam-roles", false,
+		"Delete KIAM roles created by previous releases when --enable-kiam-role=false is set.")
 	flag.BoolVar(&enableIRSARole, "enable-irsa-role", true,
 		"Enable creation and management of IRSA role for irsa app.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "e3428bb4.giantswarm.io",
+		"Name of the lease used for leader election. Instances running in the same cluster with the same --leader-election-namespace must use a unique ID each, otherwise only one of them becomes leader.")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
+		"Namespace of the lease used for leader election. Defaults to the namespace the controller runs in.")
 	flag.BoolVar(&enableRoute53Role, "enable-route53-role", true,
 		"Enable creation and management of Route53 role for external-dns app.")
+	flag.BoolVar(&enableBackupRole, "enable-backup-role", false,
+		"Enable creation and management of the AWS Backup role of the cluster.")
+	flag.BoolVar(&enableAMPRole, "enable-amp-role", false,
+		"Enable creation and management of the Prometheus remote write role for the Amazon Managed Service for Prometheus workspace annotated on the AWSCluster.")
+	flag.BoolVar(&enableLoggingRole, "enable-logging-role", false,
+		"Enable creation and management of the Fluent Bit role for the CloudWatch Logs or S3 log destination annotated on the AWSCluster.")
+	flag.BoolVar(&enableXRayRole, "enable-xray-role", false,
+		"Enable creation and management of the X-Ray daemon role. It trusts the observability/xray-daemon service account unless overridden by annotations on the AWSCluster.")
+	flag.BoolVar(&enableSecretsRotationRole, "enable-secrets-rotation-role", false,
+		"Enable creation and management of the execution role of the Secrets Manager rotation Lambda function annotated on the AWSCluster.")
+	flag.BoolVar(&enableKarpenterIRSARole, "enable-karpenter-irsa-role", false,
+		"Enable creation and management of the Karpenter role for the interruption queue and node role annotated on the AWSCluster. It trusts the kube-system/karpenter service account.")
+	flag.BoolVar(&enableGatewayAPIRole, "enable-gateway-api-role", false,
+		"Enable creation and management of the AWS Gateway API controller role for the load balancers tagged as owned by the cluster and the Route 53 hosted zones annotated on the AWSCluster. It trusts the aws-application-networking-system/aws-gateway-api-controller service account.")
+	flag.BoolVar(&enableIRSARoleMachinePool, "enable-irsa-role-machinepool", false,
+		"Enable creation and management of the IRSA roles of the cluster from AWSMachinePools. They are deleted together with the cluster.")
+	flag.BoolVar(&enableReadOnlyRole, "enable-readonly-role", false,
+		"Create a <cluster>-ReadOnly role for every cluster, allowed to describe, list and get EC2, ELB, EKS and IAM resources and trusted by --readonly-role-trusted-principal.")
+	flag.StringVar(&readOnlyRoleTrustedPrincipal, "readonly-role-trusted-principal", "",
+		"ARN of the IAM principal allowed to assume the read-only roles. Required with --enable-readonly-role.")
+	flag.BoolVar(&enableClusterGC, "enable-cluster-gc", false,
+		"Add a finalizer to every Cluster with an AWSCluster and delete the unused IAM roles tagged as owned by the cluster while it terminates, e.g. the roles of templates removed without their finalizers running.")
+	flag.BoolVar(&createServiceLinkedRoles, "create-service-linked-roles", false,
+		"Create the service-linked roles of Auto Scaling, Elastic Load Balancing and Transit Gateway in the account of every cluster, which are missing in new accounts.")
+	flag.BoolVar(&awsConfigEnabled, "aws-config-enabled", false,
+		"Register an AWS Config rule checking the required tags for every IAM role created by the operator.")
+	flag.StringVar(&iamRolePath, "iam-role-path", "/",
+		"IAM path of the roles and instance profiles created by the operator. It must begin and end with '/'.")
+	flag.StringVar(&ownershipTagKey, "ownership-tag-key", iam.IAMControllerOwnedTag,
+		"Key of the tag marking the IAM resources created by the operator. Only resources with this tag are updated in place or garbage collected.")
+	flag.StringVar(&ownershipTagValue, "ownership-tag-value", "",
+		"Value of the tag marking the IAM resources created by the operator.")
+	flag.BoolVar(&manageInstanceProfiles, "manage-instance-profiles", true,
+		"Create and delete an instance profile for every IAM role. Disable it when instance profiles are managed externally.")
+	flag.DurationVar(&minReconcileAge, "min-reconcile-age", 10*time.Minute,
+		"Skip reconciliation of unchanged objects that were successfully reconciled less than this duration ago. Set to 0 to always reconcile.")
+	flag.DurationVar(&clusterReadinessTimeout, "cluster-readiness-timeout", 2*time.Hour,
+		"Stop waiting for an AWSCluster to become ready after this duration and set its ClusterReadinessTimeout condition to true. Set to 0 to wait forever.")
+	flag.DurationVar(&finalizerRemovalTimeout, "finalizer-removal-timeout", 0,
+		"Remove the finalizers of objects terminating for longer than this duration without deleting their IAM resources, which may be orphaned. Set to 0 to wait forever.")
+	flag.DurationVar(&policyDriftCheckInterval, "policy-drift-check-interval", 0,
+		"Interval at which the IAM policy simulator checks that the roles of control plane templates are still allowed the actions of their policies, e.g. after SCP changes. Set to 0 to disable the check.")
+	flag.DurationVar(&roleCountRefreshInterval, "role-count-refresh-interval", 5*time.Minute,
+		"How often the capa_iam_managed_roles_total gauge counting the IAM roles of AWSMachineTemplates by role type is refreshed. Set to 0 to disable the gauge.")
+	flag.StringVar(&cloudWatchAuditLogGroup, "cloudwatch-audit-log-group", "",
+		"Send an audit event for every IAM mutation to this CloudWatch Logs log group. Disabled when empty.")
+	flag.StringVar(&auditLogFile, "audit-log-file", "",
+		"Append an audit event for every IAM mutation to this file. Disabled when empty.")
+	flag.IntVar(&auditLogMaxSizeMB, "audit-log-max-size-mb", 100,
+		"Rotate the audit log file before it grows larger than this many megabytes.")
+	flag.IntVar(&auditLogMaxBackups, "audit-log-max-backups", 5,
+		"Number of rotated audit log files to keep. Set to 0 to keep all of them.")
+	flag.IntVar(&auditLogMaxAgeDays, "audit-log-max-age-days", 30,
+		"Delete rotated audit log files older than this many days. Set to 0 to keep them regardless of their age.")
+	flag.StringVar(&awsConfigWebhookAddr, "aws-config-webhook-addr", "",
+		"The address the endpoint for AWS Config notifications delivered by SNS binds to. IAM role policy changes reconcile the owning AWSMachineTemplate immediately. Disabled when empty.")
+	flag.StringVar(&awsConfigTopicARNs, "aws-config-topic-arns", "",
+		"Comma separated ARNs of the SNS topics the AWS Config notification endpoint accepts messages from. Required with --aws-config-webhook-addr.")
+	flag.StringVar(&extraPolicyStatementsAllowedServices, "extra-policy-statements-allowed-services", "",
+		"Comma separated services, e.g. ec2, whose actions may be granted to node roles with the capa-iam-operator.giantswarm.io/extra-policy-statements annotation on AWSMachinePools. The annotation is rejected when empty.")
+	flag.StringVar(&iamManagementAccountRoleARN, "iam-management-account-role-arn", "",
+		"Assume this role to manage the IRSA roles of all clusters in a centralized IAM management account, where the IRSA trust domains of the clusters are registered as OIDC providers. The roles of instance profiles and AWS services are always managed in the cluster accounts.")
+	flag.IntVar(&awsThrottlingMaxRetries, "aws-throttling-max-retries", 8,
+		"Maximum number of retries of throttled IAM API calls, with exponential backoff and full jitter. Set to 0 to only rely on the retries of the AWS SDK.")
+	flag.DurationVar(&awsThrottlingMaxBackoff, "aws-throttling-max-backoff", 20*time.Second,
+		"Maximum backoff between two retries of a throttled IAM API call.")
+	flag.IntVar(&maxConcurrentReconcilesAWSMachineTemplate, "max-concurrent-reconciles-awsmachinetemplate", 1,
+		"Number of AWSMachineTemplates reconciled in parallel.")
+	flag.IntVar(&maxConcurrentReconcilesAWSMachinePool, "max-concurrent-reconciles-awsmachinepool", 1,
+		"Number of AWSMachinePools reconciled in parallel.")
+	flag.IntVar(&maxConcurrentReconcilesAWSManagedControlPlane, "max-concurrent-reconciles-awsmanagedcontrolplane", 1,
+		"Number of AWSManagedControlPlanes reconciled in parallel.")
+	flag.DurationVar(&awsCacheTTL, "aws-cache-ttl", 5*time.Minute,
+		"Cache the AWS caller identities and IAM role ARNs looked up during reconciliation for this duration. Set to 0 to disable the cache.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Log the IAM roles, policies, instance profiles and tags which would be created, changed or deleted in AWS instead of changing them. Finalizers are still added, but the IAMRoleReady condition, the IRSA role ARN annotations and the IAM status ConfigMap are not written.")
+	flag.BoolVar(&enableSSOAdminPermissionSet, "enable-sso-admin-permission-set", false,
+		"Create an IAM Identity Center permission set named <cluster>-ClusterAdmin for every cluster and assign it to the group of --sso-admin-group-id in the cluster account.")
+	flag.StringVar(&ssoAdminGroupID, "sso-admin-group-id", "",
+		"ID of the IAM Identity Center group which is assigned the cluster admin permission set. Required with --enable-sso-admin-permission-set.")
+	flag.StringVar(&ssoAdminAccountRoleARN, "sso-admin-account-role-arn", "",
+		"Assume this role to manage the cluster admin permission sets in the account owning the IAM Identity Center instance, i.e. the organization management or delegated administrator account. The cluster account is used when empty.")
+	flag.StringVar(&ssoAdminRegion, "sso-admin-region", "",
+		"Region of the IAM Identity Center instance. The region of the cluster is used when empty.")
+	flag.BoolVar(&enableAWSMachineTemplateWebhook, "enable-awsmachinetemplate-webhook", false,
+		"Serve the validating webhook rejecting AWSMachineTemplates with an IAM instance profile but a missing cluster name label or an unsupported role label on port 9443. Requires a serving certificate in the default webhook certificate directory, which the Helm chart provides with webhook.enabled.")
 	opts := zap.Options{
 		Development: false,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	// The configuration may set the zap flags, so it is loaded before the
+	// logger is built. Its errors are logged once the logger is set.
+	var configErr error
+	if configFile != "" {
+		configErr = config.Load(flag.CommandLine, configFile)
+	}
+
+	restConfig, restConfigErr := ctrl.GetConfig()
+	if configErr == nil && restConfigErr == nil && configSecret != "" {
+		configClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			configErr = fmt.Errorf("failed to create client: %w", err)
+		} else {
+			configErr = config.LoadSecret(context.Background(), configClient, flag.CommandLine, configSecret)
+		}
+	}
+
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if configErr != nil {
+		setupLog.Error(configErr, "unable to load config")
+		os.Exit(1)
+	}
+	if restConfigErr != nil {
+		setupLog.Error(restConfigErr, "unable to get kubeconfig")
+		os.Exit(1)
+	}
+
+	var awsConfigNotificationTopicARNs []string
+	if awsConfigTopicARNs != "" {
+		awsConfigNotificationTopicARNs = strings.Split(awsConfigTopicARNs, ",")
+	}
+	var extraPolicyStatementsServices []string
+	if extraPolicyStatementsAllowedServices != "" {
+		extraPolicyStatementsServices = strings.Split(extraPolicyStatementsAllowedServices, ",")
+	}
+	if awsConfigWebhookAddr != "" && len(awsConfigNotificationTopicARNs) == 0 {
+		setupLog.Error(nil, "--aws-config-topic-arns is required with --aws-config-webhook-addr")
+		os.Exit(1)
+	}
+
+	if enableSSOAdminPermissionSet && ssoAdminGroupID == "" {
+		setupLog.Error(nil, "--sso-admin-group-id is required with --enable-sso-admin-permission-set")
+		os.Exit(1)
+	}
+
+	if enableReadOnlyRole {
+		if err := iam.ValidateReadOnlyTrustedPrincipal(readOnlyRoleTrustedPrincipal); err != nil {
+			setupLog.Error(err, "--readonly-role-trusted-principal must be an IAM principal ARN with --enable-readonly-role")
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress: metricsAddr,
@@ -97,34 +309,141 @@ func main() {
 				Port: 9443,
 			},
 		),
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "e3428bb4.giantswarm.io",
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: leaderElectionNamespace,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	record.InitFromRecorder(mgr.GetEventRecorderFor("capa-iam-operator"))
+
 	awsClientAwsMachineTemplate, err := awsclient.New(awsclient.AWSClientConfig{
 		CtrlClient: mgr.GetClient(),
 		Log:        ctrl.Log.WithName("controllers").WithName("AWSMachineTemplate"),
+		CacheTTL:   awsCacheTTL,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to create aws client for controller", "controller", "AWSMachineTemplate")
 		os.Exit(1)
 	}
 
+	// the retries of throttled calls are aborted on shutdown
+	ctx := ctrl.SetupSignalHandler()
+	throttlingConfig := awsclient.ThrottlingConfig{
+		MaxRetries: awsThrottlingMaxRetries,
+		MaxBackoff: awsThrottlingMaxBackoff,
+		Context:    ctx,
+		Log:        ctrl.Log.WithName("aws-throttling"),
+	}
 	iamClientFactory := func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
-		return awsiam.New(session, &aws.Config{Region: aws.String(region)})
+		return awsclient.WrapIAMClientWithThrottlingRetries(awsiam.New(session, &aws.Config{Region: aws.String(region)}), throttlingConfig)
+	}
+
+	var configClientFactory iam.ConfigClientFactory
+	if awsConfigEnabled {
+		configClientFactory = func(session awsclientgo.ConfigProvider, region string) configserviceiface.ConfigServiceAPI {
+			return configservice.New(session, &aws.Config{Region: aws.String(region)})
+		}
+	}
+
+	var auditSinks audit.MultiSink
+	if cloudWatchAuditLogGroup != "" {
+		sess, err := session.NewSession()
+		if err != nil {
+			setupLog.Error(err, "unable to create aws session for audit log")
+			os.Exit(1)
+		}
+
+		podName := os.Getenv("POD_NAME")
+		if podName == "" {
+			podName, _ = os.Hostname()
+		}
+
+		cloudWatchSink, err := audit.NewCloudWatchSink(audit.CloudWatchSinkConfig{
+			Client:       cloudwatchlogs.New(sess),
+			LogGroupName: cloudWatchAuditLogGroup,
+			PodName:      podName,
+			Log:          ctrl.Log.WithName("audit"),
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create CloudWatch audit sink")
+			os.Exit(1)
+		}
+		if err = mgr.Add(cloudWatchSink); err != nil {
+			setupLog.Error(err, "unable to add CloudWatch audit sink to manager")
+			os.Exit(1)
+		}
+		auditSinks = append(auditSinks, cloudWatchSink)
+	}
+
+	if auditLogFile != "" {
+		fileSink, err := audit.NewFileSink(audit.FileSinkConfig{
+			Path:       auditLogFile,
+			MaxSizeMB:  auditLogMaxSizeMB,
+			MaxBackups: auditLogMaxBackups,
+			MaxAgeDays: auditLogMaxAgeDays,
+			Log:        ctrl.Log.WithName("audit"),
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create file audit sink")
+			os.Exit(1)
+		}
+		if err = mgr.Add(fileSink); err != nil {
+			setupLog.Error(err, "unable to add file audit sink to manager")
+			os.Exit(1)
+		}
+		auditSinks = append(auditSinks, fileSink)
+	}
+
+	var auditSink audit.Sink
+	switch len(auditSinks) {
+	case 0:
+	case 1:
+		auditSink = auditSinks[0]
+	default:
+		auditSink = auditSinks
 	}
 
 	if err = (&controllers.AWSMachineTemplateReconciler{
-		Client:            mgr.GetClient(),
-		EnableKiamRole:    enableKiamRole,
-		EnableRoute53Role: enableRoute53Role,
-		AWSClient:         awsClientAwsMachineTemplate,
-		IAMClientFactory:  iamClientFactory,
+		Client:                         mgr.GetClient(),
+		EnableKiamRole:                 enableKiamRole,
+		CleanupDeprecatedKiamRoles:     cleanupDeprecatedKiamRoles,
+		EnableRoute53Role:              enableRoute53Role,
+		EnableBackupRole:               enableBackupRole,
+		EnableAMPRole:                  enableAMPRole,
+		EnableLoggingRole:              enableLoggingRole,
+		EnableXRayRole:                 enableXRayRole,
+		EnableSecretsRotationRole:      enableSecretsRotationRole,
+		EnableKarpenterIRSARole:        enableKarpenterIRSARole,
+		EnableGatewayAPIRole:           enableGatewayAPIRole,
+		EnableSSOAdminPermissionSet:    enableSSOAdminPermissionSet,
+		SSOAdminGroupID:                ssoAdminGroupID,
+		SSOAdminAccountRoleARN:         ssoAdminAccountRoleARN,
+		SSOAdminRegion:                 ssoAdminRegion,
+		AWSClient:                      awsClientAwsMachineTemplate,
+		IAMClientFactory:               iamClientFactory,
+		ConfigClientFactory:            configClientFactory,
+		RolePath:                       iamRolePath,
+		OwnershipTagKey:                ownershipTagKey,
+		OwnershipTagValue:              ownershipTagValue,
+		SkipInstanceProfiles:           !manageInstanceProfiles,
+		MinReconcileAge:                minReconcileAge,
+		ClusterReadinessTimeout:        clusterReadinessTimeout,
+		FinalizerRemovalTimeout:        finalizerRemovalTimeout,
+		PolicyDriftCheckInterval:       policyDriftCheckInterval,
+		RoleCountRefreshInterval:       roleCountRefreshInterval,
+		AuditSink:                      auditSink,
+		IAMManagementAccountRoleARN:    iamManagementAccountRoleARN,
+		DryRun:                         dryRun,
+		AWSConfigNotificationAddr:      awsConfigWebhookAddr,
+		AWSConfigNotificationTopicARNs: awsConfigNotificationTopicARNs,
+		MaxConcurrentReconciles:        maxConcurrentReconcilesAWSMachineTemplate,
+		AWSCacheTTL:                    awsCacheTTL,
+		WrapReconciler:                 recovery.WrapReconciler,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AWSMachineTemplate")
 		os.Exit(1)
@@ -133,6 +452,7 @@ func main() {
 	awsClientAwsMachine, err := awsclient.New(awsclient.AWSClientConfig{
 		CtrlClient: mgr.GetClient(),
 		Log:        ctrl.Log.WithName("controllers").WithName("AWSMachinePool"),
+		CacheTTL:   awsCacheTTL,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to create aws client for controller", "controller", "AWSMachinePool")
@@ -140,23 +460,78 @@ func main() {
 	}
 
 	if err = (&controllers.AWSMachinePoolReconciler{
-		Client:           mgr.GetClient(),
-		AWSClient:        awsClientAwsMachine,
-		IAMClientFactory: iamClientFactory,
+		Client:                               mgr.GetClient(),
+		AWSClient:                            awsClientAwsMachine,
+		IAMClientFactory:                     iamClientFactory,
+		ConfigClientFactory:                  configClientFactory,
+		RolePath:                             iamRolePath,
+		OwnershipTagKey:                      ownershipTagKey,
+		OwnershipTagValue:                    ownershipTagValue,
+		SkipInstanceProfiles:                 !manageInstanceProfiles,
+		EnableIRSARole:                       enableIRSARoleMachinePool,
+		MinReconcileAge:                      minReconcileAge,
+		FinalizerRemovalTimeout:              finalizerRemovalTimeout,
+		AuditSink:                            auditSink,
+		IAMManagementAccountRoleARN:          iamManagementAccountRoleARN,
+		DryRun:                               dryRun,
+		ExtraPolicyStatementsAllowedServices: extraPolicyStatementsServices,
+		MaxConcurrentReconciles:              maxConcurrentReconcilesAWSMachinePool,
+		WrapReconciler:                       recovery.WrapReconciler,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AWSMachinePool")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.AWSManagedControlPlaneReconciler{
-		Client:           mgr.GetClient(),
-		AWSClient:        awsClientAwsMachine,
-		IAMClientFactory: iamClientFactory,
+		Client:                      mgr.GetClient(),
+		AWSClient:                   awsClientAwsMachine,
+		IAMClientFactory:            iamClientFactory,
+		ConfigClientFactory:         configClientFactory,
+		RolePath:                    iamRolePath,
+		OwnershipTagKey:             ownershipTagKey,
+		OwnershipTagValue:           ownershipTagValue,
+		SkipInstanceProfiles:        !manageInstanceProfiles,
+		FinalizerRemovalTimeout:     finalizerRemovalTimeout,
+		AuditSink:                   auditSink,
+		IAMManagementAccountRoleARN: iamManagementAccountRoleARN,
+		DryRun:                      dryRun,
+		MaxConcurrentReconciles:     maxConcurrentReconcilesAWSManagedControlPlane,
+		AWSCacheTTL:                 awsCacheTTL,
+		WrapReconciler:              recovery.WrapReconciler,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AWSManagedControlPlane")
 		os.Exit(1)
 	}
 
+	if err = (&controllers.ClusterReconciler{
+		Client:                       mgr.GetClient(),
+		AWSClient:                    awsClientAwsMachineTemplate,
+		IAMClientFactory:             iamClientFactory,
+		ConfigClientFactory:          configClientFactory,
+		RolePath:                     iamRolePath,
+		OwnershipTagKey:              ownershipTagKey,
+		OwnershipTagValue:            ownershipTagValue,
+		SkipInstanceProfiles:         !manageInstanceProfiles,
+		AuditSink:                    auditSink,
+		IAMManagementAccountRoleARN:  iamManagementAccountRoleARN,
+		DryRun:                       dryRun,
+		EnableReadOnlyRole:           enableReadOnlyRole,
+		ReadOnlyRoleTrustedPrincipal: readOnlyRoleTrustedPrincipal,
+		CreateServiceLinkedRoles:     createServiceLinkedRoles,
+		EnableClusterGC:              enableClusterGC,
+		WrapReconciler:               recovery.WrapReconciler,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
+		os.Exit(1)
+	}
+
+	if enableAWSMachineTemplateWebhook {
+		if err = webhooks.NewAWSMachineTemplateValidator(mgr.GetScheme()).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AWSMachineTemplate")
+			os.Exit(1)
+		}
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -169,7 +544,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"time"
+)
+
+// Event describes a single IAM mutation performed by the operator.
+type Event struct {
+	Time        time.Time `json:"time"`
+	ClusterName string    `json:"cluster_name"`
+	// Action is the name of the IAM API operation, e.g. CreateRole.
+	Action   string `json:"action"`
+	RoleName string `json:"role_name,omitempty"`
+	// Resource is the additional resource the action applies to, e.g. the
+	// policy or instance profile name.
+	Resource string `json:"resource,omitempty"`
+	// Error is set when the action failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Sink receives audit events. Implementations must be safe for concurrent use
+// and must not block on I/O.
+type Sink interface {
+	Write(event Event)
+}
+
+// MultiSink writes every event to all of its sinks.
+type MultiSink []Sink
+
+func (m MultiSink) Write(event Event) {
+	for _, sink := range m {
+		sink.Write(event)
+	}
+}
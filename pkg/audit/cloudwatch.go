@@ -0,0 +1,261 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+const (
+	// maxBatchSize is the maximum number of events sent with a single
+	// PutLogEvents call.
+	maxBatchSize = 100
+
+	defaultFlushInterval     = 5 * time.Second
+	defaultMaxBufferedEvents = 10000
+)
+
+type CloudWatchSinkConfig struct {
+	Client       cloudwatchlogsiface.CloudWatchLogsAPI
+	LogGroupName string
+	// PodName is used as suffix of the log stream names, so that every
+	// replica writes to its own streams.
+	PodName string
+	Log     logr.Logger
+
+	// FlushInterval is optional. It defaults to 5 seconds.
+	FlushInterval time.Duration
+	// MaxBufferedEvents is optional. It is the maximum number of events
+	// buffered per log stream, e.g. while CloudWatch is unreachable, and
+	// defaults to 10000. The oldest events are dropped beyond it.
+	MaxBufferedEvents int
+}
+
+// CloudWatchSink buffers audit events and sends them to a CloudWatch Logs log
+// group. Events are written to a log stream named <clusterName>/<podName>.
+// Buffered events are flushed periodically while the sink is running as a
+// manager runnable and once more when it is stopped. The oldest events of a
+// log stream are dropped and counted in capa_iam_audit_events_dropped_total
+// when its buffer is full.
+type CloudWatchSink struct {
+	client            cloudwatchlogsiface.CloudWatchLogsAPI
+	logGroupName      string
+	podName           string
+	flushInterval     time.Duration
+	maxBufferedEvents int
+	log               logr.Logger
+
+	mu      sync.Mutex
+	buffers map[string][]*cloudwatchlogs.InputLogEvent
+
+	// flushMu serializes flushes, which own the stream state below.
+	flushMu        sync.Mutex
+	sequenceTokens map[string]*string
+	createdStreams map[string]bool
+}
+
+func NewCloudWatchSink(config CloudWatchSinkConfig) (*CloudWatchSink, error) {
+	if config.Client == nil {
+		return nil, errors.New("cannot create CloudWatchSink with Client equal to nil")
+	}
+	if config.LogGroupName == "" {
+		return nil, errors.New("cannot create CloudWatchSink with empty LogGroupName")
+	}
+	if config.PodName == "" {
+		return nil, errors.New("cannot create CloudWatchSink with empty PodName")
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.MaxBufferedEvents <= 0 {
+		config.MaxBufferedEvents = defaultMaxBufferedEvents
+	}
+
+	s := &CloudWatchSink{
+		client:            config.Client,
+		logGroupName:      config.LogGroupName,
+		podName:           config.PodName,
+		flushInterval:     config.FlushInterval,
+		maxBufferedEvents: config.MaxBufferedEvents,
+		log:               config.Log.WithValues("log_group", config.LogGroupName),
+
+		buffers:        map[string][]*cloudwatchlogs.InputLogEvent{},
+		sequenceTokens: map[string]*string{},
+		createdStreams: map[string]bool{},
+	}
+
+	return s, nil
+}
+
+// Write buffers the event until the next flush.
+func (s *CloudWatchSink) Write(event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		s.log.Error(err, "failed to marshal audit event", "action", event.Action)
+		return
+	}
+
+	streamName := fmt.Sprintf("%s/%s", event.ClusterName, s.podName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffers[streamName] = append(s.buffers[streamName], &cloudwatchlogs.InputLogEvent{
+		Message:   aws.String(string(message)),
+		Timestamp: aws.Int64(event.Time.UnixMilli()),
+	})
+	s.dropOldest(streamName)
+}
+
+// Start flushes the buffered events every flush interval until the context is
+// cancelled. Remaining events are flushed before it returns.
+func (s *CloudWatchSink) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := s.Flush()
+			if err != nil {
+				s.log.Error(err, "failed to flush audit events on shutdown")
+			}
+			return nil
+		case <-ticker.C:
+			err := s.Flush()
+			if err != nil {
+				s.log.Error(err, "failed to flush audit events")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false, so that events of every replica are
+// flushed.
+func (s *CloudWatchSink) NeedLeaderElection() bool {
+	return false
+}
+
+// Flush sends the buffered events in batches of up to 100 events per log
+// stream. Events which could not be sent are kept for the next flush.
+func (s *CloudWatchSink) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	buffers := s.buffers
+	s.buffers = map[string][]*cloudwatchlogs.InputLogEvent{}
+	s.mu.Unlock()
+
+	streamNames := make([]string, 0, len(buffers))
+	for streamName := range buffers {
+		streamNames = append(streamNames, streamName)
+	}
+	sort.Strings(streamNames)
+
+	var errs []error
+	for _, streamName := range streamNames {
+		events := buffers[streamName]
+		for start := 0; start < len(events); start += maxBatchSize {
+			end := min(start+maxBatchSize, len(events))
+
+			err := s.putLogEvents(streamName, events[start:end])
+			if err != nil {
+				s.requeue(streamName, events[start:])
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// requeue puts events back in front of the events buffered since the flush
+// started, keeping them in chronological order.
+func (s *CloudWatchSink) requeue(streamName string, events []*cloudwatchlogs.InputLogEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffers[streamName] = append(events, s.buffers[streamName]...)
+	s.dropOldest(streamName)
+}
+
+// dropOldest drops the oldest events of the log stream beyond the maximum
+// number of buffered events. It must be called with mu held.
+func (s *CloudWatchSink) dropOldest(streamName string) {
+	dropped := len(s.buffers[streamName]) - s.maxBufferedEvents
+	if dropped <= 0 {
+		return
+	}
+
+	s.buffers[streamName] = s.buffers[streamName][dropped:]
+	metrics.AuditEventsDroppedTotal.WithLabelValues("cloudwatch").Add(float64(dropped))
+	s.log.Info("audit event buffer is full, dropped oldest events", "log_stream", streamName, "dropped", dropped)
+}
+
+func (s *CloudWatchSink) putLogEvents(streamName string, events []*cloudwatchlogs.InputLogEvent) error {
+	l := s.log.WithValues("log_stream", streamName)
+
+	err := s.ensureLogStream(streamName)
+	if err != nil {
+		return err
+	}
+
+	input := &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(s.logGroupName),
+		LogStreamName: aws.String(streamName),
+		LogEvents:     events,
+		SequenceToken: s.sequenceTokens[streamName],
+	}
+	output, err := s.client.PutLogEvents(input)
+
+	// The sequence token is unknown after a restart or when another writer
+	// used the stream. The error tells the expected one, so we retry once.
+	var invalidSequenceToken *cloudwatchlogs.InvalidSequenceTokenException
+	if errors.As(err, &invalidSequenceToken) {
+		l.Info("retrying to put audit events with expected sequence token")
+		input.SequenceToken = invalidSequenceToken.ExpectedSequenceToken
+		output, err = s.client.PutLogEvents(input)
+	}
+
+	var dataAlreadyAccepted *cloudwatchlogs.DataAlreadyAcceptedException
+	if errors.As(err, &dataAlreadyAccepted) {
+		s.sequenceTokens[streamName] = dataAlreadyAccepted.ExpectedSequenceToken
+		return nil
+	}
+	if err != nil {
+		l.Error(err, "failed to put audit events")
+		return err
+	}
+
+	s.sequenceTokens[streamName] = output.NextSequenceToken
+	return nil
+}
+
+func (s *CloudWatchSink) ensureLogStream(streamName string) error {
+	if s.createdStreams[streamName] {
+		return nil
+	}
+
+	_, err := s.client.CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(s.logGroupName),
+		LogStreamName: aws.String(streamName),
+	})
+	if err != nil && !isResourceAlreadyExists(err) {
+		s.log.Error(err, "failed to create log stream", "log_stream", streamName)
+		return err
+	}
+
+	s.createdStreams[streamName] = true
+	return nil
+}
@@ -0,0 +1,166 @@
+package audit_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("CloudWatchSink", func() {
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockCloudWatch *mocks.MockCloudWatchLogsAPI
+		sink           *audit.CloudWatchSink
+	)
+
+	writeEvents := func(clusterName string, count int) {
+		for i := 0; i < count; i++ {
+			sink.Write(audit.Event{
+				Time:        time.Now(),
+				ClusterName: clusterName,
+				Action:      "CreateRole",
+				RoleName:    "test-role",
+			})
+		}
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockCloudWatch = mocks.NewMockCloudWatchLogsAPI(mockCtrl)
+
+		var err error
+		sink, err = audit.NewCloudWatchSink(audit.CloudWatchSinkConfig{
+			Client:            mockCloudWatch,
+			LogGroupName:      "audit",
+			PodName:           "capa-iam-operator-abc",
+			Log:               ctrl.Log,
+			MaxBufferedEvents: 300,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		mockCloudWatch.EXPECT().CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
+			LogGroupName:  aws.String("audit"),
+			LogStreamName: aws.String("test-cluster/capa-iam-operator-abc"),
+		}).Return(&cloudwatchlogs.CreateLogStreamOutput{}, nil).MaxTimes(1)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("sends events in batches of up to 100", func() {
+		writeEvents("test-cluster", 250)
+
+		var batchSizes []int
+		var sequenceTokens []*string
+		mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).DoAndReturn(func(input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+			Expect(*input.LogGroupName).To(Equal("audit"))
+			Expect(*input.LogStreamName).To(Equal("test-cluster/capa-iam-operator-abc"))
+			Expect(*input.LogEvents[0].Message).To(ContainSubstring(`"action":"CreateRole"`))
+			batchSizes = append(batchSizes, len(input.LogEvents))
+			sequenceTokens = append(sequenceTokens, input.SequenceToken)
+			return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String("token")}, nil
+		}).Times(3)
+
+		Expect(sink.Flush()).To(Succeed())
+		Expect(batchSizes).To(Equal([]int{100, 100, 50}))
+		Expect(sequenceTokens).To(Equal([]*string{nil, aws.String("token"), aws.String("token")}))
+	})
+
+	It("does not call CloudWatch without events", func() {
+		Expect(sink.Flush()).To(Succeed())
+	})
+
+	It("uses a log stream per cluster", func() {
+		writeEvents("test-cluster", 1)
+		writeEvents("other-cluster", 1)
+
+		mockCloudWatch.EXPECT().CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
+			LogGroupName:  aws.String("audit"),
+			LogStreamName: aws.String("other-cluster/capa-iam-operator-abc"),
+		}).Return(&cloudwatchlogs.CreateLogStreamOutput{}, nil)
+
+		var streamNames []string
+		mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).DoAndReturn(func(input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+			streamNames = append(streamNames, *input.LogStreamName)
+			return &cloudwatchlogs.PutLogEventsOutput{}, nil
+		}).Times(2)
+
+		Expect(sink.Flush()).To(Succeed())
+		Expect(streamNames).To(ConsistOf("test-cluster/capa-iam-operator-abc", "other-cluster/capa-iam-operator-abc"))
+	})
+
+	It("retries with the expected sequence token", func() {
+		writeEvents("test-cluster", 1)
+
+		gomock.InOrder(
+			mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).Return(nil, &cloudwatchlogs.InvalidSequenceTokenException{
+				ExpectedSequenceToken: aws.String("expected-token"),
+			}),
+			mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).DoAndReturn(func(input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+				Expect(input.SequenceToken).To(Equal(aws.String("expected-token")))
+				return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String("next-token")}, nil
+			}),
+		)
+
+		Expect(sink.Flush()).To(Succeed())
+	})
+
+	It("keeps events which could not be sent for the next flush", func() {
+		writeEvents("test-cluster", 150)
+
+		var batchSizes []int
+		gomock.InOrder(
+			mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).Return(&cloudwatchlogs.PutLogEventsOutput{}, nil),
+			mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).Return(nil, errors.New("throttled")),
+			mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).DoAndReturn(func(input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+				batchSizes = append(batchSizes, len(input.LogEvents))
+				return &cloudwatchlogs.PutLogEventsOutput{}, nil
+			}),
+		)
+
+		Expect(sink.Flush()).To(MatchError(ContainSubstring("throttled")))
+		Expect(sink.Flush()).To(Succeed())
+		Expect(batchSizes).To(Equal([]int{50}))
+	})
+
+	It("drops the oldest events when the buffer is full", func() {
+		dropped := testutil.ToFloat64(metrics.AuditEventsDroppedTotal.WithLabelValues("cloudwatch"))
+		writeEvents("test-cluster", 250)
+		sink.Write(audit.Event{
+			Time:        time.Now(),
+			ClusterName: "test-cluster",
+			Action:      "DeleteRole",
+			RoleName:    "test-role",
+		})
+
+		mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).Return(nil, errors.New("unreachable")).Times(2)
+		Expect(sink.Flush()).NotTo(Succeed())
+		writeEvents("test-cluster", 100)
+		Expect(sink.Flush()).NotTo(Succeed())
+		Expect(testutil.ToFloat64(metrics.AuditEventsDroppedTotal.WithLabelValues("cloudwatch")) - dropped).To(Equal(float64(51)))
+
+		var batchSizes []int
+		var lastMessages []string
+		mockCloudWatch.EXPECT().PutLogEvents(gomock.Any()).DoAndReturn(func(input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+			batchSizes = append(batchSizes, len(input.LogEvents))
+			lastMessages = append(lastMessages, *input.LogEvents[len(input.LogEvents)-1].Message)
+			return &cloudwatchlogs.PutLogEventsOutput{}, nil
+		}).Times(3)
+		Expect(sink.Flush()).To(Succeed())
+		Expect(batchSizes).To(Equal([]int{100, 100, 100}))
+		Expect(lastMessages[1]).To(ContainSubstring(`"action":"DeleteRole"`))
+	})
+})
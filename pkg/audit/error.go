@@ -0,0 +1,15 @@
+package audit
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+func isResourceAlreadyExists(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		if aerr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException {
+			return true
+		}
+	}
+	return false
+}
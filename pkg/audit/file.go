@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+const defaultMaxSizeMB = 100
+
+type FileSinkConfig struct {
+	// Path is the path of the audit log file. Rotated files are written to
+	// the same directory as <name>-<timestamp><ext>.
+	Path string
+	Log  logr.Logger
+
+	// MaxSizeMB is optional. The file is rotated before it grows larger than
+	// this size. It defaults to 100.
+	MaxSizeMB int
+	// MaxBackups is optional. When set, only this many rotated files are
+	// kept.
+	MaxBackups int
+	// MaxAgeDays is optional. When set, rotated files older than this many
+	// days are deleted.
+	MaxAgeDays int
+
+	// FlushInterval is optional. It defaults to 5 seconds.
+	FlushInterval time.Duration
+	// MaxBufferedEvents is optional. It is the maximum number of events
+	// buffered, e.g. while the file cannot be written, and defaults to 10000.
+	// The oldest events are dropped beyond it.
+	MaxBufferedEvents int
+}
+
+// FileSink buffers audit events and appends them as JSON lines to a file,
+// which is rotated by lumberjack once it reaches its maximum size. Buffered
+// events are flushed periodically while the sink is running as a manager
+// runnable and once more when it is stopped. The oldest events are dropped
+// and counted in capa_iam_audit_events_dropped_total when the buffer is full.
+type FileSink struct {
+	flushInterval     time.Duration
+	maxBufferedEvents int
+	log               logr.Logger
+
+	mu     sync.Mutex
+	buffer [][]byte
+
+	// flushMu serializes flushes, which own the file.
+	flushMu sync.Mutex
+	file    *lumberjack.Logger
+}
+
+func NewFileSink(config FileSinkConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, errors.New("cannot create FileSink with empty Path")
+	}
+	if config.MaxSizeMB < 0 || config.MaxBackups < 0 || config.MaxAgeDays < 0 {
+		return nil, errors.New("cannot create FileSink with negative MaxSizeMB, MaxBackups or MaxAgeDays")
+	}
+	if config.MaxSizeMB == 0 {
+		config.MaxSizeMB = defaultMaxSizeMB
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.MaxBufferedEvents <= 0 {
+		config.MaxBufferedEvents = defaultMaxBufferedEvents
+	}
+
+	s := &FileSink{
+		flushInterval:     config.FlushInterval,
+		maxBufferedEvents: config.MaxBufferedEvents,
+		log:               config.Log.WithValues("path", config.Path),
+
+		file: &lumberjack.Logger{
+			Filename:   config.Path,
+			MaxSize:    config.MaxSizeMB,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAgeDays,
+		},
+	}
+
+	return s, nil
+}
+
+// Write buffers the event until the next flush.
+func (s *FileSink) Write(event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		s.log.Error(err, "failed to marshal audit event", "action", event.Action)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer = append(s.buffer, append(message, '\n'))
+	s.dropOldest()
+}
+
+// Start flushes the buffered events every flush interval until the context is
+// cancelled. Remaining events are flushed and the file is closed before it
+// returns.
+func (s *FileSink) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := s.Flush()
+			if err != nil {
+				s.log.Error(err, "failed to flush audit events on shutdown")
+			}
+			err = s.Close()
+			if err != nil {
+				s.log.Error(err, "failed to close audit log file")
+			}
+			return nil
+		case <-ticker.C:
+			err := s.Flush()
+			if err != nil {
+				s.log.Error(err, "failed to flush audit events")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false, so that events of every replica are
+// flushed.
+func (s *FileSink) NeedLeaderElection() bool {
+	return false
+}
+
+// Flush appends the buffered events to the file. Events which could not be
+// written are kept for the next flush.
+func (s *FileSink) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	buffer := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	for i, line := range buffer {
+		_, err := s.file.Write(line)
+		if err != nil {
+			s.requeue(buffer[i:])
+			return fmt.Errorf("failed to write audit log file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the file. It is reopened by the next flush.
+func (s *FileSink) Close() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	err := s.file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close audit log file: %w", err)
+	}
+	return nil
+}
+
+// requeue puts lines back in front of the lines buffered since the flush
+// started, keeping them in chronological order.
+func (s *FileSink) requeue(lines [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer = append(lines, s.buffer...)
+	s.dropOldest()
+}
+
+// dropOldest drops the oldest lines beyond the maximum number of buffered
+// events. It must be called with mu held.
+func (s *FileSink) dropOldest() {
+	dropped := len(s.buffer) - s.maxBufferedEvents
+	if dropped <= 0 {
+		return
+	}
+
+	s.buffer = s.buffer[dropped:]
+	metrics.AuditEventsDroppedTotal.WithLabelValues("file").Add(float64(dropped))
+	s.log.Info("audit event buffer is full, dropped oldest events", "dropped", dropped)
+}
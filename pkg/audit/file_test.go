@@ -0,0 +1,165 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+var _ = Describe("FileSink", func() {
+
+	var (
+		dir  string
+		path string
+		sink *audit.FileSink
+	)
+
+	// writeMB writes and flushes events of roughly 1 KiB until at least the
+	// given number of megabytes were written.
+	writeMB := func(megabytes float64) {
+		resource := strings.Repeat("x", 900)
+		count := int(megabytes * 1024)
+		for i := 0; i < count; i++ {
+			sink.Write(audit.Event{
+				Time:        time.Now(),
+				ClusterName: "test-cluster",
+				Action:      "PutRolePolicy",
+				RoleName:    "test-role",
+				Resource:    resource,
+			})
+		}
+		Expect(sink.Flush()).To(Succeed())
+	}
+
+	backups := func() []string {
+		matches, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+		Expect(err).NotTo(HaveOccurred())
+		return matches
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "audit.log")
+
+		var err error
+		sink, err = audit.NewFileSink(audit.FileSinkConfig{
+			Path:       path,
+			Log:        ctrl.Log,
+			MaxSizeMB:  1,
+			MaxBackups: 2,
+			MaxAgeDays: 30,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(sink.Close()).To(Succeed())
+	})
+
+	It("writes events as JSON lines", func() {
+		sink.Write(audit.Event{
+			Time:        time.Now(),
+			ClusterName: "test-cluster",
+			Action:      "CreateRole",
+			RoleName:    "test-role",
+		})
+		Expect(sink.Flush()).To(Succeed())
+
+		file, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		Expect(scanner.Scan()).To(BeTrue())
+		var event audit.Event
+		Expect(json.Unmarshal(scanner.Bytes(), &event)).To(Succeed())
+		Expect(event.Action).To(Equal("CreateRole"))
+		Expect(event.RoleName).To(Equal("test-role"))
+		Expect(scanner.Scan()).To(BeFalse())
+	})
+
+	It("rotates the file once it reaches the maximum size", func() {
+		writeMB(1.5)
+
+		Expect(backups()).To(HaveLen(1))
+		backupInfo, err := os.Stat(backups()[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backupInfo.Size()).To(BeNumerically("<=", 1024*1024))
+		Expect(backupInfo.Size()).To(BeNumerically(">", 1000*1024))
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(BeNumerically("<", 600*1024))
+	})
+
+	It("keeps at most the configured number of backups", func() {
+		for i := 0; i < 4; i++ {
+			writeMB(1)
+			// Backups are named by millisecond.
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		// lumberjack deletes backups in the background
+		Eventually(backups).Should(HaveLen(2))
+	})
+
+	It("deletes backups older than the maximum age", func() {
+		oldBackup := filepath.Join(dir, "audit-"+time.Now().Add(-31*24*time.Hour).UTC().Format("2006-01-02T15-04-05.000")+".log")
+		Expect(os.WriteFile(oldBackup, []byte("{}\n"), 0o600)).To(Succeed())
+
+		writeMB(1.5)
+
+		Eventually(oldBackup).ShouldNot(BeAnExistingFile())
+		Eventually(backups).Should(HaveLen(1))
+	})
+
+	It("appends to an existing file", func() {
+		Expect(os.WriteFile(path, []byte("{}\n"), 0o600)).To(Succeed())
+
+		sink.Write(audit.Event{Time: time.Now(), ClusterName: "test-cluster", Action: "CreateRole"})
+		Expect(sink.Flush()).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Count(string(data), "\n")).To(Equal(2))
+	})
+
+	It("drops the oldest events when the buffer is full", func() {
+		sink, err := audit.NewFileSink(audit.FileSinkConfig{
+			Path:              filepath.Join(dir, "full", "audit.log"),
+			Log:               ctrl.Log,
+			MaxBufferedEvents: 2,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer sink.Close()
+
+		// the directory cannot be created while a file is in its place
+		Expect(os.WriteFile(filepath.Join(dir, "full"), nil, 0o600)).To(Succeed())
+
+		dropped := testutil.ToFloat64(metrics.AuditEventsDroppedTotal.WithLabelValues("file"))
+		for _, action := range []string{"CreateRole", "PutRolePolicy", "DeleteRole"} {
+			sink.Write(audit.Event{Time: time.Now(), ClusterName: "test-cluster", Action: action})
+		}
+		Expect(sink.Flush()).NotTo(Succeed())
+		Expect(testutil.ToFloat64(metrics.AuditEventsDroppedTotal.WithLabelValues("file")) - dropped).To(Equal(float64(1)))
+
+		Expect(os.Remove(filepath.Join(dir, "full"))).To(Succeed())
+		Expect(sink.Flush()).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(dir, "full", "audit.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring(`"action":"CreateRole"`))
+		Expect(string(data)).To(ContainSubstring(`"action":"DeleteRole"`))
+	})
+})
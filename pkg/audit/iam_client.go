@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+)
+
+// iamClient wraps an IAM client and writes an audit event for every mutating
+// call made by the operator. Read-only calls are passed through unchanged.
+type iamClient struct {
+	iamiface.IAMAPI
+
+	clusterName string
+	sink        Sink
+}
+
+// WrapIAMClient returns an IAM client which writes an audit event to sink for
+// every IAM mutation.
+func WrapIAMClient(client iamiface.IAMAPI, sink Sink, clusterName string) iamiface.IAMAPI {
+	return &iamClient{
+		IAMAPI:      client,
+		clusterName: clusterName,
+		sink:        sink,
+	}
+}
+
+func (c *iamClient) write(action string, roleName, resource *string, err error) {
+	event := Event{
+		Time:        time.Now().UTC(),
+		ClusterName: c.clusterName,
+		Action:      action,
+		RoleName:    aws.StringValue(roleName),
+		Resource:    aws.StringValue(resource),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	c.sink.Write(event)
+}
+
+func (c *iamClient) AddRoleToInstanceProfile(input *iam.AddRoleToInstanceProfileInput) (*iam.AddRoleToInstanceProfileOutput, error) {
+	output, err := c.IAMAPI.AddRoleToInstanceProfile(input)
+	c.write("AddRoleToInstanceProfile", input.RoleName, input.InstanceProfileName, err)
+	return output, err
+}
+
+func (c *iamClient) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	output, err := c.IAMAPI.AttachRolePolicy(input)
+	c.write("AttachRolePolicy", input.RoleName, input.PolicyArn, err)
+	return output, err
+}
+
+func (c *iamClient) CreateInstanceProfile(input *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput, error) {
+	output, err := c.IAMAPI.CreateInstanceProfile(input)
+	c.write("CreateInstanceProfile", nil, input.InstanceProfileName, err)
+	return output, err
+}
+
+func (c *iamClient) CreateOpenIDConnectProvider(input *iam.CreateOpenIDConnectProviderInput) (*iam.CreateOpenIDConnectProviderOutput, error) {
+	output, err := c.IAMAPI.CreateOpenIDConnectProvider(input)
+	c.write("CreateOpenIDConnectProvider", nil, input.Url, err)
+	return output, err
+}
+
+func (c *iamClient) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	output, err := c.IAMAPI.CreateRole(input)
+	c.write("CreateRole", input.RoleName, nil, err)
+	return output, err
+}
+
+func (c *iamClient) DeleteInstanceProfile(input *iam.DeleteInstanceProfileInput) (*iam.DeleteInstanceProfileOutput, error) {
+	output, err := c.IAMAPI.DeleteInstanceProfile(input)
+	c.write("DeleteInstanceProfile", nil, input.InstanceProfileName, err)
+	return output, err
+}
+
+func (c *iamClient) DeleteOpenIDConnectProvider(input *iam.DeleteOpenIDConnectProviderInput) (*iam.DeleteOpenIDConnectProviderOutput, error) {
+	output, err := c.IAMAPI.DeleteOpenIDConnectProvider(input)
+	c.write("DeleteOpenIDConnectProvider", nil, input.OpenIDConnectProviderArn, err)
+	return output, err
+}
+
+func (c *iamClient) DeleteRole(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	output, err := c.IAMAPI.DeleteRole(input)
+	c.write("DeleteRole", input.RoleName, nil, err)
+	return output, err
+}
+
+func (c *iamClient) DeleteRolePolicy(input *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	output, err := c.IAMAPI.DeleteRolePolicy(input)
+	c.write("DeleteRolePolicy", input.RoleName, input.PolicyName, err)
+	return output, err
+}
+
+func (c *iamClient) DetachRolePolicy(input *iam.DetachRolePolicyInput) (*iam.DetachRolePolicyOutput, error) {
+	output, err := c.IAMAPI.DetachRolePolicy(input)
+	c.write("DetachRolePolicy", input.RoleName, input.PolicyArn, err)
+	return output, err
+}
+
+func (c *iamClient) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	output, err := c.IAMAPI.PutRolePolicy(input)
+	c.write("PutRolePolicy", input.RoleName, input.PolicyName, err)
+	return output, err
+}
+
+func (c *iamClient) RemoveRoleFromInstanceProfile(input *iam.RemoveRoleFromInstanceProfileInput) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	output, err := c.IAMAPI.RemoveRoleFromInstanceProfile(input)
+	c.write("RemoveRoleFromInstanceProfile", input.RoleName, input.InstanceProfileName, err)
+	return output, err
+}
+
+func (c *iamClient) TagInstanceProfile(input *iam.TagInstanceProfileInput) (*iam.TagInstanceProfileOutput, error) {
+	output, err := c.IAMAPI.TagInstanceProfile(input)
+	c.write("TagInstanceProfile", nil, input.InstanceProfileName, err)
+	return output, err
+}
+
+func (c *iamClient) TagRole(input *iam.TagRoleInput) (*iam.TagRoleOutput, error) {
+	output, err := c.IAMAPI.TagRole(input)
+	c.write("TagRole", input.RoleName, nil, err)
+	return output, err
+}
+
+func (c *iamClient) UntagInstanceProfile(input *iam.UntagInstanceProfileInput) (*iam.UntagInstanceProfileOutput, error) {
+	output, err := c.IAMAPI.UntagInstanceProfile(input)
+	c.write("UntagInstanceProfile", nil, input.InstanceProfileName, err)
+	return output, err
+}
+
+func (c *iamClient) UntagRole(input *iam.UntagRoleInput) (*iam.UntagRoleOutput, error) {
+	output, err := c.IAMAPI.UntagRole(input)
+	c.write("UntagRole", input.RoleName, nil, err)
+	return output, err
+}
+
+func (c *iamClient) UpdateAssumeRolePolicy(input *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	output, err := c.IAMAPI.UpdateAssumeRolePolicy(input)
+	c.write("UpdateAssumeRolePolicy", input.RoleName, nil, err)
+	return output, err
+}
@@ -0,0 +1,127 @@
+package audit_test
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *recordingSink) Write(event audit.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+var _ = Describe("WrapIAMClient", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		sink          *recordingSink
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+		sink = &recordingSink{}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("writes an audit event for mutations", func() {
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&iam.PutRolePolicyOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(nil, errors.New("access denied"))
+
+		client := audit.WrapIAMClient(mockIAMClient, sink, "test-cluster")
+		_, err := client.PutRolePolicy(&iam.PutRolePolicyInput{
+			RoleName:   aws.String("test-role"),
+			PolicyName: aws.String("test-policy"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.DeleteRole(&iam.DeleteRoleInput{RoleName: aws.String("test-role")})
+		Expect(err).To(HaveOccurred())
+
+		Expect(sink.events).To(HaveLen(2))
+		Expect(sink.events[0]).To(MatchFields(IgnoreExtras, Fields{
+			"ClusterName": Equal("test-cluster"),
+			"Action":      Equal("PutRolePolicy"),
+			"RoleName":    Equal("test-role"),
+			"Resource":    Equal("test-policy"),
+			"Error":       BeEmpty(),
+		}))
+		Expect(sink.events[1]).To(MatchFields(IgnoreExtras, Fields{
+			"Action":   Equal("DeleteRole"),
+			"RoleName": Equal("test-role"),
+			"Error":    Equal("access denied"),
+		}))
+	})
+
+	It("writes an audit event for instance profile tag changes", func() {
+		mockIAMClient.EXPECT().TagInstanceProfile(gomock.Any()).Return(&iam.TagInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().UntagInstanceProfile(gomock.Any()).Return(&iam.UntagInstanceProfileOutput{}, nil)
+
+		client := audit.WrapIAMClient(mockIAMClient, sink, "test-cluster")
+		_, err := client.TagInstanceProfile(&iam.TagInstanceProfileInput{InstanceProfileName: aws.String("test-role")})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.UntagInstanceProfile(&iam.UntagInstanceProfileInput{InstanceProfileName: aws.String("test-role")})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sink.events).To(HaveLen(2))
+		Expect(sink.events[0]).To(MatchFields(IgnoreExtras, Fields{
+			"Action":   Equal("TagInstanceProfile"),
+			"Resource": Equal("test-role"),
+		}))
+		Expect(sink.events[1]).To(MatchFields(IgnoreExtras, Fields{
+			"Action":   Equal("UntagInstanceProfile"),
+			"Resource": Equal("test-role"),
+		}))
+	})
+
+	It("writes an audit event for OIDC provider changes", func() {
+		mockIAMClient.EXPECT().CreateOpenIDConnectProvider(gomock.Any()).Return(&iam.CreateOpenIDConnectProviderOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteOpenIDConnectProvider(gomock.Any()).Return(&iam.DeleteOpenIDConnectProviderOutput{}, nil)
+
+		client := audit.WrapIAMClient(mockIAMClient, sink, "test-cluster")
+		_, err := client.CreateOpenIDConnectProvider(&iam.CreateOpenIDConnectProviderInput{Url: aws.String("https://irsa.example.com")})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.DeleteOpenIDConnectProvider(&iam.DeleteOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: aws.String("arn:aws:iam::012345678901:oidc-provider/irsa.example.com"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sink.events).To(HaveLen(2))
+		Expect(sink.events[0]).To(MatchFields(IgnoreExtras, Fields{
+			"Action":   Equal("CreateOpenIDConnectProvider"),
+			"Resource": Equal("https://irsa.example.com"),
+		}))
+		Expect(sink.events[1]).To(MatchFields(IgnoreExtras, Fields{
+			"Action":   Equal("DeleteOpenIDConnectProvider"),
+			"Resource": Equal("arn:aws:iam::012345678901:oidc-provider/irsa.example.com"),
+		}))
+	})
+
+	It("does not write audit events for reads", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&iam.GetRoleOutput{}, nil)
+
+		client := audit.WrapIAMClient(mockIAMClient, sink, "test-cluster")
+		_, err := client.GetRole(&iam.GetRoleInput{RoleName: aws.String("test-role")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sink.events).To(BeEmpty())
+	})
+})
@@ -2,28 +2,47 @@ package awsclient
 
 import (
 	"errors"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	clientaws "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/giantswarm/microerror"
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/cache"
 )
 
 type AwsClientInterface interface {
+	// GetAWSClientSession returns a session assuming awsRoleARN, or a session
+	// with the controller's own credentials if awsRoleARN is empty.
 	GetAWSClientSession(awsRoleARN string, region string) (clientaws.ConfigProvider, error)
+	// GetCallerIdentity returns the caller identity of the session of
+	// GetAWSClientSession. It is cached by awsRoleARN.
+	GetCallerIdentity(awsRoleARN string, region string) (*sts.GetCallerIdentityOutput, error)
 }
 
 type AWSClientConfig struct {
 	CtrlClient client.Client
 	Log        logr.Logger
+
+	// CacheTTL is optional. Caller identities are cached for this duration
+	// when set.
+	CacheTTL time.Duration
+	// STSClientFactory is optional and defaults to the STS client of the AWS
+	// SDK.
+	STSClientFactory func(clientaws.ConfigProvider, string) stsiface.STSAPI
 }
 
 type AwsClient struct {
-	ctrlClient client.Client
-	log        logr.Logger
+	ctrlClient       client.Client
+	log              logr.Logger
+	stsClientFactory func(clientaws.ConfigProvider, string) stsiface.STSAPI
+	callerIdentities *cache.TTLCache[*sts.GetCallerIdentityOutput]
 }
 
 func New(config AWSClientConfig) (*AwsClient, error) {
@@ -31,9 +50,18 @@ func New(config AWSClientConfig) (*AwsClient, error) {
 		return nil, errors.New("failed to generate new awsClient from nil CtrlClient")
 	}
 
+	stsClientFactory := config.STSClientFactory
+	if stsClientFactory == nil {
+		stsClientFactory = func(session clientaws.ConfigProvider, region string) stsiface.STSAPI {
+			return sts.New(session, &aws.Config{Region: aws.String(region)})
+		}
+	}
+
 	a := &AwsClient{
-		ctrlClient: config.CtrlClient,
-		log:        config.Log,
+		ctrlClient:       config.CtrlClient,
+		log:              config.Log,
+		stsClientFactory: stsClientFactory,
+		callerIdentities: cache.NewTTLCache[*sts.GetCallerIdentityOutput](config.CacheTTL),
 	}
 
 	return a, nil
@@ -46,12 +74,39 @@ func (a *AwsClient) GetAWSClientSession(awsRoleARN string, region string) (clien
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
+	if awsRoleARN == "" {
+		AddRequestIDHandlers(&ns.Handlers, a.log)
+		AddMetricsHandlers(&ns.Handlers)
+		return ns, nil
+	}
 	awsClientConfig := &aws.Config{Credentials: stscreds.NewCredentials(ns, awsRoleARN)}
 
 	o, err := session.NewSession(awsClientConfig)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
+	AddRequestIDHandlers(&o.Handlers, a.log)
+	AddMetricsHandlers(&o.Handlers)
 
 	return o, nil
 }
+
+func (a *AwsClient) GetCallerIdentity(awsRoleARN string, region string) (*sts.GetCallerIdentityOutput, error) {
+	// the identity of a role does not depend on the region
+	if identity, ok := a.callerIdentities.Get(awsRoleARN); ok {
+		return identity, nil
+	}
+
+	session, err := a.GetAWSClientSession(awsRoleARN, region)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	identity, err := a.stsClientFactory(session, region).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	a.callerIdentities.Set(awsRoleARN, identity)
+	return identity, nil
+}
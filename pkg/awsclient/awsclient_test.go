@@ -0,0 +1,85 @@
+package awsclient_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	clientaws "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("GetCallerIdentity", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockSTSClient *mocks.MockSTSAPI
+		newAWSClient  func(cacheTTL time.Duration) *awsclient.AwsClient
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockSTSClient = mocks.NewMockSTSAPI(mockCtrl)
+
+		newAWSClient = func(cacheTTL time.Duration) *awsclient.AwsClient {
+			client, err := awsclient.New(awsclient.AWSClientConfig{
+				CtrlClient: fake.NewClientBuilder().Build(),
+				Log:        ctrl.Log,
+				CacheTTL:   cacheTTL,
+				STSClientFactory: func(session clientaws.ConfigProvider, region string) stsiface.STSAPI {
+					return mockSTSClient
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return client
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("calls STS only once within the TTL", func() {
+		mockSTSClient.EXPECT().GetCallerIdentity(&sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+			Account: aws.String("012345678901"),
+		}, nil).Times(1)
+
+		client := newAWSClient(time.Minute)
+		for i := 0; i < 3; i++ {
+			identity, err := client.GetCallerIdentity("", "eu-west-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*identity.Account).To(Equal("012345678901"))
+		}
+	})
+
+	It("calls STS again after the TTL", func() {
+		mockSTSClient.EXPECT().GetCallerIdentity(&sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+			Account: aws.String("012345678901"),
+		}, nil).Times(2)
+
+		client := newAWSClient(10 * time.Millisecond)
+		_, err := client.GetCallerIdentity("", "eu-west-1")
+		Expect(err).NotTo(HaveOccurred())
+		time.Sleep(20 * time.Millisecond)
+		_, err = client.GetCallerIdentity("", "eu-west-1")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("does not cache errors", func() {
+		mockSTSClient.EXPECT().GetCallerIdentity(gomock.Any()).Return(nil, errors.New("access denied")).Times(2)
+
+		client := newAWSClient(time.Minute)
+		for i := 0; i < 2; i++ {
+			_, err := client.GetCallerIdentity("", "eu-west-1")
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
@@ -0,0 +1,41 @@
+package awsclient
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+const (
+	metricsAfterRetryHandlerName = "capa-iam-operator.MetricsAfterRetryHandler"
+	metricsCompleteHandlerName   = "capa-iam-operator.MetricsCompleteHandler"
+)
+
+// AddMetricsHandlers observes the duration of every AWS API call made with the
+// given handlers in metrics.AWSAPIDuration and counts throttled attempts in
+// metrics.AWSAPIThrottlesTotal.
+func AddMetricsHandlers(handlers *request.Handlers) {
+	handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+		Name: metricsAfterRetryHandlerName,
+		Fn: func(r *request.Request) {
+			if r.Error != nil && request.IsErrorThrottle(r.Error) {
+				metrics.AWSAPIThrottlesTotal.WithLabelValues(operationName(r)).Inc()
+			}
+		},
+	})
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: metricsCompleteHandlerName,
+		Fn: func(r *request.Request) {
+			metrics.AWSAPIDuration.WithLabelValues(operationName(r)).Observe(time.Since(r.Time).Seconds())
+		},
+	})
+}
+
+func operationName(r *request.Request) string {
+	if r.Operation == nil {
+		return ""
+	}
+	return r.Operation.Name
+}
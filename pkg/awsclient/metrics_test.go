@@ -0,0 +1,79 @@
+package awsclient_test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+var _ = Describe("AddMetricsHandlers", func() {
+
+	var (
+		sendErr error
+		req     *request.Request
+	)
+
+	sampleCount := func(operation string) uint64 {
+		metric := &dto.Metric{}
+		histogram := metrics.AWSAPIDuration.WithLabelValues(operation).(prometheus.Histogram)
+		Expect(histogram.Write(metric)).To(Succeed())
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	BeforeEach(func() {
+		sendErr = nil
+	})
+
+	JustBeforeEach(func() {
+		handlers := request.Handlers{}
+		handlers.Send.PushBack(func(r *request.Request) {
+			r.HTTPResponse = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+			r.Error = sendErr
+		})
+		awsclient.AddMetricsHandlers(&handlers)
+
+		req = request.New(
+			aws.Config{},
+			metadata.ClientInfo{ServiceName: "iam", Endpoint: "https://iam.amazonaws.com"},
+			handlers,
+			client.DefaultRetryer{},
+			&request.Operation{Name: "ListRoles", HTTPMethod: "POST", HTTPPath: "/"},
+			nil,
+			nil,
+		)
+	})
+
+	It("observes the duration of the API call", func() {
+		before := sampleCount("ListRoles")
+
+		Expect(req.Send()).To(Succeed())
+
+		Expect(sampleCount("ListRoles")).To(Equal(before + 1))
+	})
+
+	When("the API call is throttled", func() {
+		BeforeEach(func() {
+			sendErr = awserr.New("Throttling", "Rate exceeded", nil)
+		})
+
+		It("counts the throttled attempt", func() {
+			before := testutil.ToFloat64(metrics.AWSAPIThrottlesTotal.WithLabelValues("ListRoles"))
+
+			Expect(req.Send()).NotTo(Succeed())
+
+			Expect(testutil.ToFloat64(metrics.AWSAPIThrottlesTotal.WithLabelValues("ListRoles"))).To(Equal(before + 1))
+		})
+	})
+})
@@ -0,0 +1,17 @@
+package awsclient
+
+import (
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// Partition returns the ID of the AWS partition of the region, e.g.
+// aws-us-gov for us-gov-west-1 or aws-cn for cn-north-1. Regions unknown to
+// the AWS SDK are matched by the region name pattern of each partition and
+// fall back to the aws partition.
+func Partition(region string) string {
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return partition.ID()
+	}
+
+	return endpoints.AwsPartitionID
+}
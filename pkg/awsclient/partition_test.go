@@ -0,0 +1,22 @@
+package awsclient_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+)
+
+var _ = Describe("Partition", func() {
+	DescribeTable("returns the partition of the region",
+		func(region, expected string) {
+			Expect(awsclient.Partition(region)).To(Equal(expected))
+		},
+		Entry("commercial region", "eu-west-1", "aws"),
+		Entry("GovCloud region", "us-gov-west-1", "aws-us-gov"),
+		Entry("China region", "cn-north-1", "aws-cn"),
+		Entry("unknown GovCloud region", "us-gov-north-9", "aws-us-gov"),
+		Entry("unknown China region", "cn-south-9", "aws-cn"),
+		Entry("empty region", "", "aws"),
+	)
+})
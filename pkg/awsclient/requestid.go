@@ -0,0 +1,48 @@
+package awsclient
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/go-logr/logr"
+)
+
+const (
+	requestIDAfterRetryHandlerName = "capa-iam-operator.RequestIDAfterRetryHandler"
+	requestIDCompleteHandlerName   = "capa-iam-operator.RequestIDCompleteHandler"
+)
+
+// AddRequestIDHandlers logs the AWS request ID of every API call made with
+// the given handlers, together with the operation name and its outcome. The
+// request ID is needed when opening AWS support cases.
+//
+// Failed attempts are logged from the AfterRetry handler list, before the SDK
+// clears the error of retried requests. The final outcome of every call is
+// logged from the Complete handler list.
+func AddRequestIDHandlers(handlers *request.Handlers, log logr.Logger) {
+	handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+		Name: requestIDAfterRetryHandlerName,
+		Fn: func(r *request.Request) {
+			if r.Error == nil {
+				return
+			}
+			requestLogger(log, r).Info("AWS API call attempt failed", "outcome", "error", "error", r.Error.Error(), "retry_count", r.RetryCount)
+		},
+	})
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: requestIDCompleteHandlerName,
+		Fn: func(r *request.Request) {
+			if r.Error != nil {
+				requestLogger(log, r).Info("AWS API call failed", "outcome", "error", "error", r.Error.Error())
+				return
+			}
+			requestLogger(log, r).V(1).Info("AWS API call succeeded", "outcome", "success")
+		},
+	})
+}
+
+func requestLogger(log logr.Logger, r *request.Request) logr.Logger {
+	l := log.WithValues("request_id", r.RequestID, "service", r.ClientInfo.ServiceName)
+	if r.Operation != nil {
+		l = l.WithValues("operation", r.Operation.Name)
+	}
+	return l
+}
@@ -0,0 +1,82 @@
+package awsclient_test
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+)
+
+var _ = Describe("AddRequestIDHandlers", func() {
+
+	var (
+		logs    []string
+		sendErr error
+		req     *request.Request
+	)
+
+	BeforeEach(func() {
+		logs = nil
+		sendErr = nil
+	})
+
+	JustBeforeEach(func() {
+		logger := funcr.New(func(prefix, args string) {
+			logs = append(logs, args)
+		}, funcr.Options{Verbosity: 1})
+
+		handlers := request.Handlers{}
+		handlers.Send.PushBack(func(r *request.Request) {
+			r.RequestID = "mock-request-id"
+			r.HTTPResponse = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+			r.Error = sendErr
+		})
+		awsclient.AddRequestIDHandlers(&handlers, logger)
+
+		req = request.New(
+			aws.Config{},
+			metadata.ClientInfo{ServiceName: "iam", Endpoint: "https://iam.amazonaws.com"},
+			handlers,
+			client.DefaultRetryer{},
+			&request.Operation{Name: "GetRole", HTTPMethod: "POST", HTTPPath: "/"},
+			nil,
+			nil,
+		)
+	})
+
+	When("the API call succeeds", func() {
+		It("logs the request ID, operation and outcome", func() {
+			Expect(req.Send()).To(Succeed())
+
+			Expect(logs).To(HaveLen(1))
+			Expect(logs[0]).To(ContainSubstring(`"request_id"="mock-request-id"`))
+			Expect(logs[0]).To(ContainSubstring(`"operation"="GetRole"`))
+			Expect(logs[0]).To(ContainSubstring(`"outcome"="success"`))
+		})
+	})
+
+	When("the API call fails", func() {
+		BeforeEach(func() {
+			sendErr = errors.New("access denied")
+		})
+
+		It("logs the request ID of the failed attempt and the final outcome", func() {
+			Expect(req.Send()).NotTo(Succeed())
+
+			Expect(logs).To(HaveLen(2))
+			for _, line := range logs {
+				Expect(line).To(ContainSubstring(`"request_id"="mock-request-id"`))
+				Expect(line).To(ContainSubstring(`"operation"="GetRole"`))
+				Expect(line).To(ContainSubstring(`"outcome"="error"`))
+			}
+		})
+	})
+})
@@ -0,0 +1,227 @@
+package awsclient
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/go-logr/logr"
+)
+
+// DefaultThrottlingBaseBackoff is the backoff before the first retry of a
+// throttled call when ThrottlingConfig.BaseBackoff is not set.
+const DefaultThrottlingBaseBackoff = 100 * time.Millisecond
+
+// ThrottlingConfig configures the retries of throttled IAM API calls.
+type ThrottlingConfig struct {
+	// MaxRetries is the maximum number of retries of a throttled call. Zero
+	// disables the retries.
+	MaxRetries int
+	// MaxBackoff caps the exponential backoff between two retries.
+	MaxBackoff time.Duration
+	// BaseBackoff is optional and defaults to DefaultThrottlingBaseBackoff.
+	BaseBackoff time.Duration
+	// Context is optional. When it is cancelled, e.g. on shutdown, pending
+	// calls are aborted and no more retries are made. It defaults to
+	// context.Background.
+	Context context.Context
+	Log     logr.Logger
+}
+
+// throttlingIAMClient wraps an IAM client and retries the calls made by the
+// operator which were throttled by AWS. The SDK retries throttled calls only a
+// few times, so many clusters reconciling at once still exceed the IAM rate
+// limits. The full jitter spreads the retries of all workers over the backoff
+// instead of retrying them at the same time.
+type throttlingIAMClient struct {
+	iamiface.IAMAPI
+
+	config ThrottlingConfig
+}
+
+// WrapIAMClientWithThrottlingRetries returns an IAM client which retries
+// throttled calls with exponential backoff and full jitter.
+func WrapIAMClientWithThrottlingRetries(client iamiface.IAMAPI, config ThrottlingConfig) iamiface.IAMAPI {
+	if config.BaseBackoff == 0 {
+		config.BaseBackoff = DefaultThrottlingBaseBackoff
+	}
+	if config.Context == nil {
+		config.Context = context.Background()
+	}
+	return &throttlingIAMClient{
+		IAMAPI: client,
+		config: config,
+	}
+}
+
+// backoff returns a random duration between zero and the exponential backoff
+// of the given retry, capped at MaxBackoff.
+func (c *throttlingIAMClient) backoff(retry int) time.Duration {
+	backoff := c.config.MaxBackoff
+	if retry < 32 && c.config.BaseBackoff<<retry < backoff {
+		backoff = c.config.BaseBackoff << retry
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+func withThrottlingRetries[T any](c *throttlingIAMClient, operation string, call func(ctx context.Context) (T, error)) (T, error) {
+	output, err := call(c.config.Context)
+	for retry := 0; retry < c.config.MaxRetries && err != nil && request.IsErrorThrottle(err); retry++ {
+		backoff := c.backoff(retry)
+		c.config.Log.Info("AWS API call was throttled, retrying", "operation", operation, "retry", retry+1, "backoff", backoff.String())
+		sleepErr := aws.SleepWithContext(c.config.Context, backoff)
+		if sleepErr != nil {
+			return output, fmt.Errorf("aborted retries of throttled %s call: %w", operation, sleepErr)
+		}
+		output, err = call(c.config.Context)
+	}
+	return output, err
+}
+
+func (c *throttlingIAMClient) AddRoleToInstanceProfile(input *iam.AddRoleToInstanceProfileInput) (*iam.AddRoleToInstanceProfileOutput, error) {
+	return withThrottlingRetries(c, "AddRoleToInstanceProfile", func(ctx context.Context) (*iam.AddRoleToInstanceProfileOutput, error) {
+		return c.IAMAPI.AddRoleToInstanceProfileWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	return withThrottlingRetries(c, "AttachRolePolicy", func(ctx context.Context) (*iam.AttachRolePolicyOutput, error) {
+		return c.IAMAPI.AttachRolePolicyWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) CreateInstanceProfile(input *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput, error) {
+	return withThrottlingRetries(c, "CreateInstanceProfile", func(ctx context.Context) (*iam.CreateInstanceProfileOutput, error) {
+		return c.IAMAPI.CreateInstanceProfileWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	return withThrottlingRetries(c, "CreateRole", func(ctx context.Context) (*iam.CreateRoleOutput, error) {
+		return c.IAMAPI.CreateRoleWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) CreateServiceLinkedRole(input *iam.CreateServiceLinkedRoleInput) (*iam.CreateServiceLinkedRoleOutput, error) {
+	return withThrottlingRetries(c, "CreateServiceLinkedRole", func(ctx context.Context) (*iam.CreateServiceLinkedRoleOutput, error) {
+		return c.IAMAPI.CreateServiceLinkedRoleWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) DeleteInstanceProfile(input *iam.DeleteInstanceProfileInput) (*iam.DeleteInstanceProfileOutput, error) {
+	return withThrottlingRetries(c, "DeleteInstanceProfile", func(ctx context.Context) (*iam.DeleteInstanceProfileOutput, error) {
+		return c.IAMAPI.DeleteInstanceProfileWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) DeleteRole(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	return withThrottlingRetries(c, "DeleteRole", func(ctx context.Context) (*iam.DeleteRoleOutput, error) {
+		return c.IAMAPI.DeleteRoleWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) DeleteRolePolicy(input *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	return withThrottlingRetries(c, "DeleteRolePolicy", func(ctx context.Context) (*iam.DeleteRolePolicyOutput, error) {
+		return c.IAMAPI.DeleteRolePolicyWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) DetachRolePolicy(input *iam.DetachRolePolicyInput) (*iam.DetachRolePolicyOutput, error) {
+	return withThrottlingRetries(c, "DetachRolePolicy", func(ctx context.Context) (*iam.DetachRolePolicyOutput, error) {
+		return c.IAMAPI.DetachRolePolicyWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	return withThrottlingRetries(c, "GetRole", func(ctx context.Context) (*iam.GetRoleOutput, error) { return c.IAMAPI.GetRoleWithContext(ctx, input) })
+}
+
+func (c *throttlingIAMClient) GetRolePolicy(input *iam.GetRolePolicyInput) (*iam.GetRolePolicyOutput, error) {
+	return withThrottlingRetries(c, "GetRolePolicy", func(ctx context.Context) (*iam.GetRolePolicyOutput, error) {
+		return c.IAMAPI.GetRolePolicyWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) ListAttachedRolePolicies(input *iam.ListAttachedRolePoliciesInput) (*iam.ListAttachedRolePoliciesOutput, error) {
+	return withThrottlingRetries(c, "ListAttachedRolePolicies", func(ctx context.Context) (*iam.ListAttachedRolePoliciesOutput, error) {
+		return c.IAMAPI.ListAttachedRolePoliciesWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) ListInstanceProfileTags(input *iam.ListInstanceProfileTagsInput) (*iam.ListInstanceProfileTagsOutput, error) {
+	return withThrottlingRetries(c, "ListInstanceProfileTags", func(ctx context.Context) (*iam.ListInstanceProfileTagsOutput, error) {
+		return c.IAMAPI.ListInstanceProfileTagsWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) ListRolePolicies(input *iam.ListRolePoliciesInput) (*iam.ListRolePoliciesOutput, error) {
+	return withThrottlingRetries(c, "ListRolePolicies", func(ctx context.Context) (*iam.ListRolePoliciesOutput, error) {
+		return c.IAMAPI.ListRolePoliciesWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) ListRoleTags(input *iam.ListRoleTagsInput) (*iam.ListRoleTagsOutput, error) {
+	return withThrottlingRetries(c, "ListRoleTags", func(ctx context.Context) (*iam.ListRoleTagsOutput, error) {
+		return c.IAMAPI.ListRoleTagsWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) ListRoles(input *iam.ListRolesInput) (*iam.ListRolesOutput, error) {
+	return withThrottlingRetries(c, "ListRoles", func(ctx context.Context) (*iam.ListRolesOutput, error) {
+		return c.IAMAPI.ListRolesWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	return withThrottlingRetries(c, "PutRolePolicy", func(ctx context.Context) (*iam.PutRolePolicyOutput, error) {
+		return c.IAMAPI.PutRolePolicyWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) RemoveRoleFromInstanceProfile(input *iam.RemoveRoleFromInstanceProfileInput) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	return withThrottlingRetries(c, "RemoveRoleFromInstanceProfile", func(ctx context.Context) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+		return c.IAMAPI.RemoveRoleFromInstanceProfileWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) SimulatePrincipalPolicy(input *iam.SimulatePrincipalPolicyInput) (*iam.SimulatePolicyResponse, error) {
+	return withThrottlingRetries(c, "SimulatePrincipalPolicy", func(ctx context.Context) (*iam.SimulatePolicyResponse, error) {
+		return c.IAMAPI.SimulatePrincipalPolicyWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) TagInstanceProfile(input *iam.TagInstanceProfileInput) (*iam.TagInstanceProfileOutput, error) {
+	return withThrottlingRetries(c, "TagInstanceProfile", func(ctx context.Context) (*iam.TagInstanceProfileOutput, error) {
+		return c.IAMAPI.TagInstanceProfileWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) TagRole(input *iam.TagRoleInput) (*iam.TagRoleOutput, error) {
+	return withThrottlingRetries(c, "TagRole", func(ctx context.Context) (*iam.TagRoleOutput, error) { return c.IAMAPI.TagRoleWithContext(ctx, input) })
+}
+
+func (c *throttlingIAMClient) UntagInstanceProfile(input *iam.UntagInstanceProfileInput) (*iam.UntagInstanceProfileOutput, error) {
+	return withThrottlingRetries(c, "UntagInstanceProfile", func(ctx context.Context) (*iam.UntagInstanceProfileOutput, error) {
+		return c.IAMAPI.UntagInstanceProfileWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) UntagRole(input *iam.UntagRoleInput) (*iam.UntagRoleOutput, error) {
+	return withThrottlingRetries(c, "UntagRole", func(ctx context.Context) (*iam.UntagRoleOutput, error) {
+		return c.IAMAPI.UntagRoleWithContext(ctx, input)
+	})
+}
+
+func (c *throttlingIAMClient) UpdateAssumeRolePolicy(input *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	return withThrottlingRetries(c, "UpdateAssumeRolePolicy", func(ctx context.Context) (*iam.UpdateAssumeRolePolicyOutput, error) {
+		return c.IAMAPI.UpdateAssumeRolePolicyWithContext(ctx, input)
+	})
+}
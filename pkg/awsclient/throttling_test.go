@@ -0,0 +1,104 @@
+package awsclient_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/awsclient"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("WrapIAMClientWithThrottlingRetries", func() {
+	const maxRetries = 4
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		client        iamiface.IAMAPI
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		client = awsclient.WrapIAMClientWithThrottlingRetries(mockIAMClient, awsclient.ThrottlingConfig{
+			MaxRetries:  maxRetries,
+			MaxBackoff:  5 * time.Millisecond,
+			BaseBackoff: time.Millisecond,
+			Log:         ctrl.Log,
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	DescribeTable("retries throttled calls until they succeed",
+		func(code string) {
+			input := &iam.GetRoleInput{RoleName: aws.String("test-role")}
+			gomock.InOrder(
+				mockIAMClient.EXPECT().GetRoleWithContext(gomock.Any(), input).Return(nil, awserr.New(code, "Rate exceeded", nil)).Times(maxRetries),
+				mockIAMClient.EXPECT().GetRoleWithContext(gomock.Any(), input).Return(&iam.GetRoleOutput{Role: &iam.Role{RoleName: aws.String("test-role")}}, nil),
+			)
+
+			output, err := client.GetRole(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*output.Role.RoleName).To(Equal("test-role"))
+		},
+		Entry("Throttling", "Throttling"),
+		Entry("ThrottlingException", "ThrottlingException"),
+		Entry("RequestLimitExceeded", "RequestLimitExceeded"),
+	)
+
+	It("gives up after MaxRetries", func() {
+		mockIAMClient.EXPECT().PutRolePolicyWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil)).Times(maxRetries + 1)
+
+		_, err := client.PutRolePolicy(&iam.PutRolePolicyInput{RoleName: aws.String("test-role")})
+		Expect(err).To(MatchError(ContainSubstring("Rate exceeded")))
+	})
+
+	It("does not retry other errors", func() {
+		mockIAMClient.EXPECT().DeleteRoleWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New(iam.ErrCodeNoSuchEntityException, "not found", nil))
+
+		_, err := client.DeleteRole(&iam.DeleteRoleInput{RoleName: aws.String("test-role")})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not retry when MaxRetries is zero", func() {
+		client = awsclient.WrapIAMClientWithThrottlingRetries(mockIAMClient, awsclient.ThrottlingConfig{
+			Log: ctrl.Log,
+		})
+		mockIAMClient.EXPECT().TagRoleWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New("Throttling", "Rate exceeded", nil))
+
+		_, err := client.TagRole(&iam.TagRoleInput{RoleName: aws.String("test-role")})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("stops retrying when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		client = awsclient.WrapIAMClientWithThrottlingRetries(mockIAMClient, awsclient.ThrottlingConfig{
+			MaxRetries:  maxRetries,
+			MaxBackoff:  time.Hour,
+			BaseBackoff: time.Hour,
+			Context:     ctx,
+			Log:         ctrl.Log,
+		})
+		mockIAMClient.EXPECT().GetRoleWithContext(ctx, gomock.Any()).DoAndReturn(func(aws.Context, *iam.GetRoleInput, ...request.Option) (*iam.GetRoleOutput, error) {
+			cancel()
+			return nil, awserr.New("Throttling", "Rate exceeded", nil)
+		})
+
+		_, err := client.GetRole(&iam.GetRoleInput{RoleName: aws.String("test-role")})
+		Expect(err).To(MatchError(context.Canceled))
+	})
+})
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// TTLCache is a map whose entries expire after a fixed time-to-live. It is
+// safe for concurrent use. A nil TTLCache or one with a TTL of zero caches
+// nothing.
+type TTLCache[V any] struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry[V]
+}
+
+func NewTTLCache[V any](ttl time.Duration) *TTLCache[V] {
+	return &TTLCache[V]{
+		ttl:     ttl,
+		entries: map[string]entry[V]{},
+	}
+}
+
+// Get returns the value of key if it was set less than the TTL ago.
+func (c *TTLCache[V]) Get(key string) (V, bool) {
+	var zero V
+	if c == nil || c.ttl <= 0 {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores the value of key until the TTL has passed.
+func (c *TTLCache[V]) Set(key string, value V) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = entry[V]{
+		value:   value,
+		expires: time.Now().Add(c.ttl),
+	}
+}
+
+// Delete removes key, e.g. once the value it caches no longer exists.
+func (c *TTLCache[V]) Delete(key string) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
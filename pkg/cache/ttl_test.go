@@ -0,0 +1,78 @@
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/cache"
+)
+
+var _ = Describe("TTLCache", func() {
+	It("returns values until they expire", func() {
+		c := cache.NewTTLCache[string](50 * time.Millisecond)
+		c.Set("key", "value")
+
+		value, ok := c.Get("key")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal("value"))
+
+		Eventually(func() bool {
+			_, ok := c.Get("key")
+			return ok
+		}).Should(BeFalse())
+	})
+
+	It("does not return unknown keys", func() {
+		c := cache.NewTTLCache[string](time.Minute)
+
+		_, ok := c.Get("key")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not return deleted keys", func() {
+		c := cache.NewTTLCache[string](time.Minute)
+		c.Set("key", "value")
+		c.Delete("key")
+
+		_, ok := c.Get("key")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("caches nothing with a TTL of zero", func() {
+		c := cache.NewTTLCache[string](0)
+		c.Set("key", "value")
+
+		_, ok := c.Get("key")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("caches nothing when nil", func() {
+		var c *cache.TTLCache[string]
+		c.Set("key", "value")
+
+		_, ok := c.Get("key")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("is safe for concurrent use", func() {
+		c := cache.NewTTLCache[int](time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				key := fmt.Sprintf("key-%d", i%3)
+				c.Set(key, i)
+				_, ok := c.Get(key)
+				Expect(ok).To(BeTrue())
+			}(i)
+		}
+		wg.Wait()
+	})
+})
@@ -0,0 +1,145 @@
+// Package config loads the operator configuration from a YAML file or a
+// Secret. Every key of the configuration sets the command line flag of the
+// same name.
+package config
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const schemaURL = "config.schema.json"
+
+// SecretKey is the key of the JSON configuration in the data of the Secret
+// read by LoadSecret.
+const SecretKey = "config.json"
+
+// Schema is the JSON schema of the configuration file.
+//
+//go:embed schema.json
+var Schema string
+
+// Load validates the YAML configuration file at path against Schema and
+// applies its values to the flags of flagSet. Flags which were set on the
+// command line take precedence over the configuration file, so Load must be
+// called after flagSet was parsed.
+func Load(flagSet *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return load(flagSet, data, "config file "+path)
+}
+
+// LoadSecret validates the JSON configuration stored under SecretKey in the
+// Secret namespacedName, given as namespace/name, and applies its values to the
+// flags of flagSet like Load. Flags which were set on the command line or by a
+// previously loaded configuration file take precedence.
+func LoadSecret(ctx context.Context, c client.Reader, flagSet *flag.FlagSet, namespacedName string) error {
+	namespace, name, ok := strings.Cut(namespacedName, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("config secret %q must be given as namespace/name", namespacedName)
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret)
+	if err != nil {
+		return fmt.Errorf("failed to get config secret %s: %w", namespacedName, err)
+	}
+
+	data, ok := secret.Data[SecretKey]
+	if !ok {
+		return fmt.Errorf("config secret %s has no %s key", namespacedName, SecretKey)
+	}
+
+	return load(flagSet, data, "config secret "+namespacedName)
+}
+
+// load applies the configuration data read from source. JSON is a subset of
+// YAML, so both are accepted.
+func load(flagSet *flag.FlagSet, data []byte, source string) error {
+	err := validate(data)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", source, err)
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err = v.ReadConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+
+	pflagSet := pflag.NewFlagSet(flagSet.Name(), pflag.ContinueOnError)
+	pflagSet.AddGoFlagSet(flagSet)
+	err = v.BindPFlags(pflagSet)
+	if err != nil {
+		return fmt.Errorf("failed to bind flags: %w", err)
+	}
+
+	setOnCommandLine := map[string]bool{}
+	flagSet.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	var errs []error
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if setOnCommandLine[f.Name] || !v.InConfig(f.Name) {
+			return
+		}
+
+		err := flagSet.Set(f.Name, v.GetString(f.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for %s in %s: %w", f.Name, source, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+func validate(data []byte) error {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	err = decoder.Decode(&document)
+	if err != nil {
+		return err
+	}
+	// An empty file does not set anything.
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	err = compiler.AddResource(schemaURL, strings.NewReader(Schema))
+	if err != nil {
+		return err
+	}
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return err
+	}
+
+	return schema.Validate(document)
+}
@@ -0,0 +1,191 @@
+package config_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/config"
+)
+
+var _ = Describe("Load", func() {
+	var (
+		flagSet                 *flag.FlagSet
+		configFile              string
+		iamRolePath             string
+		enableBackupRole        bool
+		enableKiamRole          bool
+		minReconcileAge         time.Duration
+		cloudWatchAuditLogGroup string
+	)
+
+	writeConfig := func(content string) {
+		Expect(os.WriteFile(configFile, []byte(content), 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		configFile = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+
+		flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVar(&iamRolePath, "iam-role-path", "/", "")
+		flagSet.BoolVar(&enableBackupRole, "enable-backup-role", false, "")
+		flagSet.BoolVar(&enableKiamRole, "enable-kiam-role", true, "")
+		flagSet.DurationVar(&minReconcileAge, "min-reconcile-age", 10*time.Minute, "")
+		flagSet.StringVar(&cloudWatchAuditLogGroup, "cloudwatch-audit-log-group", "", "")
+	})
+
+	It("applies the values of the config file", func() {
+		writeConfig(`
+iam-role-path: /giantswarm/
+enable-backup-role: true
+enable-kiam-role: false
+min-reconcile-age: 1h30m
+`)
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		Expect(config.Load(flagSet, configFile)).To(Succeed())
+		Expect(iamRolePath).To(Equal("/giantswarm/"))
+		Expect(enableBackupRole).To(BeTrue())
+		Expect(enableKiamRole).To(BeFalse())
+		Expect(minReconcileAge).To(Equal(90 * time.Minute))
+		Expect(cloudWatchAuditLogGroup).To(BeEmpty())
+	})
+
+	It("prefers flags set on the command line", func() {
+		writeConfig(`
+iam-role-path: /giantswarm/
+min-reconcile-age: 1h
+`)
+		Expect(flagSet.Parse([]string{"--iam-role-path=/custom/"})).To(Succeed())
+
+		Expect(config.Load(flagSet, configFile)).To(Succeed())
+		Expect(iamRolePath).To(Equal("/custom/"))
+		Expect(minReconcileAge).To(Equal(time.Hour))
+	})
+
+	It("accepts an empty config file", func() {
+		writeConfig("")
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		Expect(config.Load(flagSet, configFile)).To(Succeed())
+		Expect(iamRolePath).To(Equal("/"))
+		Expect(minReconcileAge).To(Equal(10 * time.Minute))
+	})
+
+	DescribeTable("rejects config files which do not match the schema",
+		func(content string) {
+			writeConfig(content)
+			Expect(flagSet.Parse(nil)).To(Succeed())
+
+			Expect(config.Load(flagSet, configFile)).To(MatchError(ContainSubstring("invalid config file")))
+			Expect(iamRolePath).To(Equal("/"))
+		},
+		Entry("unknown key", "iam-role-path: /giantswarm/\nunknown-flag: true\n"),
+		Entry("wrong type", "iam-role-path: /giantswarm/\nenable-backup-role: \"yes\"\n"),
+		Entry("invalid duration", "iam-role-path: /giantswarm/\nmin-reconcile-age: 10 minutes\n"),
+		Entry("invalid role path", "iam-role-path: giantswarm\n"),
+		Entry("no concurrent reconciles", "iam-role-path: /giantswarm/\nmax-concurrent-reconciles-awsmachinetemplate: 0\n"),
+		Entry("empty leader election ID", "iam-role-path: /giantswarm/\nleader-election-id: \"\"\n"),
+		Entry("no mapping", "- iam-role-path\n"),
+	)
+
+	It("fails when the config file does not exist", func() {
+		Expect(config.Load(flagSet, filepath.Join(GinkgoT().TempDir(), "missing.yaml"))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("LoadSecret", func() {
+	var (
+		ctx              context.Context
+		flagSet          *flag.FlagSet
+		iamRolePath      string
+		enableBackupRole bool
+		minReconcileAge  time.Duration
+		secretData       map[string][]byte
+	)
+
+	loadSecret := func(namespacedName string) error {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "capa-iam-operator-config",
+				Namespace: "giantswarm",
+			},
+			Data: secretData,
+		}
+		c := fake.NewClientBuilder().WithObjects(secret).Build()
+		return config.LoadSecret(ctx, c, flagSet, namespacedName)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.StringVar(&iamRolePath, "iam-role-path", "/", "")
+		flagSet.BoolVar(&enableBackupRole, "enable-backup-role", false, "")
+		flagSet.DurationVar(&minReconcileAge, "min-reconcile-age", 10*time.Minute, "")
+
+		secretData = map[string][]byte{
+			config.SecretKey: []byte(`{"iam-role-path": "/giantswarm/", "enable-backup-role": true, "min-reconcile-age": "1h"}`),
+		}
+	})
+
+	It("applies the values of the config secret", func() {
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		Expect(loadSecret("giantswarm/capa-iam-operator-config")).To(Succeed())
+		Expect(iamRolePath).To(Equal("/giantswarm/"))
+		Expect(enableBackupRole).To(BeTrue())
+		Expect(minReconcileAge).To(Equal(time.Hour))
+	})
+
+	It("prefers flags set on the command line", func() {
+		Expect(flagSet.Parse([]string{"--iam-role-path=/custom/"})).To(Succeed())
+
+		Expect(loadSecret("giantswarm/capa-iam-operator-config")).To(Succeed())
+		Expect(iamRolePath).To(Equal("/custom/"))
+		Expect(enableBackupRole).To(BeTrue())
+	})
+
+	It("rejects configuration which does not match the schema", func() {
+		secretData[config.SecretKey] = []byte(`{"enable-backup-role": "yes"}`)
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		Expect(loadSecret("giantswarm/capa-iam-operator-config")).To(MatchError(ContainSubstring("invalid config secret")))
+		Expect(enableBackupRole).To(BeFalse())
+	})
+
+	It("fails when the secret has no configuration", func() {
+		secretData = map[string][]byte{"config.yaml": []byte("{}")}
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		Expect(loadSecret("giantswarm/capa-iam-operator-config")).To(MatchError(ContainSubstring("has no config.json key")))
+	})
+
+	It("fails when the secret does not exist", func() {
+		Expect(flagSet.Parse(nil)).To(Succeed())
+
+		Expect(loadSecret("giantswarm/missing")).To(MatchError(ContainSubstring("failed to get config secret")))
+	})
+
+	DescribeTable("rejects references which are not namespace/name",
+		func(namespacedName string) {
+			Expect(flagSet.Parse(nil)).To(Succeed())
+
+			Expect(loadSecret(namespacedName)).To(MatchError(ContainSubstring("must be given as namespace/name")))
+		},
+		Entry("name only", "capa-iam-operator-config"),
+		Entry("empty namespace", "/capa-iam-operator-config"),
+		Entry("too many segments", "giantswarm/capa-iam-operator-config/extra"),
+	)
+})
@@ -0,0 +1,88 @@
+package iam
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+const (
+	ampNamespace      = "monitoring"
+	ampServiceAccount = "prometheus"
+)
+
+const ampRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "aps:RemoteWrite",
+      "Resource": "{{ .WorkspaceARN }}"
+    }
+  ]
+}
+`
+
+// AMPRoleParams are the parameters of the trust and inline policy templates
+// of the AMP role.
+type AMPRoleParams struct {
+	Route53RoleParams
+	WorkspaceARN string
+}
+
+// ValidateAMPWorkspaceARN returns an error if workspaceARN is not the ARN of
+// an Amazon Managed Service for Prometheus workspace.
+func ValidateAMPWorkspaceARN(workspaceARN string) error {
+	parsed, err := arn.Parse(workspaceARN)
+	if err != nil {
+		return err
+	}
+	if parsed.Service != "aps" {
+		return fmt.Errorf("ARN %q is not an Amazon Managed Service for Prometheus ARN", workspaceARN)
+	}
+	return nil
+}
+
+// ReconcileAMPRole makes sure the IRSA role of Prometheus exists and allows
+// remote writes to the given Amazon Managed Service for Prometheus workspace.
+func (s *IAMService) ReconcileAMPRole(awsAccountID string, irsaTrustDomains []string, workspaceARN string) error {
+	s.log.Info("reconciling AMP IAM role")
+
+	if len(irsaTrustDomains) == 0 {
+		return fmt.Errorf("irsaTrustDomains cannot be empty")
+	}
+	err := ValidateAMPWorkspaceARN(workspaceARN)
+	if err != nil {
+		return err
+	}
+
+	irsaParams, err := s.irsaRoleParams(awsAccountID, irsaTrustDomains, ampNamespace, ampServiceAccount)
+	if err != nil {
+		return err
+	}
+
+	params := AMPRoleParams{
+		Route53RoleParams: irsaParams,
+		WorkspaceARN:      workspaceARN,
+	}
+
+	err = s.reconcileRole(roleName(AMPRole, s.clusterName), AMPRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling AMP IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteAMPRole() error {
+	s.log.Info("deleting AMP IAM resources")
+
+	err := s.deleteRole(roleName(AMPRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting AMP IAM resources")
+	return nil
+}
@@ -0,0 +1,100 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("AMPRole", func() {
+
+	const workspaceARN = "arn:aws:aps:eu-west-1:012345678901:workspace/ws-12345678-abcd-1234-abcd-123456789012"
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("deletes the AMP role", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+			RoleName: aws.String("test-cluster-AMP-Role"),
+		}).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		err := iamService.DeleteAMPRole()
+		Expect(err).To(BeNil())
+	})
+
+	When("the AMP role does not exist", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+				RoleName: aws.String("test-cluster-AMP-Role"),
+			}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-AMP-Role"))
+				Expect(*input.AssumeRolePolicyDocument).To(ContainSubstring(`"irsa.test.gaws.gigantic.io:sub": "system:serviceaccount:monitoring:prometheus"`))
+				Expect(*input.AssumeRolePolicyDocument).To(ContainSubstring("arn:aws:iam::012345678901:oidc-provider/irsa.test.gaws.gigantic.io"))
+				return &awsIAM.CreateRoleOutput{}, nil
+			})
+			mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+			// The trust policy is applied again right after creation.
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+				RoleName: aws.String("test-cluster-AMP-Role"),
+			}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil)
+			mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).Return(&awsIAM.UpdateAssumeRolePolicyOutput{}, nil)
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		})
+
+		It("allows remote writes to the workspace", func() {
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-AMP-Role"))
+
+				var policy struct {
+					Statement []struct {
+						Effect   string
+						Action   string
+						Resource string
+					}
+				}
+				Expect(json.Unmarshal([]byte(*input.PolicyDocument), &policy)).To(Succeed())
+				Expect(policy.Statement).To(HaveLen(1))
+				Expect(policy.Statement[0].Effect).To(Equal("Allow"))
+				Expect(policy.Statement[0].Action).To(Equal("aps:RemoteWrite"))
+				Expect(policy.Statement[0].Resource).To(Equal(workspaceARN))
+				return &awsIAM.PutRolePolicyOutput{}, nil
+			})
+
+			err := iamService.ReconcileAMPRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, workspaceARN)
+			Expect(err).To(BeNil())
+		})
+	})
+
+	It("rejects ARNs which are not AMP workspaces", func() {
+		err := iamService.ReconcileAMPRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, "arn:aws:s3:::bucket")
+		Expect(err).To(HaveOccurred())
+	})
+})
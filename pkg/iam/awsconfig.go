@@ -0,0 +1,96 @@
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
+)
+
+const (
+	// requiredTagsRuleIdentifier is the identifier of the AWS managed Config
+	// rule which checks that resources carry a set of tags.
+	requiredTagsRuleIdentifier = "REQUIRED_TAGS"
+	iamRoleResourceType        = "AWS::IAM::Role"
+)
+
+// ConfigClientFactory creates an AWS Config client for the given session and
+// region.
+type ConfigClientFactory func(awsclientgo.ConfigProvider, string) configserviceiface.ConfigServiceAPI
+
+// configRuleName returns the name of the AWS Config rule that checks the tags
+// of the given IAM role.
+func configRuleName(roleName string) string {
+	return fmt.Sprintf("capa-iam-operator-%s-required-tags", roleName)
+}
+
+// putConfigRule registers an AWS Config rule which verifies that the given
+// role carries the tags set by the operator. It is a no-op when AWS Config
+// integration is disabled.
+func (s *IAMService) putConfigRule(roleName string) error {
+	if s.configClient == nil {
+		return nil
+	}
+	l := s.log.WithValues("role_name", roleName)
+
+	parameters := map[string]string{
+		"tag1Key": s.ownershipTagKey,
+		"tag2Key": fmt.Sprintf(ClusterIDTag, s.clusterName),
+	}
+	if s.ownershipTagValue != "" {
+		parameters["tag1Value"] = s.ownershipTagValue
+	}
+	inputParameters, err := json.Marshal(parameters)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.configClient.PutConfigRule(&configservice.PutConfigRuleInput{
+		ConfigRule: &configservice.ConfigRule{
+			ConfigRuleName:  aws.String(configRuleName(roleName)),
+			Description:     aws.String(fmt.Sprintf("Checks that IAM role %s has the tags required by capa-iam-operator", roleName)),
+			InputParameters: aws.String(string(inputParameters)),
+			Scope: &configservice.Scope{
+				ComplianceResourceId:    aws.String(roleName),
+				ComplianceResourceTypes: []*string{aws.String(iamRoleResourceType)},
+			},
+			Source: &configservice.Source{
+				Owner:            aws.String(configservice.OwnerAws),
+				SourceIdentifier: aws.String(requiredTagsRuleIdentifier),
+			},
+		},
+	})
+	if err != nil {
+		l.Error(err, "failed to put AWS Config rule for IAM role")
+		return err
+	}
+
+	l.Info("registered AWS Config rule for IAM role")
+	return nil
+}
+
+// deleteConfigRule removes the AWS Config rule registered for the given role.
+// It is a no-op when AWS Config integration is disabled.
+func (s *IAMService) deleteConfigRule(roleName string) error {
+	if s.configClient == nil {
+		return nil
+	}
+	l := s.log.WithValues("role_name", roleName)
+
+	_, err := s.configClient.DeleteConfigRule(&configservice.DeleteConfigRuleInput{
+		ConfigRuleName: aws.String(configRuleName(roleName)),
+	})
+	if IsNoSuchConfigRule(err) {
+		return nil
+	}
+	if err != nil {
+		l.Error(err, "failed to delete AWS Config rule for IAM role")
+		return err
+	}
+
+	l.Info("deleted AWS Config rule for IAM role")
+	return nil
+}
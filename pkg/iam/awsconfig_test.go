@@ -0,0 +1,122 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("AWS Config integration", func() {
+
+	var (
+		mockCtrl         *gomock.Controller
+		mockIAMClient    *mocks.MockIAMAPI
+		mockConfigClient *mocks.MockConfigServiceAPI
+		iamService       *iam.IAMService
+		err              error
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+		mockConfigClient = mocks.NewMockConfigServiceAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.RoleType = "nodes"
+			c.ConfigClientFactory = func(session awsclientgo.ConfigProvider, region string) configserviceiface.ConfigServiceAPI {
+				return mockConfigClient
+			}
+		})
+	})
+
+	When("the role is created", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{}, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).Times(1)
+			mockIAMClient.EXPECT().CreateRole(gomock.Any()).Return(&awsIAM.CreateRoleOutput{}, nil)
+			mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(&awsIAM.GetRolePolicyOutput{}, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+		})
+
+		It("registers a config rule checking the required tags", func() {
+			mockConfigClient.EXPECT().PutConfigRule(gomock.Any()).DoAndReturn(func(input *configservice.PutConfigRuleInput) (*configservice.PutConfigRuleOutput, error) {
+				Expect(*input.ConfigRule.ConfigRuleName).To(Equal("capa-iam-operator-test-role-required-tags"))
+				Expect(*input.ConfigRule.Source.Owner).To(Equal(configservice.OwnerAws))
+				Expect(*input.ConfigRule.Source.SourceIdentifier).To(Equal("REQUIRED_TAGS"))
+				Expect(*input.ConfigRule.Scope.ComplianceResourceId).To(Equal("test-role"))
+				Expect(aws.StringValueSlice(input.ConfigRule.Scope.ComplianceResourceTypes)).To(ConsistOf("AWS::IAM::Role"))
+
+				var params map[string]string
+				Expect(json.Unmarshal([]byte(*input.ConfigRule.InputParameters), &params)).To(Succeed())
+				Expect(params).To(Equal(map[string]string{
+					"tag1Key": "capi-iam-controller/owned",
+					"tag2Key": "sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster",
+				}))
+				return &configservice.PutConfigRuleOutput{}, nil
+			})
+
+			err = iamService.ReconcileRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the role already exists", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil)
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(&awsIAM.GetRolePolicyOutput{}, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+		})
+
+		It("registers the config rule as well", func() {
+			mockConfigClient.EXPECT().PutConfigRule(gomock.Any()).DoAndReturn(func(input *configservice.PutConfigRuleInput) (*configservice.PutConfigRuleOutput, error) {
+				Expect(*input.ConfigRule.ConfigRuleName).To(Equal("capa-iam-operator-test-role-required-tags"))
+				return &configservice.PutConfigRuleOutput{}, nil
+			})
+
+			err = iamService.ReconcileRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the role is deleted", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+			mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+			mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(&awsIAM.DeleteRoleOutput{}, nil)
+		})
+
+		It("removes the config rule", func() {
+			mockConfigClient.EXPECT().DeleteConfigRule(&configservice.DeleteConfigRuleInput{
+				ConfigRuleName: aws.String("capa-iam-operator-test-role-required-tags"),
+			}).Return(&configservice.DeleteConfigRuleOutput{}, nil)
+
+			err = iamService.DeleteRole()
+			Expect(err).To(BeNil())
+		})
+
+		It("ignores a missing config rule", func() {
+			mockConfigClient.EXPECT().DeleteConfigRule(gomock.Any()).Return(nil, awserr.New(configservice.ErrCodeNoSuchConfigRuleException, "test", nil))
+
+			err = iamService.DeleteRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
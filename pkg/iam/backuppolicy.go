@@ -0,0 +1,106 @@
+package iam
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+)
+
+const backupTrustIdentityPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Service": "backup.amazonaws.com"
+      },
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}
+`
+
+// backupManagedPolicyARNs returns the ARNs of the AWS managed policies which
+// are attached to the AWS Backup role.
+func backupManagedPolicyARNs(partition string) []string {
+	return []string{
+		fmt.Sprintf("arn:%s:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForBackup", partition),
+		fmt.Sprintf("arn:%s:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForRestores", partition),
+	}
+}
+
+func (s *IAMService) ReconcileBackupRole() error {
+	s.log.Info("reconciling AWS Backup IAM role")
+
+	backupRoleName := roleName(BackupRole, s.clusterName)
+	err := s.createRole(backupRoleName, BackupRole, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	err = s.attachManagedPolicies(backupRoleName, backupManagedPolicyARNs(s.partition))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling AWS Backup IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteBackupRole() error {
+	s.log.Info("deleting AWS Backup IAM resources")
+
+	// attached managed policies are detached before the role is deleted
+	err := s.deleteRole(roleName(BackupRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting AWS Backup IAM resources")
+	return nil
+}
+
+// attachManagedPolicies attaches the given managed policies to the role,
+// skipping the ones which are already attached.
+func (s *IAMService) attachManagedPolicies(roleName string, policyARNs []string) error {
+	l := s.log.WithValues("role_name", roleName)
+
+	attached := map[string]bool{}
+	input := &awsiam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	}
+	for {
+		o, err := s.iamClient.ListAttachedRolePolicies(input)
+		if err != nil {
+			l.Error(err, "failed to list attached policies")
+			return err
+		}
+		for _, p := range o.AttachedPolicies {
+			attached[aws.StringValue(p.PolicyArn)] = true
+		}
+		if !aws.BoolValue(o.IsTruncated) {
+			break
+		}
+		input.Marker = o.Marker
+	}
+
+	for _, policyARN := range policyARNs {
+		if attached[policyARN] {
+			continue
+		}
+
+		_, err := s.iamClient.AttachRolePolicy(&awsiam.AttachRolePolicyInput{
+			PolicyArn: aws.String(policyARN),
+			RoleName:  aws.String(roleName),
+		})
+		if err != nil {
+			l.Error(err, "failed to attach policy", "policy_arn", policyARN)
+			return err
+		}
+
+		l.Info("attached policy", "policy_arn", policyARN)
+	}
+
+	return nil
+}
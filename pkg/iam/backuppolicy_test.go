@@ -0,0 +1,111 @@
+package iam_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("BackupRole", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	When("the backup role does not exist", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+				RoleName: aws.String("test-cluster-BackupRole"),
+			}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-BackupRole"))
+				Expect(*input.AssumeRolePolicyDocument).To(ContainSubstring("backup.amazonaws.com"))
+				return &awsIAM.CreateRoleOutput{}, nil
+			})
+			mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		})
+
+		It("creates the role and attaches the AWS Backup managed policies", func() {
+			mockIAMClient.EXPECT().AttachRolePolicy(&awsIAM.AttachRolePolicyInput{
+				PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForBackup"),
+				RoleName:  aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.AttachRolePolicyOutput{}, nil)
+			mockIAMClient.EXPECT().AttachRolePolicy(&awsIAM.AttachRolePolicyInput{
+				PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForRestores"),
+				RoleName:  aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.AttachRolePolicyOutput{}, nil)
+
+			err := iamService.ReconcileBackupRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the backup role exists with its policies attached", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil)
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{
+				AttachedPolicies: []*awsIAM.AttachedPolicy{
+					{PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForBackup")},
+					{PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForRestores")},
+				},
+			}, nil)
+		})
+
+		It("does not attach them again", func() {
+			err := iamService.ReconcileBackupRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the backup role is deleted", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{
+				AttachedPolicies: []*awsIAM.AttachedPolicy{
+					{PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForBackup"), PolicyName: aws.String("AWSBackupServiceRolePolicyForBackup")},
+					{PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForRestores"), PolicyName: aws.String("AWSBackupServiceRolePolicyForRestores")},
+				},
+			}, nil)
+			mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+			mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		})
+
+		It("detaches the managed policies before deleting the role", func() {
+			detachBackup := mockIAMClient.EXPECT().DetachRolePolicy(&awsIAM.DetachRolePolicyInput{
+				PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForBackup"),
+				RoleName:  aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.DetachRolePolicyOutput{}, nil)
+			detachRestores := mockIAMClient.EXPECT().DetachRolePolicy(&awsIAM.DetachRolePolicyInput{
+				PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSBackupServiceRolePolicyForRestores"),
+				RoleName:  aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.DetachRolePolicyOutput{}, nil)
+			mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+				RoleName: aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.DeleteRoleOutput{}, nil).After(detachBackup).After(detachRestores)
+
+			err := iamService.DeleteBackupRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
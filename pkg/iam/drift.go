@@ -0,0 +1,202 @@
+package iam
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+)
+
+// maxSimulatedActions limits the number of actions simulated per role.
+const maxSimulatedActions = 20
+
+// DeniedAction is an action allowed by the inline policy of a role which the
+// IAM policy simulator reports as denied, e.g. because an SCP restricts it.
+type DeniedAction struct {
+	RoleName string
+	Action   string
+	// Decision is the evaluation decision of the simulator, implicitDeny or
+	// explicitDeny.
+	Decision string
+}
+
+// DetectPolicyDrift simulates a subset of the actions allowed by the inline
+// policy of the main role and, if includeIRSARoles is set, of the IRSA roles.
+// It returns the actions which are denied. Roles which do not exist are
+// skipped.
+func (s *IAMService) DetectPolicyDrift(includeIRSARoles bool) ([]DeniedAction, error) {
+	roleTypes := map[string]string{s.mainRoleName: s.roleType}
+	if includeIRSARoles {
+		for _, roleType := range getIRSARoles() {
+			roleTypes[roleName(roleType, s.clusterName)] = roleType
+		}
+	}
+
+	roleNames := make([]string, 0, len(roleTypes))
+	for roleName := range roleTypes {
+		roleNames = append(roleNames, roleName)
+	}
+	slices.Sort(roleNames)
+
+	var denied []DeniedAction
+	for _, roleName := range roleNames {
+		d, err := s.simulateRolePolicy(roleName, roleTypes[roleName])
+		if err != nil {
+			return nil, err
+		}
+		denied = append(denied, d...)
+	}
+
+	return denied, nil
+}
+
+func (s *IAMService) simulateRolePolicy(roleName string, roleType string) ([]DeniedAction, error) {
+	l := s.log.WithValues("role_name", roleName)
+
+	actions, err := simulatedActions(roleType, s.driftPolicyParams())
+	if err != nil {
+		l.Error(err, "failed to determine the actions to simulate")
+		return nil, err
+	}
+	if len(actions) == 0 {
+		return nil, nil
+	}
+
+	role, err := s.iamClient.GetRole(&awsiam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		l.Error(err, "failed to fetch IAM role")
+		return nil, err
+	}
+
+	var denied []DeniedAction
+	input := &awsiam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: role.Role.Arn,
+		ActionNames:     aws.StringSlice(actions),
+	}
+	for {
+		o, err := s.iamClient.SimulatePrincipalPolicy(input)
+		if err != nil {
+			l.Error(err, "failed to simulate IAM role policy")
+			return nil, err
+		}
+		for _, result := range o.EvaluationResults {
+			decision := aws.StringValue(result.EvalDecision)
+			if decision == awsiam.PolicyEvaluationDecisionTypeAllowed {
+				continue
+			}
+			denied = append(denied, DeniedAction{
+				RoleName: roleName,
+				Action:   aws.StringValue(result.EvalActionName),
+				Decision: decision,
+			})
+		}
+		if !aws.BoolValue(o.IsTruncated) {
+			break
+		}
+		input.Marker = o.Marker
+	}
+
+	return denied, nil
+}
+
+// driftPolicyParams renders the inline policies of all role types. Statements
+// with resources depending on missing parameters are not simulated anyway.
+func (s *IAMService) driftPolicyParams() interface{} {
+	return struct {
+		ClusterName      string
+		EC2ServiceDomain string
+		AWSDomain        string
+		Region           string
+		AccountID        string
+	}{
+		ClusterName:      s.clusterName,
+		EC2ServiceDomain: ec2ServiceDomain(s.partition),
+		AWSDomain:        s.partition,
+		Region:           s.region,
+	}
+}
+
+// simulatedActions returns the actions of the inline policy of the role type
+// which are allowed on all resources without conditions. Other actions are
+// not simulated, since their outcome depends on the simulated resources and
+// context. The result is sorted and limited to maxSimulatedActions.
+func simulatedActions(roleType string, params interface{}) ([]string, error) {
+	tmpl := getInlinePolicyTemplate(roleType)
+	if tmpl == "" {
+		return nil, nil
+	}
+
+	document, err := generatePolicyDocument(tmpl, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy struct {
+		Statement []struct {
+			Effect    string
+			Action    json.RawMessage
+			Resource  json.RawMessage
+			Condition json.RawMessage
+		}
+	}
+	err = json.Unmarshal([]byte(document), &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []string
+	for _, statement := range policy.Statement {
+		if statement.Effect != "Allow" || len(statement.Condition) > 0 {
+			continue
+		}
+		resources, err := stringOrSlice(statement.Resource)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(resources, []string{"*"}) {
+			continue
+		}
+		statementActions, err := stringOrSlice(statement.Action)
+		if err != nil {
+			return nil, err
+		}
+		for _, action := range statementActions {
+			if !strings.Contains(action, "*") && !slices.Contains(actions, action) {
+				actions = append(actions, action)
+			}
+		}
+	}
+
+	slices.Sort(actions)
+	if len(actions) > maxSimulatedActions {
+		actions = actions[:maxSimulatedActions]
+	}
+
+	return actions, nil
+}
+
+// stringOrSlice decodes a policy element which is either a string or a list
+// of strings.
+func stringOrSlice(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return []string{value}, nil
+	}
+
+	var values []string
+	err := json.Unmarshal(raw, &values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
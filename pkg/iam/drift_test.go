@@ -0,0 +1,78 @@
+package iam_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("DetectPolicyDrift", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("reports actions denied by the policy simulator", func() {
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-role"),
+		}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+			Arn: aws.String("arn:aws:iam::012345678901:role/test-role"),
+		}}, nil)
+		mockIAMClient.EXPECT().SimulatePrincipalPolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.SimulatePrincipalPolicyInput) (*awsIAM.SimulatePolicyResponse, error) {
+			Expect(*input.PolicySourceArn).To(Equal("arn:aws:iam::012345678901:role/test-role"))
+			actions := aws.StringValueSlice(input.ActionNames)
+			Expect(actions).To(ContainElement("autoscaling:DescribeAutoScalingGroups"))
+			Expect(actions).NotTo(ContainElement("elasticloadbalancing:*"))
+			Expect(actions).NotTo(ContainElement("autoscaling:SetDesiredCapacity"))
+			Expect(len(actions)).To(BeNumerically("<=", 20))
+
+			return &awsIAM.SimulatePolicyResponse{
+				EvaluationResults: []*awsIAM.EvaluationResult{
+					{
+						EvalActionName: aws.String("autoscaling:DescribeAutoScalingGroups"),
+						EvalDecision:   aws.String(awsIAM.PolicyEvaluationDecisionTypeAllowed),
+					},
+					{
+						EvalActionName: aws.String("autoscaling:DescribeTags"),
+						EvalDecision:   aws.String(awsIAM.PolicyEvaluationDecisionTypeExplicitDeny),
+					},
+				},
+			}, nil
+		})
+
+		denied, err := iamService.DetectPolicyDrift(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(denied).To(ConsistOf(iam.DeniedAction{
+			RoleName: "test-role",
+			Action:   "autoscaling:DescribeTags",
+			Decision: awsIAM.PolicyEvaluationDecisionTypeExplicitDeny,
+		}))
+	})
+
+	It("skips roles which do not exist", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+
+		denied, err := iamService.DetectPolicyDrift(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(denied).To(BeEmpty())
+	})
+})
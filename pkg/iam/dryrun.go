@@ -0,0 +1,171 @@
+package iam
+
+import (
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/ssoadmin"
+	"github.com/aws/aws-sdk-go/service/ssoadmin/ssoadminiface"
+	"github.com/go-logr/logr"
+)
+
+// dryRunLogger logs the mutating AWS API calls skipped in dry-run mode.
+type dryRunLogger struct {
+	log logr.Logger
+}
+
+func (l dryRunLogger) skip(operation string, input interface{}) {
+	l.log.Info("dry run, skipping AWS API call", "operation", operation, "input", input)
+}
+
+// dryRunIAMClient wraps an IAM client and logs mutating calls instead of
+// making them. Read-only calls are passed through unchanged, so that the
+// differences to the desired state are still detected.
+type dryRunIAMClient struct {
+	iamiface.IAMAPI
+	dryRunLogger
+}
+
+func (c *dryRunIAMClient) AddRoleToInstanceProfile(input *awsiam.AddRoleToInstanceProfileInput) (*awsiam.AddRoleToInstanceProfileOutput, error) {
+	c.skip("AddRoleToInstanceProfile", input)
+	return &awsiam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (c *dryRunIAMClient) AttachRolePolicy(input *awsiam.AttachRolePolicyInput) (*awsiam.AttachRolePolicyOutput, error) {
+	c.skip("AttachRolePolicy", input)
+	return &awsiam.AttachRolePolicyOutput{}, nil
+}
+
+func (c *dryRunIAMClient) CreateInstanceProfile(input *awsiam.CreateInstanceProfileInput) (*awsiam.CreateInstanceProfileOutput, error) {
+	c.skip("CreateInstanceProfile", input)
+	return &awsiam.CreateInstanceProfileOutput{}, nil
+}
+
+func (c *dryRunIAMClient) CreateOpenIDConnectProvider(input *awsiam.CreateOpenIDConnectProviderInput) (*awsiam.CreateOpenIDConnectProviderOutput, error) {
+	c.skip("CreateOpenIDConnectProvider", input)
+	return &awsiam.CreateOpenIDConnectProviderOutput{}, nil
+}
+
+func (c *dryRunIAMClient) CreateRole(input *awsiam.CreateRoleInput) (*awsiam.CreateRoleOutput, error) {
+	c.skip("CreateRole", input)
+	return &awsiam.CreateRoleOutput{}, nil
+}
+
+func (c *dryRunIAMClient) CreateServiceLinkedRole(input *awsiam.CreateServiceLinkedRoleInput) (*awsiam.CreateServiceLinkedRoleOutput, error) {
+	c.skip("CreateServiceLinkedRole", input)
+	return &awsiam.CreateServiceLinkedRoleOutput{}, nil
+}
+
+func (c *dryRunIAMClient) DeleteInstanceProfile(input *awsiam.DeleteInstanceProfileInput) (*awsiam.DeleteInstanceProfileOutput, error) {
+	c.skip("DeleteInstanceProfile", input)
+	return &awsiam.DeleteInstanceProfileOutput{}, nil
+}
+
+func (c *dryRunIAMClient) DeleteOpenIDConnectProvider(input *awsiam.DeleteOpenIDConnectProviderInput) (*awsiam.DeleteOpenIDConnectProviderOutput, error) {
+	c.skip("DeleteOpenIDConnectProvider", input)
+	return &awsiam.DeleteOpenIDConnectProviderOutput{}, nil
+}
+
+func (c *dryRunIAMClient) DeleteRole(input *awsiam.DeleteRoleInput) (*awsiam.DeleteRoleOutput, error) {
+	c.skip("DeleteRole", input)
+	return &awsiam.DeleteRoleOutput{}, nil
+}
+
+func (c *dryRunIAMClient) DeleteRolePolicy(input *awsiam.DeleteRolePolicyInput) (*awsiam.DeleteRolePolicyOutput, error) {
+	c.skip("DeleteRolePolicy", input)
+	return &awsiam.DeleteRolePolicyOutput{}, nil
+}
+
+func (c *dryRunIAMClient) DetachRolePolicy(input *awsiam.DetachRolePolicyInput) (*awsiam.DetachRolePolicyOutput, error) {
+	c.skip("DetachRolePolicy", input)
+	return &awsiam.DetachRolePolicyOutput{}, nil
+}
+
+func (c *dryRunIAMClient) PutRolePolicy(input *awsiam.PutRolePolicyInput) (*awsiam.PutRolePolicyOutput, error) {
+	c.skip("PutRolePolicy", input)
+	return &awsiam.PutRolePolicyOutput{}, nil
+}
+
+func (c *dryRunIAMClient) RemoveRoleFromInstanceProfile(input *awsiam.RemoveRoleFromInstanceProfileInput) (*awsiam.RemoveRoleFromInstanceProfileOutput, error) {
+	c.skip("RemoveRoleFromInstanceProfile", input)
+	return &awsiam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
+
+func (c *dryRunIAMClient) TagInstanceProfile(input *awsiam.TagInstanceProfileInput) (*awsiam.TagInstanceProfileOutput, error) {
+	c.skip("TagInstanceProfile", input)
+	return &awsiam.TagInstanceProfileOutput{}, nil
+}
+
+func (c *dryRunIAMClient) TagRole(input *awsiam.TagRoleInput) (*awsiam.TagRoleOutput, error) {
+	c.skip("TagRole", input)
+	return &awsiam.TagRoleOutput{}, nil
+}
+
+func (c *dryRunIAMClient) UntagInstanceProfile(input *awsiam.UntagInstanceProfileInput) (*awsiam.UntagInstanceProfileOutput, error) {
+	c.skip("UntagInstanceProfile", input)
+	return &awsiam.UntagInstanceProfileOutput{}, nil
+}
+
+func (c *dryRunIAMClient) UntagRole(input *awsiam.UntagRoleInput) (*awsiam.UntagRoleOutput, error) {
+	c.skip("UntagRole", input)
+	return &awsiam.UntagRoleOutput{}, nil
+}
+
+func (c *dryRunIAMClient) UpdateAssumeRolePolicy(input *awsiam.UpdateAssumeRolePolicyInput) (*awsiam.UpdateAssumeRolePolicyOutput, error) {
+	c.skip("UpdateAssumeRolePolicy", input)
+	return &awsiam.UpdateAssumeRolePolicyOutput{}, nil
+}
+
+// dryRunConfigClient wraps an AWS Config client like dryRunIAMClient.
+type dryRunConfigClient struct {
+	configserviceiface.ConfigServiceAPI
+	dryRunLogger
+}
+
+func (c *dryRunConfigClient) DeleteConfigRule(input *configservice.DeleteConfigRuleInput) (*configservice.DeleteConfigRuleOutput, error) {
+	c.skip("DeleteConfigRule", input)
+	return &configservice.DeleteConfigRuleOutput{}, nil
+}
+
+func (c *dryRunConfigClient) PutConfigRule(input *configservice.PutConfigRuleInput) (*configservice.PutConfigRuleOutput, error) {
+	c.skip("PutConfigRule", input)
+	return &configservice.PutConfigRuleOutput{}, nil
+}
+
+// dryRunSSOAdminClient wraps an IAM Identity Center client like
+// dryRunIAMClient.
+type dryRunSSOAdminClient struct {
+	ssoadminiface.SSOAdminAPI
+	dryRunLogger
+}
+
+func (c *dryRunSSOAdminClient) CreateAccountAssignment(input *ssoadmin.CreateAccountAssignmentInput) (*ssoadmin.CreateAccountAssignmentOutput, error) {
+	c.skip("CreateAccountAssignment", input)
+	return &ssoadmin.CreateAccountAssignmentOutput{}, nil
+}
+
+func (c *dryRunSSOAdminClient) CreatePermissionSet(input *ssoadmin.CreatePermissionSetInput) (*ssoadmin.CreatePermissionSetOutput, error) {
+	c.skip("CreatePermissionSet", input)
+	return &ssoadmin.CreatePermissionSetOutput{}, nil
+}
+
+func (c *dryRunSSOAdminClient) DeleteAccountAssignment(input *ssoadmin.DeleteAccountAssignmentInput) (*ssoadmin.DeleteAccountAssignmentOutput, error) {
+	c.skip("DeleteAccountAssignment", input)
+	return &ssoadmin.DeleteAccountAssignmentOutput{}, nil
+}
+
+func (c *dryRunSSOAdminClient) DeletePermissionSet(input *ssoadmin.DeletePermissionSetInput) (*ssoadmin.DeletePermissionSetOutput, error) {
+	c.skip("DeletePermissionSet", input)
+	return &ssoadmin.DeletePermissionSetOutput{}, nil
+}
+
+func (c *dryRunSSOAdminClient) ProvisionPermissionSet(input *ssoadmin.ProvisionPermissionSetInput) (*ssoadmin.ProvisionPermissionSetOutput, error) {
+	c.skip("ProvisionPermissionSet", input)
+	return &ssoadmin.ProvisionPermissionSetOutput{}, nil
+}
+
+func (c *dryRunSSOAdminClient) PutInlinePolicyToPermissionSet(input *ssoadmin.PutInlinePolicyToPermissionSetInput) (*ssoadmin.PutInlinePolicyToPermissionSetOutput, error) {
+	c.skip("PutInlinePolicyToPermissionSet", input)
+	return &ssoadmin.PutInlinePolicyToPermissionSetOutput{}, nil
+}
@@ -0,0 +1,86 @@
+package iam_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+// The mocks only expect read-only calls, so every mutating call fails the
+// specs.
+var _ = Describe("DryRun", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.RoleType = "nodes"
+			c.DryRun = true
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("does not create missing roles", func() {
+		notFound := awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(nil, notFound)
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, notFound)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{RoleName: aws.String("test-role")}).Return(nil, notFound)
+
+		Expect(iamService.ReconcileRole()).To(Succeed())
+		Expect(iamService.ReconcileRoleTags("test-role")).To(Succeed())
+	})
+
+	It("skips the KIAM role if the control plane role was not created", func() {
+		notFound := awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(nil, notFound)
+
+		Expect(iamService.ReconcileKiamRole()).To(Succeed())
+	})
+
+	It("does not update drifted roles", func() {
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil)
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(&awsIAM.GetRolePolicyOutput{
+			PolicyDocument: aws.String(`{"Version": "2012-10-17", "Statement": []}`),
+		}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{RoleName: aws.String("test-role")}).Return(&awsIAM.ListRoleTagsOutput{
+			Tags: []*awsIAM.Tag{
+				{Key: aws.String(iam.IAMControllerOwnedTag), Value: aws.String("")},
+			},
+		}, nil)
+		mockIAMClient.EXPECT().ListInstanceProfileTags(gomock.Any()).Return(&awsIAM.ListInstanceProfileTagsOutput{}, nil)
+
+		Expect(iamService.ReconcileRole()).To(Succeed())
+		Expect(iamService.ReconcileRoleTags("test-role")).To(Succeed())
+	})
+
+	It("does not delete roles", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{
+			AttachedPolicies: []*awsIAM.AttachedPolicy{
+				{
+					PolicyArn:  aws.String("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+					PolicyName: aws.String("ReadOnlyAccess"),
+				},
+			},
+		}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{
+			PolicyNames: []*string{aws.String("nodes-test-cluster-policy")},
+		}, nil)
+
+		Expect(iamService.DeleteRole()).To(Succeed())
+	})
+})
@@ -1,8 +1,12 @@
 package iam
 
 import (
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/configservice"
 	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/ssoadmin"
 	"github.com/giantswarm/microerror"
 )
 
@@ -10,6 +14,42 @@ var invalidClusterError = &microerror.Error{
 	Kind: "invalidClusterError",
 }
 
+var invalidIRSARoleTypeError = &microerror.Error{
+	Kind: "invalidIRSARoleTypeError",
+}
+
+// IsInvalidIRSARoleType asserts invalidIRSARoleTypeError.
+func IsInvalidIRSARoleType(err error) bool {
+	return microerror.Cause(err) == invalidIRSARoleTypeError
+}
+
+var invalidIRSAConfigError = &microerror.Error{
+	Kind: "invalidIRSAConfigError",
+}
+
+// IsInvalidIRSAConfig asserts invalidIRSAConfigError.
+func IsInvalidIRSAConfig(err error) bool {
+	return microerror.Cause(err) == invalidIRSAConfigError
+}
+
+var invalidRoleNameError = &microerror.Error{
+	Kind: "invalidRoleNameError",
+}
+
+// IsInvalidRoleName asserts invalidRoleNameError.
+func IsInvalidRoleName(err error) bool {
+	return microerror.Cause(err) == invalidRoleNameError
+}
+
+var policyTooLargeError = &microerror.Error{
+	Kind: "policyTooLargeError",
+}
+
+// IsPolicyTooLarge asserts policyTooLargeError.
+func IsPolicyTooLarge(err error) bool {
+	return microerror.Cause(err) == policyTooLargeError
+}
+
 func IsNotFound(err error) bool {
 	if aerr, ok := err.(awserr.Error); ok {
 		if aerr.Code() == awsiam.ErrCodeNoSuchEntityException {
@@ -27,3 +67,42 @@ func IsAlreadyExists(err error) bool {
 	}
 	return false
 }
+
+// IsAccessDenied returns true if the call was denied by IAM policies or SCPs.
+func IsAccessDenied(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		if aerr.Code() == "AccessDenied" || aerr.Code() == "AccessDeniedException" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsServiceLinkedRoleTaken returns true if CreateServiceLinkedRole failed
+// because the service-linked role already exists in the account.
+func IsServiceLinkedRoleTaken(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		if aerr.Code() == awsiam.ErrCodeInvalidInputException && strings.Contains(aerr.Message(), "has been taken") {
+			return true
+		}
+	}
+	return false
+}
+
+func IsNoSuchConfigRule(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		if aerr.Code() == configservice.ErrCodeNoSuchConfigRuleException {
+			return true
+		}
+	}
+	return false
+}
+
+func isSSOAdminNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		if aerr.Code() == ssoadmin.ErrCodeResourceNotFoundException {
+			return true
+		}
+	}
+	return false
+}
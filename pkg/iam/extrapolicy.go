@@ -0,0 +1,132 @@
+package iam
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// maxInlinePolicySize is the maximum size of the inline policies of an IAM
+// role in characters, not counting whitespace.
+const maxInlinePolicySize = 10240
+
+// ParseExtraPolicyStatements decodes the base64-encoded JSON array of IAM
+// policy statements which are merged into the inline policy of a node role.
+// Only statements with the Allow effect whose actions belong to one of the
+// allowed services, e.g. ec2 for ec2:DescribeElasticGpus, are accepted, so
+// extra statements can neither restrict the permissions the nodes need to
+// join the cluster nor grant arbitrary permissions. Wildcards are supported
+// within the actions of a service only. An empty value results in no extra
+// statements.
+func ParseExtraPolicyStatements(encoded string, allowedServices []string) ([]json.RawMessage, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+
+	var statements []json.RawMessage
+	err = json.Unmarshal(data, &statements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON array of statements: %w", err)
+	}
+
+	for i, statement := range statements {
+		var s struct {
+			Effect    string
+			Action    json.RawMessage
+			NotAction json.RawMessage
+		}
+		err = json.Unmarshal(statement, &s)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d is not an object: %w", i, err)
+		}
+		if s.Effect != "Allow" {
+			return nil, fmt.Errorf("statement %d has effect %q, only Allow is supported", i, s.Effect)
+		}
+		if len(s.NotAction) > 0 {
+			return nil, fmt.Errorf("statement %d uses NotAction, only Action is supported", i)
+		}
+		actions, err := statementActions(s.Action)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		for _, action := range actions {
+			service, _, _ := strings.Cut(action, ":")
+			if !slices.Contains(allowedServices, service) {
+				return nil, fmt.Errorf("statement %d has action %q of a service which is not allowed, allowed services are %v", i, action, allowedServices)
+			}
+		}
+	}
+
+	return statements, nil
+}
+
+// statementActions returns the actions of a statement, which are either a
+// single string or a list of strings.
+func statementActions(action json.RawMessage) ([]string, error) {
+	if len(action) == 0 {
+		return nil, fmt.Errorf("missing Action")
+	}
+
+	var single string
+	if json.Unmarshal(action, &single) == nil {
+		return []string{single}, nil
+	}
+
+	var actions []string
+	err := json.Unmarshal(action, &actions)
+	if err != nil {
+		return nil, fmt.Errorf("Action is neither a string nor a list of strings: %w", err)
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("empty Action")
+	}
+
+	return actions, nil
+}
+
+// checkInlinePolicySize returns a policyTooLargeError if the policy document
+// exceeds the size IAM accepts for the inline policies of a role.
+func checkInlinePolicySize(policyDocument string) error {
+	var compacted bytes.Buffer
+	err := json.Compact(&compacted, []byte(policyDocument))
+	if err != nil {
+		return err
+	}
+	if compacted.Len() > maxInlinePolicySize {
+		return microerror.Maskf(policyTooLargeError, "inline policy has %d characters, at most %d are allowed", compacted.Len(), maxInlinePolicySize)
+	}
+
+	return nil
+}
+
+// mergePolicyStatements appends statements to the Statement list of the
+// policy document.
+func mergePolicyStatements(policyDocument string, statements []json.RawMessage) (string, error) {
+	var policy struct {
+		Version   string
+		Statement []json.RawMessage
+	}
+	err := json.Unmarshal([]byte(policyDocument), &policy)
+	if err != nil {
+		return "", err
+	}
+
+	policy.Statement = append(policy.Statement, statements...)
+
+	merged, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(merged), nil
+}
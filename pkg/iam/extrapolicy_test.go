@@ -0,0 +1,140 @@
+package iam_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("ExtraPolicyStatements", func() {
+
+	const gpuStatements = `[
+  {
+    "Effect": "Allow",
+    "Action": ["elastic-inference:Connect", "ec2:DescribeElasticGpus"],
+    "Resource": "*"
+  }
+]`
+
+	allowedServices := []string{"ec2", "elastic-inference"}
+
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	Describe("ParseExtraPolicyStatements", func() {
+		It("accepts Allow statements", func() {
+			statements, err := iam.ParseExtraPolicyStatements(encode(gpuStatements), allowedServices)
+			Expect(err).To(BeNil())
+			Expect(statements).To(HaveLen(1))
+		})
+
+		It("accepts an empty value", func() {
+			statements, err := iam.ParseExtraPolicyStatements("", allowedServices)
+			Expect(err).To(BeNil())
+			Expect(statements).To(BeEmpty())
+		})
+
+		DescribeTable("rejects invalid statements",
+			func(encoded string) {
+				_, err := iam.ParseExtraPolicyStatements(encoded, allowedServices)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("not base64", "not base64!"),
+			Entry("not a JSON array", encode(`{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}`)),
+			Entry("not an object", encode(`["s3:GetObject"]`)),
+			Entry("Deny effect", encode(`[{"Effect": "Deny", "Action": "s3:GetObject", "Resource": "*"}]`)),
+			Entry("missing effect", encode(`[{"Action": "ec2:DescribeElasticGpus", "Resource": "*"}]`)),
+			Entry("missing action", encode(`[{"Effect": "Allow", "Resource": "*"}]`)),
+			Entry("NotAction", encode(`[{"Effect": "Allow", "NotAction": "ec2:DescribeElasticGpus", "Resource": "*"}]`)),
+			Entry("wildcard action", encode(`[{"Effect": "Allow", "Action": "*", "Resource": "*"}]`)),
+			Entry("action of a service which is not allowed", encode(`[{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]`)),
+			Entry("one of the actions of a service which is not allowed", encode(`[{"Effect": "Allow", "Action": ["ec2:DescribeElasticGpus", "iam:PassRole"], "Resource": "*"}]`)),
+		)
+	})
+
+	Describe("ReconcileRole", func() {
+		var (
+			mockCtrl       *gomock.Controller
+			mockIAMClient  *mocks.MockIAMAPI
+			iamService     *iam.IAMService
+			policyDocument string
+		)
+
+		newService := func(statements []json.RawMessage) *iam.IAMService {
+			return newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+				c.MainRoleName = "test-cluster-nodes-gpu"
+				c.RoleType = "nodes"
+				c.ExtraPolicyStatements = statements
+			})
+		}
+
+		BeforeEach(func() {
+			policyDocument = ""
+
+			statements, err := iam.ParseExtraPolicyStatements(encode(gpuStatements), allowedServices)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+			iamService = newService(statements)
+
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+			mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).Return(&awsIAM.UpdateAssumeRolePolicyOutput{}, nil).AnyTimes()
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-nodes-gpu"))
+				policyDocument = *input.PolicyDocument
+				return &awsIAM.PutRolePolicyOutput{}, nil
+			}).AnyTimes()
+		})
+
+		AfterEach(func() {
+			mockCtrl.Finish()
+		})
+
+		It("merges the extra statements into the node policy", func() {
+			err := iamService.ReconcileRole()
+			Expect(err).To(BeNil())
+
+			var policy struct {
+				Version   string
+				Statement []struct {
+					Effect string
+					Action interface{}
+				}
+			}
+			Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+			Expect(policy.Version).To(Equal("2012-10-17"))
+			Expect(len(policy.Statement)).To(BeNumerically(">", 1))
+			Expect(policy.Statement[len(policy.Statement)-1].Action).To(ConsistOf("elastic-inference:Connect", "ec2:DescribeElasticGpus"))
+
+			// the base node policy is kept
+			Expect(policyDocument).To(ContainSubstring(`"secretsmanager:GetSecretValue"`))
+		})
+
+		It("refuses policies exceeding the inline policy size limit", func() {
+			actions := make([]string, 500)
+			for i := range actions {
+				actions[i] = `"ec2:DescribeElasticGpus"`
+			}
+			statements, err := iam.ParseExtraPolicyStatements(encode(`[{"Effect": "Allow", "Action": [`+strings.Join(actions, ",")+`], "Resource": "*"}]`), allowedServices)
+			Expect(err).NotTo(HaveOccurred())
+
+			iamService = newService(statements)
+			err = iamService.ReconcileRole()
+			Expect(iam.IsPolicyTooLarge(err)).To(BeTrue())
+			Expect(policyDocument).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package iam
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	gatewayAPINamespace      = "aws-application-networking-system"
+	gatewayAPIServiceAccount = "aws-gateway-api-controller"
+)
+
+// gatewayAPIRolePolicyTemplate allows the AWS Gateway API controller to manage
+// the load balancers tagged as owned by the cluster and the records of the
+// given hosted zones. Describing VPCs, load balancers and hosted zones is not
+// scoped, as these calls do not support resource-level permissions.
+const gatewayAPIRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ec2:DescribeVpcs",
+        "ec2:DescribeSubnets",
+        "ec2:DescribeSecurityGroups",
+        "elasticloadbalancing:Describe*",
+        "route53:Get*",
+        "route53:List*"
+      ],
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "elasticloadbalancing:CreateLoadBalancer",
+        "elasticloadbalancing:CreateTargetGroup"
+      ],
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {
+          "aws:RequestTag/{{ .ClusterTag }}": "owned"
+        }
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Action": "elasticloadbalancing:AddTags",
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {
+          "elasticloadbalancing:CreateAction": [
+            "CreateLoadBalancer",
+            "CreateTargetGroup"
+          ],
+          "aws:RequestTag/{{ .ClusterTag }}": "owned"
+        }
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Action": "elasticloadbalancing:*",
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {
+          "aws:ResourceTag/{{ .ClusterTag }}": "owned"
+        }
+      }
+    }{{ if .HostedZoneIDs }},
+    {
+      "Effect": "Allow",
+      "Action": [
+        "route53:ChangeResourceRecordSets",
+        "route53:GetHostedZone",
+        "route53:ListResourceRecordSets"
+      ],
+      "Resource": [
+        {{- range $i, $id := .HostedZoneIDs }}{{ if $i }},{{ end }}
+        "arn:*:route53:::hostedzone/{{ $id }}"
+        {{- end }}
+      ]
+    }{{ end }}
+  ]
+}
+`
+
+var hostedZoneIDRegexp = regexp.MustCompile(`^[A-Z0-9]{1,32}$`)
+
+// GatewayAPIRoleParams are the parameters of the trust and inline policy
+// templates of the AWS Gateway API controller role.
+type GatewayAPIRoleParams struct {
+	Route53RoleParams
+	// ClusterTag is the key of the tag marking the load balancers of the
+	// cluster.
+	ClusterTag    string
+	HostedZoneIDs []string
+}
+
+// ValidateHostedZoneID returns an error if id is not the ID of a Route 53
+// hosted zone.
+func ValidateHostedZoneID(id string) error {
+	if !hostedZoneIDRegexp.MatchString(id) {
+		return fmt.Errorf("%q is not a Route 53 hosted zone ID", id)
+	}
+	return nil
+}
+
+// ReconcileGatewayAPIRole makes sure the IRSA role of the AWS Gateway API
+// controller exists and allows managing the load balancers of the cluster and
+// the records of the given hosted zones.
+func (s *IAMService) ReconcileGatewayAPIRole(awsAccountID string, irsaTrustDomains []string, hostedZoneIDs []string) error {
+	s.log.Info("reconciling Gateway API IAM role")
+
+	if len(irsaTrustDomains) == 0 {
+		return fmt.Errorf("irsaTrustDomains cannot be empty")
+	}
+	for _, id := range hostedZoneIDs {
+		err := ValidateHostedZoneID(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	irsaParams, err := s.irsaRoleParams(awsAccountID, irsaTrustDomains, gatewayAPINamespace, gatewayAPIServiceAccount)
+	if err != nil {
+		return err
+	}
+
+	params := GatewayAPIRoleParams{
+		Route53RoleParams: irsaParams,
+		ClusterTag:        fmt.Sprintf(ClusterIDTag, s.clusterName),
+		HostedZoneIDs:     hostedZoneIDs,
+	}
+
+	err = s.reconcileRole(roleName(GatewayAPIRole, s.clusterName), GatewayAPIRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling Gateway API IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteGatewayAPIRole() error {
+	s.log.Info("deleting Gateway API IAM resources")
+
+	err := s.deleteRole(roleName(GatewayAPIRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting Gateway API IAM resources")
+	return nil
+}
@@ -0,0 +1,160 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("GatewayAPIRole", func() {
+
+	type statement struct {
+		Effect    string
+		Action    interface{}
+		Resource  interface{}
+		Condition map[string]map[string]interface{}
+	}
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockIAMClient  *mocks.MockIAMAPI
+		iamService     *iam.IAMService
+		trustPolicy    string
+		policyDocument string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-cluster-GatewayAPI-Role"),
+		}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+		mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-GatewayAPI-Role"))
+			trustPolicy = *input.PolicyDocument
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		}).AnyTimes()
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-GatewayAPI-Role"))
+			policyDocument = *input.PolicyDocument
+			return &awsIAM.PutRolePolicyOutput{}, nil
+		}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	policyStatements := func() []statement {
+		var policy struct {
+			Statement []statement
+		}
+		Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+		return policy.Statement
+	}
+
+	It("scopes load balancer management to the load balancers of the cluster", func() {
+		err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, nil)
+		Expect(err).To(BeNil())
+
+		clusterTag := "sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"
+		statements := policyStatements()
+		Expect(statements).To(ContainElement(statement{
+			Effect:   "Allow",
+			Action:   []interface{}{"elasticloadbalancing:CreateLoadBalancer", "elasticloadbalancing:CreateTargetGroup"},
+			Resource: "*",
+			Condition: map[string]map[string]interface{}{
+				"StringEquals": {"aws:RequestTag/" + clusterTag: "owned"},
+			},
+		}))
+		Expect(statements).To(ContainElement(statement{
+			Effect:   "Allow",
+			Action:   "elasticloadbalancing:AddTags",
+			Resource: "*",
+			Condition: map[string]map[string]interface{}{
+				"StringEquals": {
+					"elasticloadbalancing:CreateAction": []interface{}{"CreateLoadBalancer", "CreateTargetGroup"},
+					"aws:RequestTag/" + clusterTag:      "owned",
+				},
+			},
+		}))
+		Expect(statements).To(ContainElement(statement{
+			Effect:   "Allow",
+			Action:   "elasticloadbalancing:*",
+			Resource: "*",
+			Condition: map[string]map[string]interface{}{
+				"StringEquals": {"aws:ResourceTag/" + clusterTag: "owned"},
+			},
+		}))
+	})
+
+	It("only uses supported condition keys", func() {
+		err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, []string{"Z0123456789ABC"})
+		Expect(err).To(BeNil())
+
+		for _, statement := range policyStatements() {
+			for _, condition := range statement.Condition {
+				for conditionKey := range condition {
+					Expect(conditionKey).To(SatisfyAny(
+						HavePrefix("aws:RequestTag/"),
+						HavePrefix("aws:ResourceTag/"),
+						Equal("elasticloadbalancing:CreateAction"),
+					))
+				}
+			}
+		}
+	})
+
+	It("allows changing the records of the given hosted zones", func() {
+		err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, []string{"Z0123456789ABC", "Z0123456789DEF"})
+		Expect(err).To(BeNil())
+
+		Expect(policyStatements()).To(ContainElement(statement{
+			Effect:   "Allow",
+			Action:   []interface{}{"route53:ChangeResourceRecordSets", "route53:GetHostedZone", "route53:ListResourceRecordSets"},
+			Resource: []interface{}{"arn:*:route53:::hostedzone/Z0123456789ABC", "arn:*:route53:::hostedzone/Z0123456789DEF"},
+		}))
+	})
+
+	It("does not allow changing records without hosted zones", func() {
+		err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, nil)
+		Expect(err).To(BeNil())
+
+		Expect(policyDocument).NotTo(ContainSubstring("route53:ChangeResourceRecordSets"))
+	})
+
+	It("allows describing VPCs", func() {
+		err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, nil)
+		Expect(err).To(BeNil())
+
+		Expect(policyDocument).To(ContainSubstring(`"ec2:DescribeVpcs"`))
+	})
+
+	It("trusts the Gateway API controller service account", func() {
+		err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, nil)
+		Expect(err).To(BeNil())
+
+		Expect(trustPolicy).To(ContainSubstring(`"irsa.test.gaws.gigantic.io:sub": "system:serviceaccount:aws-application-networking-system:aws-gateway-api-controller"`))
+	})
+
+	DescribeTable("rejects invalid hosted zone IDs",
+		func(hostedZoneID string) {
+			err := iamService.ReconcileGatewayAPIRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, []string{hostedZoneID})
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("empty", ""),
+		Entry("ARN", "arn:aws:route53:::hostedzone/Z0123456789ABC"),
+	)
+})
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"reflect"
 	"slices"
@@ -11,12 +12,22 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
 	"github.com/aws/aws-sdk-go/service/eks"
 	"github.com/aws/aws-sdk-go/service/eks/eksiface"
 	awsiam "github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/identitystore"
+	"github.com/aws/aws-sdk-go/service/identitystore/identitystoreiface"
+	"github.com/aws/aws-sdk-go/service/ssoadmin"
+	"github.com/aws/aws-sdk-go/service/ssoadmin/ssoadminiface"
 	"github.com/giantswarm/microerror"
 	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/audit"
+	"github.com/giantswarm/capa-iam-operator/pkg/cache"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
 )
 
 const (
@@ -31,9 +42,24 @@ const (
 	EBSCSIDriverRole      = "ebs-csi-driver-role"
 	EFSCSIDriverRole      = "efs-csi-driver-role"
 	ClusterAutoscalerRole = "cluster-autoscaler-role"
+	BackupRole            = "backup-role"
+	AMPRole               = "AMP-Role"
+	LoggingRole           = "Logging-Role"
+	XRayRole              = "XRay-Role"
+	SecretsRotationRole   = "SecretsRotation-Role"
+	KarpenterRole         = "Karpenter-Role"
+	GatewayAPIRole        = "GatewayAPI-Role"
+	ReadOnlyRole          = "ReadOnly"
 
 	IAMControllerOwnedTag = "capi-iam-controller/owned"
 	ClusterIDTag          = "sigs.k8s.io/cluster-api-provider-aws/cluster/%s"
+	// CustomTagKeysTag records the keys of the custom tags applied by the
+	// operator, separated by customTagKeysSeparator, so that only those are
+	// removed when they disappear from the custom tags of the cluster.
+	CustomTagKeysTag = "capi-iam-controller/custom-tag-keys"
+
+	customTagKeysSeparator = "+"
+	maxTagValueLength      = 256
 )
 
 type IAMServiceConfig struct {
@@ -46,19 +72,117 @@ type IAMServiceConfig struct {
 	PrincipalRoleARN string
 	CustomTags       map[string]string
 
+	// Partition is optional and defaults to aws. It is the AWS partition of
+	// Region used in ARNs and service principals, e.g. aws-us-gov or aws-cn,
+	// see awsclient.Partition.
+	Partition string
+
 	IAMClientFactory func(awsclientgo.ConfigProvider, string) iamiface.IAMAPI
+	// SkipInstanceProfiles is optional. When set, instance profiles are
+	// neither created nor deleted, e.g. because they are managed externally.
+	SkipInstanceProfiles bool
+
+	// RolePath is optional. When set, roles and instance profiles are created
+	// with this path, e.g. to comply with SCPs restricting IAM paths.
+	RolePath string
+
+	// ConfigClientFactory is optional. When set, an AWS Config rule checking
+	// the required tags is registered for every role created by the service.
+	ConfigClientFactory ConfigClientFactory
+
+	// AuditSink is optional. When set, an audit event is written for every
+	// IAM mutation.
+	AuditSink audit.Sink
+
+	// IAMManagementSession is optional. When set, the IRSA roles are managed
+	// with this session instead of AWSSession, e.g. to keep them in a
+	// centralized IAM management account, and the IRSA trust domains are
+	// registered there as OIDC providers. The roles of instance profiles and
+	// of AWS services, e.g. the backup role, must be in the account of the
+	// cluster and are always managed with AWSSession.
+	IAMManagementSession awsclientgo.ConfigProvider
+	// IAMManagementAccountID is the AWS account ID of IAMManagementSession. It
+	// is required when IAMManagementSession is set.
+	IAMManagementAccountID string
+
+	// SSOAdminSession is optional. When set, the IAM Identity Center
+	// permission set is managed with this session instead of AWSSession,
+	// i.e. in the organization management or delegated administrator
+	// account which owns the Identity Center instance.
+	SSOAdminSession awsclientgo.ConfigProvider
+	// SSOAdminRegion is the region of the Identity Center instance. It
+	// defaults to Region.
+	SSOAdminRegion string
+	// SSOAdminClientFactory and IdentityStoreClientFactory are optional and
+	// default to the IAM Identity Center clients of the AWS SDK. They are
+	// called with SSOAdminSession, or AWSSession when it is not set.
+	SSOAdminClientFactory      SSOAdminClientFactory
+	IdentityStoreClientFactory IdentityStoreClientFactory
+
+	// ExtraPolicyStatements is optional. When set, the statements are merged
+	// into the inline policy of the main role, see ParseExtraPolicyStatements.
+	// Reconciling the role fails with an error asserted by IsPolicyTooLarge
+	// when the merged policy exceeds the size limit of IAM.
+	ExtraPolicyStatements []json.RawMessage
+
+	// OwnershipTagKey and OwnershipTagValue are optional and default to
+	// IAMControllerOwnedTag with an empty value. The tag marks the resources
+	// created by the operator, e.g. a custom key may be required by SCPs
+	// restricting tag keys. Resources tagged with IAMControllerOwnedTag by
+	// previous releases stay owned and are migrated to the configured tag.
+	OwnershipTagKey   string
+	OwnershipTagValue string
+
+	// DryRun is optional. When set, mutating AWS API calls are logged instead
+	// of made, e.g. to audit the changes before deploying to a new account.
+	// Read-only calls are still made.
+	DryRun bool
+
+	// RoleARNCache is optional. When set, GetRoleARN caches the ARNs by role
+	// name, so it must only be shared by services managing the roles of the
+	// same AWS accounts.
+	RoleARNCache *cache.TTLCache[string]
 }
 
 type IAMService struct {
-	clusterName      string
-	iamClient        iamiface.IAMAPI
-	eksClient        eksiface.EKSAPI
-	mainRoleName     string
-	log              logr.Logger
-	region           string
-	roleType         string
-	principalRoleARN string
-	customTags       map[string]string
+	clusterName         string
+	iamClient           iamiface.IAMAPI
+	eksClient           eksiface.EKSAPI
+	ssoAdminClient      ssoadminiface.SSOAdminAPI
+	identityStoreClient identitystoreiface.IdentityStoreAPI
+	configClient        configserviceiface.ConfigServiceAPI
+	mainRoleName        string
+	log                 logr.Logger
+	region              string
+	partition           string
+	roleType            string
+	principalRoleARN    string
+	customTags          map[string]string
+	ownershipTagKey     string
+	ownershipTagValue   string
+
+	extraPolicyStatements []json.RawMessage
+	rolePath              string
+	skipInstanceProfiles  bool
+	dryRun                bool
+	irsaServiceAccounts   map[string]irsaServiceAccount
+	irsaHostedZoneIDs     []string
+	irsaExtraConditions   []TrustPolicyCondition
+	roleARNCache          *cache.TTLCache[string]
+
+	// irsa manages the IRSA roles in the IAM management account, it is nil
+	// when they are managed in the account of the cluster.
+	irsa                   *IAMService
+	iamManagementAccountID string
+	// oidcProviders holds the IRSA trust domains registered as OIDC providers
+	// in the IAM management account.
+	oidcProviders map[string]bool
+}
+
+// irsaServiceAccount is a service account trusted by an IRSA role.
+type irsaServiceAccount struct {
+	namespace string
+	name      string
 }
 
 type Route53RoleParams struct {
@@ -69,6 +193,15 @@ type Route53RoleParams struct {
 	Namespace        string
 	ServiceAccount   string
 	PrincipalRoleARN string
+	// HostedZoneIDs restrict the Route 53 records the role may change. All
+	// hosted zones are allowed when empty.
+	HostedZoneIDs []string
+	// ExtraConditions are added to every statement of the trust policy.
+	ExtraConditions []TrustPolicyCondition
+}
+
+func (p Route53RoleParams) trustPolicyConditions() []TrustPolicyCondition {
+	return p.ExtraConditions
 }
 
 func New(config IAMServiceConfig) (*IAMService, error) {
@@ -87,36 +220,135 @@ func New(config IAMServiceConfig) (*IAMService, error) {
 	if !(config.RoleType == ControlPlaneRole || config.RoleType == NodesRole || config.RoleType == BastionRole || config.RoleType == IRSARole) {
 		return nil, fmt.Errorf("cannot create IAMService with invalid RoleType '%s'", config.RoleType)
 	}
+	if config.RolePath != "" && !(strings.HasPrefix(config.RolePath, "/") && strings.HasSuffix(config.RolePath, "/")) {
+		return nil, fmt.Errorf("cannot create IAMService with invalid RolePath '%s', it must begin and end with '/'", config.RolePath)
+	}
+	if config.IAMManagementSession != nil && config.IAMManagementAccountID == "" {
+		return nil, errors.New("cannot create IAMService with IAMManagementSession and empty IAMManagementAccountID")
+	}
 	iamClient := config.IAMClientFactory(config.AWSSession, config.Region)
+	if config.AuditSink != nil {
+		iamClient = audit.WrapIAMClient(iamClient, config.AuditSink, config.ClusterName)
+	}
+	var iamManagementClient iamiface.IAMAPI
+	if config.IAMManagementSession != nil {
+		iamManagementClient = config.IAMClientFactory(config.IAMManagementSession, config.Region)
+		if config.AuditSink != nil {
+			iamManagementClient = audit.WrapIAMClient(iamManagementClient, config.AuditSink, config.ClusterName)
+		}
+	}
 	eksClient := eks.New(config.AWSSession, &aws.Config{Region: aws.String(config.Region)})
+	ssoAdminSession := config.SSOAdminSession
+	if ssoAdminSession == nil {
+		ssoAdminSession = config.AWSSession
+	}
+	ssoAdminRegion := config.SSOAdminRegion
+	if ssoAdminRegion == "" {
+		ssoAdminRegion = config.Region
+	}
+	var ssoAdminClient ssoadminiface.SSOAdminAPI
+	if config.SSOAdminClientFactory != nil {
+		ssoAdminClient = config.SSOAdminClientFactory(ssoAdminSession, ssoAdminRegion)
+	} else {
+		ssoAdminClient = ssoadmin.New(ssoAdminSession, &aws.Config{Region: aws.String(ssoAdminRegion)})
+	}
+	var identityStoreClient identitystoreiface.IdentityStoreAPI
+	if config.IdentityStoreClientFactory != nil {
+		identityStoreClient = config.IdentityStoreClientFactory(ssoAdminSession, ssoAdminRegion)
+	} else {
+		identityStoreClient = identitystore.New(ssoAdminSession, &aws.Config{Region: aws.String(ssoAdminRegion)})
+	}
+	var configClient configserviceiface.ConfigServiceAPI
+	if config.ConfigClientFactory != nil {
+		configClient = config.ConfigClientFactory(config.AWSSession, config.Region)
+	}
+	// the dry-run clients wrap the audited clients, so that no audit events
+	// are written for skipped mutations
+	if config.DryRun {
+		dryRunLog := dryRunLogger{log: config.Log.WithValues("clusterName", config.ClusterName, "dryRun", true)}
+		iamClient = &dryRunIAMClient{IAMAPI: iamClient, dryRunLogger: dryRunLog}
+		if iamManagementClient != nil {
+			iamManagementClient = &dryRunIAMClient{IAMAPI: iamManagementClient, dryRunLogger: dryRunLog}
+		}
+		ssoAdminClient = &dryRunSSOAdminClient{SSOAdminAPI: ssoAdminClient, dryRunLogger: dryRunLog}
+		if configClient != nil {
+			configClient = &dryRunConfigClient{ConfigServiceAPI: configClient, dryRunLogger: dryRunLog}
+		}
+	}
+
+	partition := config.Partition
+	if partition == "" {
+		partition = endpoints.AwsPartitionID
+	}
+
+	ownershipTagKey := config.OwnershipTagKey
+	if ownershipTagKey == "" {
+		ownershipTagKey = IAMControllerOwnedTag
+	}
 
 	l := config.Log.WithValues("clusterName", config.ClusterName, "iam-role", config.RoleType)
 	s := &IAMService{
-		clusterName:      config.ClusterName,
-		iamClient:        iamClient,
-		eksClient:        eksClient,
-		mainRoleName:     config.MainRoleName,
-		log:              l,
-		roleType:         config.RoleType,
-		region:           config.Region,
-		principalRoleARN: config.PrincipalRoleARN,
-		customTags:       config.CustomTags,
+		clusterName:         config.ClusterName,
+		iamClient:           iamClient,
+		eksClient:           eksClient,
+		ssoAdminClient:      ssoAdminClient,
+		identityStoreClient: identityStoreClient,
+		configClient:        configClient,
+		mainRoleName:        config.MainRoleName,
+		log:                 l,
+		roleType:            config.RoleType,
+		region:              config.Region,
+		partition:           partition,
+		principalRoleARN:    config.PrincipalRoleARN,
+		customTags:          config.CustomTags,
+		ownershipTagKey:     ownershipTagKey,
+		ownershipTagValue:   config.OwnershipTagValue,
+
+		extraPolicyStatements: config.ExtraPolicyStatements,
+		rolePath:              config.RolePath,
+		skipInstanceProfiles:  config.SkipInstanceProfiles,
+		dryRun:                config.DryRun,
+		roleARNCache:          config.RoleARNCache,
+	}
+
+	if iamManagementClient != nil {
+		// AWS Config rules are registered in the account of the cluster, so
+		// the roles of the IAM management account are not checked
+		irsa := *s
+		irsa.iamClient = iamManagementClient
+		irsa.configClient = nil
+		irsa.log = l.WithValues("iamManagementAccountID", config.IAMManagementAccountID)
+		irsa.oidcProviders = map[string]bool{}
+		s.irsa = &irsa
+		s.iamManagementAccountID = config.IAMManagementAccountID
 	}
 
 	return s, nil
 }
 
+// forRole returns the service managing the role with the given name, which is
+// the service of the IAM management account for IRSA roles when configured.
+func (s *IAMService) forRole(roleName string) *IAMService {
+	if s.irsa != nil && slices.Contains(s.irsaTrustingRoleNames(), roleName) {
+		return s.irsa
+	}
+	return s
+}
+
+// irsaTrustingRoleNames returns the names of all roles of the cluster which
+// are assumed by service accounts.
+func (s *IAMService) irsaTrustingRoleNames() []string {
+	var names []string
+	for _, roleType := range append(getIRSARoles(), AMPRole, LoggingRole, XRayRole, KarpenterRole, GatewayAPIRole) {
+		names = append(names, roleName(roleType, s.clusterName))
+	}
+	return names
+}
+
 func (s *IAMService) ReconcileRole() error {
 	s.log.Info("reconciling IAM role")
 
-	params := struct {
-		ClusterName      string
-		EC2ServiceDomain string
-	}{
-		ClusterName:      s.clusterName,
-		EC2ServiceDomain: ec2ServiceDomain(s.region),
-	}
-	err := s.reconcileRole(s.mainRoleName, s.roleType, params)
+	err := s.reconcileRole(s.mainRoleName, s.roleType, s.machineRoleParams())
 	if err != nil {
 		return err
 	}
@@ -125,6 +357,41 @@ func (s *IAMService) ReconcileRole() error {
 	return nil
 }
 
+// IsMachineRoleType returns true if the given role type is assumed by EC2
+// instances and can therefore be used as an extra role type of a machine
+// template.
+func IsMachineRoleType(roleType string) bool {
+	return roleType == BastionRole || roleType == ControlPlaneRole || roleType == NodesRole
+}
+
+// ReconcileExtraRole creates the IAM role of the given machine role type for
+// the cluster, which is managed alongside the main role.
+func (s *IAMService) ReconcileExtraRole(roleType string) error {
+	if !IsMachineRoleType(roleType) {
+		return fmt.Errorf("unsupported extra role type %q", roleType)
+	}
+
+	s.log.Info("reconciling extra IAM role", "role_type", roleType)
+
+	err := s.reconcileRole(roleName(roleType, s.clusterName), roleType, s.machineRoleParams())
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling extra IAM role", "role_type", roleType)
+	return nil
+}
+
+func (s *IAMService) machineRoleParams() interface{} {
+	return struct {
+		ClusterName      string
+		EC2ServiceDomain string
+	}{
+		ClusterName:      s.clusterName,
+		EC2ServiceDomain: ec2ServiceDomain(s.partition),
+	}
+}
+
 func (s *IAMService) ReconcileKiamRole() error {
 	s.log.Info("reconciling KIAM IAM role")
 
@@ -136,7 +403,10 @@ func (s *IAMService) ReconcileKiamRole() error {
 		}
 
 		o, err := s.iamClient.GetRole(i)
-		if err != nil {
+		if IsNotFound(err) && s.dryRun {
+			s.log.Info("dry run, ControlPlane role was not created, skipping KIAM role")
+			return nil
+		} else if err != nil {
 			s.log.Error(err, "failed to fetch ControlPlane role")
 			return err
 		}
@@ -149,9 +419,9 @@ func (s *IAMService) ReconcileKiamRole() error {
 		ControlPlaneRoleARN string
 		EC2ServiceDomain    string
 	}{
-		AWSDomain:           awsDomain(s.region),
+		AWSDomain:           s.partition,
 		ControlPlaneRoleARN: controlPlaneRoleARN,
-		EC2ServiceDomain:    ec2ServiceDomain(s.region),
+		EC2ServiceDomain:    ec2ServiceDomain(s.partition),
 	}
 
 	err := s.reconcileRole(roleName(KIAMRole, s.clusterName), KIAMRole, params)
@@ -184,11 +454,69 @@ func (s *IAMService) ReconcileRolesForIRSA(awsAccountID string, irsaTrustDomains
 	return nil
 }
 
+// IRSARoleNames returns the names of the IAM roles created by
+// ReconcileRolesForIRSA.
+func (s *IAMService) IRSARoleNames() []string {
+	var names []string
+	for _, roleType := range getIRSARoles() {
+		names = append(names, roleName(roleType, s.clusterName))
+	}
+	return names
+}
+
+// RoleARN returns the ARN of the role with the given name in the given
+// account of the cluster, without calling AWS. The role does not need to
+// exist. The ARNs of IRSA roles are in the IAM management account when
+// configured.
+func (s *IAMService) RoleARN(accountID, roleName string) string {
+	if s.forRole(roleName) == s.irsa {
+		accountID = s.iamManagementAccountID
+	}
+	path := s.rolePath
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role%s%s", s.partition, accountID, path, roleName)
+}
+
+// IRSARoleTypes returns the role types of the IAM roles created by
+// ReconcileRolesForIRSA.
+func IRSARoleTypes() []string {
+	return getIRSARoles()
+}
+
+// RoleName returns the name of the IAM role of the given role type which is
+// created for the cluster, e.g. the IRSA or backup roles.
+func RoleName(roleType, clusterName string) string {
+	return roleName(roleType, clusterName)
+}
+
+// IRSARoleARNs returns the ARNs of the IAM roles created by
+// ReconcileRolesForIRSA, keyed by role type.
+func (s *IAMService) IRSARoleARNs() (map[string]string, error) {
+	arns := map[string]string{}
+	for _, roleType := range getIRSARoles() {
+		arn, err := s.GetRoleARN(roleName(roleType, s.clusterName))
+		if IsNotFound(microerror.Cause(err)) && s.dryRun {
+			// roles are not created in dry-run mode
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		arns[roleType] = arn
+	}
+	return arns, nil
+}
+
 func (s *IAMService) generateRoute53RoleParams(roleTypeToReconcile string, awsAccountID string, irsaTrustDomains []string) (Route53RoleParams, error) {
 	if len(irsaTrustDomains) == 0 || slices.ContainsFunc(irsaTrustDomains, func(irsaTrustDomain string) bool { return irsaTrustDomain == "" }) {
 		return Route53RoleParams{}, fmt.Errorf("irsaTrustDomains cannot be empty or have empty values: %v", irsaTrustDomains)
 	}
 
+	if sa, ok := s.irsaServiceAccounts[roleTypeToReconcile]; ok {
+		return s.irsaRoleParams(awsAccountID, irsaTrustDomains, sa.namespace, sa.name)
+	}
+
 	namespace := "kube-system"
 	serviceAccount, err := getServiceAccount(roleTypeToReconcile)
 	if err != nil {
@@ -196,26 +524,54 @@ func (s *IAMService) generateRoute53RoleParams(roleTypeToReconcile string, awsAc
 		return Route53RoleParams{}, err
 	}
 
-	params := Route53RoleParams{
-		AWSDomain:        awsDomain(s.region),
-		EC2ServiceDomain: ec2ServiceDomain(s.region),
+	return s.irsaRoleParams(awsAccountID, irsaTrustDomains, namespace, serviceAccount)
+}
+
+// irsaRoleParams returns the parameters of an IRSA trust policy for the given
+// service account. awsAccountID is the account of the cluster, the policy
+// trusts the OIDC providers of the IAM management account when configured,
+// which are registered if missing.
+func (s *IAMService) irsaRoleParams(awsAccountID string, irsaTrustDomains []string, namespace, serviceAccount string) (Route53RoleParams, error) {
+	if s.irsa != nil {
+		err := s.irsa.ensureOIDCProviders(irsaTrustDomains)
+		if err != nil {
+			return Route53RoleParams{}, err
+		}
+		awsAccountID = s.iamManagementAccountID
+	}
+
+	return Route53RoleParams{
+		AWSDomain:        s.partition,
+		EC2ServiceDomain: ec2ServiceDomain(s.partition),
 		AccountID:        awsAccountID,
 		IRSATrustDomains: irsaTrustDomains,
 		Namespace:        namespace,
 		ServiceAccount:   serviceAccount,
+		HostedZoneIDs:    s.irsaHostedZoneIDs,
+		ExtraConditions:  s.irsaExtraConditions,
+	}, nil
+}
+
+func (s *IAMService) reconcileRole(roleName string, roleType string, params interface{}) (err error) {
+	if t := s.forRole(roleName); t != s {
+		return t.reconcileRole(roleName, roleType, params)
 	}
 
-	return params, nil
-}
+	defer func() {
+		result := metrics.ResultSuccess
+		if err != nil {
+			result = metrics.ResultError
+		}
+		metrics.RoleReconcileTotal.WithLabelValues(roleType, result).Inc()
+	}()
 
-func (s *IAMService) reconcileRole(roleName string, roleType string, params interface{}) error {
 	l := s.log.WithValues("role_name", roleName, "role_type", roleType)
-	err := s.createRole(roleName, roleType, params)
+	err = s.createRole(roleName, roleType, params)
 	if err != nil {
 		return err
 	}
 
-	if roleType == IRSARole || roleType == CertManagerRole || roleType == Route53Role || roleType == ALBConrollerRole || roleType == EBSCSIDriverRole || roleType == EFSCSIDriverRole || roleType == ClusterAutoscalerRole {
+	if roleType == IRSARole || roleType == CertManagerRole || roleType == Route53Role || roleType == ALBConrollerRole || roleType == EBSCSIDriverRole || roleType == EFSCSIDriverRole || roleType == ClusterAutoscalerRole || roleType == AMPRole || roleType == LoggingRole || roleType == XRayRole || roleType == KarpenterRole || roleType == GatewayAPIRole || roleType == ReadOnlyRole {
 		if err = s.applyAssumePolicyRole(roleName, roleType, params); err != nil {
 			l.Error(err, "Failed to apply assume role policy to role")
 			return err
@@ -228,6 +584,14 @@ func (s *IAMService) reconcileRole(roleName string, roleType string, params inte
 		return err
 	}
 
+	// the rule is put on every reconciliation, so that roles created before
+	// AWS Config integration was enabled get one too and deleted rules are
+	// restored
+	err = s.putConfigRule(roleName)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -235,7 +599,15 @@ func (s *IAMService) reconcileRole(roleName string, roleType string, params inte
 func (s *IAMService) createRole(roleName string, roleType string, params interface{}) error {
 	l := s.log.WithValues("role_name", roleName, "role_type", roleType)
 
-	_, err := s.iamClient.GetRole(&awsiam.GetRoleInput{
+	// AWS would reject the name anyway, but a typed error lets callers stop
+	// retrying until the name is changed
+	err := ValidateRoleName(roleName)
+	if err != nil {
+		l.Error(err, "refusing to create IAM role with invalid name")
+		return err
+	}
+
+	_, err = s.iamClient.GetRole(&awsiam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	})
 
@@ -249,47 +621,54 @@ func (s *IAMService) createRole(roleName string, roleType string, params interfa
 		return err
 	}
 
-	tmpl := getTrustPolicyTemplate(roleType)
-
-	assumeRolePolicyDocument, err := generatePolicyDocument(tmpl, params)
+	assumeRolePolicyDocument, err := generateTrustPolicyDocument(roleType, params)
 	if err != nil {
 		l.Error(err, "failed to generate assume policy document from template for IAM role")
 		return err
 	}
 
-	tags := []*awsiam.Tag{
-		{
-			Key:   aws.String(IAMControllerOwnedTag),
-			Value: aws.String(""),
-		},
-		{
-			Key:   aws.String(fmt.Sprintf(ClusterIDTag, s.clusterName)),
-			Value: aws.String("owned"),
-		},
-	}
-	for k, v := range s.customTags {
-		tags = append(tags, &awsiam.Tag{
-			Key:   aws.String(k),
-			Value: aws.String(v),
-		})
-	}
+	tags := s.creationTags()
 
-	_, err = s.iamClient.CreateRole(&awsiam.CreateRoleInput{
+	createRoleInput := &awsiam.CreateRoleInput{
 		RoleName:                 aws.String(roleName),
 		AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocument),
 		Tags:                     tags,
-	})
+	}
+	if s.rolePath != "" {
+		createRoleInput.Path = aws.String(s.rolePath)
+	}
+	_, err = s.iamClient.CreateRole(createRoleInput)
 	if err != nil {
 		l.Error(err, "failed to create IAM Role")
 		return err
 	}
 
+	if !s.skipInstanceProfiles {
+		err = s.createInstanceProfile(roleName, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.Info("successfully created a new IAM role")
+
+	return nil
+}
+
+// createInstanceProfile creates an instance profile with the same name as the
+// role and adds the role to it.
+func (s *IAMService) createInstanceProfile(roleName string, tags []*awsiam.Tag) error {
+	l := s.log.WithValues("role_name", roleName)
+
 	i2 := &awsiam.CreateInstanceProfileInput{
 		InstanceProfileName: aws.String(roleName),
 		Tags:                tags,
 	}
+	if s.rolePath != "" {
+		i2.Path = aws.String(s.rolePath)
+	}
 
-	_, err = s.iamClient.CreateInstanceProfile(i2)
+	_, err := s.iamClient.CreateInstanceProfile(i2)
 	if IsAlreadyExists(err) {
 		// fall thru
 	} else if err != nil {
@@ -310,18 +689,438 @@ func (s *IAMService) createRole(roleName string, roleType string, params interfa
 		return err
 	}
 
-	l.Info("successfully created a new IAM role")
+	return nil
+}
+
+// ListRoles returns the names of all IAM roles below the configured role path.
+func (s *IAMService) ListRoles() ([]string, error) {
+	pathPrefix := s.rolePath
+	if pathPrefix == "" {
+		pathPrefix = "/"
+	}
+
+	roles, err := s.listRoles(pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var roleNames []string
+	for _, role := range roles {
+		roleNames = append(roleNames, aws.StringValue(role.RoleName))
+	}
+
+	return roleNames, nil
+}
+
+// listLegacyRoles returns the names of the IAM roles at the default path /,
+// where the roles were created before a role path was configured. Nothing is
+// returned when no other path is configured, since those roles are already
+// listed by ListRoles, or when listing the roles at / is denied, e.g. by SCPs
+// restricting the operator to the configured path.
+func (s *IAMService) listLegacyRoles() ([]string, error) {
+	if s.rolePath == "" || s.rolePath == "/" {
+		return nil, nil
+	}
+
+	roles, err := s.listRoles("/")
+	if IsAccessDenied(err) {
+		s.log.Info("listing IAM roles at the default path is denied, skipping them")
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var roleNames []string
+	for _, role := range roles {
+		if aws.StringValue(role.Path) == "/" {
+			roleNames = append(roleNames, aws.StringValue(role.RoleName))
+		}
+	}
+
+	return roleNames, nil
+}
+
+func (s *IAMService) listRoles(pathPrefix string) ([]*awsiam.Role, error) {
+	var roles []*awsiam.Role
+	input := &awsiam.ListRolesInput{
+		PathPrefix: aws.String(pathPrefix),
+	}
+	for {
+		o, err := s.iamClient.ListRoles(input)
+		if err != nil {
+			s.log.Error(err, "failed to list IAM roles", "path_prefix", pathPrefix)
+			return nil, err
+		}
+		roles = append(roles, o.Roles...)
+		if !aws.BoolValue(o.IsTruncated) {
+			break
+		}
+		input.Marker = o.Marker
+	}
+
+	return roles, nil
+}
+
+// ListClusterRoles returns the names of the IAM roles below the configured
+// role path or at the default path / which are owned by the operator and
+// tagged as owned by the cluster. Only the tags of roles whose name contains the cluster name are
+// listed, since the tags of every role of the account would have to be
+// requested otherwise.
+func (s *IAMService) ListClusterRoles() ([]string, error) {
+	clusterRoleNames, err := s.listClusterRoles()
+	if err != nil {
+		return nil, err
+	}
+	if s.irsa != nil {
+		irsaRoleNames, err := s.irsa.listClusterRoles()
+		if err != nil {
+			return nil, err
+		}
+		// only the IRSA roles are managed in the IAM management account, so
+		// the other roles are left alone since they would be deleted from the
+		// account of the cluster
+		for _, roleName := range irsaRoleNames {
+			if s.forRole(roleName) == s.irsa {
+				clusterRoleNames = append(clusterRoleNames, roleName)
+			}
+		}
+	}
+
+	return clusterRoleNames, nil
+}
+
+func (s *IAMService) listClusterRoles() ([]string, error) {
+	roleNames, err := s.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	legacyRoleNames, err := s.listLegacyRoles()
+	if err != nil {
+		return nil, err
+	}
+	roleNames = append(roleNames, legacyRoleNames...)
+
+	clusterTag := fmt.Sprintf(ClusterIDTag, s.clusterName)
+
+	var clusterRoleNames []string
+	for _, roleName := range roleNames {
+		if !strings.Contains(roleName, s.clusterName) {
+			continue
+		}
+
+		var tags []*awsiam.Tag
+		input := &awsiam.ListRoleTagsInput{
+			RoleName: aws.String(roleName),
+		}
+		for {
+			o, err := s.iamClient.ListRoleTags(input)
+			if IsNotFound(err) {
+				break
+			} else if err != nil {
+				s.log.Error(err, "failed to list tags of IAM role", "role_name", roleName)
+				return nil, err
+			}
+			tags = append(tags, o.Tags...)
+			if !aws.BoolValue(o.IsTruncated) {
+				break
+			}
+			input.Marker = o.Marker
+		}
+
+		if !s.isOwned(tags) {
+			continue
+		}
+		for _, tag := range tags {
+			if aws.StringValue(tag.Key) == clusterTag && aws.StringValue(tag.Value) == "owned" {
+				clusterRoleNames = append(clusterRoleNames, roleName)
+				break
+			}
+		}
+	}
+
+	return clusterRoleNames, nil
+}
+
+// ReconcileRoleTags makes sure the tags of the given role and of its instance
+// profile match the custom tags of the cluster. Only roles owned by the
+// operator are changed. Tags added by the operator to identify its roles are
+// restored, and only custom tags previously applied by the operator are
+// removed, so tags added by others are left alone.
+func (s *IAMService) ReconcileRoleTags(roleName string) error {
+	if t := s.forRole(roleName); t != s {
+		return t.ReconcileRoleTags(roleName)
+	}
+
+	l := s.log.WithValues("role_name", roleName)
+
+	var currentTags []*awsiam.Tag
+	input := &awsiam.ListRoleTagsInput{
+		RoleName: aws.String(roleName),
+	}
+	for {
+		o, err := s.iamClient.ListRoleTags(input)
+		if IsNotFound(err) && s.dryRun {
+			l.Info("dry run, IAM role was not created, skipping tags")
+			return nil
+		} else if err != nil {
+			l.Error(err, "failed to list tags of IAM role")
+			return err
+		}
+		currentTags = append(currentTags, o.Tags...)
+		if !aws.BoolValue(o.IsTruncated) {
+			break
+		}
+		input.Marker = o.Marker
+	}
+
+	if !s.isOwned(currentTags) {
+		l.Info("IAM role is not owned by the operator, skipping tags")
+		return nil
+	}
+
+	desired := s.desiredTags()
+	err := s.reconcileTags(l, "IAM role", currentTags, desired,
+		func(tags []*awsiam.Tag) error {
+			_, err := s.iamClient.TagRole(&awsiam.TagRoleInput{
+				RoleName: aws.String(roleName),
+				Tags:     tags,
+			})
+			return err
+		},
+		func(tagKeys []*string) error {
+			_, err := s.iamClient.UntagRole(&awsiam.UntagRoleInput{
+				RoleName: aws.String(roleName),
+				TagKeys:  tagKeys,
+			})
+			return err
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if !s.skipInstanceProfiles {
+		err := s.reconcileInstanceProfileTags(roleName, desired)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// reconcileInstanceProfileTags makes sure the tags of the instance profile
+// with the same name as the role match the desired tags. Roles without an
+// instance profile, and instance profiles not owned by the operator, are
+// ignored.
+func (s *IAMService) reconcileInstanceProfileTags(roleName string, desired map[string]string) error {
+	l := s.log.WithValues("instance_profile_name", roleName)
+
+	var currentTags []*awsiam.Tag
+	input := &awsiam.ListInstanceProfileTagsInput{
+		InstanceProfileName: aws.String(roleName),
+	}
+	for {
+		o, err := s.iamClient.ListInstanceProfileTags(input)
+		if IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			l.Error(err, "failed to list tags of instance profile")
+			return err
+		}
+		currentTags = append(currentTags, o.Tags...)
+		if !aws.BoolValue(o.IsTruncated) {
+			break
+		}
+		input.Marker = o.Marker
+	}
+
+	if !s.isOwned(currentTags) {
+		l.Info("instance profile is not owned by the operator, skipping tags")
+		return nil
+	}
+
+	return s.reconcileTags(l, "instance profile", currentTags, desired,
+		func(tags []*awsiam.Tag) error {
+			_, err := s.iamClient.TagInstanceProfile(&awsiam.TagInstanceProfileInput{
+				InstanceProfileName: aws.String(roleName),
+				Tags:                tags,
+			})
+			return err
+		},
+		func(tagKeys []*string) error {
+			_, err := s.iamClient.UntagInstanceProfile(&awsiam.UntagInstanceProfileInput{
+				InstanceProfileName: aws.String(roleName),
+				TagKeys:             tagKeys,
+			})
+			return err
+		},
+	)
+}
+
+// reconcileTags adds and removes the tags of a role or an instance profile,
+// named by kind in logs, so that currentTags match the desired tags.
+func (s *IAMService) reconcileTags(l logr.Logger, kind string, currentTags []*awsiam.Tag, desired map[string]string, tag func([]*awsiam.Tag) error, untag func([]*string) error) error {
+	tagsToAdd, tagKeysToRemove := s.diffTags(currentTags, desired)
+
+	if len(tagsToAdd) > 0 {
+		err := tag(tagsToAdd)
+		if err != nil {
+			l.Error(err, "failed to tag "+kind)
+			return err
+		}
+		l.Info("added tags to "+kind, "tags", len(tagsToAdd))
+	}
+
+	if len(tagKeysToRemove) > 0 {
+		err := untag(tagKeysToRemove)
+		if err != nil {
+			l.Error(err, "failed to untag "+kind)
+			return err
+		}
+		l.Info("removed tags from "+kind, "tags", len(tagKeysToRemove))
+	}
+
+	return nil
+}
+
+// desiredTags returns the tags identifying resources owned by the operator and
+// the custom tags of the cluster, together with the CustomTagKeysTag recording
+// the keys of the custom tags.
+func (s *IAMService) desiredTags() map[string]string {
+	desired := map[string]string{}
+	for _, tag := range s.ownedTags() {
+		desired[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	var customTagKeys []string
+	for k, v := range s.customTags {
+		if _, ok := desired[k]; ok || k == CustomTagKeysTag {
+			continue
+		}
+		desired[k] = v
+		customTagKeys = append(customTagKeys, k)
+	}
+	record := s.customTagKeysRecord(customTagKeys)
+	if record != "" {
+		desired[CustomTagKeysTag] = record
+	}
+
+	return desired
+}
+
+// creationTags returns the desired tags of new roles and instance profiles,
+// beginning with the tags identifying them as owned by the operator.
+func (s *IAMService) creationTags() []*awsiam.Tag {
+	tags := s.ownedTags()
+
+	desired := s.desiredTags()
+	for _, tag := range tags {
+		delete(desired, aws.StringValue(tag.Key))
+	}
+	for _, k := range slices.Sorted(maps.Keys(desired)) {
+		tags = append(tags, &awsiam.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(desired[k]),
+		})
+	}
+
+	return tags
+}
+
+// customTagKeysRecord joins the keys to the value of the CustomTagKeysTag.
+// Keys containing the separator, or not fitting into a tag value, cannot be
+// recorded and are therefore never removed by the operator.
+func (s *IAMService) customTagKeysRecord(keys []string) string {
+	slices.Sort(keys)
+
+	var record string
+	for _, k := range keys {
+		value := k
+		if record != "" {
+			value = record + customTagKeysSeparator + k
+		}
+		if strings.Contains(k, customTagKeysSeparator) || len(value) > maxTagValueLength {
+			s.log.Info("custom tag key cannot be recorded, it will not be removed when it is removed from the cluster", "tag_key", k)
+			continue
+		}
+		record = value
+	}
+
+	return record
+}
+
+// diffTags returns the tags which have to be added or updated and the keys of
+// the tags which have to be removed so that current matches desired. Only
+// tags applied by the operator are removed: the keys recorded in the
+// CustomTagKeysTag of current, the record itself and the default ownership
+// tag replaced by a custom one. Callers must add tags before removing any, so
+// that the default ownership tag is only removed once the custom one is set.
+func (s *IAMService) diffTags(currentTags []*awsiam.Tag, desired map[string]string) ([]*awsiam.Tag, []*string) {
+	current := map[string]string{}
+	for _, tag := range currentTags {
+		current[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	var tagsToAdd []*awsiam.Tag
+	for k, v := range desired {
+		if value, ok := current[k]; !ok || value != v {
+			tagsToAdd = append(tagsToAdd, &awsiam.Tag{
+				Key:   aws.String(k),
+				Value: aws.String(v),
+			})
+		}
+	}
+	slices.SortFunc(tagsToAdd, func(a, b *awsiam.Tag) int { return strings.Compare(*a.Key, *b.Key) })
+
+	removable := []string{CustomTagKeysTag, IAMControllerOwnedTag}
+	if record := current[CustomTagKeysTag]; record != "" {
+		removable = append(removable, strings.Split(record, customTagKeysSeparator)...)
+	}
+
+	var tagKeysToRemove []*string
+	for _, k := range removable {
+		if _, ok := current[k]; !ok {
+			continue
+		}
+		if _, ok := desired[k]; ok || s.isProtectedTag(k) {
+			continue
+		}
+		tagKeysToRemove = append(tagKeysToRemove, aws.String(k))
+	}
+	slices.SortFunc(tagKeysToRemove, func(a, b *string) int { return strings.Compare(*a, *b) })
+
+	return tagsToAdd, tagKeysToRemove
+}
+
+// ownedTags returns the tags the operator adds to the roles and instance
+// profiles it creates for the cluster.
+func (s *IAMService) ownedTags() []*awsiam.Tag {
+	return []*awsiam.Tag{
+		{
+			Key:   aws.String(s.ownershipTagKey),
+			Value: aws.String(s.ownershipTagValue),
+		},
+		{
+			Key:   aws.String(fmt.Sprintf(ClusterIDTag, s.clusterName)),
+			Value: aws.String("owned"),
+		},
+	}
+}
+
+// isProtectedTag returns whether the tag is set by the operator to identify
+// its roles and must therefore never be removed.
+func (s *IAMService) isProtectedTag(tagKey string) bool {
+	return tagKey == s.ownershipTagKey || tagKey == fmt.Sprintf(ClusterIDTag, s.clusterName)
+}
+
 func (s *IAMService) applyAssumePolicyRole(roleName string, roleType string, params interface{}) error {
 	log := s.log.WithValues("role_name", roleName)
 	i := &awsiam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	}
 
-	_, err := s.iamClient.GetRole(i)
+	output, err := s.iamClient.GetRole(i)
 
 	if IsNotFound(err) {
 		log.Info("role doesn't exist. Skipping application of assume policy")
@@ -333,15 +1132,38 @@ func (s *IAMService) applyAssumePolicyRole(roleName string, roleType string, par
 		return err
 	}
 
-	log.Info("applying assume policy role to role")
-
-	tmpl := getTrustPolicyTemplate(roleType)
-	assumeRolePolicyDocument, err := generatePolicyDocument(tmpl, params)
+	assumeRolePolicyDocument, err := generateTrustPolicyDocument(roleType, params)
 	if err != nil {
 		log.Error(err, "failed to generate assume policy document from template for IAM role")
 		return err
 	}
 
+	// updating an unchanged policy would only add noise to CloudTrail
+	if output != nil && output.Role != nil && output.Role.AssumeRolePolicyDocument != nil {
+		isEqual, err := areEqualPolicy(*output.Role.AssumeRolePolicyDocument, assumeRolePolicyDocument)
+		if err != nil {
+			log.Error(err, "failed to compare assume policy documents")
+			return err
+		}
+		if isEqual {
+			log.Info("assume policy of IAM role is up to date, skipping")
+			return nil
+		}
+
+		// the trusted OIDC providers change e.g. while migrating a cluster to
+		// a new issuer, when both are trusted for a while
+		added, removed, err := trustDomainChanges(*output.Role.AssumeRolePolicyDocument, assumeRolePolicyDocument)
+		if err != nil {
+			log.Error(err, "failed to compare trusted OIDC providers of assume policy documents")
+			return err
+		}
+		if len(added) > 0 || len(removed) > 0 {
+			log = log.WithValues("added_trust_domains", added, "removed_trust_domains", removed)
+		}
+	}
+
+	log.Info("applying assume policy role to role")
+
 	updateInput := &awsiam.UpdateAssumeRolePolicyInput{
 		RoleName:       aws.String(roleName),
 		PolicyDocument: aws.String(assumeRolePolicyDocument),
@@ -363,6 +1185,19 @@ func (s *IAMService) attachInlinePolicy(roleName string, roleType string, params
 		return err
 	}
 
+	if roleName == s.mainRoleName && len(s.extraPolicyStatements) > 0 {
+		policyDocument, err = mergePolicyStatements(policyDocument, s.extraPolicyStatements)
+		if err != nil {
+			l.Error(err, "failed to merge extra statements into inline policy document for IAM role")
+			return err
+		}
+		err = checkInlinePolicySize(policyDocument)
+		if err != nil {
+			l.Error(err, "inline policy document with extra statements is too large for IAM role")
+			return err
+		}
+	}
+
 	// check if the inline policy already exists
 	output, err := s.iamClient.GetRolePolicy(&awsiam.GetRolePolicyInput{
 		RoleName:   aws.String(roleName),
@@ -423,6 +1258,21 @@ func (s *IAMService) DeleteRole() error {
 	return nil
 }
 
+// DeleteRoleByName deletes the given IAM role together with its policies and
+// instance profile. It is used to clean up roles which are no longer
+// referenced, e.g. after the instance profile of a template was renamed.
+func (s *IAMService) DeleteRoleByName(roleName string) error {
+	s.log.Info("deleting IAM resources", "role_name", roleName)
+
+	err := s.deleteRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting IAM resources", "role_name", roleName)
+	return nil
+}
+
 func (s *IAMService) DeleteKiamRole() error {
 	s.log.Info("deleting KIAM IAM resources")
 
@@ -436,6 +1286,37 @@ func (s *IAMService) DeleteKiamRole() error {
 	return nil
 }
 
+// CleanupDeprecatedKiamRole deletes the KIAM role of the cluster if it exists
+// and is owned by the operator. It returns whether a role was deleted.
+func (s *IAMService) CleanupDeprecatedKiamRole() (bool, error) {
+	kiamRoleName := roleName(KIAMRole, s.clusterName)
+	l := s.log.WithValues("role_name", kiamRoleName)
+
+	o, err := s.iamClient.GetRole(&awsiam.GetRoleInput{
+		RoleName: aws.String(kiamRoleName),
+	})
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		l.Error(err, "failed to fetch KIAM IAM role")
+		return false, err
+	}
+
+	if !s.isOwned(o.Role.Tags) {
+		l.Info("KIAM IAM role is not owned by capa-iam-operator, not deleting it")
+		return false, nil
+	}
+
+	l.Info("deleting deprecated KIAM IAM role")
+	err = s.DeleteKiamRole()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (s *IAMService) DeleteRoute53Role() error {
 	s.log.Info("deleting Route53 IAM resources")
 
@@ -460,37 +1341,56 @@ func (s *IAMService) DeleteRolesForIRSA() error {
 		}
 	}
 
+	if s.irsa != nil {
+		err := s.irsa.deleteOIDCProviders()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (s *IAMService) deleteRole(roleName string) error {
+	if t := s.forRole(roleName); t != s {
+		return t.deleteRole(roleName)
+	}
+
 	l := s.log.WithValues("role_name", roleName)
 
+	// a role with an invalid name was never created
+	if ValidateRoleName(roleName) != nil {
+		l.Info("IAM role name is invalid, nothing to delete")
+		return nil
+	}
+
 	// clean any attached policies, otherwise deletion of role will not work
 	err := s.cleanRolePolicies(roleName)
 	if err != nil {
 		return err
 	}
 
-	i := &awsiam.RemoveRoleFromInstanceProfileInput{
-		InstanceProfileName: aws.String(roleName),
-		RoleName:            aws.String(roleName),
-	}
+	if !s.skipInstanceProfiles {
+		i := &awsiam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(roleName),
+			RoleName:            aws.String(roleName),
+		}
 
-	_, err = s.iamClient.RemoveRoleFromInstanceProfile(i)
-	if err != nil && !IsNotFound(err) {
-		l.Error(err, "failed to remove role from instance profile")
-		return err
-	}
+		_, err = s.iamClient.RemoveRoleFromInstanceProfile(i)
+		if err != nil && !IsNotFound(err) {
+			l.Error(err, "failed to remove role from instance profile")
+			return err
+		}
 
-	i2 := &awsiam.DeleteInstanceProfileInput{
-		InstanceProfileName: aws.String(roleName),
-	}
+		i2 := &awsiam.DeleteInstanceProfileInput{
+			InstanceProfileName: aws.String(roleName),
+		}
 
-	_, err = s.iamClient.DeleteInstanceProfile(i2)
-	if err != nil && !IsNotFound(err) {
-		l.Error(err, "failed to delete instance profile")
-		return err
+		_, err = s.iamClient.DeleteInstanceProfile(i2)
+		if err != nil && !IsNotFound(err) {
+			l.Error(err, "failed to delete instance profile")
+			return err
+		}
 	}
 
 	// delete the role
@@ -503,6 +1403,13 @@ func (s *IAMService) deleteRole(roleName string) error {
 		l.Error(err, "failed to delete role")
 		return err
 	}
+	// a role created again with the same name gets a new ARN
+	s.roleARNCache.Delete(roleName)
+
+	err = s.deleteConfigRule(roleName)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -543,7 +1450,7 @@ func (s *IAMService) cleanAttachedPolicies(roleName string) error {
 	}
 
 	for _, p := range o.AttachedPolicies {
-		l.Info(fmt.Sprintf("detaching policy %s", *p.PolicyName))
+		l.Info("detaching policy", "policy_name", *p.PolicyName)
 
 		i := &awsiam.DetachRolePolicyInput{
 			PolicyArn: p.PolicyArn,
@@ -552,11 +1459,11 @@ func (s *IAMService) cleanAttachedPolicies(roleName string) error {
 
 		_, err := s.iamClient.DetachRolePolicy(i)
 		if err != nil {
-			l.Error(err, fmt.Sprintf("failed to detach policy %s", *p.PolicyName))
+			l.Error(err, "failed to detach policy", "policy_name", *p.PolicyName)
 			return err
 		}
 
-		l.Info(fmt.Sprintf("detached policy %s", *p.PolicyName))
+		l.Info("detached policy", "policy_name", *p.PolicyName)
 	}
 	return nil
 }
@@ -578,7 +1485,7 @@ func (s *IAMService) cleanInlinePolicies(roleName string) error {
 	}
 
 	for _, p := range o.PolicyNames {
-		l.Info(fmt.Sprintf("deleting inline policy %s", *p))
+		l.Info("deleting inline policy", "policy_name", *p)
 
 		i := &awsiam.DeleteRolePolicyInput{
 			RoleName:   aws.String(roleName),
@@ -587,16 +1494,24 @@ func (s *IAMService) cleanInlinePolicies(roleName string) error {
 
 		_, err := s.iamClient.DeleteRolePolicy(i)
 		if err != nil && !IsNotFound(err) {
-			l.Error(err, fmt.Sprintf("failed to delete inline policy %s", *p))
+			l.Error(err, "failed to delete inline policy", "policy_name", *p)
 			return err
 		}
-		l.Info(fmt.Sprintf("deleted inline policy %s", *p))
+		l.Info("deleted inline policy", "policy_name", *p)
 	}
 
 	return nil
 }
 
 func (s *IAMService) GetRoleARN(roleName string) (string, error) {
+	if t := s.forRole(roleName); t != s {
+		return t.GetRoleARN(roleName)
+	}
+
+	if arn, ok := s.roleARNCache.Get(roleName); ok {
+		return arn, nil
+	}
+
 	o, err := s.iamClient.GetRole(&awsiam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	})
@@ -604,6 +1519,7 @@ func (s *IAMService) GetRoleARN(roleName string) (string, error) {
 		return "", microerror.Mask(err)
 	}
 
+	s.roleARNCache.Set(roleName, *o.Role.Arn)
 	return *o.Role.Arn, nil
 }
 
@@ -611,6 +1527,45 @@ func (s *IAMService) SetPrincipalRoleARN(arn string) {
 	s.principalRoleARN = arn
 }
 
+// SetIRSAServiceAccount overrides the service account trusted by the IRSA
+// role of the given role type, which defaults to a service account in the
+// kube-system namespace.
+func (s *IAMService) SetIRSAServiceAccount(roleType, namespace, name string) error {
+	if !slices.Contains(getIRSARoles(), roleType) {
+		return microerror.Maskf(invalidIRSARoleTypeError, "role type %q is not an IRSA role", roleType)
+	}
+	if s.irsaServiceAccounts == nil {
+		s.irsaServiceAccounts = map[string]irsaServiceAccount{}
+	}
+	s.irsaServiceAccounts[roleType] = irsaServiceAccount{namespace: namespace, name: name}
+	return nil
+}
+
+// SetIRSAHostedZoneIDs restricts the Route 53 records the external-dns and
+// cert-manager roles may change to the given hosted zones.
+func (s *IAMService) SetIRSAHostedZoneIDs(hostedZoneIDs []string) error {
+	for _, id := range hostedZoneIDs {
+		err := ValidateHostedZoneID(id)
+		if err != nil {
+			return microerror.Maskf(invalidIRSAConfigError, "%s", err)
+		}
+	}
+	s.irsaHostedZoneIDs = hostedZoneIDs
+	return nil
+}
+
+// SetIRSAExtraConditions adds the given conditions to the trust policies of
+// all IRSA roles.
+func (s *IAMService) SetIRSAExtraConditions(conditions []TrustPolicyCondition) error {
+	for _, condition := range conditions {
+		if condition.Operator == "" || condition.Key == "" || len(condition.Values) == 0 {
+			return microerror.Maskf(invalidIRSAConfigError, "condition %+v must have an operator, a key and values", condition)
+		}
+	}
+	s.irsaExtraConditions = conditions
+	return nil
+}
+
 func (s *IAMService) GetIRSAOpenIDForEKS(clusterName string) (string, error) {
 	i := &eks.DescribeClusterInput{
 		Name: aws.String(clusterName),
@@ -635,11 +1590,28 @@ func roleName(role string, clusterID string) string {
 		return fmt.Sprintf("%s-IAMManager-Role", clusterID)
 	} else if role == CertManagerRole {
 		return fmt.Sprintf("%s-CertManager-Role", clusterID)
+	} else if role == BackupRole {
+		return fmt.Sprintf("%s-BackupRole", clusterID)
 	} else {
 		return fmt.Sprintf("%s-%s", clusterID, role)
 	}
 }
 
+// isOwned returns whether the tags contain the ownership tag of the operator,
+// or the IAMControllerOwnedTag set by releases before the ownership tag was
+// configurable.
+func (s *IAMService) isOwned(tags []*awsiam.Tag) bool {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == s.ownershipTagKey && aws.StringValue(tag.Value) == s.ownershipTagValue {
+			return true
+		}
+		if aws.StringValue(tag.Key) == IAMControllerOwnedTag && aws.StringValue(tag.Value) == "" {
+			return true
+		}
+	}
+	return false
+}
+
 func policyName(role string, clusterID string) string {
 	return fmt.Sprintf("%s-%s-policy", role, clusterID)
 }
@@ -3,11 +3,48 @@ package iam_test
 import (
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
 )
 
 func TestIam(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Iam Suite")
 }
+
+// newTestIAMService returns an IAMService for the control plane role
+// test-role of test-cluster in eu-west-1, which uses mockIAMClient for all
+// IAM calls. overrides is called with the config before the service is
+// created, unless it is nil.
+func newTestIAMService(mockIAMClient iamiface.IAMAPI, overrides func(c *iam.IAMServiceConfig)) *iam.IAMService {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String("eu-west-1")},
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	c := iam.IAMServiceConfig{
+		ClusterName:  "test-cluster",
+		MainRoleName: "test-role",
+		Region:       "eu-west-1",
+		RoleType:     "control-plane",
+		Log:          ctrl.Log,
+		AWSSession:   sess,
+		IAMClientFactory: func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
+			return mockIAMClient
+		},
+	}
+	if overrides != nil {
+		overrides(&c)
+	}
+
+	iamService, err := iam.New(c)
+	Expect(err).NotTo(HaveOccurred())
+	return iamService
+}
@@ -1,20 +1,30 @@
 package iam_test
 
 import (
+	"encoding/json"
 	"errors"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
 	awsIAM "github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/go-logr/logr/funcr"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/giantswarm/capa-iam-operator/pkg/cache"
 	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
 	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
 )
 
@@ -24,36 +34,18 @@ var _ = Describe("ReconcileRole", func() {
 		mockCtrl      *gomock.Controller
 		mockIAMClient *mocks.MockIAMAPI
 		iamService    *iam.IAMService
-		err           error
-		sess          awsclientgo.ConfigProvider
 	)
 
 	const controlPlanePolicyTemplate = "%7B%0A%09%09%22Version%22%3A%20%222012-10-17%22%2C%0A%09%09%22Statement%22%3A%20%5B%0A%09%09%20%20%7B%0A%09%09%09%22Action%22%3A%20%22elasticloadbalancing%3A%2A%22%2C%0A%09%09%09%22Resource%22%3A%20%22%2A%22%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%2C%0A%09%09%20%20%7B%0A%09%09%09%22Action%22%3A%20%5B%0A%09%09%09%20%20%22autoscaling%3ADescribeAutoScalingGroups%22%2C%0A%09%09%09%20%20%22autoscaling%3ADescribeAutoScalingInstances%22%2C%0A%09%09%09%20%20%22autoscaling%3ADescribeTags%22%2C%0A%09%09%09%20%20%22autoscaling%3ADescribeLaunchConfigurations%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeLaunchTemplateVersions%22%0A%09%09%09%5D%2C%0A%09%09%09%22Resource%22%3A%20%22%2A%22%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%2C%0A%09%09%20%20%7B%0A%09%09%09%22Condition%22%3A%20%7B%0A%09%09%09%20%20%22StringEquals%22%3A%20%7B%0A%09%09%09%09%22autoscaling%3AResourceTag%2Fsigs.k8s.io%2Fcluster-api-provider-aws%2Fcluster%2Ftest-cluster%22%3A%20%22owned%22%0A%09%09%09%20%20%7D%0A%09%09%09%7D%2C%0A%09%09%09%22Action%22%3A%20%5B%0A%09%09%09%20%20%22autoscaling%3ASetDesiredCapacity%22%2C%0A%09%09%09%20%20%22autoscaling%3ATerminateInstanceInAutoScalingGroup%22%0A%09%09%09%5D%2C%0A%09%09%09%22Resource%22%3A%20%22%2A%22%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%2C%0A%09%09%20%20%7B%0A%09%09%09%22Action%22%3A%20%5B%0A%09%09%09%20%20%22ecr%3AGetAuthorizationToken%22%2C%0A%09%09%09%20%20%22ecr%3ABatchCheckLayerAvailability%22%2C%0A%09%09%09%20%20%22ecr%3AGetDownloadUrlForLayer%22%2C%0A%09%09%09%20%20%22ecr%3AGetRepositoryPolicy%22%2C%0A%09%09%09%20%20%22ecr%3ADescribeRepositories%22%2C%0A%09%09%09%20%20%22ecr%3AListImages%22%2C%0A%09%09%09%20%20%22ecr%3ABatchGetImage%22%0A%09%09%09%5D%2C%0A%09%09%09%22Resource%22%3A%20%22%2A%22%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%2C%0A%09%09%20%20%7B%0A%09%09%09%22Action%22%3A%20%5B%0A%09%09%09%20%20%22ec2%3AAssignPrivateIpAddresses%22%2C%0A%09%09%09%20%20%22ec2%3AAttachNetworkInterface%22%2C%0A%09%09%09%20%20%22ec2%3ACreateNetworkInterface%22%2C%0A%09%09%09%20%20%22ec2%3ADeleteNetworkInterface%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeInstances%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeInstanceTypes%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeTags%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeNetworkInterfaces%22%2C%0A%09%09%09%20%20%22ec2%3ADetachNetworkInterface%22%2C%0A%09%09%09%20%20%22ec2%3AModifyNetworkInterfaceAttribute%22%2C%0A%09%09%09%20%20%22ec2%3AUnassignPrivateIpAddresses%22%0A%09%09%09%5D%2C%0A%09%09%09%22Resource%22%3A%20%22%2A%22%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%2C%0A%09%09%20%20%7B%0A%09%09%09%22Action%22%3A%20%5B%0A%09%09%09%20%20%22autoscaling%3ADescribeAutoScalingGroups%22%2C%0A%09%09%09%20%20%22autoscaling%3ADescribeLaunchConfigurations%22%2C%0A%09%09%09%20%20%22autoscaling%3ADescribeTags%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeAvailabilityZones%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeInstances%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeImages%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeRegions%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeRouteTables%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeSecurityGroups%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeSubnets%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeVolumes%22%2C%0A%09%09%09%20%20%22ec2%3ACreateSecurityGroup%22%2C%0A%09%09%09%20%20%22ec2%3ACreateTags%22%2C%0A%09%09%09%20%20%22ec2%3ACreateVolume%22%2C%0A%09%09%09%20%20%22ec2%3AModifyInstanceAttribute%22%2C%0A%09%09%09%20%20%22ec2%3AModifyVolume%22%2C%0A%09%09%09%20%20%22ec2%3AAttachVolume%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeVolumesModifications%22%2C%0A%09%09%09%20%20%22ec2%3AAuthorizeSecurityGroupIngress%22%2C%0A%09%09%09%20%20%22ec2%3ACreateRoute%22%2C%0A%09%09%09%20%20%22ec2%3ADeleteRoute%22%2C%0A%09%09%09%20%20%22ec2%3ADeleteSecurityGroup%22%2C%0A%09%09%09%20%20%22ec2%3ADeleteVolume%22%2C%0A%09%09%09%20%20%22ec2%3ADetachVolume%22%2C%0A%09%09%09%20%20%22ec2%3ARevokeSecurityGroupIngress%22%2C%0A%09%09%09%20%20%22ec2%3ADescribeVpcs%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AAddTags%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AAttachLoadBalancerToSubnets%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AApplySecurityGroupsToLoadBalancer%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ACreateLoadBalancer%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ACreateLoadBalancerPolicy%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ACreateLoadBalancerListeners%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AConfigureHealthCheck%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADeleteLoadBalancer%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADeleteLoadBalancerListeners%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADescribeLoadBalancers%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADescribeLoadBalancerAttributes%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADetachLoadBalancerFromSubnets%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADeregisterInstancesFromLoadBalancer%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AModifyLoadBalancerAttributes%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ARegisterInstancesWithLoadBalancer%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ASetLoadBalancerPoliciesForBackendServer%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AAddTags%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ACreateListener%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ACreateTargetGroup%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADeleteListener%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADeleteTargetGroup%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADescribeListeners%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADescribeLoadBalancerPolicies%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADescribeTargetGroups%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ADescribeTargetHealth%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AModifyListener%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3AModifyTargetGroup%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ARegisterTargets%22%2C%0A%09%09%09%20%20%22elasticloadbalancing%3ASetLoadBalancerPoliciesOfListener%22%2C%0A%09%09%09%20%20%22iam%3ACreateServiceLinkedRole%22%2C%0A%09%09%09%20%20%22kms%3ADescribeKey%22%0A%09%09%09%5D%2C%0A%09%09%09%22Resource%22%3A%20%5B%0A%09%09%09%20%20%22%2A%22%0A%09%09%09%5D%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%2C%0A%09%09%20%20%7B%0A%09%09%09%22Action%22%3A%20%5B%0A%09%09%09%20%20%22secretsmanager%3AGetSecretValue%22%2C%0A%09%09%09%20%20%22secretsmanager%3ADeleteSecret%22%0A%09%09%09%5D%2C%0A%09%09%09%22Resource%22%3A%20%22arn%3A%2A%3Asecretsmanager%3A%2A%3A%2A%3Asecret%3Aaws.cluster.x-k8s.io%2F%2A%22%2C%0A%09%09%09%22Effect%22%3A%20%22Allow%22%0A%09%09%20%20%7D%0A%09%09%5D%0A%09%20%20%7D"
 
 	BeforeEach(func() {
-		//create me new iam service config with mocks
-		sess, err = session.NewSession(&aws.Config{
-			Region: aws.String("eu-west-1")},
-		)
-		Expect(err).NotTo(HaveOccurred())
-
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
 
-		iamConfig := iam.IAMServiceConfig{
-			ClusterName:      "test-cluster",
-			MainRoleName:     "test-role",
-			Region:           "test-region",
-			RoleType:         "control-plane",
-			PrincipalRoleARN: "test-principal-role-arn",
-			Log:              ctrl.Log,
-			AWSSession:       sess,
-			IAMClientFactory: func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
-				return mockIAMClient
-			},
-		}
-		iamService, err = iam.New(iamConfig)
-		Expect(err).To(BeNil())
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.Region = "test-region"
+			c.PrincipalRoleARN = "test-principal-role-arn"
+		})
 	})
 
 	When("role is present", func() {
@@ -71,8 +63,11 @@ var _ = Describe("ReconcileRole", func() {
 				}, nil).AnyTimes()
 			})
 			It("should return nil", func() {
+				before := testutil.ToFloat64(metrics.RoleReconcileTotal.WithLabelValues("control-plane", metrics.ResultSuccess))
+
 				err := iamService.ReconcileRole()
 				Expect(err).To(BeNil())
+				Expect(testutil.ToFloat64(metrics.RoleReconcileTotal.WithLabelValues("control-plane", metrics.ResultSuccess))).To(Equal(before + 1))
 			})
 		})
 		When("could not attach InlinePolicy", func() {
@@ -81,8 +76,11 @@ var _ = Describe("ReconcileRole", func() {
 				mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, errors.New("test error")).AnyTimes()
 			})
 			It("should return error", func() {
+				before := testutil.ToFloat64(metrics.RoleReconcileTotal.WithLabelValues("control-plane", metrics.ResultError))
+
 				err := iamService.ReconcileRole()
 				Expect(err).NotTo(BeNil())
+				Expect(testutil.ToFloat64(metrics.RoleReconcileTotal.WithLabelValues("control-plane", metrics.ResultError))).To(Equal(before + 1))
 			})
 		})
 	})
@@ -105,7 +103,1566 @@ var _ = Describe("ReconcileRole", func() {
 		})
 	})
 
+	When("role name is invalid", func() {
+		BeforeEach(func() {
+			iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+				c.MainRoleName = "aws-test-role"
+				c.Region = "test-region"
+				c.PrincipalRoleARN = "test-principal-role-arn"
+			})
+		})
+		It("should fail without calling AWS", func() {
+			err := iamService.ReconcileRole()
+			Expect(iam.IsInvalidRoleName(err)).To(BeTrue())
+		})
+		It("should not try to delete the role", func() {
+			err := iamService.DeleteRole()
+			Expect(err).To(BeNil())
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
+
+var _ = Describe("ReconcileRolesForIRSA", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+		trustPolicies []string
+		rolePolicies  []string
+	)
+
+	JustBeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		trustPolicies = nil
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+		mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			trustPolicies = append(trustPolicies, *input.PolicyDocument)
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		}).AnyTimes()
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		rolePolicies = nil
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+			rolePolicies = append(rolePolicies, *input.PolicyDocument)
+			return &awsIAM.PutRolePolicyOutput{}, nil
+		}).AnyTimes()
+	})
+
+	type trustPolicy struct {
+		Statement []struct {
+			Condition map[string]map[string]string
+		}
+	}
+
+	It("only conditions the trust policies on the service account", func() {
+		err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com", "oidc.test.example.com"})
+		Expect(err).To(BeNil())
+		Expect(trustPolicies).NotTo(BeEmpty())
+
+		for _, document := range trustPolicies {
+			var policy trustPolicy
+			Expect(json.Unmarshal([]byte(document), &policy)).To(Succeed())
+			Expect(policy.Statement).To(HaveLen(2))
+
+			for i, domain := range []string{"irsa.test.example.com", "oidc.test.example.com"} {
+				// STS does not populate a token issue time key for web
+				// identity tokens, a date condition on it would never match
+				Expect(policy.Statement[i].Condition).To(HaveLen(1))
+				for _, condition := range policy.Statement[i].Condition {
+					Expect(condition).To(HaveLen(1))
+					Expect(condition).To(HaveKey(domain + ":sub"))
+				}
+			}
+		}
+	})
+
+	It("renders the same trust policies on every reconciliation", func() {
+		err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com"})
+		Expect(err).To(BeNil())
+		first := trustPolicies
+		trustPolicies = nil
+
+		err = iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com"})
+		Expect(err).To(BeNil())
+		Expect(trustPolicies).To(Equal(first))
+	})
+
+	When("several trust domains are given", func() {
+		It("trusts the service account of every domain", func() {
+			err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.old.example.com", "irsa.new.example.com"})
+			Expect(err).To(BeNil())
+			Expect(trustPolicies).NotTo(BeEmpty())
+
+			for _, document := range trustPolicies {
+				var policy struct {
+					Statement []struct {
+						Principal struct {
+							Federated string
+						}
+						Condition map[string]map[string]string
+					}
+				}
+				Expect(json.Unmarshal([]byte(document), &policy)).To(Succeed())
+				Expect(policy.Statement).To(HaveLen(2))
+				Expect(policy.Statement[0].Principal.Federated).To(Equal("arn:aws:iam::012345678901:oidc-provider/irsa.old.example.com"))
+				Expect(policy.Statement[1].Principal.Federated).To(Equal("arn:aws:iam::012345678901:oidc-provider/irsa.new.example.com"))
+				for i, domain := range []string{"irsa.old.example.com", "irsa.new.example.com"} {
+					Expect(policy.Statement[i].Condition).To(HaveLen(1))
+					for _, condition := range policy.Statement[i].Condition {
+						Expect(condition).To(HaveKey(domain + ":sub"))
+					}
+				}
+			}
+		})
+	})
+
+	When("a service account is overridden", func() {
+		It("trusts the overridden service account", func() {
+			Expect(iamService.SetIRSAServiceAccount(iam.CertManagerRole, "security", "custom-cert-manager")).To(Succeed())
+
+			err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com"})
+			Expect(err).To(BeNil())
+
+			var subjects []string
+			for _, document := range trustPolicies {
+				var policy trustPolicy
+				Expect(json.Unmarshal([]byte(document), &policy)).To(Succeed())
+				for _, condition := range policy.Statement[0].Condition {
+					subjects = append(subjects, condition["irsa.test.example.com:sub"])
+				}
+			}
+			Expect(subjects).To(ContainElement("system:serviceaccount:security:custom-cert-manager"))
+			Expect(subjects).NotTo(ContainElement("system:serviceaccount:kube-system:cert-manager-app"))
+			Expect(subjects).To(ContainElement("system:serviceaccount:kube-system:ebs-csi-controller-sa"))
+		})
+
+		It("rejects role types which are not IRSA roles", func() {
+			err := iamService.SetIRSAServiceAccount(iam.NodesRole, "kube-system", "nodes")
+			Expect(iam.IsInvalidIRSARoleType(err)).To(BeTrue())
+		})
+	})
+
+	When("hosted zones are configured", func() {
+		It("only allows changing the records of the hosted zones", func() {
+			Expect(iamService.SetIRSAHostedZoneIDs([]string{"Z0123456789ABC", "Z9876543210XYZ"})).To(Succeed())
+
+			err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com"})
+			Expect(err).To(BeNil())
+
+			var route53Policies []string
+			for _, document := range rolePolicies {
+				if strings.Contains(document, "route53:ChangeResourceRecordSets") {
+					route53Policies = append(route53Policies, document)
+				}
+			}
+
+			// external-dns and cert-manager
+			Expect(route53Policies).To(HaveLen(2))
+			for _, document := range route53Policies {
+				Expect(document).To(ContainSubstring(`"arn:*:route53:::hostedzone/Z0123456789ABC"`))
+				Expect(document).To(ContainSubstring(`"arn:*:route53:::hostedzone/Z9876543210XYZ"`))
+				Expect(document).NotTo(ContainSubstring(`"arn:*:route53:::hostedzone/*"`))
+				Expect(json.Valid([]byte(document))).To(BeTrue())
+			}
+		})
+
+		It("rejects invalid hosted zone IDs", func() {
+			err := iamService.SetIRSAHostedZoneIDs([]string{"not-a-zone"})
+			Expect(iam.IsInvalidIRSAConfig(err)).To(BeTrue())
+		})
+	})
+
+	When("extra conditions are configured", func() {
+		It("adds them to every statement of the trust policies", func() {
+			Expect(iamService.SetIRSAExtraConditions([]iam.TrustPolicyCondition{
+				{Operator: "IpAddress", Key: "aws:SourceIp", Values: []string{"203.0.113.0/24"}},
+				{Operator: "StringEquals", Key: "aws:RequestedRegion", Values: []string{"eu-west-1"}},
+			})).To(Succeed())
+
+			err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.old.example.com", "irsa.new.example.com"})
+			Expect(err).To(BeNil())
+			Expect(trustPolicies).NotTo(BeEmpty())
+
+			for _, document := range trustPolicies {
+				var policy struct {
+					Statement []struct {
+						Condition map[string]map[string]interface{}
+					}
+				}
+				Expect(json.Unmarshal([]byte(document), &policy)).To(Succeed())
+				Expect(policy.Statement).To(HaveLen(2))
+				for i, domain := range []string{"irsa.old.example.com", "irsa.new.example.com"} {
+					Expect(policy.Statement[i].Condition).To(HaveKeyWithValue("IpAddress", map[string]interface{}{
+						"aws:SourceIp": []interface{}{"203.0.113.0/24"},
+					}))
+					Expect(policy.Statement[i].Condition["StringEquals"]).To(HaveKeyWithValue("aws:RequestedRegion", []interface{}{"eu-west-1"}))
+
+					var subjects []interface{}
+					for _, condition := range policy.Statement[i].Condition {
+						if subject, ok := condition[domain+":sub"]; ok {
+							subjects = append(subjects, subject)
+						}
+					}
+					Expect(subjects).To(HaveLen(1))
+				}
+			}
+		})
+
+		It("fails on conditions replacing the trusted service account", func() {
+			Expect(iamService.SetIRSAExtraConditions([]iam.TrustPolicyCondition{
+				{Operator: "StringEquals", Key: "irsa.test.example.com:sub", Values: []string{"system:serviceaccount:default:default"}},
+			})).To(Succeed())
+
+			err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects incomplete conditions", func() {
+			err := iamService.SetIRSAExtraConditions([]iam.TrustPolicyCondition{{Operator: "StringEquals", Key: "aws:SourceVpc"}})
+			Expect(iam.IsInvalidIRSAConfig(err)).To(BeTrue())
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
+
+var _ = Describe("IRSA trust domain changes", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+		logs          []map[string]interface{}
+	)
+
+	trustPolicy := func(domains ...string) string {
+		var statements []string
+		for _, domain := range domains {
+			statements = append(statements, `{"Effect": "Allow", "Principal": {"Federated": "arn:aws:iam::012345678901:oidc-provider/`+domain+`"}, "Action": "sts:AssumeRoleWithWebIdentity"}`)
+		}
+		return `{"Version": "2012-10-17", "Statement": [` + strings.Join(statements, ",") + `]}`
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		logs = nil
+		logger := funcr.NewJSON(func(obj string) {
+			var entry map[string]interface{}
+			Expect(json.Unmarshal([]byte(obj), &entry)).To(Succeed())
+			logs = append(logs, entry)
+		}, funcr.Options{})
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.Log = logger
+		})
+
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+			AssumeRolePolicyDocument: aws.String(url.QueryEscape(trustPolicy("irsa.kept.example.com", "irsa.old.example.com"))),
+		}}, nil).AnyTimes()
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("replaces stale trust domains and logs the changes", func() {
+		var trustPolicies []string
+		mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			trustPolicies = append(trustPolicies, *input.PolicyDocument)
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		}).AnyTimes()
+
+		err := iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.kept.example.com", "irsa.new.example.com"})
+		Expect(err).To(BeNil())
+
+		Expect(trustPolicies).NotTo(BeEmpty())
+		for _, document := range trustPolicies {
+			Expect(document).To(ContainSubstring("oidc-provider/irsa.kept.example.com"))
+			Expect(document).To(ContainSubstring("oidc-provider/irsa.new.example.com"))
+			Expect(document).NotTo(ContainSubstring("irsa.old.example.com"))
+		}
+
+		Expect(logs).To(ContainElement(SatisfyAll(
+			HaveKeyWithValue("msg", "applying assume policy role to role"),
+			HaveKeyWithValue("role_name", "test-cluster-CertManager-Role"),
+			HaveKeyWithValue("added_trust_domains", ConsistOf("irsa.new.example.com")),
+			HaveKeyWithValue("removed_trust_domains", ConsistOf("irsa.old.example.com")),
+		)))
+	})
+})
+
+var _ = Describe("CleanupDeprecatedKiamRole", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	When("the KIAM role does not exist", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+				RoleName: aws.String("test-cluster-IAMManager-Role"),
+			}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		})
+
+		It("does nothing", func() {
+			deleted, err := iamService.CleanupDeprecatedKiamRole()
+			Expect(err).To(BeNil())
+			Expect(deleted).To(BeFalse())
+		})
+	})
+
+	When("the KIAM role is not owned by the operator", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil)
+		})
+
+		It("does not delete it", func() {
+			deleted, err := iamService.CleanupDeprecatedKiamRole()
+			Expect(err).To(BeNil())
+			Expect(deleted).To(BeFalse())
+		})
+	})
+
+	When("the KIAM role is owned by the operator", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+				Tags: []*awsIAM.Tag{{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")}},
+			}}, nil)
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+			mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+			mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		})
+
+		It("deletes it", func() {
+			mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+				RoleName: aws.String("test-cluster-IAMManager-Role"),
+			}).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+			deleted, err := iamService.CleanupDeprecatedKiamRole()
+			Expect(err).To(BeNil())
+			Expect(deleted).To(BeTrue())
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
+
+var _ = Describe("ListClusterRoles", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/"),
+		}).Return(&awsIAM.ListRolesOutput{
+			Roles: []*awsIAM.Role{
+				{RoleName: aws.String("control-plane-test-cluster")},
+				{RoleName: aws.String("test-cluster-other-cluster-role")},
+				{RoleName: aws.String("test-cluster-unmanaged-role")},
+				{RoleName: aws.String("test-cluster-deleted-role")},
+				// the tags of roles not named after the cluster are not listed
+				{RoleName: aws.String("unrelated-role")},
+			},
+		}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("control-plane-test-cluster"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+			{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")},
+		}}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-other-cluster-role"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+			{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/other-cluster"), Value: aws.String("owned")},
+		}}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-unmanaged-role"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")},
+		}}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-deleted-role"),
+		}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+	})
+
+	It("returns the roles owned by the operator for the cluster", func() {
+		roleNames, err := iamService.ListClusterRoles()
+		Expect(err).To(BeNil())
+		Expect(roleNames).To(Equal([]string{"control-plane-test-cluster"}))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
+
+var _ = Describe("ReconcileRoleTags", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	ownedTag := &awsIAM.Tag{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")}
+	clusterTag := &awsIAM.Tag{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")}
+	recordTag := func(keys string) *awsIAM.Tag {
+		return &awsIAM.Tag{Key: aws.String(iam.CustomTagKeysTag), Value: aws.String(keys)}
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.CustomTags = map[string]string{
+				"env":  "prod",
+				"team": "a",
+			}
+		})
+	})
+
+	When("the tags are in sync", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+				RoleName: aws.String("test-role"),
+			}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+				ownedTag,
+				clusterTag,
+				recordTag("env+team"),
+				{Key: aws.String("env"), Value: aws.String("prod")},
+				{Key: aws.String("team"), Value: aws.String("a")},
+			}}, nil)
+			mockIAMClient.EXPECT().ListInstanceProfileTags(&awsIAM.ListInstanceProfileTagsInput{
+				InstanceProfileName: aws.String("test-role"),
+			}).Return(&awsIAM.ListInstanceProfileTagsOutput{Tags: []*awsIAM.Tag{
+				ownedTag,
+				clusterTag,
+				recordTag("env+team"),
+				{Key: aws.String("env"), Value: aws.String("prod")},
+				{Key: aws.String("team"), Value: aws.String("a")},
+			}}, nil)
+		})
+
+		It("does not change any tag", func() {
+			err := iamService.ReconcileRoleTags("test-role")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the custom tags changed", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+				RoleName: aws.String("test-role"),
+			}).Return(&awsIAM.ListRoleTagsOutput{
+				Tags:        []*awsIAM.Tag{ownedTag, {Key: aws.String("team"), Value: aws.String("b")}},
+				IsTruncated: aws.Bool(true),
+				Marker:      aws.String("next"),
+			}, nil)
+			mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+				RoleName: aws.String("test-role"),
+				Marker:   aws.String("next"),
+			}).Return(&awsIAM.ListRoleTagsOutput{
+				Tags: []*awsIAM.Tag{
+					clusterTag,
+					recordTag("removed+team"),
+					{Key: aws.String("removed"), Value: aws.String("x")},
+					{Key: aws.String("foreign"), Value: aws.String("y")},
+				},
+			}, nil)
+			mockIAMClient.EXPECT().ListInstanceProfileTags(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		})
+
+		It("adds and updates the custom tags and removes stale custom tags, but keeps the protected and foreign tags", func() {
+			mockIAMClient.EXPECT().TagRole(&awsIAM.TagRoleInput{
+				RoleName: aws.String("test-role"),
+				Tags: []*awsIAM.Tag{
+					recordTag("env+team"),
+					{Key: aws.String("env"), Value: aws.String("prod")},
+					{Key: aws.String("team"), Value: aws.String("a")},
+				},
+			}).Return(&awsIAM.TagRoleOutput{}, nil)
+			mockIAMClient.EXPECT().UntagRole(&awsIAM.UntagRoleInput{
+				RoleName: aws.String("test-role"),
+				TagKeys:  []*string{aws.String("removed")},
+			}).Return(&awsIAM.UntagRoleOutput{}, nil)
+
+			err := iamService.ReconcileRoleTags("test-role")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the cluster tag was removed from an owned role", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+				ownedTag,
+				recordTag("env+team"),
+				{Key: aws.String("env"), Value: aws.String("prod")},
+				{Key: aws.String("team"), Value: aws.String("a")},
+			}}, nil)
+			mockIAMClient.EXPECT().ListInstanceProfileTags(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		})
+
+		It("restores the cluster tag", func() {
+			mockIAMClient.EXPECT().TagRole(&awsIAM.TagRoleInput{
+				RoleName: aws.String("test-role"),
+				Tags:     []*awsIAM.Tag{clusterTag},
+			}).Return(&awsIAM.TagRoleOutput{}, nil)
+
+			err := iamService.ReconcileRoleTags("test-role")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	When("the role is not owned by the operator", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+				{Key: aws.String("env"), Value: aws.String("prod")},
+				{Key: aws.String("team"), Value: aws.String("b")},
+				{Key: aws.String("foreign"), Value: aws.String("y")},
+			}}, nil)
+		})
+
+		It("does not change any tag of the role or of its instance profile", func() {
+			err := iamService.ReconcileRoleTags("test-role")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	DescribeTable("instance profile tags",
+		func(currentTags []*awsIAM.Tag, tagsToAdd []*awsIAM.Tag, tagKeysToRemove []*string) {
+			mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+				ownedTag,
+				clusterTag,
+				recordTag("env+team"),
+				{Key: aws.String("env"), Value: aws.String("prod")},
+				{Key: aws.String("team"), Value: aws.String("a")},
+			}}, nil)
+			mockIAMClient.EXPECT().ListInstanceProfileTags(&awsIAM.ListInstanceProfileTagsInput{
+				InstanceProfileName: aws.String("test-role"),
+			}).Return(&awsIAM.ListInstanceProfileTagsOutput{Tags: currentTags}, nil)
+			if tagsToAdd != nil {
+				mockIAMClient.EXPECT().TagInstanceProfile(&awsIAM.TagInstanceProfileInput{
+					InstanceProfileName: aws.String("test-role"),
+					Tags:                tagsToAdd,
+				}).Return(&awsIAM.TagInstanceProfileOutput{}, nil)
+			}
+			if tagKeysToRemove != nil {
+				mockIAMClient.EXPECT().UntagInstanceProfile(&awsIAM.UntagInstanceProfileInput{
+					InstanceProfileName: aws.String("test-role"),
+					TagKeys:             tagKeysToRemove,
+				}).Return(&awsIAM.UntagInstanceProfileOutput{}, nil)
+			}
+
+			err := iamService.ReconcileRoleTags("test-role")
+			Expect(err).To(BeNil())
+		},
+		Entry("add only",
+			[]*awsIAM.Tag{ownedTag, clusterTag, recordTag("env+team"), {Key: aws.String("team"), Value: aws.String("a")}},
+			[]*awsIAM.Tag{{Key: aws.String("env"), Value: aws.String("prod")}},
+			nil,
+		),
+		Entry("remove only",
+			[]*awsIAM.Tag{ownedTag, clusterTag, recordTag("env+removed+team"), {Key: aws.String("env"), Value: aws.String("prod")}, {Key: aws.String("team"), Value: aws.String("a")}, {Key: aws.String("removed"), Value: aws.String("x")}},
+			[]*awsIAM.Tag{recordTag("env+team")},
+			[]*string{aws.String("removed")},
+		),
+		Entry("mixed",
+			[]*awsIAM.Tag{ownedTag, recordTag("removed+team"), {Key: aws.String("team"), Value: aws.String("b")}, {Key: aws.String("removed"), Value: aws.String("x")}},
+			[]*awsIAM.Tag{recordTag("env+team"), {Key: aws.String("env"), Value: aws.String("prod")}, clusterTag, {Key: aws.String("team"), Value: aws.String("a")}},
+			[]*string{aws.String("removed")},
+		),
+		Entry("instance profile not owned by the operator",
+			[]*awsIAM.Tag{{Key: aws.String("team"), Value: aws.String("b")}},
+			nil,
+			nil,
+		),
+		Entry("foreign tags",
+			[]*awsIAM.Tag{ownedTag, clusterTag, recordTag("env+team"), {Key: aws.String("env"), Value: aws.String("prod")}, {Key: aws.String("team"), Value: aws.String("a")}, {Key: aws.String("foreign"), Value: aws.String("y")}},
+			nil,
+			nil,
+		),
+	)
+
+	It("records the custom tag keys on created roles and instance profiles", func() {
+		tags := []*awsIAM.Tag{
+			ownedTag,
+			clusterTag,
+			recordTag("env+team"),
+			{Key: aws.String("env"), Value: aws.String("prod")},
+			{Key: aws.String("team"), Value: aws.String("a")},
+		}
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+			Expect(input.Tags).To(Equal(tags))
+			return &awsIAM.CreateRoleOutput{}, nil
+		})
+		mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateInstanceProfileInput) (*awsIAM.CreateInstanceProfileOutput, error) {
+			Expect(input.Tags).To(Equal(tags))
+			return &awsIAM.CreateInstanceProfileOutput{}, nil
+		})
+		mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+
+		err := iamService.ReconcileRole()
+		Expect(err).To(BeNil())
+	})
+
+	When("instance profiles are skipped", func() {
+		BeforeEach(func() {
+			iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+				c.SkipInstanceProfiles = true
+			})
+
+			mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{ownedTag, clusterTag}}, nil)
+		})
+
+		It("does not list the tags of the instance profile", func() {
+			err := iamService.ReconcileRoleTags("test-role")
+			Expect(err).To(BeNil())
+		})
+	})
+
 	AfterEach(func() {
 		mockCtrl.Finish()
 	})
 })
+
+var _ = Describe("RolePath", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamConfig     iam.IAMServiceConfig
+	)
+
+	BeforeEach(func() {
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String("eu-west-1")},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamConfig = iam.IAMServiceConfig{
+			ClusterName:  "test-cluster",
+			MainRoleName: "test-role",
+			Region:       "eu-west-1",
+			RoleType:     "nodes",
+			RolePath:     "/capa/",
+			Log:          ctrl.Log,
+			AWSSession:   sess,
+			IAMClientFactory: func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
+				return mockIAMClient
+			},
+		}
+	})
+
+	It("creates roles and instance profiles with the path", func() {
+		iamService, err := iam.New(iamConfig)
+		Expect(err).To(BeNil())
+
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+			Expect(input.Path).To(Equal(aws.String("/capa/")))
+			return &awsIAM.CreateRoleOutput{}, nil
+		})
+		mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateInstanceProfileInput) (*awsIAM.CreateInstanceProfileOutput, error) {
+			Expect(input.Path).To(Equal(aws.String("/capa/")))
+			return &awsIAM.CreateInstanceProfileOutput{}, nil
+		})
+		mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+
+		err = iamService.ReconcileRole()
+		Expect(err).To(BeNil())
+	})
+
+	It("builds role ARNs with the path", func() {
+		iamService, err := iam.New(iamConfig)
+		Expect(err).To(BeNil())
+
+		Expect(iamService.RoleARN("012345678901", "test-role")).To(Equal("arn:aws:iam::012345678901:role/capa/test-role"))
+	})
+
+	It("lists roles below the path", func() {
+		iamService, err := iam.New(iamConfig)
+		Expect(err).To(BeNil())
+
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/capa/"),
+		}).Return(&awsIAM.ListRolesOutput{
+			Roles:       []*awsIAM.Role{{RoleName: aws.String("role-1")}},
+			IsTruncated: aws.Bool(true),
+			Marker:      aws.String("next"),
+		}, nil)
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/capa/"),
+			Marker:     aws.String("next"),
+		}).Return(&awsIAM.ListRolesOutput{
+			Roles: []*awsIAM.Role{{RoleName: aws.String("role-2")}},
+		}, nil)
+
+		roleNames, err := iamService.ListRoles()
+		Expect(err).To(BeNil())
+		Expect(roleNames).To(Equal([]string{"role-1", "role-2"}))
+	})
+
+	It("lists the cluster roles below the path and at the default path", func() {
+		iamService, err := iam.New(iamConfig)
+		Expect(err).To(BeNil())
+
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/capa/"),
+		}).Return(&awsIAM.ListRolesOutput{
+			Roles: []*awsIAM.Role{{RoleName: aws.String("test-cluster-new"), Path: aws.String("/capa/")}},
+		}, nil)
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/"),
+		}).Return(&awsIAM.ListRolesOutput{
+			Roles: []*awsIAM.Role{
+				{RoleName: aws.String("test-cluster-new"), Path: aws.String("/capa/")},
+				{RoleName: aws.String("test-cluster-legacy"), Path: aws.String("/")},
+				{RoleName: aws.String("test-cluster-other"), Path: aws.String("/other/")},
+			},
+		}, nil)
+		ownedTags := []*awsIAM.Tag{
+			{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+			{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")},
+		}
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-new"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: ownedTags}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-legacy"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: ownedTags}, nil)
+
+		roleNames, err := iamService.ListClusterRoles()
+		Expect(err).To(BeNil())
+		Expect(roleNames).To(Equal([]string{"test-cluster-new", "test-cluster-legacy"}))
+	})
+
+	It("skips the default path when listing it is denied", func() {
+		iamService, err := iam.New(iamConfig)
+		Expect(err).To(BeNil())
+
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/capa/"),
+		}).Return(&awsIAM.ListRolesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRoles(&awsIAM.ListRolesInput{
+			PathPrefix: aws.String("/"),
+		}).Return(nil, awserr.New("AccessDenied", "test", nil))
+
+		roleNames, err := iamService.ListClusterRoles()
+		Expect(err).To(BeNil())
+		Expect(roleNames).To(BeEmpty())
+	})
+
+	It("rejects paths not beginning and ending with a slash", func() {
+		iamConfig.RolePath = "capa"
+		_, err := iam.New(iamConfig)
+		Expect(err).To(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
+
+var _ = Describe("SkipInstanceProfiles", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		// Unexpected calls, e.g. to CreateInstanceProfile, fail the test.
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.RoleType = "nodes"
+			c.SkipInstanceProfiles = true
+		})
+	})
+
+	It("creates the role without an instance profile", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().CreateRole(gomock.Any()).Return(&awsIAM.CreateRoleOutput{}, nil)
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+
+		err := iamService.ReconcileRole()
+		Expect(err).To(BeNil())
+	})
+
+	It("deletes the role without touching the instance profile", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		err := iamService.DeleteRole()
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+})
+
+var _ = Describe("ReconcileExtraRole", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("creates a role named after the cluster and role type", func() {
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-cluster-nodes")}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-nodes"))
+			Expect(*input.AssumeRolePolicyDocument).To(ContainSubstring("ec2.amazonaws.com"))
+			return &awsIAM.CreateRoleOutput{}, nil
+		})
+		mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().GetRolePolicy(&awsIAM.GetRolePolicyInput{
+			PolicyName: aws.String("control-plane-test-cluster-policy"),
+			RoleName:   aws.String("test-cluster-nodes"),
+		}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-nodes"))
+			Expect(*input.PolicyName).To(Equal("control-plane-test-cluster-policy"))
+			return &awsIAM.PutRolePolicyOutput{}, nil
+		})
+
+		err := iamService.ReconcileExtraRole("nodes")
+		Expect(err).To(BeNil())
+	})
+
+	It("rejects role types which are not assumed by EC2 instances", func() {
+		err := iamService.ReconcileExtraRole("route53-role")
+		Expect(err).To(MatchError(ContainSubstring("unsupported extra role type")))
+	})
+})
+
+var _ = Describe("structured logging", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+		logs          []map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		logs = nil
+		logger := funcr.NewJSON(func(obj string) {
+			var entry map[string]interface{}
+			Expect(json.Unmarshal([]byte(obj), &entry)).To(Succeed())
+			logs = append(logs, entry)
+		}, funcr.Options{})
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.RoleType = "nodes"
+			c.Log = logger
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("logs policy names as key-value pairs", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{
+			AttachedPolicies: []*awsIAM.AttachedPolicy{{PolicyName: aws.String("attached-policy"), PolicyArn: aws.String("arn:aws:iam::aws:policy/attached-policy")}},
+		}, nil)
+		mockIAMClient.EXPECT().DetachRolePolicy(gomock.Any()).Return(&awsIAM.DetachRolePolicyOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{
+			PolicyNames: []*string{aws.String("inline-policy")},
+		}, nil)
+		mockIAMClient.EXPECT().DeleteRolePolicy(gomock.Any()).Return(&awsIAM.DeleteRolePolicyOutput{}, nil)
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		Expect(iamService.DeleteRole()).To(Succeed())
+
+		Expect(logs).To(ContainElement(SatisfyAll(
+			HaveKeyWithValue("msg", "detached policy"),
+			HaveKeyWithValue("role_name", "test-role"),
+			HaveKeyWithValue("policy_name", "attached-policy"),
+		)))
+		Expect(logs).To(ContainElement(SatisfyAll(
+			HaveKeyWithValue("msg", "deleted inline policy"),
+			HaveKeyWithValue("role_name", "test-role"),
+			HaveKeyWithValue("policy_name", "inline-policy"),
+		)))
+	})
+})
+
+var _ = Describe("IAMManagementSession", func() {
+
+	var (
+		mockCtrl                *gomock.Controller
+		mockIAMClient           *mocks.MockIAMAPI
+		mockManagementIAMClient *mocks.MockIAMAPI
+		iamService              *iam.IAMService
+		clusterSession          awsclientgo.ConfigProvider
+		managementSession       awsclientgo.ConfigProvider
+	)
+
+	BeforeEach(func() {
+		var err error
+		clusterSession, err = session.NewSession(&aws.Config{Region: aws.String("eu-west-1")})
+		Expect(err).NotTo(HaveOccurred())
+		managementSession, err = session.NewSession(&aws.Config{Region: aws.String("eu-central-1")})
+		Expect(err).NotTo(HaveOccurred())
+
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+		mockManagementIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService, err = iam.New(iam.IAMServiceConfig{
+			ClusterName:            "test-cluster",
+			MainRoleName:           "test-role",
+			Region:                 "eu-west-1",
+			RoleType:               "control-plane",
+			Log:                    ctrl.Log,
+			AWSSession:             clusterSession,
+			IAMManagementSession:   managementSession,
+			IAMManagementAccountID: "999999999999",
+			IAMClientFactory: func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
+				if session == managementSession {
+					return mockManagementIAMClient
+				}
+				return mockIAMClient
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("requires the ID of the IAM management account", func() {
+		_, err := iam.New(iam.IAMServiceConfig{
+			ClusterName:          "test-cluster",
+			MainRoleName:         "test-role",
+			Region:               "eu-west-1",
+			RoleType:             "control-plane",
+			Log:                  ctrl.Log,
+			AWSSession:           clusterSession,
+			IAMManagementSession: managementSession,
+			IAMClientFactory: func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
+				return mockIAMClient
+			},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("manages the roles of instance profiles in the cluster account", func() {
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+			Arn: aws.String("arn:aws:iam::012345678901:role/test-role"),
+		}}, nil)
+		arn, err := iamService.GetRoleARN("test-role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(arn).To(Equal("arn:aws:iam::012345678901:role/test-role"))
+		Expect(iamService.RoleARN("012345678901", "test-role")).To(Equal("arn:aws:iam::012345678901:role/test-role"))
+	})
+
+	It("manages the IRSA roles in the management account", func() {
+		mockManagementIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-cluster-AMP-Role")}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+			Arn: aws.String("arn:aws:iam::999999999999:role/test-cluster-AMP-Role"),
+		}}, nil)
+		arn, err := iamService.GetRoleARN("test-cluster-AMP-Role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(arn).To(Equal("arn:aws:iam::999999999999:role/test-cluster-AMP-Role"))
+		Expect(iamService.RoleARN("012345678901", "test-cluster-AMP-Role")).To(Equal("arn:aws:iam::999999999999:role/test-cluster-AMP-Role"))
+	})
+
+	It("registers the IRSA trust domains as OIDC providers of the management account", func() {
+		mockManagementIAMClient.EXPECT().ListOpenIDConnectProviders(gomock.Any()).Return(&awsIAM.ListOpenIDConnectProvidersOutput{
+			OpenIDConnectProviderList: []*awsIAM.OpenIDConnectProviderListEntry{
+				{Arn: aws.String("arn:aws:iam::999999999999:oidc-provider/irsa.existing.example.com")},
+			},
+		}, nil).Times(1)
+		mockManagementIAMClient.EXPECT().CreateOpenIDConnectProvider(&awsIAM.CreateOpenIDConnectProviderInput{
+			Url:          aws.String("https://irsa.test.example.com"),
+			ClientIDList: aws.StringSlice([]string{"sts.amazonaws.com"}),
+			Tags: []*awsIAM.Tag{
+				{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+				{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")},
+			},
+		}).Return(&awsIAM.CreateOpenIDConnectProviderOutput{}, nil).Times(1)
+
+		mockManagementIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+		mockManagementIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			Expect(*input.PolicyDocument).To(ContainSubstring("arn:aws:iam::999999999999:oidc-provider/irsa.test.example.com"))
+			Expect(*input.PolicyDocument).NotTo(ContainSubstring("012345678901"))
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		}).Times(2)
+		mockManagementIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).Times(2)
+		mockManagementIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			err := iamService.ReconcileXRayRole("012345678901", []string{"irsa.existing.example.com", "irsa.test.example.com"}, "observability", "xray-daemon")
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("deletes the OIDC providers of the cluster together with the IRSA roles", func() {
+		mockManagementIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil).AnyTimes()
+		mockManagementIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil).AnyTimes()
+		mockManagementIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil).AnyTimes()
+		mockManagementIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil).AnyTimes()
+		mockManagementIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(&awsIAM.DeleteRoleOutput{}, nil).Times(len(iamService.IRSARoleNames()))
+
+		mockManagementIAMClient.EXPECT().ListOpenIDConnectProviders(gomock.Any()).Return(&awsIAM.ListOpenIDConnectProvidersOutput{
+			OpenIDConnectProviderList: []*awsIAM.OpenIDConnectProviderListEntry{
+				{Arn: aws.String("arn:aws:iam::999999999999:oidc-provider/irsa.test.example.com")},
+				{Arn: aws.String("arn:aws:iam::999999999999:oidc-provider/irsa.other.example.com")},
+			},
+		}, nil)
+		mockManagementIAMClient.EXPECT().ListOpenIDConnectProviderTags(&awsIAM.ListOpenIDConnectProviderTagsInput{
+			OpenIDConnectProviderArn: aws.String("arn:aws:iam::999999999999:oidc-provider/irsa.test.example.com"),
+		}).Return(&awsIAM.ListOpenIDConnectProviderTagsOutput{
+			Tags: []*awsIAM.Tag{
+				{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+				{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")},
+			},
+		}, nil)
+		mockManagementIAMClient.EXPECT().ListOpenIDConnectProviderTags(&awsIAM.ListOpenIDConnectProviderTagsInput{
+			OpenIDConnectProviderArn: aws.String("arn:aws:iam::999999999999:oidc-provider/irsa.other.example.com"),
+		}).Return(&awsIAM.ListOpenIDConnectProviderTagsOutput{
+			Tags: []*awsIAM.Tag{
+				{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+				{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/other-cluster"), Value: aws.String("owned")},
+			},
+		}, nil)
+		mockManagementIAMClient.EXPECT().DeleteOpenIDConnectProvider(&awsIAM.DeleteOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: aws.String("arn:aws:iam::999999999999:oidc-provider/irsa.test.example.com"),
+		}).Return(&awsIAM.DeleteOpenIDConnectProviderOutput{}, nil)
+
+		err := iamService.DeleteRolesForIRSA()
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("GetRoleARN", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		roleARNCache  *cache.TTLCache[string]
+		newIAMService func() *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+		roleARNCache = cache.NewTTLCache[string](time.Minute)
+
+		newIAMService = func() *iam.IAMService {
+			return newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+				c.RoleType = "irsa-role"
+				c.RoleARNCache = roleARNCache
+			})
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("gets the ARN only once from IAM within the TTL", func() {
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+			Arn: aws.String("arn:aws:iam::012345678901:role/test-role"),
+		}}, nil).Times(1)
+
+		for i := 0; i < 3; i++ {
+			arn, err := newIAMService().GetRoleARN("test-role")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(arn).To(Equal("arn:aws:iam::012345678901:role/test-role"))
+		}
+	})
+
+	It("gets the ARN again from IAM after the role was deleted", func() {
+		gomock.InOrder(
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+				Arn: aws.String("arn:aws:iam::012345678901:role/test-role"),
+			}}, nil),
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+				Arn: aws.String("arn:aws:iam::012345678901:role/recreated/test-role"),
+			}}, nil),
+		)
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		_, err := newIAMService().GetRoleARN("test-role")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(newIAMService().DeleteRole()).To(Succeed())
+
+		arn, err := newIAMService().GetRoleARN("test-role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(arn).To(Equal("arn:aws:iam::012345678901:role/recreated/test-role"))
+	})
+
+	It("does not cache errors", func() {
+		notFound := awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{RoleName: aws.String("test-role")}).Return(nil, notFound).Times(2)
+
+		for i := 0; i < 2; i++ {
+			_, err := newIAMService().GetRoleARN("test-role")
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
+
+var _ = Describe("ReconcileKiamRole", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("trusts the control plane role", func() {
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-role"),
+		}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+			Arn: aws.String("arn:aws:iam::012345678901:role/test-role"),
+		}}, nil)
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-cluster-IAMManager-Role"),
+		}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-IAMManager-Role"))
+			Expect(*input.AssumeRolePolicyDocument).To(ContainSubstring("arn:aws:iam::012345678901:role/test-role"))
+			return &awsIAM.CreateRoleOutput{}, nil
+		})
+		mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+
+		err := iamService.ReconcileKiamRole()
+		Expect(err).To(BeNil())
+	})
+
+	It("fails if the control plane role does not exist", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+
+		err := iamService.ReconcileKiamRole()
+		Expect(iam.IsNotFound(err)).To(BeTrue())
+	})
+})
+
+var _ = Describe("IRSA roles", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("names the roles after the cluster", func() {
+		Expect(iamService.IRSARoleNames()).To(ContainElements("test-cluster-Route53Manager-Role", "test-cluster-CertManager-Role"))
+		Expect(iamService.IRSARoleNames()).To(HaveLen(len(iam.IRSARoleTypes())))
+		Expect(iam.RoleName(iam.CertManagerRole, "test-cluster")).To(Equal("test-cluster-CertManager-Role"))
+	})
+
+	It("returns the role ARNs by role type", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).DoAndReturn(func(input *awsIAM.GetRoleInput) (*awsIAM.GetRoleOutput, error) {
+			return &awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+				Arn: aws.String("arn:aws:iam::012345678901:role/" + *input.RoleName),
+			}}, nil
+		}).Times(len(iam.IRSARoleTypes()))
+
+		arns, err := iamService.IRSARoleARNs()
+		Expect(err).To(BeNil())
+		Expect(arns).To(HaveKeyWithValue(iam.Route53Role, "arn:aws:iam::012345678901:role/test-cluster-Route53Manager-Role"))
+		Expect(arns).To(HaveLen(len(iam.IRSARoleTypes())))
+	})
+
+	It("fails if a role does not exist", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+
+		_, err := iamService.IRSARoleARNs()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("deletes all roles", func() {
+		var deletedRoles []string
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil).AnyTimes()
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil).AnyTimes()
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		mockIAMClient.EXPECT().DeleteRole(gomock.Any()).DoAndReturn(func(input *awsIAM.DeleteRoleInput) (*awsIAM.DeleteRoleOutput, error) {
+			deletedRoles = append(deletedRoles, *input.RoleName)
+			return &awsIAM.DeleteRoleOutput{}, nil
+		}).AnyTimes()
+
+		err := iamService.DeleteRolesForIRSA()
+		Expect(err).To(BeNil())
+		Expect(deletedRoles).To(ConsistOf(iamService.IRSARoleNames()))
+	})
+})
+
+var _ = Describe("DeleteRoleByName", func() {
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("detaches and deletes all policies before deleting the role", func() {
+		gomock.InOrder(
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(&awsIAM.ListAttachedRolePoliciesInput{
+				RoleName: aws.String("old-role"),
+			}).Return(&awsIAM.ListAttachedRolePoliciesOutput{
+				AttachedPolicies: []*awsIAM.AttachedPolicy{{PolicyName: aws.String("attached-policy"), PolicyArn: aws.String("arn:aws:iam::aws:policy/attached-policy")}},
+			}, nil),
+			mockIAMClient.EXPECT().DetachRolePolicy(&awsIAM.DetachRolePolicyInput{
+				RoleName:  aws.String("old-role"),
+				PolicyArn: aws.String("arn:aws:iam::aws:policy/attached-policy"),
+			}).Return(&awsIAM.DetachRolePolicyOutput{}, nil),
+			mockIAMClient.EXPECT().ListRolePolicies(&awsIAM.ListRolePoliciesInput{
+				RoleName: aws.String("old-role"),
+			}).Return(&awsIAM.ListRolePoliciesOutput{
+				PolicyNames: []*string{aws.String("inline-policy")},
+			}, nil),
+			mockIAMClient.EXPECT().DeleteRolePolicy(&awsIAM.DeleteRolePolicyInput{
+				RoleName:   aws.String("old-role"),
+				PolicyName: aws.String("inline-policy"),
+			}).Return(&awsIAM.DeleteRolePolicyOutput{}, nil),
+			mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil),
+			mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil),
+			mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+				RoleName: aws.String("old-role"),
+			}).Return(&awsIAM.DeleteRoleOutput{}, nil),
+		)
+
+		err := iamService.DeleteRoleByName("old-role")
+		Expect(err).To(BeNil())
+	})
+
+	It("succeeds if the role does not exist", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().DeleteRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+
+		err := iamService.DeleteRoleByName("old-role")
+		Expect(err).To(BeNil())
+	})
+
+	It("fails if a policy cannot be detached", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{
+			AttachedPolicies: []*awsIAM.AttachedPolicy{{PolicyName: aws.String("attached-policy"), PolicyArn: aws.String("arn:aws:iam::aws:policy/attached-policy")}},
+		}, nil)
+		mockIAMClient.EXPECT().DetachRolePolicy(gomock.Any()).Return(nil, errors.New("access denied"))
+
+		err := iamService.DeleteRoleByName("old-role")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("policy updates", func() {
+
+	const (
+		trustedPrincipal   = "arn:aws:iam::012345678901:role/sre"
+		currentTrustPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::012345678901:role/sre"},"Action":"sts:AssumeRole"}]}`
+		otherTrustPolicy   = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::012345678901:role/other"},"Action":"sts:AssumeRole"}]}`
+		currentPolicy      = `{"Statement":[{"Resource":"*","Effect":"Allow","Action":["ec2:Describe*","ec2:Get*","ec2:List*","elasticloadbalancing:Describe*","elasticloadbalancing:Get*","elasticloadbalancing:List*","eks:Describe*","eks:Get*","eks:List*","iam:Get*","iam:List*"]}],"Version":"2012-10-17"}`
+		otherPolicy        = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"ec2:*","Resource":"*"}]}`
+	)
+
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	// IAM returns URL-encoded policy documents, which differ in whitespace
+	// and key order from the rendered templates.
+	DescribeTable("only writes policy documents which changed",
+		func(trustPolicy, policy string, expectTrustPolicyUpdate, expectPolicyUpdate bool) {
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{
+				AssumeRolePolicyDocument: aws.String(url.QueryEscape(trustPolicy)),
+			}}, nil).AnyTimes()
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(&awsIAM.GetRolePolicyOutput{
+				PolicyDocument: aws.String(url.QueryEscape(policy)),
+			}, nil)
+
+			if expectTrustPolicyUpdate {
+				mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+					Expect(*input.PolicyDocument).To(ContainSubstring(trustedPrincipal))
+					return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+				})
+			}
+			if expectPolicyUpdate {
+				mockIAMClient.EXPECT().DeleteRolePolicy(gomock.Any()).Return(&awsIAM.DeleteRolePolicyOutput{}, nil)
+				mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+			}
+
+			err := iamService.ReconcileReadOnlyRole(trustedPrincipal)
+			Expect(err).To(BeNil())
+		},
+		Entry("no-op when both documents are identical", currentTrustPolicy, currentPolicy, false, false),
+		Entry("updates the trust policy when it differs", otherTrustPolicy, currentPolicy, true, false),
+		Entry("updates the inline policy when it differs", currentTrustPolicy, otherPolicy, false, true),
+		Entry("updates both when both differ", otherTrustPolicy, otherPolicy, true, true),
+	)
+})
+
+var _ = Describe("OwnershipTag", func() {
+
+	var (
+		mockCtrl         *gomock.Controller
+		mockIAMClient    *mocks.MockIAMAPI
+		mockConfigClient *mocks.MockConfigServiceAPI
+		iamService       *iam.IAMService
+	)
+
+	ownershipTag := &awsIAM.Tag{Key: aws.String("example.com/managed-by"), Value: aws.String("capa-iam-operator")}
+	clusterTag := &awsIAM.Tag{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/cluster/test-cluster"), Value: aws.String("owned")}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+		mockConfigClient = mocks.NewMockConfigServiceAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.RoleType = "nodes"
+			c.OwnershipTagKey = "example.com/managed-by"
+			c.OwnershipTagValue = "capa-iam-operator"
+			c.ConfigClientFactory = func(session awsclientgo.ConfigProvider, region string) configserviceiface.ConfigServiceAPI {
+				return mockConfigClient
+			}
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("tags created roles and instance profiles and requires the tag with AWS Config", func() {
+		mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+			Expect(input.Tags).To(Equal([]*awsIAM.Tag{ownershipTag, clusterTag}))
+			return &awsIAM.CreateRoleOutput{}, nil
+		})
+		mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateInstanceProfileInput) (*awsIAM.CreateInstanceProfileOutput, error) {
+			Expect(input.Tags).To(Equal([]*awsIAM.Tag{ownershipTag, clusterTag}))
+			return &awsIAM.CreateInstanceProfileOutput{}, nil
+		})
+		mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+		mockConfigClient.EXPECT().PutConfigRule(gomock.Any()).DoAndReturn(func(input *configservice.PutConfigRuleInput) (*configservice.PutConfigRuleOutput, error) {
+			var params map[string]string
+			Expect(json.Unmarshal([]byte(*input.ConfigRule.InputParameters), &params)).To(Succeed())
+			Expect(params).To(HaveKeyWithValue("tag1Key", "example.com/managed-by"))
+			Expect(params).To(HaveKeyWithValue("tag1Value", "capa-iam-operator"))
+			return &configservice.PutConfigRuleOutput{}, nil
+		})
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+
+		err := iamService.ReconcileRole()
+		Expect(err).To(BeNil())
+	})
+
+	It("migrates roles with the default ownership tag to the custom ownership tag", func() {
+		legacyTag := &awsIAM.Tag{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")}
+		mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{legacyTag, clusterTag}}, nil)
+		gomock.InOrder(
+			mockIAMClient.EXPECT().TagRole(&awsIAM.TagRoleInput{
+				RoleName: aws.String("test-role"),
+				Tags:     []*awsIAM.Tag{ownershipTag},
+			}).Return(&awsIAM.TagRoleOutput{}, nil),
+			mockIAMClient.EXPECT().UntagRole(&awsIAM.UntagRoleInput{
+				RoleName: aws.String("test-role"),
+				TagKeys:  []*string{aws.String("capi-iam-controller/owned")},
+			}).Return(&awsIAM.UntagRoleOutput{}, nil),
+		)
+		mockIAMClient.EXPECT().ListInstanceProfileTags(gomock.Any()).Return(&awsIAM.ListInstanceProfileTagsOutput{Tags: []*awsIAM.Tag{legacyTag, clusterTag}}, nil)
+		gomock.InOrder(
+			mockIAMClient.EXPECT().TagInstanceProfile(&awsIAM.TagInstanceProfileInput{
+				InstanceProfileName: aws.String("test-role"),
+				Tags:                []*awsIAM.Tag{ownershipTag},
+			}).Return(&awsIAM.TagInstanceProfileOutput{}, nil),
+			mockIAMClient.EXPECT().UntagInstanceProfile(&awsIAM.UntagInstanceProfileInput{
+				InstanceProfileName: aws.String("test-role"),
+				TagKeys:             []*string{aws.String("capi-iam-controller/owned")},
+			}).Return(&awsIAM.UntagInstanceProfileOutput{}, nil),
+		)
+
+		err := iamService.ReconcileRoleTags("test-role")
+		Expect(err).To(BeNil())
+	})
+
+	It("keeps the default ownership tag if the custom ownership tag cannot be added", func() {
+		mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+			clusterTag,
+		}}, nil)
+		mockIAMClient.EXPECT().TagRole(gomock.Any()).Return(nil, awserr.New("AccessDenied", "test", nil))
+
+		err := iamService.ReconcileRoleTags("test-role")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("restores the cluster tag of roles with the ownership tag and removes the default ownership tag", func() {
+		mockIAMClient.EXPECT().ListRoleTags(gomock.Any()).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			ownershipTag,
+			{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+		}}, nil)
+		mockIAMClient.EXPECT().TagRole(&awsIAM.TagRoleInput{
+			RoleName: aws.String("test-role"),
+			Tags:     []*awsIAM.Tag{clusterTag},
+		}).Return(&awsIAM.TagRoleOutput{}, nil)
+		mockIAMClient.EXPECT().UntagRole(&awsIAM.UntagRoleInput{
+			RoleName: aws.String("test-role"),
+			TagKeys:  []*string{aws.String("capi-iam-controller/owned")},
+		}).Return(&awsIAM.UntagRoleOutput{}, nil)
+		mockIAMClient.EXPECT().ListInstanceProfileTags(gomock.Any()).Return(&awsIAM.ListInstanceProfileTagsOutput{Tags: []*awsIAM.Tag{ownershipTag, clusterTag}}, nil)
+
+		err := iamService.ReconcileRoleTags("test-role")
+		Expect(err).To(BeNil())
+	})
+
+	It("only lists roles with the ownership tag or the default ownership tag as cluster roles", func() {
+		mockIAMClient.EXPECT().ListRoles(gomock.Any()).Return(&awsIAM.ListRolesOutput{
+			Roles: []*awsIAM.Role{
+				{RoleName: aws.String("test-cluster-custom-tag-role")},
+				{RoleName: aws.String("test-cluster-default-tag-role")},
+				{RoleName: aws.String("test-cluster-other-value-role")},
+			},
+		}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-custom-tag-role"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{ownershipTag, clusterTag}}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-default-tag-role"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			{Key: aws.String("capi-iam-controller/owned"), Value: aws.String("")},
+			clusterTag,
+		}}, nil)
+		mockIAMClient.EXPECT().ListRoleTags(&awsIAM.ListRoleTagsInput{
+			RoleName: aws.String("test-cluster-other-value-role"),
+		}).Return(&awsIAM.ListRoleTagsOutput{Tags: []*awsIAM.Tag{
+			{Key: aws.String("example.com/managed-by"), Value: aws.String("someone-else")},
+			clusterTag,
+		}}, nil)
+
+		roleNames, err := iamService.ListClusterRoles()
+		Expect(err).To(BeNil())
+		Expect(roleNames).To(Equal([]string{"test-cluster-custom-tag-role", "test-cluster-default-tag-role"}))
+	})
+})
@@ -0,0 +1,152 @@
+package iam
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+const (
+	karpenterNamespace      = "kube-system"
+	karpenterServiceAccount = "karpenter"
+)
+
+// karpenterRolePolicyTemplate allows Karpenter to launch and terminate spot
+// and on-demand instances for the cluster and to consume the interruption
+// events of the annotated SQS queue. Instances are only terminated if they
+// are owned by the cluster and only the node role of the cluster can be
+// passed to them.
+const karpenterRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ec2:CreateFleet",
+        "ec2:CreateLaunchTemplate",
+        "ec2:CreateTags",
+        "ec2:RunInstances",
+        "ec2:DescribeAvailabilityZones",
+        "ec2:DescribeImages",
+        "ec2:DescribeInstances",
+        "ec2:DescribeInstanceTypeOfferings",
+        "ec2:DescribeInstanceTypes",
+        "ec2:DescribeLaunchTemplates",
+        "ec2:DescribeSecurityGroups",
+        "ec2:DescribeSpotPriceHistory",
+        "ec2:DescribeSubnets",
+        "autoscaling:DescribeAutoScalingGroups",
+        "pricing:GetProducts",
+        "ssm:GetParameter"
+      ],
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ec2:DeleteLaunchTemplate",
+        "ec2:TerminateInstances"
+      ],
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {
+          "ec2:ResourceTag/kubernetes.io/cluster/{{ .ClusterName }}": "owned"
+        }
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Action": "iam:PassRole",
+      "Resource": "{{ .NodeRoleARN }}",
+      "Condition": {
+        "StringEquals": {
+          "iam:PassedToService": "{{ .EC2ServiceDomain }}"
+        }
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "sqs:DeleteMessage",
+        "sqs:GetQueueAttributes",
+        "sqs:GetQueueUrl",
+        "sqs:ReceiveMessage"
+      ],
+      "Resource": "{{ .QueueARN }}"
+    }
+  ]
+}
+`
+
+// KarpenterRoleParams are the parameters of the trust and inline policy
+// templates of the Karpenter role.
+type KarpenterRoleParams struct {
+	Route53RoleParams
+	ClusterName string
+	QueueARN    string
+	// NodeRoleARN is the ARN of the role of the nodes launched by Karpenter.
+	NodeRoleARN string
+}
+
+// ValidateKarpenterQueueARN returns an error if queueARN is not the ARN of an
+// SQS queue.
+func ValidateKarpenterQueueARN(queueARN string) error {
+	parsed, err := arn.Parse(queueARN)
+	if err != nil {
+		return err
+	}
+	if parsed.Service != "sqs" || parsed.Resource == "" {
+		return fmt.Errorf("ARN %q is not an SQS queue ARN", queueARN)
+	}
+	return nil
+}
+
+// ReconcileKarpenterRole makes sure the IRSA role of Karpenter exists and
+// allows provisioning nodes with the given node role of the cluster account
+// and consuming the given interruption queue.
+func (s *IAMService) ReconcileKarpenterRole(awsAccountID string, irsaTrustDomains []string, queueARN string, nodeRoleName string) error {
+	s.log.Info("reconciling Karpenter IAM role")
+
+	if len(irsaTrustDomains) == 0 {
+		return fmt.Errorf("irsaTrustDomains cannot be empty")
+	}
+	err := ValidateKarpenterQueueARN(queueARN)
+	if err != nil {
+		return err
+	}
+	if nodeRoleName == "" {
+		return fmt.Errorf("nodeRoleName cannot be empty")
+	}
+
+	irsaParams, err := s.irsaRoleParams(awsAccountID, irsaTrustDomains, karpenterNamespace, karpenterServiceAccount)
+	if err != nil {
+		return err
+	}
+
+	params := KarpenterRoleParams{
+		Route53RoleParams: irsaParams,
+		ClusterName:       s.clusterName,
+		QueueARN:          queueARN,
+		NodeRoleARN:       s.RoleARN(awsAccountID, nodeRoleName),
+	}
+
+	err = s.reconcileRole(roleName(KarpenterRole, s.clusterName), KarpenterRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling Karpenter IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteKarpenterRole() error {
+	s.log.Info("deleting Karpenter IAM resources")
+
+	err := s.deleteRole(roleName(KarpenterRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting Karpenter IAM resources")
+	return nil
+}
@@ -0,0 +1,121 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("KarpenterRole", func() {
+
+	const queueARN = "arn:aws:sqs:eu-west-1:012345678901:test-cluster-karpenter"
+
+	type statement struct {
+		Effect   string
+		Action   interface{}
+		Resource interface{}
+	}
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockIAMClient  *mocks.MockIAMAPI
+		iamService     *iam.IAMService
+		trustPolicy    string
+		policyDocument string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-cluster-Karpenter-Role"),
+		}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+		mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-Karpenter-Role"))
+			trustPolicy = *input.PolicyDocument
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		}).AnyTimes()
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-Karpenter-Role"))
+			policyDocument = *input.PolicyDocument
+			return &awsIAM.PutRolePolicyOutput{}, nil
+		}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("allows consuming the interruption queue", func() {
+		err := iamService.ReconcileKarpenterRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, queueARN, "test-cluster-nodes")
+		Expect(err).To(BeNil())
+
+		var policy struct {
+			Statement []statement
+		}
+		Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+		Expect(policy.Statement).To(ContainElement(statement{
+			Effect:   "Allow",
+			Action:   []interface{}{"sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:GetQueueUrl", "sqs:ReceiveMessage"},
+			Resource: queueARN,
+		}))
+	})
+
+	It("allows launching and terminating instances of the cluster", func() {
+		err := iamService.ReconcileKarpenterRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, queueARN, "test-cluster-nodes")
+		Expect(err).To(BeNil())
+
+		Expect(policyDocument).To(ContainSubstring(`"ec2:RunInstances"`))
+		Expect(policyDocument).To(ContainSubstring(`"ec2:TerminateInstances"`))
+		Expect(policyDocument).To(ContainSubstring(`"ec2:ResourceTag/kubernetes.io/cluster/test-cluster": "owned"`))
+	})
+
+	It("only allows passing the node role to instances", func() {
+		err := iamService.ReconcileKarpenterRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, queueARN, "test-cluster-nodes")
+		Expect(err).To(BeNil())
+
+		var policy struct {
+			Statement []statement
+		}
+		Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+		for _, s := range policy.Statement {
+			if s.Action == "iam:PassRole" {
+				Expect(s.Resource).To(Equal("arn:aws:iam::012345678901:role/test-cluster-nodes"))
+			}
+		}
+		Expect(policyDocument).To(ContainSubstring(`"iam:PassRole"`))
+	})
+
+	It("requires the node role", func() {
+		err := iamService.ReconcileKarpenterRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, queueARN, "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("trusts the Karpenter service account", func() {
+		err := iamService.ReconcileKarpenterRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, queueARN, "test-cluster-nodes")
+		Expect(err).To(BeNil())
+
+		Expect(trustPolicy).To(ContainSubstring(`"irsa.test.gaws.gigantic.io:sub": "system:serviceaccount:kube-system:karpenter"`))
+	})
+
+	DescribeTable("rejects ARNs which are not SQS queue ARNs",
+		func(queueARN string) {
+			err := iamService.ReconcileKarpenterRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, queueARN, "test-cluster-nodes")
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("not an ARN", "test-cluster-karpenter"),
+		Entry("other service", "arn:aws:sns:eu-west-1:012345678901:test-cluster-karpenter"),
+	)
+})
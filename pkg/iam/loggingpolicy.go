@@ -0,0 +1,125 @@
+package iam
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// LoggingDestinationCloudWatch forwards the logs to a CloudWatch Logs
+	// log group.
+	LoggingDestinationCloudWatch = "cloudwatch"
+	// LoggingDestinationS3 forwards the logs to an S3 bucket.
+	LoggingDestinationS3 = "s3"
+
+	loggingNamespace      = "kube-system"
+	loggingServiceAccount = "fluent-bit"
+)
+
+var (
+	logGroupNameRegexp = regexp.MustCompile(`^[.\-_/#A-Za-z0-9]{1,512}$`)
+	bucketNameRegexp   = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+)
+
+const loggingRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {{- if eq .DestinationType "cloudwatch" }}
+    {
+      "Effect": "Allow",
+      "Action": [
+        "logs:CreateLogGroup",
+        "logs:CreateLogStream",
+        "logs:DescribeLogStreams",
+        "logs:PutLogEvents"
+      ],
+      "Resource": [
+        "arn:{{ .AWSDomain }}:logs:{{ .Region }}:{{ .AccountID }}:log-group:{{ .Target }}",
+        "arn:{{ .AWSDomain }}:logs:{{ .Region }}:{{ .AccountID }}:log-group:{{ .Target }}:*"
+      ]
+    }
+    {{- else }}
+    {
+      "Effect": "Allow",
+      "Action": "s3:PutObject",
+      "Resource": "arn:{{ .AWSDomain }}:s3:::{{ .Target }}/*"
+    }
+    {{- end }}
+  ]
+}
+`
+
+// LoggingRoleParams are the parameters of the trust and inline policy
+// templates of the logging role.
+type LoggingRoleParams struct {
+	Route53RoleParams
+	Region          string
+	DestinationType string
+	// Target is the log group name for CloudWatch and the bucket name for S3.
+	Target string
+}
+
+// ValidateLoggingDestination returns an error if destinationType is not a
+// supported log destination or target is not a valid log group or bucket
+// name for it.
+func ValidateLoggingDestination(destinationType, target string) error {
+	switch destinationType {
+	case LoggingDestinationCloudWatch:
+		if !logGroupNameRegexp.MatchString(target) {
+			return fmt.Errorf("%q is not a valid CloudWatch Logs log group name", target)
+		}
+	case LoggingDestinationS3:
+		if !bucketNameRegexp.MatchString(target) {
+			return fmt.Errorf("%q is not a valid S3 bucket name", target)
+		}
+	default:
+		return fmt.Errorf("unsupported log destination %q, use %s or %s", destinationType, LoggingDestinationCloudWatch, LoggingDestinationS3)
+	}
+	return nil
+}
+
+// ReconcileLoggingRole makes sure the IRSA role of Fluent Bit exists and allows
+// forwarding logs to the given destination.
+func (s *IAMService) ReconcileLoggingRole(awsAccountID string, irsaTrustDomains []string, destinationType, target string) error {
+	s.log.Info("reconciling logging IAM role")
+
+	if len(irsaTrustDomains) == 0 {
+		return fmt.Errorf("irsaTrustDomains cannot be empty")
+	}
+	err := ValidateLoggingDestination(destinationType, target)
+	if err != nil {
+		return err
+	}
+
+	irsaParams, err := s.irsaRoleParams(awsAccountID, irsaTrustDomains, loggingNamespace, loggingServiceAccount)
+	if err != nil {
+		return err
+	}
+
+	params := LoggingRoleParams{
+		Route53RoleParams: irsaParams,
+		Region:            s.region,
+		DestinationType:   destinationType,
+		Target:            target,
+	}
+
+	err = s.reconcileRole(roleName(LoggingRole, s.clusterName), LoggingRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling logging IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteLoggingRole() error {
+	s.log.Info("deleting logging IAM resources")
+
+	err := s.deleteRole(roleName(LoggingRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting logging IAM resources")
+	return nil
+}
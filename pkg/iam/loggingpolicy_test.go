@@ -0,0 +1,116 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("LoggingRole", func() {
+
+	type statement struct {
+		Effect   string
+		Action   interface{}
+		Resource interface{}
+	}
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockIAMClient  *mocks.MockIAMAPI
+		iamService     *iam.IAMService
+		policyDocument string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		policyDocument = ""
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-cluster-Logging-Role"),
+		}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+		mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-Logging-Role"))
+			Expect(*input.PolicyDocument).To(ContainSubstring(`"irsa.test.gaws.gigantic.io:sub": "system:serviceaccount:kube-system:fluent-bit"`))
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		}).AnyTimes()
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-Logging-Role"))
+			policyDocument = *input.PolicyDocument
+			return &awsIAM.PutRolePolicyOutput{}, nil
+		}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	statements := func() []statement {
+		var policy struct {
+			Statement []statement
+		}
+		Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+		return policy.Statement
+	}
+
+	It("allows writing to the CloudWatch Logs log group", func() {
+		err := iamService.ReconcileLoggingRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, "cloudwatch", "/giantswarm/test-cluster")
+		Expect(err).To(BeNil())
+
+		Expect(statements()).To(ConsistOf(statement{
+			Effect: "Allow",
+			Action: []interface{}{"logs:CreateLogGroup", "logs:CreateLogStream", "logs:DescribeLogStreams", "logs:PutLogEvents"},
+			Resource: []interface{}{
+				"arn:aws:logs:eu-west-1:012345678901:log-group:/giantswarm/test-cluster",
+				"arn:aws:logs:eu-west-1:012345678901:log-group:/giantswarm/test-cluster:*",
+			},
+		}))
+		Expect(policyDocument).NotTo(ContainSubstring("s3:"))
+	})
+
+	It("allows putting objects into the S3 bucket", func() {
+		err := iamService.ReconcileLoggingRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, "s3", "test-cluster-logs")
+		Expect(err).To(BeNil())
+
+		Expect(statements()).To(ConsistOf(statement{
+			Effect:   "Allow",
+			Action:   "s3:PutObject",
+			Resource: "arn:aws:s3:::test-cluster-logs/*",
+		}))
+		Expect(policyDocument).NotTo(ContainSubstring("logs:"))
+	})
+
+	It("rejects unsupported destinations", func() {
+		err := iamService.ReconcileLoggingRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, "kinesis", "stream")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("deletes the logging role", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+			RoleName: aws.String("test-cluster-Logging-Role"),
+		}).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		err := iamService.DeleteLoggingRole()
+		Expect(err).To(BeNil())
+	})
+
+	It("rejects invalid bucket names", func() {
+		err := iamService.ReconcileLoggingRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, "s3", "Invalid_Bucket")
+		Expect(err).To(HaveOccurred())
+	})
+})
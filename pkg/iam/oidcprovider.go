@@ -0,0 +1,128 @@
+package iam
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+)
+
+// oidcProviderClientID is the audience of the service account tokens
+// exchanged for IRSA credentials.
+const oidcProviderClientID = "sts.amazonaws.com"
+
+// ensureOIDCProviders registers the IRSA trust domains as OIDC providers in
+// the IAM management account, so that the IRSA roles created there can be
+// assumed with the service account tokens of the cluster. The providers of
+// the account of the cluster are created together with the cluster, e.g. by
+// CAPA or EKS.
+func (s *IAMService) ensureOIDCProviders(irsaTrustDomains []string) error {
+	var missing []string
+	for _, domain := range irsaTrustDomains {
+		if !s.oidcProviders[domain] {
+			missing = append(missing, domain)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	existing, err := s.listOIDCProviders()
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range missing {
+		l := s.log.WithValues("oidc_provider", domain)
+		if _, ok := existing[domain]; !ok {
+			_, err = s.iamClient.CreateOpenIDConnectProvider(&awsiam.CreateOpenIDConnectProviderInput{
+				Url:          aws.String("https://" + domain),
+				ClientIDList: aws.StringSlice([]string{oidcProviderClientID}),
+				Tags:         s.ownedTags(),
+			})
+			if IsAlreadyExists(err) {
+				l.Info("OIDC provider already exists")
+			} else if err != nil {
+				l.Error(err, "failed to create OIDC provider")
+				return err
+			} else {
+				l.Info("created OIDC provider")
+			}
+		}
+		s.oidcProviders[domain] = true
+	}
+
+	return nil
+}
+
+// deleteOIDCProviders deletes the OIDC providers created by
+// ensureOIDCProviders for the cluster. Providers which are not owned by the
+// operator are left alone.
+func (s *IAMService) deleteOIDCProviders() error {
+	existing, err := s.listOIDCProviders()
+	if err != nil {
+		return err
+	}
+
+	clusterTag := fmt.Sprintf(ClusterIDTag, s.clusterName)
+	for domain, arn := range existing {
+		l := s.log.WithValues("oidc_provider", domain)
+
+		o, err := s.iamClient.ListOpenIDConnectProviderTags(&awsiam.ListOpenIDConnectProviderTagsInput{
+			OpenIDConnectProviderArn: aws.String(arn),
+		})
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			l.Error(err, "failed to list tags of OIDC provider")
+			return err
+		}
+		if !s.isOwned(o.Tags) || !hasTag(o.Tags, clusterTag, "owned") {
+			continue
+		}
+
+		_, err = s.iamClient.DeleteOpenIDConnectProvider(&awsiam.DeleteOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: aws.String(arn),
+		})
+		if err != nil && !IsNotFound(err) {
+			l.Error(err, "failed to delete OIDC provider")
+			return err
+		}
+		delete(s.oidcProviders, domain)
+		l.Info("deleted OIDC provider")
+	}
+
+	return nil
+}
+
+// listOIDCProviders returns the ARNs of the OIDC providers of the account by
+// domain.
+func (s *IAMService) listOIDCProviders() (map[string]string, error) {
+	o, err := s.iamClient.ListOpenIDConnectProviders(&awsiam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		s.log.Error(err, "failed to list OIDC providers")
+		return nil, err
+	}
+
+	providers := map[string]string{}
+	for _, provider := range o.OpenIDConnectProviderList {
+		arn := aws.StringValue(provider.Arn)
+		_, domain, ok := strings.Cut(arn, ":oidc-provider/")
+		if !ok {
+			continue
+		}
+		providers[domain] = arn
+	}
+
+	return providers, nil
+}
+
+func hasTag(tags []*awsiam.Tag, key, value string) bool {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key && aws.StringValue(tag.Value) == value {
+			return true
+		}
+	}
+	return false
+}
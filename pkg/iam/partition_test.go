@@ -0,0 +1,123 @@
+package iam_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("Partition", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+	)
+
+	newIAMService := func(roleType, region, partition string) *iam.IAMService {
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String(region)},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		return newTestIAMService(mockIAMClient, func(c *iam.IAMServiceConfig) {
+			c.Region = region
+			c.Partition = partition
+			c.RoleType = roleType
+			c.AWSSession = sess
+		})
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	DescribeTable("uses the partition in the ARNs of the managed policies",
+		func(region, partition, expectedPartition string) {
+			iamService := newIAMService(iam.ControlPlaneRole, region, partition)
+
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil)
+			mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+			mockIAMClient.EXPECT().AttachRolePolicy(&awsIAM.AttachRolePolicyInput{
+				PolicyArn: aws.String("arn:" + expectedPartition + ":iam::aws:policy/service-role/AWSBackupServiceRolePolicyForBackup"),
+				RoleName:  aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.AttachRolePolicyOutput{}, nil)
+			mockIAMClient.EXPECT().AttachRolePolicy(&awsIAM.AttachRolePolicyInput{
+				PolicyArn: aws.String("arn:" + expectedPartition + ":iam::aws:policy/service-role/AWSBackupServiceRolePolicyForRestores"),
+				RoleName:  aws.String("test-cluster-BackupRole"),
+			}).Return(&awsIAM.AttachRolePolicyOutput{}, nil)
+
+			Expect(iamService.ReconcileBackupRole()).To(Succeed())
+		},
+		Entry("aws", "eu-west-1", "aws", "aws"),
+		Entry("aws-us-gov", "us-gov-west-1", "aws-us-gov", "aws-us-gov"),
+		Entry("aws-cn", "cn-north-1", "aws-cn", "aws-cn"),
+		Entry("default", "eu-west-1", "", "aws"),
+	)
+
+	DescribeTable("uses the partition in the ARNs of the OIDC providers",
+		func(region, partition, expectedPartition string) {
+			iamService := newIAMService(iam.ControlPlaneRole, region, partition)
+
+			var trustPolicies []string
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+			mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+				trustPolicies = append(trustPolicies, *input.PolicyDocument)
+				return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+			}).AnyTimes()
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil).AnyTimes()
+
+			Expect(iamService.ReconcileRolesForIRSA("012345678901", []string{"irsa.test.example.com"})).To(Succeed())
+			Expect(trustPolicies).NotTo(BeEmpty())
+			for _, document := range trustPolicies {
+				Expect(document).To(ContainSubstring(`"Federated": "arn:` + expectedPartition + `:iam::012345678901:oidc-provider/irsa.test.example.com"`))
+			}
+		},
+		Entry("aws", "eu-west-1", "aws", "aws"),
+		Entry("aws-us-gov", "us-gov-west-1", "aws-us-gov", "aws-us-gov"),
+		Entry("aws-cn", "cn-north-1", "aws-cn", "aws-cn"),
+	)
+
+	DescribeTable("uses the EC2 service principal of the partition",
+		func(region, partition, expectedPrincipal string) {
+			iamService := newIAMService(iam.NodesRole, region, partition)
+
+			mockIAMClient.EXPECT().GetRole(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+				Expect(*input.AssumeRolePolicyDocument).To(ContainSubstring(`"Service": "` + expectedPrincipal + `"`))
+				return &awsIAM.CreateRoleOutput{}, nil
+			})
+			mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).Return(&awsIAM.PutRolePolicyOutput{}, nil)
+
+			Expect(iamService.ReconcileRole()).To(Succeed())
+		},
+		Entry("aws", "eu-west-1", "aws", "ec2.amazonaws.com"),
+		Entry("aws-us-gov", "us-gov-west-1", "aws-us-gov", "ec2.amazonaws.com"),
+		Entry("aws-cn", "cn-north-1", "aws-cn", "ec2.amazonaws.com.cn"),
+	)
+
+	DescribeTable("builds role ARNs in the partition",
+		func(region, partition, expectedARN string) {
+			iamService := newIAMService(iam.ControlPlaneRole, region, partition)
+
+			Expect(iamService.RoleARN("012345678901", "test-role")).To(Equal(expectedARN))
+		},
+		Entry("aws", "eu-west-1", "aws", "arn:aws:iam::012345678901:role/test-role"),
+		Entry("aws-us-gov", "us-gov-west-1", "aws-us-gov", "arn:aws-us-gov:iam::012345678901:role/test-role"),
+		Entry("aws-cn", "cn-north-1", "aws-cn", "arn:aws-cn:iam::012345678901:role/test-role"),
+	)
+})
@@ -0,0 +1,102 @@
+package iam
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+const readOnlyTrustIdentityPolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "AWS": "{{ .TrustedPrincipalARN }}"
+      },
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}
+`
+
+// readOnlyRolePolicyTemplate allows inspecting the AWS resources of the
+// cluster without modifying them.
+const readOnlyRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ec2:Describe*",
+        "ec2:Get*",
+        "ec2:List*",
+        "elasticloadbalancing:Describe*",
+        "elasticloadbalancing:Get*",
+        "elasticloadbalancing:List*",
+        "eks:Describe*",
+        "eks:Get*",
+        "eks:List*",
+        "iam:Get*",
+        "iam:List*"
+      ],
+      "Resource": "*"
+    }
+  ]
+}
+`
+
+// ReadOnlyRoleParams are the parameters of the trust policy template of the
+// read-only role.
+type ReadOnlyRoleParams struct {
+	TrustedPrincipalARN string
+}
+
+// ValidateReadOnlyTrustedPrincipal returns an error if principalARN is not
+// the ARN of an IAM principal, e.g. a role or an account root.
+func ValidateReadOnlyTrustedPrincipal(principalARN string) error {
+	parsed, err := arn.Parse(principalARN)
+	if err != nil {
+		return err
+	}
+	if parsed.Service != "iam" || parsed.Resource == "" {
+		return fmt.Errorf("ARN %q is not an IAM principal ARN", principalARN)
+	}
+	return nil
+}
+
+// ReconcileReadOnlyRole makes sure the read-only role of the cluster exists
+// and trusts the given principal, e.g. the role SRE teams inspect clusters
+// with.
+func (s *IAMService) ReconcileReadOnlyRole(trustedPrincipalARN string) error {
+	s.log.Info("reconciling read-only IAM role")
+
+	err := ValidateReadOnlyTrustedPrincipal(trustedPrincipalARN)
+	if err != nil {
+		return err
+	}
+
+	params := ReadOnlyRoleParams{
+		TrustedPrincipalARN: trustedPrincipalARN,
+	}
+
+	err = s.reconcileRole(roleName(ReadOnlyRole, s.clusterName), ReadOnlyRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling read-only IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteReadOnlyRole() error {
+	s.log.Info("deleting read-only IAM resources")
+
+	err := s.deleteRole(roleName(ReadOnlyRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting read-only IAM resources")
+	return nil
+}
@@ -0,0 +1,126 @@
+package iam_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("ReadOnlyRole", func() {
+
+	const principalARN = "arn:aws:iam::012345678901:role/sre"
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockIAMClient  *mocks.MockIAMAPI
+		iamService     *iam.IAMService
+		trustPolicy    string
+		policyDocument string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	When("the role exists", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+				RoleName: aws.String("test-cluster-ReadOnly"),
+			}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).AnyTimes()
+			mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-ReadOnly"))
+				trustPolicy = *input.PolicyDocument
+				return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+			}).AnyTimes()
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil)).AnyTimes()
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-ReadOnly"))
+				policyDocument = *input.PolicyDocument
+				return &awsIAM.PutRolePolicyOutput{}, nil
+			}).AnyTimes()
+		})
+
+		It("trusts the configured principal", func() {
+			err := iamService.ReconcileReadOnlyRole(principalARN)
+			Expect(err).To(BeNil())
+
+			var policy struct {
+				Statement []struct {
+					Effect    string
+					Principal struct {
+						AWS string
+					}
+					Action string
+				}
+			}
+			Expect(json.Unmarshal([]byte(trustPolicy), &policy)).To(Succeed())
+			Expect(policy.Statement).To(HaveLen(1))
+			Expect(policy.Statement[0].Effect).To(Equal("Allow"))
+			Expect(policy.Statement[0].Principal.AWS).To(Equal(principalARN))
+			Expect(policy.Statement[0].Action).To(Equal("sts:AssumeRole"))
+		})
+
+		It("only allows read-only actions", func() {
+			err := iamService.ReconcileReadOnlyRole(principalARN)
+			Expect(err).To(BeNil())
+
+			var policy struct {
+				Statement []struct {
+					Effect string
+					Action []string
+				}
+			}
+			Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+			Expect(policy.Statement).NotTo(BeEmpty())
+
+			var services []string
+			for _, statement := range policy.Statement {
+				Expect(statement.Effect).To(Equal("Allow"))
+				for _, action := range statement.Action {
+					service, operation, found := strings.Cut(action, ":")
+					Expect(found).To(BeTrue())
+					Expect(operation).To(Or(HavePrefix("Describe"), HavePrefix("List"), HavePrefix("Get")), "action %s is not read-only", action)
+					services = append(services, service)
+				}
+			}
+			Expect(services).To(ContainElements("ec2", "elasticloadbalancing", "eks", "iam"))
+		})
+	})
+
+	It("deletes the role", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+			RoleName: aws.String("test-cluster-ReadOnly"),
+		}).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		err := iamService.DeleteReadOnlyRole()
+		Expect(err).To(BeNil())
+	})
+
+	DescribeTable("rejects ARNs which are not IAM principal ARNs",
+		func(principalARN string) {
+			Expect(iam.ValidateReadOnlyTrustedPrincipal(principalARN)).NotTo(Succeed())
+		},
+		Entry("not an ARN", "sre"),
+		Entry("other service", "arn:aws:sqs:eu-west-1:012345678901:sre"),
+	)
+})
@@ -0,0 +1,40 @@
+package iam
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// maxRoleNameLength is the maximum length of an IAM role name.
+const maxRoleNameLength = 64
+
+var (
+	// reservedRoleNamePrefixes are prefixes AWS reserves for its own roles.
+	reservedRoleNamePrefixes = []string{"aws-", "AWS-", "AmazonCSM"}
+
+	roleNameRegexp = regexp.MustCompile(`^[\w+=,.@-]+$`)
+)
+
+// ValidateRoleName returns an error if the given name cannot be used for an
+// IAM role, either because it uses a prefix reserved by AWS or because it
+// does not match the IAM naming rules.
+func ValidateRoleName(name string) error {
+	if name == "" {
+		return microerror.Maskf(invalidRoleNameError, "role name must not be empty")
+	}
+	if len(name) > maxRoleNameLength {
+		return microerror.Maskf(invalidRoleNameError, "role name %q is longer than %d characters", name, maxRoleNameLength)
+	}
+	for _, prefix := range reservedRoleNamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return microerror.Maskf(invalidRoleNameError, "role name %q uses reserved prefix %q", name, prefix)
+		}
+	}
+	if !roleNameRegexp.MatchString(name) {
+		return microerror.Maskf(invalidRoleNameError, "role name %q must match %s", name, roleNameRegexp.String())
+	}
+
+	return nil
+}
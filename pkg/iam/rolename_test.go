@@ -0,0 +1,40 @@
+package iam_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+)
+
+var _ = Describe("ValidateRoleName", func() {
+	DescribeTable("valid role names",
+		func(name string) {
+			Expect(iam.ValidateRoleName(name)).To(Succeed())
+		},
+		Entry("control plane role", "test-cluster-control-plane-role"),
+		Entry("IRSA role", "test-cluster-Route53Manager-Role"),
+		Entry("all allowed special characters", "role_+=,.@-name"),
+		Entry("aws not as prefix", "my-aws-role"),
+		Entry("maximum length", strings.Repeat("a", 64)),
+	)
+
+	DescribeTable("invalid role names",
+		func(name string) {
+			err := iam.ValidateRoleName(name)
+			Expect(err).To(HaveOccurred())
+			Expect(iam.IsInvalidRoleName(err)).To(BeTrue())
+		},
+		Entry("empty name", ""),
+		Entry("reserved lower case aws- prefix", "aws-test-cluster"),
+		Entry("reserved upper case AWS- prefix", "AWS-test-cluster"),
+		Entry("reserved AmazonCSM prefix", "AmazonCSMTestRole"),
+		Entry("space", "test cluster"),
+		Entry("slash", "test/cluster"),
+		Entry("colon", "test:cluster"),
+		Entry("non-ASCII character", "test-clüster"),
+		Entry("too long", strings.Repeat("a", 65)),
+	)
+})
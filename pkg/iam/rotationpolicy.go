@@ -0,0 +1,147 @@
+package iam
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+const secretsRotationTrustIdentityPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Service": "lambda.amazonaws.com"
+      },
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}
+`
+
+// secretsRotationRolePolicyTemplate follows the execution role of the rotation
+// functions provided by AWS. Secrets can only be changed if they name the
+// rotation Lambda in their AllowRotationLambdaArn resource tag, which Secrets
+// Manager sets when rotation is configured.
+const secretsRotationRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "secretsmanager:DescribeSecret",
+        "secretsmanager:GetSecretValue",
+        "secretsmanager:PutSecretValue",
+        "secretsmanager:UpdateSecretVersionStage"
+      ],
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {
+          "secretsmanager:resource/AllowRotationLambdaArn": "{{ .LambdaARN }}"
+        }
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Action": "secretsmanager:GetRandomPassword",
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "rds:DescribeDBClusters",
+        "rds:DescribeDBInstances"
+      ],
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ec2:CreateNetworkInterface",
+        "ec2:DeleteNetworkInterface",
+        "ec2:DescribeNetworkInterfaces"
+      ],
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "logs:CreateLogGroup",
+        "logs:CreateLogStream",
+        "logs:PutLogEvents"
+      ],
+      "Resource": [
+        "{{ .LogGroupARN }}",
+        "{{ .LogGroupARN }}:*"
+      ]
+    }
+  ]
+}
+`
+
+// SecretsRotationRoleParams are the parameters of the inline policy template
+// of the secrets rotation role.
+type SecretsRotationRoleParams struct {
+	LambdaARN string
+	// LogGroupARN is the ARN of the log group the Lambda function logs to.
+	LogGroupARN string
+}
+
+// ValidateRotationLambdaARN returns an error if lambdaARN is not the ARN of a
+// Lambda function.
+func ValidateRotationLambdaARN(lambdaARN string) error {
+	_, err := rotationLambdaFunctionName(lambdaARN)
+	return err
+}
+
+func rotationLambdaFunctionName(lambdaARN string) (string, error) {
+	parsed, err := arn.Parse(lambdaARN)
+	if err != nil {
+		return "", err
+	}
+	// The resource is function:<name> with an optional :<qualifier>.
+	resource := strings.Split(parsed.Resource, ":")
+	if parsed.Service != "lambda" || len(resource) < 2 || resource[0] != "function" || resource[1] == "" {
+		return "", fmt.Errorf("ARN %q is not a Lambda function ARN", lambdaARN)
+	}
+	return resource[1], nil
+}
+
+// ReconcileSecretsRotationRole makes sure the execution role of the given
+// Secrets Manager rotation Lambda function exists.
+func (s *IAMService) ReconcileSecretsRotationRole(lambdaARN string) error {
+	s.log.Info("reconciling secrets rotation IAM role")
+
+	functionName, err := rotationLambdaFunctionName(lambdaARN)
+	if err != nil {
+		return err
+	}
+	parsed, _ := arn.Parse(lambdaARN)
+
+	params := SecretsRotationRoleParams{
+		LambdaARN:   lambdaARN,
+		LogGroupARN: fmt.Sprintf("arn:%s:logs:%s:%s:log-group:/aws/lambda/%s", parsed.Partition, parsed.Region, parsed.AccountID, functionName),
+	}
+
+	err = s.reconcileRole(roleName(SecretsRotationRole, s.clusterName), SecretsRotationRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling secrets rotation IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteSecretsRotationRole() error {
+	s.log.Info("deleting secrets rotation IAM resources")
+
+	err := s.deleteRole(roleName(SecretsRotationRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting secrets rotation IAM resources")
+	return nil
+}
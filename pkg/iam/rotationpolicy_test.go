@@ -0,0 +1,116 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("SecretsRotationRole", func() {
+
+	const lambdaARN = "arn:aws:lambda:eu-west-1:012345678901:function:test-cluster-rotation"
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockIAMClient  *mocks.MockIAMAPI
+		iamService     *iam.IAMService
+		trustPolicy    string
+		policyDocument string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	When("the role does not exist", func() {
+		BeforeEach(func() {
+			mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+				RoleName: aws.String("test-cluster-SecretsRotation-Role"),
+			}).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().CreateRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateRoleInput) (*awsIAM.CreateRoleOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-SecretsRotation-Role"))
+				trustPolicy = *input.AssumeRolePolicyDocument
+				return &awsIAM.CreateRoleOutput{}, nil
+			})
+			mockIAMClient.EXPECT().CreateInstanceProfile(gomock.Any()).Return(&awsIAM.CreateInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().AddRoleToInstanceProfile(gomock.Any()).Return(&awsIAM.AddRoleToInstanceProfileOutput{}, nil)
+			mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+			mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+				Expect(*input.RoleName).To(Equal("test-cluster-SecretsRotation-Role"))
+				policyDocument = *input.PolicyDocument
+				return &awsIAM.PutRolePolicyOutput{}, nil
+			})
+		})
+
+		It("is trusted by Lambda", func() {
+			err := iamService.ReconcileSecretsRotationRole(lambdaARN)
+			Expect(err).To(BeNil())
+
+			var policy struct {
+				Statement []struct {
+					Effect    string
+					Principal struct {
+						Service string
+					}
+					Action string
+				}
+			}
+			Expect(json.Unmarshal([]byte(trustPolicy), &policy)).To(Succeed())
+			Expect(policy.Statement).To(HaveLen(1))
+			Expect(policy.Statement[0].Effect).To(Equal("Allow"))
+			Expect(policy.Statement[0].Principal.Service).To(Equal("lambda.amazonaws.com"))
+			Expect(policy.Statement[0].Action).To(Equal("sts:AssumeRole"))
+		})
+
+		It("only allows changing secrets rotated by the Lambda function", func() {
+			err := iamService.ReconcileSecretsRotationRole(lambdaARN)
+			Expect(err).To(BeNil())
+
+			Expect(policyDocument).To(ContainSubstring(`"secretsmanager:resource/AllowRotationLambdaArn": "` + lambdaARN + `"`))
+			Expect(policyDocument).To(ContainSubstring(`"secretsmanager:PutSecretValue"`))
+			Expect(policyDocument).To(ContainSubstring(`"rds:DescribeDBInstances"`))
+			Expect(policyDocument).To(ContainSubstring(`"arn:aws:logs:eu-west-1:012345678901:log-group:/aws/lambda/test-cluster-rotation"`))
+		})
+	})
+
+	It("deletes the role", func() {
+		mockIAMClient.EXPECT().ListAttachedRolePolicies(gomock.Any()).Return(&awsIAM.ListAttachedRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().ListRolePolicies(gomock.Any()).Return(&awsIAM.ListRolePoliciesOutput{}, nil)
+		mockIAMClient.EXPECT().RemoveRoleFromInstanceProfile(gomock.Any()).Return(&awsIAM.RemoveRoleFromInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteInstanceProfile(gomock.Any()).Return(&awsIAM.DeleteInstanceProfileOutput{}, nil)
+		mockIAMClient.EXPECT().DeleteRole(&awsIAM.DeleteRoleInput{
+			RoleName: aws.String("test-cluster-SecretsRotation-Role"),
+		}).Return(&awsIAM.DeleteRoleOutput{}, nil)
+
+		err := iamService.DeleteSecretsRotationRole()
+		Expect(err).To(BeNil())
+	})
+
+	DescribeTable("rejects ARNs which are not Lambda function ARNs",
+		func(lambdaARN string) {
+			Expect(iam.ValidateRotationLambdaARN(lambdaARN)).NotTo(Succeed())
+		},
+		Entry("not an ARN", "test-cluster-rotation"),
+		Entry("other service", "arn:aws:sqs:eu-west-1:012345678901:test-cluster-rotation"),
+		Entry("Lambda layer", "arn:aws:lambda:eu-west-1:012345678901:layer:test:1"),
+	)
+
+	It("accepts qualified Lambda function ARNs", func() {
+		Expect(iam.ValidateRotationLambdaARN(lambdaARN + ":live")).To(Succeed())
+	})
+})
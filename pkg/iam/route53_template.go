@@ -72,7 +72,11 @@ const route53RolePolicyTemplate = `{
     {
       "Action": "route53:ChangeResourceRecordSets",
       "Resource": [
+        {{- if .HostedZoneIDs }}{{ range $i, $id := .HostedZoneIDs }}{{ if $i }},{{ end }}
+        "arn:*:route53:::hostedzone/{{ $id }}"
+        {{- end }}{{ else }}
         "arn:*:route53:::hostedzone/*"
+        {{- end }}
       ],
       "Effect": "Allow"
     },
@@ -102,7 +106,13 @@ const route53RolePolicyTemplateForCertManager = `{
         "route53:ChangeResourceRecordSets",
         "route53:ListResourceRecordSets"
       ],
-      "Resource": "arn:*:route53:::hostedzone/*"
+      "Resource": [
+        {{- if .HostedZoneIDs }}{{ range $i, $id := .HostedZoneIDs }}{{ if $i }},{{ end }}
+        "arn:*:route53:::hostedzone/{{ $id }}"
+        {{- end }}{{ else }}
+        "arn:*:route53:::hostedzone/*"
+        {{- end }}
+      ]
     },
     {
       "Effect": "Allow",
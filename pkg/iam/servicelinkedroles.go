@@ -0,0 +1,49 @@
+package iam
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+)
+
+// serviceLinkedRoleServices are the AWS services whose service-linked roles
+// are needed by the VPCs, NAT gateways, load balancers and auto scaling groups
+// created by CAPA. They are missing in new accounts until the services are
+// used for the first time.
+var serviceLinkedRoleServices = []string{
+	"autoscaling.amazonaws.com",
+	"elasticloadbalancing.amazonaws.com",
+	"transitgateway.amazonaws.com",
+}
+
+// ServiceLinkedRoleServices returns the AWS services of the service-linked
+// roles created by CreateServiceLinkedRoles.
+func ServiceLinkedRoleServices() []string {
+	return serviceLinkedRoleServices
+}
+
+// CreateServiceLinkedRoles makes sure the service-linked roles needed by CAPA
+// exist in the cluster account. Service-linked roles are shared by all
+// clusters of an account and managed by AWS, so they are never deleted.
+func (s *IAMService) CreateServiceLinkedRoles() error {
+	s.log.Info("creating service-linked IAM roles")
+
+	for _, service := range serviceLinkedRoleServices {
+		l := s.log.WithValues("service", service)
+
+		_, err := s.iamClient.CreateServiceLinkedRole(&awsiam.CreateServiceLinkedRoleInput{
+			AWSServiceName: aws.String(service),
+		})
+		if IsServiceLinkedRoleTaken(err) {
+			l.Info("service-linked IAM role already exists, skipping creation")
+			continue
+		}
+		if err != nil {
+			l.Error(err, "failed to create service-linked IAM role")
+			return err
+		}
+		l.Info("created service-linked IAM role")
+	}
+
+	s.log.Info("finished creating service-linked IAM roles")
+	return nil
+}
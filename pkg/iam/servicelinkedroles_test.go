@@ -0,0 +1,74 @@
+package iam_test
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("ServiceLinkedRoles", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockIAMClient *mocks.MockIAMAPI
+		iamService    *iam.IAMService
+		createdRoles  []string
+		createErr     error
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		createdRoles = nil
+		createErr = nil
+		mockIAMClient.EXPECT().CreateServiceLinkedRole(gomock.Any()).DoAndReturn(func(input *awsIAM.CreateServiceLinkedRoleInput) (*awsIAM.CreateServiceLinkedRoleOutput, error) {
+			if createErr != nil {
+				return nil, createErr
+			}
+			createdRoles = append(createdRoles, *input.AWSServiceName)
+			return &awsIAM.CreateServiceLinkedRoleOutput{}, nil
+		}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("creates the service-linked roles", func() {
+		err := iamService.CreateServiceLinkedRoles()
+		Expect(err).To(BeNil())
+
+		Expect(createdRoles).To(Equal(iam.ServiceLinkedRoleServices()))
+		Expect(createdRoles).To(ContainElement("elasticloadbalancing.amazonaws.com"))
+	})
+
+	It("tolerates roles which already exist", func() {
+		createErr = awserr.New(awsIAM.ErrCodeInvalidInputException, "Service role name AWSServiceRoleForElasticLoadBalancing has been taken in this account, please try a different suffix.", nil)
+
+		err := iamService.CreateServiceLinkedRoles()
+		Expect(err).To(BeNil())
+	})
+
+	It("fails on other invalid input", func() {
+		createErr = awserr.New(awsIAM.ErrCodeInvalidInputException, "Invalid service name", nil)
+
+		err := iamService.CreateServiceLinkedRoles()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails on other errors", func() {
+		createErr = errors.New("AWS is unreachable")
+
+		err := iamService.CreateServiceLinkedRoles()
+		Expect(err).To(MatchError("AWS is unreachable"))
+	})
+})
@@ -0,0 +1,346 @@
+package iam
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/identitystore"
+	"github.com/aws/aws-sdk-go/service/identitystore/identitystoreiface"
+	"github.com/aws/aws-sdk-go/service/ssoadmin"
+	"github.com/aws/aws-sdk-go/service/ssoadmin/ssoadminiface"
+	"github.com/giantswarm/microerror"
+)
+
+// maxPermissionSetNameLength is the maximum length of the name of an IAM
+// Identity Center permission set.
+const maxPermissionSetNameLength = 32
+
+const ssoAdminPermissionSetPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "eks:*",
+        "ec2:Describe*",
+        "iam:Get*"
+      ],
+      "Resource": "*"
+    }
+  ]
+}
+`
+
+// SSOAdminClientFactory creates an IAM Identity Center admin client for the
+// given session and region.
+type SSOAdminClientFactory func(awsclientgo.ConfigProvider, string) ssoadminiface.SSOAdminAPI
+
+// IdentityStoreClientFactory creates an IAM Identity Center identity store
+// client for the given session and region.
+type IdentityStoreClientFactory func(awsclientgo.ConfigProvider, string) identitystoreiface.IdentityStoreAPI
+
+// SSOAdminPermissionSetName returns the name of the IAM Identity Center
+// permission set granting cluster admin access.
+func SSOAdminPermissionSetName(clusterName string) string {
+	return fmt.Sprintf("%s-ClusterAdmin", clusterName)
+}
+
+// ReconcileSSOAdminPermissionSet creates the cluster admin permission set of
+// the cluster in IAM Identity Center and assigns it to the given group in the
+// given account. It returns the ARN of the permission set, which should be
+// passed as knownARN to later calls, so that the permission set does not have
+// to be looked up by name.
+func (s *IAMService) ReconcileSSOAdminPermissionSet(groupID, accountID, knownARN string) (string, error) {
+	permissionSetName := SSOAdminPermissionSetName(s.clusterName)
+	l := s.log.WithValues("permission_set_name", permissionSetName, "group_id", groupID, "account_id", accountID)
+	l.Info("reconciling IAM Identity Center permission set")
+
+	if len(permissionSetName) > maxPermissionSetNameLength {
+		return "", microerror.Maskf(invalidClusterError, "permission set name %q is longer than %d characters", permissionSetName, maxPermissionSetNameLength)
+	}
+
+	instance, err := s.ssoInstance()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.identityStoreClient.DescribeGroup(&identitystore.DescribeGroupInput{
+		IdentityStoreId: instance.IdentityStoreId,
+		GroupId:         aws.String(groupID),
+	})
+	if err != nil {
+		l.Error(err, "failed to describe IAM Identity Center group")
+		return "", microerror.Mask(err)
+	}
+
+	permissionSetARN, err := s.lookupPermissionSet(*instance.InstanceArn, permissionSetName, knownARN)
+	if err != nil {
+		return "", err
+	}
+
+	created := false
+	if permissionSetARN == "" {
+		o, err := s.ssoAdminClient.CreatePermissionSet(&ssoadmin.CreatePermissionSetInput{
+			InstanceArn: instance.InstanceArn,
+			Name:        aws.String(permissionSetName),
+			Description: aws.String(fmt.Sprintf("Cluster admin access to cluster %s", s.clusterName)),
+			Tags: []*ssoadmin.Tag{
+				{
+					Key:   aws.String(s.ownershipTagKey),
+					Value: aws.String(s.ownershipTagValue),
+				},
+				{
+					Key:   aws.String(fmt.Sprintf(ClusterIDTag, s.clusterName)),
+					Value: aws.String("owned"),
+				},
+			},
+		})
+		if err != nil {
+			l.Error(err, "failed to create IAM Identity Center permission set")
+			return "", microerror.Mask(err)
+		}
+		if s.dryRun {
+			// the policy and assignments of a permission set which was not
+			// created cannot be compared
+			return "", nil
+		}
+		permissionSetARN = *o.PermissionSet.PermissionSetArn
+		created = true
+		l.Info("created IAM Identity Center permission set")
+	}
+
+	policyChanged, err := s.putPermissionSetPolicy(*instance.InstanceArn, permissionSetARN)
+	if err != nil {
+		return "", err
+	}
+
+	// Assigned accounts only receive changes of existing permission sets once
+	// they are provisioned again.
+	if policyChanged && !created {
+		_, err = s.ssoAdminClient.ProvisionPermissionSet(&ssoadmin.ProvisionPermissionSetInput{
+			InstanceArn:      instance.InstanceArn,
+			PermissionSetArn: aws.String(permissionSetARN),
+			TargetType:       aws.String(ssoadmin.ProvisionTargetTypeAllProvisionedAccounts),
+		})
+		if err != nil {
+			l.Error(err, "failed to provision IAM Identity Center permission set")
+			return "", microerror.Mask(err)
+		}
+		l.Info("provisioned IAM Identity Center permission set")
+	}
+
+	assigned, err := s.isPermissionSetAssigned(*instance.InstanceArn, permissionSetARN, groupID, accountID)
+	if err != nil {
+		return "", err
+	}
+	if !assigned {
+		_, err = s.ssoAdminClient.CreateAccountAssignment(&ssoadmin.CreateAccountAssignmentInput{
+			InstanceArn:      instance.InstanceArn,
+			PermissionSetArn: aws.String(permissionSetARN),
+			PrincipalId:      aws.String(groupID),
+			PrincipalType:    aws.String(ssoadmin.PrincipalTypeGroup),
+			TargetId:         aws.String(accountID),
+			TargetType:       aws.String(ssoadmin.TargetTypeAwsAccount),
+		})
+		if err != nil {
+			l.Error(err, "failed to assign IAM Identity Center permission set")
+			return "", microerror.Mask(err)
+		}
+		l.Info("assigned IAM Identity Center permission set")
+	}
+
+	l.Info("finished reconciling IAM Identity Center permission set")
+	return permissionSetARN, nil
+}
+
+// DeleteSSOAdminPermissionSet removes all group assignments of the cluster
+// admin permission set in the given account and deletes the permission set.
+// knownARN is the ARN returned by ReconcileSSOAdminPermissionSet, if any.
+func (s *IAMService) DeleteSSOAdminPermissionSet(accountID, knownARN string) error {
+	permissionSetName := SSOAdminPermissionSetName(s.clusterName)
+	l := s.log.WithValues("permission_set_name", permissionSetName, "account_id", accountID)
+	l.Info("deleting IAM Identity Center permission set")
+
+	instance, err := s.ssoInstance()
+	if err != nil {
+		return err
+	}
+
+	permissionSetARN, err := s.lookupPermissionSet(*instance.InstanceArn, permissionSetName, knownARN)
+	if err != nil {
+		return err
+	}
+	if permissionSetARN == "" {
+		l.Info("IAM Identity Center permission set does not exist")
+		return nil
+	}
+
+	assignments, err := s.permissionSetAssignments(*instance.InstanceArn, permissionSetARN, accountID)
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		_, err = s.ssoAdminClient.DeleteAccountAssignment(&ssoadmin.DeleteAccountAssignmentInput{
+			InstanceArn:      instance.InstanceArn,
+			PermissionSetArn: aws.String(permissionSetARN),
+			PrincipalId:      assignment.PrincipalId,
+			PrincipalType:    assignment.PrincipalType,
+			TargetId:         aws.String(accountID),
+			TargetType:       aws.String(ssoadmin.TargetTypeAwsAccount),
+		})
+		if err != nil {
+			l.Error(err, "failed to delete IAM Identity Center account assignment", "principal_id", aws.StringValue(assignment.PrincipalId))
+			return microerror.Mask(err)
+		}
+	}
+
+	// The deletion fails with a conflict while account assignments are still
+	// being deleted, the next reconciliation retries it.
+	_, err = s.ssoAdminClient.DeletePermissionSet(&ssoadmin.DeletePermissionSetInput{
+		InstanceArn:      instance.InstanceArn,
+		PermissionSetArn: aws.String(permissionSetARN),
+	})
+	if err != nil && !isSSOAdminNotFound(err) {
+		l.Error(err, "failed to delete IAM Identity Center permission set")
+		return microerror.Mask(err)
+	}
+
+	l.Info("finished deleting IAM Identity Center permission set")
+	return nil
+}
+
+// ssoInstance returns the IAM Identity Center instance of the account.
+func (s *IAMService) ssoInstance() (*ssoadmin.InstanceMetadata, error) {
+	o, err := s.ssoAdminClient.ListInstances(&ssoadmin.ListInstancesInput{})
+	if err != nil {
+		s.log.Error(err, "failed to list IAM Identity Center instances")
+		return nil, microerror.Mask(err)
+	}
+	if len(o.Instances) == 0 {
+		return nil, microerror.Maskf(invalidClusterError, "no IAM Identity Center instance found for cluster %s", s.clusterName)
+	}
+
+	return o.Instances[0], nil
+}
+
+// lookupPermissionSet returns the ARN of the permission set with the given
+// name or an empty string if it does not exist. The known ARN is verified with
+// a single call. All permission sets of the instance are only searched when it
+// is empty or outdated.
+func (s *IAMService) lookupPermissionSet(instanceARN, name, knownARN string) (string, error) {
+	if knownARN != "" {
+		o, err := s.ssoAdminClient.DescribePermissionSet(&ssoadmin.DescribePermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: aws.String(knownARN),
+		})
+		if err != nil && !isSSOAdminNotFound(err) {
+			s.log.Error(err, "failed to describe IAM Identity Center permission set", "permission_set_arn", knownARN)
+			return "", microerror.Mask(err)
+		}
+		if err == nil && aws.StringValue(o.PermissionSet.Name) == name {
+			return knownARN, nil
+		}
+	}
+
+	return s.findPermissionSet(instanceARN, name)
+}
+
+// findPermissionSet returns the ARN of the permission set with the given name
+// or an empty string if it does not exist.
+func (s *IAMService) findPermissionSet(instanceARN, name string) (string, error) {
+	var permissionSetARNs []*string
+	err := s.ssoAdminClient.ListPermissionSetsPages(&ssoadmin.ListPermissionSetsInput{
+		InstanceArn: aws.String(instanceARN),
+	}, func(page *ssoadmin.ListPermissionSetsOutput, lastPage bool) bool {
+		permissionSetARNs = append(permissionSetARNs, page.PermissionSets...)
+		return true
+	})
+	if err != nil {
+		s.log.Error(err, "failed to list IAM Identity Center permission sets")
+		return "", microerror.Mask(err)
+	}
+
+	for _, permissionSetARN := range permissionSetARNs {
+		o, err := s.ssoAdminClient.DescribePermissionSet(&ssoadmin.DescribePermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: permissionSetARN,
+		})
+		if err != nil {
+			s.log.Error(err, "failed to describe IAM Identity Center permission set", "permission_set_arn", aws.StringValue(permissionSetARN))
+			return "", microerror.Mask(err)
+		}
+		if aws.StringValue(o.PermissionSet.Name) == name {
+			return aws.StringValue(permissionSetARN), nil
+		}
+	}
+
+	return "", nil
+}
+
+// putPermissionSetPolicy sets the inline policy of the permission set and
+// returns whether it changed.
+func (s *IAMService) putPermissionSetPolicy(instanceARN, permissionSetARN string) (bool, error) {
+	o, err := s.ssoAdminClient.GetInlinePolicyForPermissionSet(&ssoadmin.GetInlinePolicyForPermissionSetInput{
+		InstanceArn:      aws.String(instanceARN),
+		PermissionSetArn: aws.String(permissionSetARN),
+	})
+	if err != nil {
+		s.log.Error(err, "failed to get inline policy of IAM Identity Center permission set")
+		return false, microerror.Mask(err)
+	}
+	if aws.StringValue(o.InlinePolicy) != "" {
+		equal, err := areEqualJSON(*o.InlinePolicy, ssoAdminPermissionSetPolicy)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+		if equal {
+			return false, nil
+		}
+	}
+
+	_, err = s.ssoAdminClient.PutInlinePolicyToPermissionSet(&ssoadmin.PutInlinePolicyToPermissionSetInput{
+		InstanceArn:      aws.String(instanceARN),
+		PermissionSetArn: aws.String(permissionSetARN),
+		InlinePolicy:     aws.String(ssoAdminPermissionSetPolicy),
+	})
+	if err != nil {
+		s.log.Error(err, "failed to put inline policy of IAM Identity Center permission set")
+		return false, microerror.Mask(err)
+	}
+
+	s.log.Info("updated inline policy of IAM Identity Center permission set", "permission_set_arn", permissionSetARN)
+	return true, nil
+}
+
+func (s *IAMService) isPermissionSetAssigned(instanceARN, permissionSetARN, groupID, accountID string) (bool, error) {
+	assignments, err := s.permissionSetAssignments(instanceARN, permissionSetARN, accountID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, assignment := range assignments {
+		if aws.StringValue(assignment.PrincipalType) == ssoadmin.PrincipalTypeGroup && aws.StringValue(assignment.PrincipalId) == groupID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *IAMService) permissionSetAssignments(instanceARN, permissionSetARN, accountID string) ([]*ssoadmin.AccountAssignment, error) {
+	var assignments []*ssoadmin.AccountAssignment
+	err := s.ssoAdminClient.ListAccountAssignmentsPages(&ssoadmin.ListAccountAssignmentsInput{
+		InstanceArn:      aws.String(instanceARN),
+		PermissionSetArn: aws.String(permissionSetARN),
+		AccountId:        aws.String(accountID),
+	}, func(page *ssoadmin.ListAccountAssignmentsOutput, lastPage bool) bool {
+		assignments = append(assignments, page.AccountAssignments...)
+		return true
+	})
+	if err != nil {
+		s.log.Error(err, "failed to list IAM Identity Center account assignments")
+		return nil, microerror.Mask(err)
+	}
+
+	return assignments, nil
+}
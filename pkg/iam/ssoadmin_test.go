@@ -0,0 +1,294 @@
+package iam_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsclientgo "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/identitystore"
+	"github.com/aws/aws-sdk-go/service/identitystore/identitystoreiface"
+	"github.com/aws/aws-sdk-go/service/ssoadmin"
+	"github.com/aws/aws-sdk-go/service/ssoadmin/ssoadminiface"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("SSO admin permission set", func() {
+
+	const (
+		instanceARN      = "arn:aws:sso:::instance/ssoins-1234"
+		identityStoreID  = "d-1234"
+		permissionSetARN = "arn:aws:sso:::permissionSet/ssoins-1234/ps-1234"
+		groupID          = "group-1234"
+		accountID        = "012345678901"
+	)
+
+	var (
+		mockCtrl                *gomock.Controller
+		mockSSOAdminClient      *mocks.MockSSOAdminAPI
+		mockIdentityStoreClient *mocks.MockIdentityStoreAPI
+		iamService              *iam.IAMService
+	)
+
+	expectPermissionSets := func(permissionSets map[string]string) {
+		mockSSOAdminClient.EXPECT().ListPermissionSetsPages(gomock.Any(), gomock.Any()).DoAndReturn(func(input *ssoadmin.ListPermissionSetsInput, fn func(*ssoadmin.ListPermissionSetsOutput, bool) bool) error {
+			Expect(*input.InstanceArn).To(Equal(instanceARN))
+			page := &ssoadmin.ListPermissionSetsOutput{}
+			for arn := range permissionSets {
+				page.PermissionSets = append(page.PermissionSets, aws.String(arn))
+			}
+			fn(page, true)
+			return nil
+		})
+		for arn, name := range permissionSets {
+			mockSSOAdminClient.EXPECT().DescribePermissionSet(&ssoadmin.DescribePermissionSetInput{
+				InstanceArn:      aws.String(instanceARN),
+				PermissionSetArn: aws.String(arn),
+			}).Return(&ssoadmin.DescribePermissionSetOutput{
+				PermissionSet: &ssoadmin.PermissionSet{Name: aws.String(name), PermissionSetArn: aws.String(arn)},
+			}, nil).AnyTimes()
+		}
+	}
+
+	expectAssignments := func(principalIDs ...string) {
+		mockSSOAdminClient.EXPECT().ListAccountAssignmentsPages(gomock.Any(), gomock.Any()).DoAndReturn(func(input *ssoadmin.ListAccountAssignmentsInput, fn func(*ssoadmin.ListAccountAssignmentsOutput, bool) bool) error {
+			Expect(*input.AccountId).To(Equal(accountID))
+			Expect(*input.PermissionSetArn).To(Equal(permissionSetARN))
+			page := &ssoadmin.ListAccountAssignmentsOutput{}
+			for _, principalID := range principalIDs {
+				page.AccountAssignments = append(page.AccountAssignments, &ssoadmin.AccountAssignment{
+					AccountId:        aws.String(accountID),
+					PermissionSetArn: aws.String(permissionSetARN),
+					PrincipalId:      aws.String(principalID),
+					PrincipalType:    aws.String(ssoadmin.PrincipalTypeGroup),
+				})
+			}
+			fn(page, true)
+			return nil
+		})
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockSSOAdminClient = mocks.NewMockSSOAdminAPI(mockCtrl)
+		mockIdentityStoreClient = mocks.NewMockIdentityStoreAPI(mockCtrl)
+
+		iamService = newTestIAMService(mocks.NewMockIAMAPI(mockCtrl), func(c *iam.IAMServiceConfig) {
+			c.SSOAdminClientFactory = func(session awsclientgo.ConfigProvider, region string) ssoadminiface.SSOAdminAPI {
+				return mockSSOAdminClient
+			}
+			c.IdentityStoreClientFactory = func(session awsclientgo.ConfigProvider, region string) identitystoreiface.IdentityStoreAPI {
+				return mockIdentityStoreClient
+			}
+		})
+
+		mockSSOAdminClient.EXPECT().ListInstances(gomock.Any()).Return(&ssoadmin.ListInstancesOutput{
+			Instances: []*ssoadmin.InstanceMetadata{
+				{InstanceArn: aws.String(instanceARN), IdentityStoreId: aws.String(identityStoreID)},
+			},
+		}, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("ReconcileSSOAdminPermissionSet", func() {
+		BeforeEach(func() {
+			mockIdentityStoreClient.EXPECT().DescribeGroup(&identitystore.DescribeGroupInput{
+				IdentityStoreId: aws.String(identityStoreID),
+				GroupId:         aws.String(groupID),
+			}).Return(&identitystore.DescribeGroupOutput{GroupId: aws.String(groupID)}, nil)
+		})
+
+		When("the permission set does not exist", func() {
+			BeforeEach(func() {
+				expectPermissionSets(map[string]string{
+					"arn:aws:sso:::permissionSet/ssoins-1234/ps-other": "other-cluster-ClusterAdmin",
+				})
+				expectAssignments()
+			})
+
+			It("creates the permission set and assigns it to the group", func() {
+				mockSSOAdminClient.EXPECT().CreatePermissionSet(gomock.Any()).DoAndReturn(func(input *ssoadmin.CreatePermissionSetInput) (*ssoadmin.CreatePermissionSetOutput, error) {
+					Expect(*input.InstanceArn).To(Equal(instanceARN))
+					Expect(*input.Name).To(Equal("test-cluster-ClusterAdmin"))
+					return &ssoadmin.CreatePermissionSetOutput{
+						PermissionSet: &ssoadmin.PermissionSet{PermissionSetArn: aws.String(permissionSetARN)},
+					}, nil
+				})
+				mockSSOAdminClient.EXPECT().GetInlinePolicyForPermissionSet(gomock.Any()).Return(&ssoadmin.GetInlinePolicyForPermissionSetOutput{}, nil)
+				mockSSOAdminClient.EXPECT().PutInlinePolicyToPermissionSet(gomock.Any()).DoAndReturn(func(input *ssoadmin.PutInlinePolicyToPermissionSetInput) (*ssoadmin.PutInlinePolicyToPermissionSetOutput, error) {
+					Expect(*input.PermissionSetArn).To(Equal(permissionSetARN))
+					Expect(*input.InlinePolicy).To(MatchJSON(`{
+						"Version": "2012-10-17",
+						"Statement": [{"Effect": "Allow", "Action": ["eks:*", "ec2:Describe*", "iam:Get*"], "Resource": "*"}]
+					}`))
+					return &ssoadmin.PutInlinePolicyToPermissionSetOutput{}, nil
+				})
+				mockSSOAdminClient.EXPECT().CreateAccountAssignment(&ssoadmin.CreateAccountAssignmentInput{
+					InstanceArn:      aws.String(instanceARN),
+					PermissionSetArn: aws.String(permissionSetARN),
+					PrincipalId:      aws.String(groupID),
+					PrincipalType:    aws.String(ssoadmin.PrincipalTypeGroup),
+					TargetId:         aws.String(accountID),
+					TargetType:       aws.String(ssoadmin.TargetTypeAwsAccount),
+				}).Return(&ssoadmin.CreateAccountAssignmentOutput{}, nil)
+
+				Expect(iamService.ReconcileSSOAdminPermissionSet(groupID, accountID, "")).To(Equal(permissionSetARN))
+			})
+		})
+
+		When("the permission set exists with an outdated policy", func() {
+			BeforeEach(func() {
+				expectPermissionSets(map[string]string{permissionSetARN: "test-cluster-ClusterAdmin"})
+				expectAssignments(groupID)
+			})
+
+			It("updates the policy and provisions the permission set", func() {
+				mockSSOAdminClient.EXPECT().GetInlinePolicyForPermissionSet(gomock.Any()).Return(&ssoadmin.GetInlinePolicyForPermissionSetOutput{
+					InlinePolicy: aws.String(`{"Version": "2012-10-17", "Statement": []}`),
+				}, nil)
+				mockSSOAdminClient.EXPECT().PutInlinePolicyToPermissionSet(gomock.Any()).Return(&ssoadmin.PutInlinePolicyToPermissionSetOutput{}, nil)
+				mockSSOAdminClient.EXPECT().ProvisionPermissionSet(&ssoadmin.ProvisionPermissionSetInput{
+					InstanceArn:      aws.String(instanceARN),
+					PermissionSetArn: aws.String(permissionSetARN),
+					TargetType:       aws.String(ssoadmin.ProvisionTargetTypeAllProvisionedAccounts),
+				}).Return(&ssoadmin.ProvisionPermissionSetOutput{}, nil)
+
+				Expect(iamService.ReconcileSSOAdminPermissionSet(groupID, accountID, "")).To(Equal(permissionSetARN))
+			})
+		})
+
+		When("the permission set is up to date", func() {
+			BeforeEach(func() {
+				expectPermissionSets(map[string]string{permissionSetARN: "test-cluster-ClusterAdmin"})
+				expectAssignments(groupID)
+			})
+
+			It("does not change anything", func() {
+				mockSSOAdminClient.EXPECT().GetInlinePolicyForPermissionSet(gomock.Any()).Return(&ssoadmin.GetInlinePolicyForPermissionSetOutput{
+					InlinePolicy: aws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["eks:*","ec2:Describe*","iam:Get*"],"Resource":"*"}]}`),
+				}, nil)
+
+				Expect(iamService.ReconcileSSOAdminPermissionSet(groupID, accountID, "")).To(Equal(permissionSetARN))
+			})
+		})
+	})
+
+	Describe("ReconcileSSOAdminPermissionSet with a known permission set ARN", func() {
+		BeforeEach(func() {
+			mockIdentityStoreClient.EXPECT().DescribeGroup(gomock.Any()).Return(&identitystore.DescribeGroupOutput{GroupId: aws.String(groupID)}, nil)
+		})
+
+		It("does not list the permission sets", func() {
+			mockSSOAdminClient.EXPECT().DescribePermissionSet(&ssoadmin.DescribePermissionSetInput{
+				InstanceArn:      aws.String(instanceARN),
+				PermissionSetArn: aws.String(permissionSetARN),
+			}).Return(&ssoadmin.DescribePermissionSetOutput{
+				PermissionSet: &ssoadmin.PermissionSet{Name: aws.String("test-cluster-ClusterAdmin"), PermissionSetArn: aws.String(permissionSetARN)},
+			}, nil)
+			mockSSOAdminClient.EXPECT().GetInlinePolicyForPermissionSet(gomock.Any()).Return(&ssoadmin.GetInlinePolicyForPermissionSetOutput{
+				InlinePolicy: aws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["eks:*","ec2:Describe*","iam:Get*"],"Resource":"*"}]}`),
+			}, nil)
+			expectAssignments(groupID)
+
+			Expect(iamService.ReconcileSSOAdminPermissionSet(groupID, accountID, permissionSetARN)).To(Equal(permissionSetARN))
+		})
+
+		It("looks the permission set up by name when the ARN is outdated", func() {
+			const deletedARN = "arn:aws:sso:::permissionSet/ssoins-1234/ps-deleted"
+			mockSSOAdminClient.EXPECT().DescribePermissionSet(&ssoadmin.DescribePermissionSetInput{
+				InstanceArn:      aws.String(instanceARN),
+				PermissionSetArn: aws.String(deletedARN),
+			}).Return(nil, awserr.New(ssoadmin.ErrCodeResourceNotFoundException, "test", nil))
+			expectPermissionSets(map[string]string{permissionSetARN: "test-cluster-ClusterAdmin"})
+			mockSSOAdminClient.EXPECT().GetInlinePolicyForPermissionSet(gomock.Any()).Return(&ssoadmin.GetInlinePolicyForPermissionSetOutput{
+				InlinePolicy: aws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["eks:*","ec2:Describe*","iam:Get*"],"Resource":"*"}]}`),
+			}, nil)
+			expectAssignments(groupID)
+
+			Expect(iamService.ReconcileSSOAdminPermissionSet(groupID, accountID, deletedARN)).To(Equal(permissionSetARN))
+		})
+	})
+
+	Describe("ReconcileSSOAdminPermissionSet with an unknown group", func() {
+		It("returns an error", func() {
+			mockIdentityStoreClient.EXPECT().DescribeGroup(gomock.Any()).Return(nil, awserr.New(identitystore.ErrCodeResourceNotFoundException, "test", nil))
+
+			_, err := iamService.ReconcileSSOAdminPermissionSet(groupID, accountID, "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("IAMServiceConfig", func() {
+		It("creates the Identity Center clients with the Identity Center session and region", func() {
+			// drain the expectation of the outer BeforeEach
+			_, err := mockSSOAdminClient.ListInstances(&ssoadmin.ListInstancesInput{})
+			Expect(err).NotTo(HaveOccurred())
+
+			clusterSession, err := session.NewSession(&aws.Config{Region: aws.String("eu-west-1")})
+			Expect(err).NotTo(HaveOccurred())
+			ssoSession, err := session.NewSession(&aws.Config{Region: aws.String("us-east-1")})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = iam.New(iam.IAMServiceConfig{
+				ClusterName:     "test-cluster",
+				MainRoleName:    "test-role",
+				Region:          "eu-west-1",
+				RoleType:        "control-plane",
+				Log:             ctrl.Log,
+				AWSSession:      clusterSession,
+				SSOAdminSession: ssoSession,
+				SSOAdminRegion:  "us-east-1",
+				IAMClientFactory: func(session awsclientgo.ConfigProvider, region string) iamiface.IAMAPI {
+					return mocks.NewMockIAMAPI(mockCtrl)
+				},
+				SSOAdminClientFactory: func(session awsclientgo.ConfigProvider, region string) ssoadminiface.SSOAdminAPI {
+					Expect(session).To(BeIdenticalTo(ssoSession))
+					Expect(region).To(Equal("us-east-1"))
+					return mockSSOAdminClient
+				},
+				IdentityStoreClientFactory: func(session awsclientgo.ConfigProvider, region string) identitystoreiface.IdentityStoreAPI {
+					Expect(session).To(BeIdenticalTo(ssoSession))
+					Expect(region).To(Equal("us-east-1"))
+					return mockIdentityStoreClient
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("DeleteSSOAdminPermissionSet", func() {
+		It("deletes the assignments and the permission set", func() {
+			expectPermissionSets(map[string]string{permissionSetARN: "test-cluster-ClusterAdmin"})
+			expectAssignments(groupID)
+			mockSSOAdminClient.EXPECT().DeleteAccountAssignment(&ssoadmin.DeleteAccountAssignmentInput{
+				InstanceArn:      aws.String(instanceARN),
+				PermissionSetArn: aws.String(permissionSetARN),
+				PrincipalId:      aws.String(groupID),
+				PrincipalType:    aws.String(ssoadmin.PrincipalTypeGroup),
+				TargetId:         aws.String(accountID),
+				TargetType:       aws.String(ssoadmin.TargetTypeAwsAccount),
+			}).Return(&ssoadmin.DeleteAccountAssignmentOutput{}, nil)
+			mockSSOAdminClient.EXPECT().DeletePermissionSet(&ssoadmin.DeletePermissionSetInput{
+				InstanceArn:      aws.String(instanceARN),
+				PermissionSetArn: aws.String(permissionSetARN),
+			}).Return(&ssoadmin.DeletePermissionSetOutput{}, nil)
+
+			Expect(iamService.DeleteSSOAdminPermissionSet(accountID, "")).To(Succeed())
+		})
+
+		It("does nothing when the permission set does not exist", func() {
+			expectPermissionSets(map[string]string{})
+
+			Expect(iamService.DeleteSSOAdminPermissionSet(accountID, "")).To(Succeed())
+		})
+	})
+})
@@ -2,8 +2,9 @@ package iam
 
 import (
 	"bytes"
-	"strings"
 	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 )
 
 func generatePolicyDocument(t string, params interface{}) (string, error) {
@@ -21,30 +22,33 @@ func generatePolicyDocument(t string, params interface{}) (string, error) {
 	return buf.String(), nil
 }
 
-func ec2ServiceDomain(region string) string {
-	domain := "ec2.amazonaws.com"
+// generateTrustPolicyDocument renders the trust policy of the role type and
+// adds the extra conditions of the IRSA role parameters to its statements.
+func generateTrustPolicyDocument(roleType string, params interface{}) (string, error) {
+	document, err := generatePolicyDocument(getTrustPolicyTemplate(roleType), params)
+	if err != nil {
+		return "", err
+	}
 
-	if isChinaRegion(region) {
-		domain += ".cn"
+	p, ok := params.(interface{ trustPolicyConditions() []TrustPolicyCondition })
+	if !ok || len(p.trustPolicyConditions()) == 0 {
+		return document, nil
 	}
 
-	return domain
+	return addTrustPolicyConditions(document, p.trustPolicyConditions())
 }
 
-func awsDomain(region string) string {
-	domain := "aws"
+// ec2ServiceDomain returns the service principal of EC2 in the partition.
+func ec2ServiceDomain(partition string) string {
+	domain := "ec2.amazonaws.com"
 
-	if isChinaRegion(region) {
-		domain = "aws-cn"
+	if partition == endpoints.AwsCnPartitionID {
+		domain += ".cn"
 	}
 
 	return domain
 }
 
-func isChinaRegion(region string) bool {
-	return strings.Contains(region, "cn-")
-}
-
 func getInlinePolicyTemplate(roleType string) string {
 	switch roleType {
 	case BastionRole:
@@ -69,6 +73,20 @@ func getInlinePolicyTemplate(roleType string) string {
 		return EFSCSIDriverPolicyTemplate
 	case ClusterAutoscalerRole:
 		return clusterAutoscalerPolicyTemplate
+	case AMPRole:
+		return ampRolePolicyTemplate
+	case LoggingRole:
+		return loggingRolePolicyTemplate
+	case XRayRole:
+		return xrayRolePolicyTemplate
+	case SecretsRotationRole:
+		return secretsRotationRolePolicyTemplate
+	case KarpenterRole:
+		return karpenterRolePolicyTemplate
+	case GatewayAPIRole:
+		return gatewayAPIRolePolicyTemplate
+	case ReadOnlyRole:
+		return readOnlyRolePolicyTemplate
 	default:
 		return ""
 	}
@@ -98,6 +116,22 @@ func getTrustPolicyTemplate(roleType string) string {
 		return trustIdentityPolicyIRSA
 	case ClusterAutoscalerRole:
 		return trustIdentityPolicyIRSA
+	case BackupRole:
+		return backupTrustIdentityPolicy
+	case AMPRole:
+		return trustIdentityPolicyIRSA
+	case LoggingRole:
+		return trustIdentityPolicyIRSA
+	case XRayRole:
+		return trustIdentityPolicyIRSA
+	case SecretsRotationRole:
+		return secretsRotationTrustIdentityPolicy
+	case KarpenterRole:
+		return trustIdentityPolicyIRSA
+	case GatewayAPIRole:
+		return trustIdentityPolicyIRSA
+	case ReadOnlyRole:
+		return readOnlyTrustIdentityPolicyTemplate
 
 	default:
 		return ""
@@ -0,0 +1,61 @@
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TrustPolicyCondition is a condition added to the statements of the trust
+// policies of the IRSA roles, e.g. StringEquals on aws:SourceVpc.
+type TrustPolicyCondition struct {
+	Operator string
+	Key      string
+	Values   []string
+}
+
+// addTrustPolicyConditions adds the conditions to every statement of the
+// policy document. Conditions on keys which the statements already have with
+// the same operator are refused, as they would replace the trust of the role
+// instead of restricting it.
+func addTrustPolicyConditions(policyDocument string, conditions []TrustPolicyCondition) (string, error) {
+	var policy map[string]interface{}
+	err := json.Unmarshal([]byte(policyDocument), &policy)
+	if err != nil {
+		return "", err
+	}
+
+	statements, ok := policy["Statement"].([]interface{})
+	if !ok {
+		return "", fmt.Errorf("policy document has no list of statements")
+	}
+
+	for _, s := range statements {
+		statement, ok := s.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("policy document has a statement which is not an object")
+		}
+		condition, _ := statement["Condition"].(map[string]interface{})
+		if condition == nil {
+			condition = map[string]interface{}{}
+			statement["Condition"] = condition
+		}
+
+		for _, c := range conditions {
+			operator, _ := condition[c.Operator].(map[string]interface{})
+			if operator == nil {
+				operator = map[string]interface{}{}
+				condition[c.Operator] = operator
+			}
+			if _, ok := operator[c.Key]; ok {
+				return "", fmt.Errorf("trust policy already has a %s condition on %s", c.Operator, c.Key)
+			}
+			operator[c.Key] = c.Values
+		}
+	}
+
+	document, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(document), nil
+}
@@ -0,0 +1,78 @@
+package iam
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// oidcProviderDomains returns the domains of the OIDC providers trusted by
+// the statements of a trust policy, e.g. irsa.example.com for
+// arn:aws:iam::012345678901:oidc-provider/irsa.example.com.
+func oidcProviderDomains(policyDocument string) ([]string, error) {
+	var policy struct {
+		Statement []struct {
+			Principal struct {
+				Federated json.RawMessage
+			}
+		}
+	}
+	err := json.Unmarshal([]byte(policyDocument), &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	for _, statement := range policy.Statement {
+		if len(statement.Principal.Federated) == 0 {
+			continue
+		}
+
+		// a principal is either a single ARN or a list of ARNs
+		var arns []string
+		if err := json.Unmarshal(statement.Principal.Federated, &arns); err != nil {
+			var arn string
+			if err := json.Unmarshal(statement.Principal.Federated, &arn); err != nil {
+				return nil, err
+			}
+			arns = []string{arn}
+		}
+
+		for _, arn := range arns {
+			_, domain, found := strings.Cut(arn, ":oidc-provider/")
+			if found && !slices.Contains(domains, domain) {
+				domains = append(domains, domain)
+			}
+		}
+	}
+	return domains, nil
+}
+
+// trustDomainChanges returns the OIDC provider domains which are added to and
+// removed from the URL-encoded current trust policy by the desired one.
+func trustDomainChanges(encodedCurrentPolicy, desiredPolicy string) (added []string, removed []string, err error) {
+	currentPolicy, err := urlDecode(encodedCurrentPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := oidcProviderDomains(currentPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+	desired, err := oidcProviderDomains(desiredPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, domain := range desired {
+		if !slices.Contains(current, domain) {
+			added = append(added, domain)
+		}
+	}
+	for _, domain := range current {
+		if !slices.Contains(desired, domain) {
+			removed = append(removed, domain)
+		}
+	}
+	return added, removed, nil
+}
@@ -0,0 +1,71 @@
+package iam
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultXRayNamespace and DefaultXRayServiceAccount are trusted by the
+	// X-Ray role unless the cluster configures another service account.
+	DefaultXRayNamespace      = "observability"
+	DefaultXRayServiceAccount = "xray-daemon"
+)
+
+// xrayRolePolicyTemplate grants the permissions of the AWS managed
+// AWSXRayDaemonWriteAccess policy. X-Ray does not support resource-level
+// permissions for these actions.
+const xrayRolePolicyTemplate = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "xray:PutTraceSegments",
+        "xray:PutTelemetryRecords",
+        "xray:GetSamplingRules",
+        "xray:GetSamplingTargets",
+        "xray:GetSamplingStatisticSummaries"
+      ],
+      "Resource": "*"
+    }
+  ]
+}
+`
+
+// ReconcileXRayRole makes sure the IRSA role of the X-Ray daemon exists and
+// allows sending traces. It trusts the given service account.
+func (s *IAMService) ReconcileXRayRole(awsAccountID string, irsaTrustDomains []string, namespace, serviceAccount string) error {
+	s.log.Info("reconciling X-Ray IAM role")
+
+	if len(irsaTrustDomains) == 0 {
+		return fmt.Errorf("irsaTrustDomains cannot be empty")
+	}
+	if namespace == "" || serviceAccount == "" {
+		return fmt.Errorf("namespace and serviceAccount cannot be empty")
+	}
+
+	params, err := s.irsaRoleParams(awsAccountID, irsaTrustDomains, namespace, serviceAccount)
+	if err != nil {
+		return err
+	}
+
+	err = s.reconcileRole(roleName(XRayRole, s.clusterName), XRayRole, params)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished reconciling X-Ray IAM role")
+	return nil
+}
+
+func (s *IAMService) DeleteXRayRole() error {
+	s.log.Info("deleting X-Ray IAM resources")
+
+	err := s.deleteRole(roleName(XRayRole, s.clusterName))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("finished deleting X-Ray IAM resources")
+	return nil
+}
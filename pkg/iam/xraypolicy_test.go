@@ -0,0 +1,79 @@
+package iam_test
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsIAM "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/test/mocks"
+)
+
+var _ = Describe("XRayRole", func() {
+
+	var (
+		mockCtrl       *gomock.Controller
+		mockIAMClient  *mocks.MockIAMAPI
+		iamService     *iam.IAMService
+		trustPolicy    string
+		policyDocument string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockIAMClient = mocks.NewMockIAMAPI(mockCtrl)
+
+		iamService = newTestIAMService(mockIAMClient, nil)
+
+		mockIAMClient.EXPECT().GetRole(&awsIAM.GetRoleInput{
+			RoleName: aws.String("test-cluster-XRay-Role"),
+		}).Return(&awsIAM.GetRoleOutput{Role: &awsIAM.Role{}}, nil).Times(2)
+		mockIAMClient.EXPECT().UpdateAssumeRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.UpdateAssumeRolePolicyInput) (*awsIAM.UpdateAssumeRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-XRay-Role"))
+			trustPolicy = *input.PolicyDocument
+			return &awsIAM.UpdateAssumeRolePolicyOutput{}, nil
+		})
+		mockIAMClient.EXPECT().GetRolePolicy(gomock.Any()).Return(nil, awserr.New(awsIAM.ErrCodeNoSuchEntityException, "test", nil))
+		mockIAMClient.EXPECT().PutRolePolicy(gomock.Any()).DoAndReturn(func(input *awsIAM.PutRolePolicyInput) (*awsIAM.PutRolePolicyOutput, error) {
+			Expect(*input.RoleName).To(Equal("test-cluster-XRay-Role"))
+			policyDocument = *input.PolicyDocument
+			return &awsIAM.PutRolePolicyOutput{}, nil
+		})
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("allows sending traces and trusts the service account", func() {
+		err := iamService.ReconcileXRayRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, iam.DefaultXRayNamespace, iam.DefaultXRayServiceAccount)
+		Expect(err).To(BeNil())
+
+		var policy struct {
+			Statement []struct {
+				Effect   string
+				Action   []string
+				Resource string
+			}
+		}
+		Expect(json.Unmarshal([]byte(policyDocument), &policy)).To(Succeed())
+		Expect(policy.Statement).To(HaveLen(1))
+		Expect(policy.Statement[0].Effect).To(Equal("Allow"))
+		Expect(policy.Statement[0].Action).To(ContainElements("xray:PutTraceSegments", "xray:PutTelemetryRecords"))
+		Expect(policy.Statement[0].Resource).To(Equal("*"))
+
+		Expect(trustPolicy).To(ContainSubstring(`"irsa.test.gaws.gigantic.io:sub": "system:serviceaccount:observability:xray-daemon"`))
+	})
+
+	It("trusts a custom service account", func() {
+		err := iamService.ReconcileXRayRole("012345678901", []string{"irsa.test.gaws.gigantic.io"}, "tracing", "xray")
+		Expect(err).To(BeNil())
+
+		Expect(trustPolicy).To(ContainSubstring(`"irsa.test.gaws.gigantic.io:sub": "system:serviceaccount:tracing:xray"`))
+	})
+})
@@ -9,3 +9,12 @@ var clusterValuesConfigMapNotFound = &microerror.Error{
 var baseDomainNotFound = &microerror.Error{
 	Kind: "baseDomainNotFoundError",
 }
+
+var unsupportedIdentityKindError = &microerror.Error{
+	Kind: "unsupportedIdentityKindError",
+}
+
+// IsUnsupportedIdentityKind asserts unsupportedIdentityKindError.
+func IsUnsupportedIdentityKind(err error) bool {
+	return microerror.Cause(err) == unsupportedIdentityKindError
+}
@@ -23,6 +23,114 @@ const (
 	ClusterNameLabel        = "cluster.x-k8s.io/cluster-name"
 	ClusterWatchFilterLabel = "cluster.x-k8s.io/watch-filter"
 	ClusterRole             = "cluster.x-k8s.io/role"
+
+	// LastReconcileSuccessAnnotation holds the RFC3339 timestamp of the last
+	// successful full reconciliation of an object.
+	LastReconcileSuccessAnnotation = "capa-iam-operator.giantswarm.io/last-reconcile-success"
+	// LastReconcileGenerationAnnotation holds the object generation observed
+	// during the last successful full reconciliation.
+	LastReconcileGenerationAnnotation = "capa-iam-operator.giantswarm.io/last-reconcile-generation"
+	// LastReconcileInputsAnnotation holds a fingerprint of the AWSCluster
+	// and IRSAConfig observed during the last successful full reconciliation.
+	LastReconcileInputsAnnotation = "capa-iam-operator.giantswarm.io/last-reconcile-inputs"
+	// LastRoleNameAnnotation holds the name of the IAM role that was last
+	// reconciled for an object.
+	LastRoleNameAnnotation = "capa-iam-operator.giantswarm.io/last-role-name"
+	// RolesToDeleteAnnotation holds a comma-separated list of IAM roles which
+	// are no longer referenced by an object and still need to be deleted.
+	RolesToDeleteAnnotation = "capa-iam-operator.giantswarm.io/roles-to-delete"
+	// ExtraRoleTypesAnnotation holds a comma-separated list of additional role
+	// types for which IAM roles are created alongside the primary role of an
+	// AWSMachineTemplate.
+	ExtraRoleTypesAnnotation = "capa-iam-operator.giantswarm.io/extra-role-types"
+	// LastExtraRoleTypesAnnotation holds the extra role types that were last
+	// reconciled for an AWSMachineTemplate.
+	LastExtraRoleTypesAnnotation = "capa-iam-operator.giantswarm.io/last-extra-role-types"
+	// ExtraPolicyStatementsAnnotation holds a base64-encoded JSON array of IAM
+	// policy statements with the Allow effect which are merged into the inline
+	// policy of the node role of an AWSMachinePool, e.g. for GPU or
+	// high-memory node pools.
+	ExtraPolicyStatementsAnnotation = "capa-iam-operator.giantswarm.io/extra-policy-statements"
+	// AWSClusterNotReadySinceAnnotation holds the RFC3339 timestamp at which
+	// the AWSCluster of an object was first seen not being ready.
+	AWSClusterNotReadySinceAnnotation = "capa-iam-operator.giantswarm.io/awscluster-not-ready-since"
+	// DeprecatedKiamRoleDeletedAnnotation holds the RFC3339 timestamp at which
+	// the deprecated KIAM role of the cluster was deleted.
+	DeprecatedKiamRoleDeletedAnnotation = "capa-iam-operator.giantswarm.io/deprecated-kiam-role-deleted"
+	// LastPolicyDriftCheckAnnotation holds the RFC3339 timestamp of the last
+	// policy drift check of an AWSMachineTemplate.
+	LastPolicyDriftCheckAnnotation = "capa-iam-operator.giantswarm.io/last-policy-drift-check"
+	// SSOAdminPermissionSetARNAnnotation holds the ARN of the IAM Identity
+	// Center permission set of the cluster, so that it does not have to be
+	// looked up by name on every reconciliation.
+	SSOAdminPermissionSetARNAnnotation = "capa-iam-operator.giantswarm.io/sso-admin-permission-set-arn"
+
+	// AMPWorkspaceARNAnnotation holds the ARN of the Amazon Managed Service
+	// for Prometheus workspace the Prometheus of the cluster writes to.
+	AMPWorkspaceARNAnnotation = "capa-iam-operator.giantswarm.io/amp-workspace-arn"
+
+	// LoggingDestinationAnnotation holds the type of the log destination of
+	// the cluster, either cloudwatch or s3.
+	LoggingDestinationAnnotation = "capa-iam-operator.giantswarm.io/logging-destination"
+
+	// LoggingTargetAnnotation holds the CloudWatch Logs log group name or the
+	// S3 bucket name the logs of the cluster are forwarded to.
+	LoggingTargetAnnotation = "capa-iam-operator.giantswarm.io/logging-target"
+
+	// XRayNamespaceAnnotation and XRayServiceAccountAnnotation override the
+	// service account of the X-Ray daemon of the cluster, which is trusted by
+	// the X-Ray role.
+	XRayNamespaceAnnotation      = "capa-iam-operator.giantswarm.io/xray-namespace"
+	XRayServiceAccountAnnotation = "capa-iam-operator.giantswarm.io/xray-service-account"
+
+	// RotationLambdaARNAnnotation holds the ARN of the Secrets Manager
+	// rotation Lambda function of the cluster, which assumes the secrets
+	// rotation role.
+	RotationLambdaARNAnnotation = "capa-iam-operator.giantswarm.io/rotation-lambda-arn"
+
+	// KarpenterQueueARNAnnotation holds the ARN of the SQS queue Karpenter
+	// receives interruption events from.
+	KarpenterQueueARNAnnotation = "capa-iam-operator.giantswarm.io/karpenter-queue-arn"
+
+	// KarpenterNodeRoleAnnotation holds the name of the IAM role of the nodes
+	// launched by Karpenter, the only role Karpenter may pass to instances.
+	KarpenterNodeRoleAnnotation = "capa-iam-operator.giantswarm.io/karpenter-node-role"
+
+	// GatewayAPIHostedZoneIDsAnnotation holds the comma separated IDs of the
+	// Route 53 hosted zones the AWS Gateway API controller manages records
+	// in.
+	GatewayAPIHostedZoneIDsAnnotation = "capa-iam-operator.giantswarm.io/gateway-api-hosted-zone-ids"
+
+	// ClusterReadinessTimeoutCondition is set to true on an AWSCluster which
+	// did not become ready within the configured timeout. Reconciliation of the
+	// cluster's templates resumes once the condition is removed.
+	ClusterReadinessTimeoutCondition capi.ConditionType = "ClusterReadinessTimeout"
+
+	// ClusterInfrastructureReadyCondition is set to false on an AWSCluster
+	// while the templates of the cluster wait for the infrastructure of the
+	// CAPI Cluster to be ready. It is removed once the infrastructure is ready.
+	ClusterInfrastructureReadyCondition capi.ConditionType = "ClusterInfrastructureReady"
+
+	// PolicyDriftDetectedCondition is set to true on an AWSCluster when the
+	// IAM policy simulator denies actions the IAM roles of the cluster are
+	// expected to be allowed, e.g. because of a changed SCP. It is removed
+	// once all simulated actions are allowed again.
+	PolicyDriftDetectedCondition capi.ConditionType = "PolicyDriftDetected"
+
+	// IAMRoleReadyCondition reports whether the IAM roles were reconciled
+	// successfully. It is set on the AWSCluster for the control plane role,
+	// on the AWSMachinePool for its nodes role and on the
+	// AWSManagedControlPlane for the IRSA roles, and removed once the roles
+	// are deleted.
+	IAMRoleReadyCondition capi.ConditionType = "IAMRoleReady"
+
+	// IAMRoleReconcileErrorReason is the reason of a false
+	// IAMRoleReadyCondition.
+	IAMRoleReconcileErrorReason = "ReconcileError"
+
+	// InvalidRoleNameReason is the reason of a false IAMRoleReadyCondition
+	// when a role name is rejected by the IAM naming rules.
+	InvalidRoleNameReason = "InvalidRoleName"
 )
 
 func FinalizerName(roleName string) string {
@@ -68,11 +176,22 @@ func GetAWSClusterByName(ctx context.Context, ctrlClient client.Client, clusterN
 	return &awsClusterList.Items[0], nil
 }
 
-func GetAWSClusterRoleIdentity(ctx context.Context, ctrlClient client.Client, awsClusterRoleIdentityName string) (*capa.AWSClusterRoleIdentity, error) {
+// GetAWSClusterRoleIdentity returns the AWSClusterRoleIdentity referenced by
+// identityRef. It returns nil for an AWSClusterControllerIdentity or a nil
+// reference, in which case the controller uses its own credentials instead of
+// assuming a role. Other identity kinds are not supported.
+func GetAWSClusterRoleIdentity(ctx context.Context, ctrlClient client.Client, identityRef *capa.AWSIdentityReference) (*capa.AWSClusterRoleIdentity, error) {
+	if identityRef == nil || identityRef.Kind == capa.ControllerIdentityKind {
+		return nil, nil
+	}
+	if identityRef.Kind != capa.ClusterRoleIdentityKind {
+		return nil, microerror.Maskf(unsupportedIdentityKindError, "identity %q has unsupported kind %q, use %s or %s", identityRef.Name, identityRef.Kind, capa.ClusterRoleIdentityKind, capa.ControllerIdentityKind)
+	}
+
 	awsClusterRoleIdentity := &capa.AWSClusterRoleIdentity{}
 
 	if err := ctrlClient.Get(ctx, types.NamespacedName{
-		Name:      awsClusterRoleIdentityName,
+		Name:      identityRef.Name,
 		Namespace: "",
 	}, awsClusterRoleIdentity); err != nil {
 		return nil, err
@@ -81,6 +200,15 @@ func GetAWSClusterRoleIdentity(ctx context.Context, ctrlClient client.Client, aw
 	return awsClusterRoleIdentity, nil
 }
 
+// GetIdentityRoleARN returns the role ARN of the AWSClusterRoleIdentity, or an
+// empty string for the controller's own credentials.
+func GetIdentityRoleARN(awsClusterRoleIdentity *capa.AWSClusterRoleIdentity) string {
+	if awsClusterRoleIdentity == nil {
+		return ""
+	}
+	return awsClusterRoleIdentity.Spec.RoleArn
+}
+
 func HasCapiWatchLabel(labels map[string]string) bool {
 	value, ok := labels[ClusterWatchFilterLabel]
 	if ok {
@@ -164,17 +292,27 @@ func GetAWSAccountID(awsClusterRoleIdentity *capa.AWSClusterRoleIdentity) (strin
 	return a.AccountID, nil
 }
 
-func GetIRSATrustDomains(awsMachineTemplate *capa.AWSMachineTemplate, awsCluster *capa.AWSCluster, ensurePrimaryIRSATrustDomain string) []string {
+// GetIRSATrustDomains returns the primary IRSA trust domain followed by the
+// additional domains of the cluster, i.e. the AWSCluster or the
+// AWSManagedControlPlane, or of the object reconciling the IRSA roles if the
+// cluster has none.
+func GetIRSATrustDomains(obj v1.Object, cluster v1.Object, ensurePrimaryIRSATrustDomain string) []string {
 	var values []string
-	if s := GetAnnotation(awsCluster, "aws.giantswarm.io/irsa-trust-domains"); s != "" {
+	if s := GetAnnotation(cluster, "aws.giantswarm.io/irsa-trust-domains"); s != "" {
 		values = strings.Split(s, ",")
-	} else if s = GetAnnotation(awsMachineTemplate, "aws.giantswarm.io/irsa-additional-domain"); s != "" {
+	} else if s = GetAnnotation(obj, "aws.giantswarm.io/irsa-additional-domain"); s != "" {
 		// Fall back to previously-used, singular annotation for backward compatibility
 		values = append(values, s)
 	}
 
-	irsaTrustDomains := []string{ensurePrimaryIRSATrustDomain}
-	for _, value := range values {
+	return MergeIRSATrustDomains(ensurePrimaryIRSATrustDomain, values)
+}
+
+// MergeIRSATrustDomains returns the primary IRSA trust domain followed by the
+// given additional domains, skipping empty and duplicate values.
+func MergeIRSATrustDomains(primaryIRSATrustDomain string, additionalDomains []string) []string {
+	irsaTrustDomains := []string{primaryIRSATrustDomain}
+	for _, value := range additionalDomains {
 		value = strings.TrimSpace(value)
 		if value != "" && !slices.Contains(irsaTrustDomains, value) {
 			irsaTrustDomains = append(irsaTrustDomains, value)
@@ -195,3 +333,60 @@ func GetAnnotation(o v1.Object, annotation string) string {
 func IsChinaRegion(region string) bool {
 	return strings.Contains(region, "cn-")
 }
+
+// ValidateRoleName returns an error if the given name cannot be used for an
+// IAM role, either because it uses a prefix reserved by AWS or because it
+// does not match the IAM naming rules. The error can be asserted with
+// iam.IsInvalidRoleName.
+func ValidateRoleName(name string) error {
+	return iam.ValidateRoleName(name)
+}
+
+// GetRolesToDelete returns the IAM role names listed in the roles-to-delete
+// annotation.
+func GetRolesToDelete(o v1.Object) []string {
+	return getAnnotationList(o, RolesToDeleteAnnotation)
+}
+
+// GetExtraRoleTypes returns the role types listed in the extra-role-types
+// annotation.
+func GetExtraRoleTypes(o v1.Object) []string {
+	return getAnnotationList(o, ExtraRoleTypesAnnotation)
+}
+
+// GetLastExtraRoleTypes returns the role types listed in the
+// last-extra-role-types annotation.
+func GetLastExtraRoleTypes(o v1.Object) []string {
+	return getAnnotationList(o, LastExtraRoleTypesAnnotation)
+}
+
+// getAnnotationList returns the unique, non-empty values of a comma-separated
+// annotation.
+func getAnnotationList(o v1.Object, annotation string) []string {
+	var values []string
+	for _, value := range strings.Split(GetAnnotation(o, annotation), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" && !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// IRSARoleARNAnnotation returns the annotation key under which the ARN of the
+// IRSA role of the given type is published on the AWSCluster.
+func IRSARoleARNAnnotation(roleType string) string {
+	return fmt.Sprintf("capa-iam-operator.giantswarm.io/irsa-%s-arn", strings.ToLower(roleType))
+}
+
+// IsIRSARoleARNAnnotation returns true if the annotation key is one returned
+// by IRSARoleARNAnnotation.
+func IsIRSARoleARNAnnotation(annotation string) bool {
+	return strings.HasPrefix(annotation, "capa-iam-operator.giantswarm.io/irsa-") && strings.HasSuffix(annotation, "-arn")
+}
+
+// IAMStatusConfigMapName returns the name of the ConfigMap holding the IAM
+// reconciliation state of the cluster.
+func IAMStatusConfigMapName(clusterName string) string {
+	return fmt.Sprintf("%s-iam-status", clusterName)
+}
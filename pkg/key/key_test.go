@@ -0,0 +1,409 @@
+package key_test
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+)
+
+var _ = Describe("ValidateRoleName", func() {
+	It("accepts valid role names", func() {
+		Expect(key.ValidateRoleName("test-cluster-control-plane-role")).To(Succeed())
+	})
+
+	It("rejects role names using a reserved prefix", func() {
+		err := key.ValidateRoleName("aws-test-cluster")
+		Expect(err).To(HaveOccurred())
+		Expect(iam.IsInvalidRoleName(err)).To(BeTrue())
+	})
+
+	It("rejects role names with invalid characters", func() {
+		err := key.ValidateRoleName("test/cluster")
+		Expect(err).To(HaveOccurred())
+		Expect(iam.IsInvalidRoleName(err)).To(BeTrue())
+	})
+})
+
+var _ = Describe("IRSARoleARNAnnotation", func() {
+	It("uses the lowercased role type", func() {
+		Expect(key.IRSARoleARNAnnotation("ALBController-Role")).To(Equal("capa-iam-operator.giantswarm.io/irsa-albcontroller-role-arn"))
+		Expect(key.IRSARoleARNAnnotation("route53-role")).To(Equal("capa-iam-operator.giantswarm.io/irsa-route53-role-arn"))
+	})
+})
+
+var _ = Describe("IsIRSARoleARNAnnotation", func() {
+	It("matches the annotations returned by IRSARoleARNAnnotation", func() {
+		Expect(key.IsIRSARoleARNAnnotation(key.IRSARoleARNAnnotation("ALBController-Role"))).To(BeTrue())
+		Expect(key.IsIRSARoleARNAnnotation(key.KarpenterQueueARNAnnotation)).To(BeFalse())
+		Expect(key.IsIRSARoleARNAnnotation(key.LastReconcileSuccessAnnotation)).To(BeFalse())
+	})
+})
+
+var _ = Describe("IAMStatusConfigMapName", func() {
+	It("suffixes the cluster name", func() {
+		Expect(key.IAMStatusConfigMapName("test-cluster")).To(Equal("test-cluster-iam-status"))
+	})
+})
+
+var _ = Describe("FinalizerName", func() {
+	DescribeTable("prefixes the role name",
+		func(roleName, expected string) {
+			Expect(key.FinalizerName(roleName)).To(Equal(expected))
+		},
+		Entry("control plane role", "control-plane", "capa-iam-operator.finalizers.giantswarm.io/control-plane"),
+		Entry("empty role name", "", "capa-iam-operator.finalizers.giantswarm.io/"),
+		Entry("special characters", "role_+=,.@-name", "capa-iam-operator.finalizers.giantswarm.io/role_+=,.@-name"),
+		Entry("long role name", strings.Repeat("a", 100), "capa-iam-operator.finalizers.giantswarm.io/"+strings.Repeat("a", 100)),
+	)
+})
+
+var _ = Describe("GetClusterIDFromLabels", func() {
+	DescribeTable("returns the cluster name label",
+		func(labels map[string]string, expected string) {
+			clusterID, err := key.GetClusterIDFromLabels(metav1.ObjectMeta{Labels: labels})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(clusterID).To(Equal(expected))
+		},
+		Entry("cluster name only", map[string]string{key.ClusterNameLabel: "test-cluster"}, "test-cluster"),
+		Entry("other labels", map[string]string{key.ClusterNameLabel: "test-cluster", key.ClusterRole: "control-plane"}, "test-cluster"),
+		Entry("long cluster name", map[string]string{key.ClusterNameLabel: strings.Repeat("a", 63)}, strings.Repeat("a", 63)),
+		Entry("special characters", map[string]string{key.ClusterNameLabel: "test_cluster.1"}, "test_cluster.1"),
+	)
+
+	DescribeTable("fails without cluster name label",
+		func(labels map[string]string) {
+			_, err := key.GetClusterIDFromLabels(metav1.ObjectMeta{Labels: labels})
+			Expect(err).To(MatchError(ContainSubstring(key.ClusterNameLabel)))
+		},
+		Entry("nil labels", nil),
+		Entry("empty labels", map[string]string{}),
+		Entry("missing label", map[string]string{key.ClusterRole: "control-plane"}),
+		Entry("empty label value", map[string]string{key.ClusterNameLabel: ""}),
+	)
+})
+
+var _ = Describe("label predicates", func() {
+	DescribeTable("HasCapiWatchLabel",
+		func(labels map[string]string, expected bool) {
+			Expect(key.HasCapiWatchLabel(labels)).To(Equal(expected))
+		},
+		Entry("capi watch filter", map[string]string{key.ClusterWatchFilterLabel: "capi"}, true),
+		Entry("other watch filter", map[string]string{key.ClusterWatchFilterLabel: "vintage"}, false),
+		Entry("upper case value", map[string]string{key.ClusterWatchFilterLabel: "CAPI"}, false),
+		Entry("empty value", map[string]string{key.ClusterWatchFilterLabel: ""}, false),
+		Entry("missing label", map[string]string{key.ClusterNameLabel: "test-cluster"}, false),
+		Entry("nil labels", nil, false),
+	)
+
+	DescribeTable("IsControlPlaneAWSMachineTemplate",
+		func(labels map[string]string, expected bool) {
+			Expect(key.IsControlPlaneAWSMachineTemplate(labels)).To(Equal(expected))
+		},
+		Entry("control plane role", map[string]string{key.ClusterRole: iam.ControlPlaneRole}, true),
+		Entry("bastion role", map[string]string{key.ClusterRole: iam.BastionRole}, false),
+		Entry("empty value", map[string]string{key.ClusterRole: ""}, false),
+		Entry("missing label", map[string]string{key.ClusterNameLabel: "test-cluster"}, false),
+		Entry("nil labels", nil, false),
+	)
+
+	DescribeTable("IsBastionAWSMachineTemplate",
+		func(labels map[string]string, expected bool) {
+			Expect(key.IsBastionAWSMachineTemplate(labels)).To(Equal(expected))
+		},
+		Entry("bastion role", map[string]string{key.ClusterRole: iam.BastionRole}, true),
+		Entry("control plane role", map[string]string{key.ClusterRole: iam.ControlPlaneRole}, false),
+		Entry("empty value", map[string]string{key.ClusterRole: ""}, false),
+		Entry("missing label", map[string]string{key.ClusterNameLabel: "test-cluster"}, false),
+		Entry("nil labels", nil, false),
+	)
+})
+
+var _ = Describe("IRSADomain", func() {
+	DescribeTable("returns the IRSA domain",
+		func(baseDomain, region, awsAccount, clusterName, expected string) {
+			Expect(key.IRSADomain(baseDomain, region, awsAccount, clusterName)).To(Equal(expected))
+		},
+		Entry("global region", "test.gaws.gigantic.io", "eu-west-1", "012345678901", "test-cluster", "irsa.test.gaws.gigantic.io"),
+		Entry("china region", "test.gaws.gigantic.io", "cn-north-1", "012345678901", "test-cluster", "s3.cn-north-1.amazonaws.com.cn/012345678901-g8s-test-cluster-oidc-pod-identity-v3"),
+		Entry("empty base domain", "", "eu-west-1", "012345678901", "test-cluster", "irsa."),
+		Entry("empty china inputs", "", "cn-northwest-1", "", "", "s3.cn-northwest-1.amazonaws.com.cn/-g8s--oidc-pod-identity-v3"),
+		Entry("long base domain", strings.Repeat("a", 60)+".gigantic.io", "us-east-1", "012345678901", "test-cluster", "irsa."+strings.Repeat("a", 60)+".gigantic.io"),
+	)
+})
+
+var _ = Describe("IsChinaRegion", func() {
+	DescribeTable("detects china regions",
+		func(region string, expected bool) {
+			Expect(key.IsChinaRegion(region)).To(Equal(expected))
+		},
+		Entry("cn-north-1", "cn-north-1", true),
+		Entry("cn-northwest-1", "cn-northwest-1", true),
+		Entry("eu-west-1", "eu-west-1", false),
+		Entry("empty region", "", false),
+	)
+})
+
+var _ = Describe("GetAWSAccountID", func() {
+	DescribeTable("parses the role ARN",
+		func(arn string, expected string) {
+			accountID, err := key.GetAWSAccountID(&capa.AWSClusterRoleIdentity{
+				Spec: capa.AWSClusterRoleIdentitySpec{AWSRoleSpec: capa.AWSRoleSpec{RoleArn: arn}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accountID).To(Equal(expected))
+		},
+		Entry("aws partition", "arn:aws:iam::012345678901:role/capa-controller", "012345678901"),
+		Entry("china partition", "arn:aws-cn:iam::123456789012:role/capa-controller", "123456789012"),
+		Entry("role path", "arn:aws:iam::012345678901:role/giantswarm/capa-controller", "012345678901"),
+	)
+
+	DescribeTable("fails for invalid ARNs",
+		func(arn string) {
+			_, err := key.GetAWSAccountID(&capa.AWSClusterRoleIdentity{
+				Spec: capa.AWSClusterRoleIdentitySpec{AWSRoleSpec: capa.AWSRoleSpec{RoleArn: arn}},
+			})
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("empty ARN", ""),
+		Entry("not an ARN", "capa-controller"),
+		Entry("too few sections", "arn:aws:iam::012345678901"),
+	)
+})
+
+var _ = Describe("GetAnnotation", func() {
+	DescribeTable("returns the annotation value",
+		func(annotations map[string]string, expected string) {
+			Expect(key.GetAnnotation(&metav1.ObjectMeta{Annotations: annotations}, "example.com/test")).To(Equal(expected))
+		},
+		Entry("present", map[string]string{"example.com/test": "value"}, "value"),
+		Entry("special characters", map[string]string{"example.com/test": "a,b; c=d"}, "a,b; c=d"),
+		Entry("missing", map[string]string{"example.com/other": "value"}, ""),
+		Entry("nil annotations", nil, ""),
+	)
+})
+
+var _ = Describe("GetRolesToDelete", func() {
+	DescribeTable("parses the annotation",
+		func(value string, expected []string) {
+			object := &metav1.ObjectMeta{Annotations: map[string]string{key.RolesToDeleteAnnotation: value}}
+			Expect(key.GetRolesToDelete(object)).To(Equal(expected))
+		},
+		Entry("single role", "role-a", []string{"role-a"}),
+		Entry("multiple roles", "role-a,role-b", []string{"role-a", "role-b"}),
+		Entry("whitespace", " role-a , role-b ", []string{"role-a", "role-b"}),
+		Entry("duplicates", "role-a,role-a", []string{"role-a"}),
+		Entry("empty entries", ",role-a,,", []string{"role-a"}),
+		Entry("empty value", "", nil),
+	)
+})
+
+var _ = Describe("GetExtraRoleTypes", func() {
+	DescribeTable("parses the annotation",
+		func(annotations map[string]string, expected []string) {
+			Expect(key.GetExtraRoleTypes(&metav1.ObjectMeta{Annotations: annotations})).To(Equal(expected))
+		},
+		Entry("single role type", map[string]string{key.ExtraRoleTypesAnnotation: "nodes"}, []string{"nodes"}),
+		Entry("multiple role types", map[string]string{key.ExtraRoleTypesAnnotation: "nodes, bastion"}, []string{"nodes", "bastion"}),
+		Entry("duplicates", map[string]string{key.ExtraRoleTypesAnnotation: "nodes,nodes"}, []string{"nodes"}),
+		Entry("empty value", map[string]string{key.ExtraRoleTypesAnnotation: ""}, nil),
+		Entry("missing annotation", nil, nil),
+	)
+})
+
+var _ = Describe("GetIRSATrustDomains", func() {
+	DescribeTable("returns the primary domain first",
+		func(clusterAnnotations, templateAnnotations map[string]string, expected []string) {
+			awsCluster := &capa.AWSCluster{ObjectMeta: metav1.ObjectMeta{Annotations: clusterAnnotations}}
+			awsMachineTemplate := &capa.AWSMachineTemplate{ObjectMeta: metav1.ObjectMeta{Annotations: templateAnnotations}}
+			Expect(key.GetIRSATrustDomains(awsMachineTemplate, awsCluster, "irsa.primary.example.com")).To(Equal(expected))
+		},
+		Entry("no annotations", nil, nil, []string{"irsa.primary.example.com"}),
+		Entry("trust domains on cluster",
+			map[string]string{"aws.giantswarm.io/irsa-trust-domains": "irsa.a.example.com, irsa.b.example.com"}, nil,
+			[]string{"irsa.primary.example.com", "irsa.a.example.com", "irsa.b.example.com"}),
+		Entry("duplicates and empty entries",
+			map[string]string{"aws.giantswarm.io/irsa-trust-domains": "irsa.primary.example.com,,irsa.a.example.com,irsa.a.example.com"}, nil,
+			[]string{"irsa.primary.example.com", "irsa.a.example.com"}),
+		Entry("deprecated template annotation",
+			nil, map[string]string{"aws.giantswarm.io/irsa-additional-domain": "irsa.old.example.com"},
+			[]string{"irsa.primary.example.com", "irsa.old.example.com"}),
+		Entry("cluster annotation takes precedence",
+			map[string]string{"aws.giantswarm.io/irsa-trust-domains": "irsa.a.example.com"},
+			map[string]string{"aws.giantswarm.io/irsa-additional-domain": "irsa.old.example.com"},
+			[]string{"irsa.primary.example.com", "irsa.a.example.com"}),
+	)
+})
+
+var _ = Describe("MergeIRSATrustDomains", func() {
+	It("returns the primary domain first and skips duplicate and empty domains", func() {
+		Expect(key.MergeIRSATrustDomains("irsa.primary.example.com", []string{" irsa.a.example.com", "", "irsa.primary.example.com", "irsa.a.example.com"})).To(Equal([]string{"irsa.primary.example.com", "irsa.a.example.com"}))
+	})
+})
+
+var _ = Describe("client lookups", func() {
+	var (
+		ctx        context.Context
+		ctrlClient client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		Expect(capa.AddToScheme(scheme)).To(Succeed())
+		Expect(capi.AddToScheme(scheme)).To(Succeed())
+
+		ctrlClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "org-test"},
+			},
+			&capa.AWSCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "org-test",
+					Labels:    map[string]string{key.ClusterNameLabel: "test-cluster"},
+				},
+			},
+			&capa.AWSCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "duplicate-a",
+					Namespace: "org-test",
+					Labels:    map[string]string{key.ClusterNameLabel: "duplicate"},
+				},
+			},
+			&capa.AWSCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "duplicate-b",
+					Namespace: "org-test",
+					Labels:    map[string]string{key.ClusterNameLabel: "duplicate"},
+				},
+			},
+			&capa.AWSClusterRoleIdentity{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-cluster-values", Namespace: "org-test"},
+				Data:       map[string]string{"values": "baseDomain: test.gaws.gigantic.io\n"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "no-values-cluster-values", Namespace: "org-test"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "no-base-domain-cluster-values", Namespace: "org-test"},
+				Data:       map[string]string{"values": "provider: capa\n"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "invalid-cluster-values", Namespace: "org-test"},
+				Data:       map[string]string{"values": "baseDomain: [\n"},
+			},
+		).Build()
+	})
+
+	Describe("GetClusterByName", func() {
+		It("returns the cluster", func() {
+			cluster, err := key.GetClusterByName(ctx, ctrlClient, "test-cluster", "org-test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cluster.Name).To(Equal("test-cluster"))
+		})
+
+		It("fails for a missing cluster", func() {
+			_, err := key.GetClusterByName(ctx, ctrlClient, "test-cluster", "other")
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("GetAWSClusterByName", func() {
+		It("returns the AWSCluster with the cluster name label", func() {
+			awsCluster, err := key.GetAWSClusterByName(ctx, ctrlClient, "test-cluster", "org-test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(awsCluster.Name).To(Equal("test-cluster"))
+		})
+
+		DescribeTable("fails unless exactly one AWSCluster matches",
+			func(clusterName, namespace, expectedError string) {
+				_, err := key.GetAWSClusterByName(ctx, ctrlClient, clusterName, namespace)
+				Expect(err).To(MatchError(expectedError))
+			},
+			Entry("other namespace", "test-cluster", "other", "expected 1 AWSCluster but found 0"),
+			Entry("unknown cluster", "unknown", "org-test", "expected 1 AWSCluster but found 0"),
+			Entry("empty cluster name", "", "org-test", "expected 1 AWSCluster but found 0"),
+			Entry("multiple AWSClusters", "duplicate", "org-test", "expected 1 AWSCluster but found 2"),
+		)
+	})
+
+	Describe("GetAWSClusterRoleIdentity", func() {
+		It("returns the AWSClusterRoleIdentity", func() {
+			identity, err := key.GetAWSClusterRoleIdentity(ctx, ctrlClient, &capa.AWSIdentityReference{Name: "default", Kind: capa.ClusterRoleIdentityKind})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(identity.Name).To(Equal("default"))
+		})
+
+		It("fails for a missing AWSClusterRoleIdentity", func() {
+			_, err := key.GetAWSClusterRoleIdentity(ctx, ctrlClient, &capa.AWSIdentityReference{Name: "unknown", Kind: capa.ClusterRoleIdentityKind})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("returns no identity for an AWSClusterControllerIdentity", func() {
+			identity, err := key.GetAWSClusterRoleIdentity(ctx, ctrlClient, &capa.AWSIdentityReference{Name: "default", Kind: capa.ControllerIdentityKind})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(identity).To(BeNil())
+			Expect(key.GetIdentityRoleARN(identity)).To(BeEmpty())
+		})
+
+		It("returns no identity without an identity reference", func() {
+			identity, err := key.GetAWSClusterRoleIdentity(ctx, ctrlClient, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(identity).To(BeNil())
+		})
+
+		It("fails for an AWSClusterStaticIdentity", func() {
+			_, err := key.GetAWSClusterRoleIdentity(ctx, ctrlClient, &capa.AWSIdentityReference{Name: "static", Kind: capa.ClusterStaticIdentityKind})
+			Expect(key.IsUnsupportedIdentityKind(err)).To(BeTrue())
+		})
+	})
+
+	Describe("GetIdentityRoleARN", func() {
+		It("returns the role ARN of the AWSClusterRoleIdentity", func() {
+			identity := &capa.AWSClusterRoleIdentity{
+				Spec: capa.AWSClusterRoleIdentitySpec{
+					AWSRoleSpec: capa.AWSRoleSpec{RoleArn: "arn:aws:iam::012345678901:role/test"},
+				},
+			}
+			Expect(key.GetIdentityRoleARN(identity)).To(Equal("arn:aws:iam::012345678901:role/test"))
+		})
+	})
+
+	Describe("GetBaseDomain", func() {
+		It("returns the base domain from the cluster values", func() {
+			baseDomain, err := key.GetBaseDomain(ctx, ctrlClient, "test-cluster", "org-test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(baseDomain).To(Equal("test.gaws.gigantic.io"))
+		})
+
+		DescribeTable("fails for missing or invalid cluster values",
+			func(clusterName string) {
+				_, err := key.GetBaseDomain(ctx, ctrlClient, clusterName, "org-test")
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("missing ConfigMap", "unknown"),
+			Entry("empty cluster name", ""),
+			Entry("missing values", "no-values"),
+			Entry("missing base domain", "no-base-domain"),
+			Entry("invalid YAML", "invalid"),
+		)
+	})
+})
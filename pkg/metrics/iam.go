@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// unknownReason is the reason label of errors which are neither AWS API nor
+// Kubernetes API errors.
+const unknownReason = "Unknown"
+
+// RoleReconcileTotal counts the reconciliations of IAM roles by role type and
+// result, which is either ResultSuccess or ResultError.
+var RoleReconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "capa_iam_role_reconcile_total",
+	Help: "Total number of IAM role reconciliations by role type and result.",
+}, []string{"role_type", "result"})
+
+// AWSAPIDuration observes the duration of AWS API calls including retries.
+var AWSAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "capa_iam_aws_api_duration_seconds",
+	Help:    "Duration of AWS API calls including retries by operation.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation"})
+
+// AWSAPIThrottlesTotal counts the attempts of AWS API calls which were
+// throttled and retried.
+var AWSAPIThrottlesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "capa_iam_aws_api_throttles_total",
+	Help: "Total number of throttled AWS API call attempts by operation.",
+}, []string{"operation"})
+
+// ReconcileErrorsTotal counts the reconciliations of the reconcilers wrapped
+// by WrapReconciler which returned an error, by the reason of the error.
+var ReconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "capa_iam_reconcile_errors_total",
+	Help: "Total number of failed reconciliations by controller and reason.",
+}, []string{"controller", "reason"})
+
+// ManagedRoles is the number of IAM roles managed for AWSMachineTemplates by
+// role type.
+var ManagedRoles = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "capa_iam_managed_roles_total",
+	Help: "Number of IAM roles managed for AWSMachineTemplates by role type.",
+}, []string{"role_type"})
+
+// AuditEventsDroppedTotal counts the audit events which were dropped because
+// the buffer of a sink was full, by sink.
+var AuditEventsDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "capa_iam_audit_events_dropped_total",
+	Help: "Total number of audit events dropped because the buffer of the sink was full, by sink.",
+}, []string{"sink"})
+
+func init() {
+	metrics.Registry.MustRegister(RoleReconcileTotal, AWSAPIDuration, AWSAPIThrottlesTotal, ReconcileErrorsTotal, ManagedRoles, AuditEventsDroppedTotal)
+}
+
+// ErrorReason returns the error code of AWS API errors and the status reason
+// of Kubernetes API errors, so that alerts can tell e.g. missing permissions
+// from throttling. It returns Unknown for all other errors.
+func ErrorReason(err error) string {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code()
+	}
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	return unknownReason
+}
@@ -0,0 +1,26 @@
+package metrics_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+var _ = Describe("ErrorReason", func() {
+	DescribeTable("returns the reason of the error",
+		func(err error, expectedReason string) {
+			Expect(metrics.ErrorReason(err)).To(Equal(expectedReason))
+		},
+		Entry("AWS API error", awserr.New("AccessDenied", "test", nil), "AccessDenied"),
+		Entry("wrapped AWS API error", fmt.Errorf("failed to create role: %w", awserr.New("Throttling", "test", nil)), "Throttling"),
+		Entry("Kubernetes API error", apierrors.NewConflict(schema.GroupResource{Resource: "awsclusters"}, "test", errors.New("test")), "Conflict"),
+		Entry("other error", errors.New("test"), "Unknown"),
+	)
+})
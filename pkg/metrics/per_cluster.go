@@ -0,0 +1,91 @@
+// Package metrics contains the Prometheus metrics of the operator, which are
+// served by the metrics endpoint of the controller-runtime manager.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultRequeue = "requeue"
+	ResultError   = "error"
+
+	// unknownCluster is the cluster label of reconciliations of objects which
+	// do not exist anymore or have no cluster name label.
+	unknownCluster = "unknown"
+)
+
+// ReconcileDuration observes the duration of every reconciliation of the
+// reconcilers wrapped by WrapReconciler.
+var ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "capa_iam_reconcile_duration_seconds",
+	Help:    "Duration of reconciliations by cluster, controller and result.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"cluster", "controller", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(ReconcileDuration)
+}
+
+// WrapReconciler returns a reconciler which observes the duration of every
+// reconciliation of r in ReconcileDuration and counts its errors in
+// ReconcileErrorsTotal. The cluster label is taken from
+// the cluster name label of the reconciled object, which is fetched with
+// ctrlClient into a new object returned by newObject.
+func WrapReconciler(controllerName string, ctrlClient client.Client, newObject func() client.Object, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		clusterName := unknownCluster
+		obj := newObject()
+		if err := ctrlClient.Get(ctx, req.NamespacedName, obj); err == nil && obj.GetLabels()[capi.ClusterNameLabel] != "" {
+			clusterName = obj.GetLabels()[capi.ClusterNameLabel]
+		}
+
+		start := time.Now()
+		result, err := r.Reconcile(ctx, req)
+		ReconcileDuration.WithLabelValues(clusterName, controllerName, resultLabel(result, err)).Observe(time.Since(start).Seconds())
+		if err != nil {
+			ReconcileErrorsTotal.WithLabelValues(controllerName, ErrorReason(err)).Inc()
+		}
+
+		// objects of a deleted cluster are only reconciled until their
+		// finalizers are removed, so their series would be exported forever
+		if obj.GetDeletionTimestamp() != nil && clusterName != unknownCluster && clusterDeleted(ctx, ctrlClient, obj.GetNamespace(), clusterName) {
+			DeleteClusterSeries(clusterName)
+		}
+
+		return result, err
+	})
+}
+
+// DeleteClusterSeries deletes the ReconcileDuration series of all controllers
+// and results of the cluster.
+func DeleteClusterSeries(clusterName string) {
+	ReconcileDuration.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+}
+
+// clusterDeleted returns true if the CAPI Cluster is gone. Other errors are
+// treated as the cluster still existing.
+func clusterDeleted(ctx context.Context, ctrlClient client.Client, namespace, clusterName string) bool {
+	err := ctrlClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: clusterName}, &capi.Cluster{})
+	return apierrors.IsNotFound(err)
+}
+
+func resultLabel(result reconcile.Result, err error) string {
+	if err != nil {
+		return ResultError
+	}
+	if result.Requeue || result.RequeueAfter > 0 {
+		return ResultRequeue
+	}
+	return ResultSuccess
+}
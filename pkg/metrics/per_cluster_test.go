@@ -0,0 +1,166 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/metrics"
+)
+
+var _ = Describe("WrapReconciler", func() {
+
+	var ctrlClient client.Client
+
+	newAWSMachineTemplate := func() client.Object {
+		return &capa.AWSMachineTemplate{}
+	}
+
+	sampleCount := func(cluster, result string) uint64 {
+		metric := &dto.Metric{}
+		histogram := metrics.ReconcileDuration.WithLabelValues(cluster, "awsmachinetemplate", result).(prometheus.Histogram)
+		Expect(histogram.Write(metric)).To(Succeed())
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(capa.AddToScheme(scheme)).To(Succeed())
+		ctrlClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(&capa.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+				Labels: map[string]string{
+					"cluster.x-k8s.io/cluster-name": "test-cluster",
+				},
+			},
+		}).Build()
+	})
+
+	DescribeTable("observes the reconcile duration by cluster and result",
+		func(name, cluster string, result reconcile.Result, err error, expectedResult string) {
+			before := sampleCount(cluster, expectedResult)
+
+			reconciler := metrics.WrapReconciler("awsmachinetemplate", ctrlClient, newAWSMachineTemplate, reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return result, err
+			}))
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+			actualResult, actualErr := reconciler.Reconcile(context.Background(), req)
+			Expect(actualResult).To(Equal(result))
+			if err == nil {
+				Expect(actualErr).NotTo(HaveOccurred())
+			} else {
+				Expect(actualErr).To(MatchError(err))
+			}
+
+			Expect(sampleCount(cluster, expectedResult)).To(Equal(before + 1))
+		},
+		Entry("success", "test", "test-cluster", reconcile.Result{}, nil, metrics.ResultSuccess),
+		Entry("requeue", "test", "test-cluster", reconcile.Result{RequeueAfter: time.Minute}, nil, metrics.ResultRequeue),
+		Entry("error", "test", "test-cluster", reconcile.Result{}, errors.New("test error"), metrics.ResultError),
+		Entry("deleted object", "missing", "unknown", reconcile.Result{}, nil, metrics.ResultSuccess),
+	)
+
+	It("counts errors by reason", func() {
+		errorCount := func() float64 {
+			return testutil.ToFloat64(metrics.ReconcileErrorsTotal.WithLabelValues("awsmachinetemplate", "AccessDenied"))
+		}
+		before := errorCount()
+
+		reconciler := metrics.WrapReconciler("awsmachinetemplate", ctrlClient, newAWSMachineTemplate, reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+			return reconcile.Result{}, awserr.New("AccessDenied", "test", nil)
+		}))
+
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test"}}
+		_, err := reconciler.Reconcile(context.Background(), req)
+		Expect(err).To(HaveOccurred())
+
+		Expect(errorCount()).To(Equal(before + 1))
+	})
+
+	When("a terminating object is reconciled", func() {
+		var reconciler reconcile.Reconciler
+
+		seriesCount := func() int {
+			return testutil.CollectAndCount(metrics.ReconcileDuration)
+		}
+
+		newClient := func(objects ...client.Object) client.Client {
+			scheme := runtime.NewScheme()
+			Expect(capa.AddToScheme(scheme)).To(Succeed())
+			Expect(capi.AddToScheme(scheme)).To(Succeed())
+			deletionTimestamp := metav1.Now()
+			objects = append(objects, &capa.AWSMachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "terminating",
+					Namespace:         "default",
+					DeletionTimestamp: &deletionTimestamp,
+					Finalizers:        []string{"test"},
+					Labels: map[string]string{
+						"cluster.x-k8s.io/cluster-name": "deleted-cluster",
+					},
+				},
+			})
+			return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		}
+
+		reconcileTerminating := func() {
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "terminating"}}
+			_, err := reconciler.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			metrics.ReconcileDuration.Reset()
+		})
+
+		It("deletes the series of the cluster once the cluster is gone", func() {
+			reconciler = metrics.WrapReconciler("awsmachinetemplate", newClient(), newAWSMachineTemplate, reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}))
+
+			reconcileTerminating()
+			Expect(seriesCount()).To(BeZero())
+		})
+
+		It("keeps the series while the cluster exists", func() {
+			reconciler = metrics.WrapReconciler("awsmachinetemplate", newClient(&capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "deleted-cluster", Namespace: "default"},
+			}), newAWSMachineTemplate, reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}))
+
+			reconcileTerminating()
+			Expect(seriesCount()).To(Equal(1))
+		})
+	})
+})
+
+var _ = Describe("DeleteClusterSeries", func() {
+	It("only deletes the series of the given cluster", func() {
+		metrics.ReconcileDuration.Reset()
+		metrics.ReconcileDuration.WithLabelValues("deleted-cluster", "awsmachinetemplate", metrics.ResultSuccess).Observe(1)
+		metrics.ReconcileDuration.WithLabelValues("deleted-cluster", "awsmachinepool", metrics.ResultError).Observe(1)
+		metrics.ReconcileDuration.WithLabelValues("other-cluster", "awsmachinetemplate", metrics.ResultSuccess).Observe(1)
+
+		metrics.DeleteClusterSeries("deleted-cluster")
+
+		Expect(testutil.CollectAndCount(metrics.ReconcileDuration)).To(Equal(1))
+	})
+})
@@ -0,0 +1,41 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ReconcilePanicsTotal counts the panics recovered by WrapReconciler.
+var ReconcilePanicsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "capa_iam_reconcile_panics_total",
+	Help: "Total number of panics recovered during reconciliation.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(ReconcilePanicsTotal)
+}
+
+// WrapReconciler returns a reconciler which recovers from panics of r. The
+// panic is logged together with its stack trace and returned as an error, so
+// that the request is retried instead of the manager crashing.
+func WrapReconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (result reconcile.Result, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				ReconcilePanicsTotal.Inc()
+				log.FromContext(ctx).Error(fmt.Errorf("%v", p), "recovered from panic during reconciliation", "stacktrace", string(debug.Stack()))
+
+				result = reconcile.Result{}
+				err = fmt.Errorf("recovered from panic during reconciliation of %s: %v", req.NamespacedName, p)
+			}
+		}()
+
+		return r.Reconcile(ctx, req)
+	})
+}
@@ -0,0 +1,56 @@
+package recovery_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/recovery"
+)
+
+var _ = Describe("WrapReconciler", func() {
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test"}}
+
+	When("the reconciler panics", func() {
+		It("returns an error and increments the panic counter", func() {
+			panicsBefore := testutil.ToFloat64(recovery.ReconcilePanicsTotal)
+
+			reconciler := recovery.WrapReconciler(reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				panic("test panic")
+			}))
+
+			var (
+				result reconcile.Result
+				err    error
+			)
+			Expect(func() {
+				result, err = reconciler.Reconcile(context.Background(), req)
+			}).NotTo(Panic())
+			Expect(err).To(MatchError(ContainSubstring("test panic")))
+			Expect(err).To(MatchError(ContainSubstring("default/test")))
+			Expect(result).To(Equal(reconcile.Result{}))
+			Expect(testutil.ToFloat64(recovery.ReconcilePanicsTotal)).To(Equal(panicsBefore + 1))
+		})
+	})
+
+	When("the reconciler does not panic", func() {
+		It("passes through its result", func() {
+			panicsBefore := testutil.ToFloat64(recovery.ReconcilePanicsTotal)
+
+			reconciler := recovery.WrapReconciler(reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{RequeueAfter: time.Minute}, nil
+			}))
+
+			result, err := reconciler.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+			Expect(testutil.ToFloat64(recovery.ReconcilePanicsTotal)).To(Equal(panicsBefore))
+		})
+	})
+})
@@ -1,5 +1,10 @@
 //go:generate ../../../tools/mockgen -destination aws_iam_mock.go -package mocks github.com/aws/aws-sdk-go/service/iam/iamiface IAMAPI
 //go:generate ../../../tools/mockgen -destination awsclient_mock.go -package mocks -source ../../awsclient/awsclient.go AWSClient
+//go:generate ../../../tools/mockgen -destination configservice_mock.go -package mocks github.com/aws/aws-sdk-go/service/configservice/configserviceiface ConfigServiceAPI
+//go:generate ../../../tools/mockgen -destination cloudwatchlogs_mock.go -package mocks github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface CloudWatchLogsAPI
+//go:generate ../../../tools/mockgen -destination sts_mock.go -package mocks github.com/aws/aws-sdk-go/service/sts/stsiface STSAPI
+//go:generate ../../../tools/mockgen -destination ssoadmin_mock.go -package mocks github.com/aws/aws-sdk-go/service/ssoadmin/ssoadminiface SSOAdminAPI
+//go:generate ../../../tools/mockgen -destination identitystore_mock.go -package mocks github.com/aws/aws-sdk-go/service/identitystore/identitystoreiface IdentityStoreAPI
 //go:generate ../../../tools/mockgen -destination eks_mock.go -package mocks github.com/aws/aws-sdk-go/service/eks/eksiface EKSAPI
 
 package mocks
@@ -0,0 +1,114 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/giantswarm/capa-iam-operator/pkg/iam"
+	"github.com/giantswarm/capa-iam-operator/pkg/key"
+)
+
+// AWSMachineTemplateValidationPath is the path the AWSMachineTemplate
+// validating webhook is served at.
+const AWSMachineTemplateValidationPath = "/validate-infrastructure-cluster-x-k8s-io-v1beta2-awsmachinetemplate"
+
+// +kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta2-awsmachinetemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=awsmachinetemplates,verbs=create;update,versions=v1beta2,name=vawsmachinetemplate.capa-iam-operator.giantswarm.io,admissionReviewVersions=v1
+
+// AWSMachineTemplateValidator rejects AWSMachineTemplates which have an IAM
+// instance profile and the watch filter label of the operator, but whose
+// IAM role would never be created by the AWSMachineTemplateReconciler because
+// their cluster name label is missing or their role label is not supported.
+// Templates without a role label, e.g. the ones of worker nodes whose roles
+// are managed elsewhere, are allowed.
+type AWSMachineTemplateValidator struct {
+	decoder admission.Decoder
+}
+
+func NewAWSMachineTemplateValidator(scheme *runtime.Scheme) *AWSMachineTemplateValidator {
+	return &AWSMachineTemplateValidator{
+		decoder: admission.NewDecoder(scheme),
+	}
+}
+
+// SetupWithManager registers the webhook with the webhook server of the
+// manager.
+func (v *AWSMachineTemplateValidator) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(AWSMachineTemplateValidationPath, &webhook.Admission{Handler: v})
+	return nil
+}
+
+func (v *AWSMachineTemplateValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	awsMachineTemplate := &capa.AWSMachineTemplate{}
+	err := v.decoder.Decode(req, awsMachineTemplate)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	err = validateAWSMachineTemplate(awsMachineTemplate)
+	if err == nil {
+		return admission.Allowed("")
+	}
+
+	if req.Operation == admissionv1.Update {
+		// templates with invalid labels which already exist are ignored by
+		// the operator, so they may still be updated, e.g. to be deleted
+		if awsMachineTemplate.DeletionTimestamp != nil {
+			return admission.Allowed("")
+		}
+
+		oldAWSMachineTemplate := &capa.AWSMachineTemplate{}
+		err := v.decoder.DecodeRaw(req.OldObject, oldAWSMachineTemplate)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if validateAWSMachineTemplate(oldAWSMachineTemplate) != nil {
+			return admission.Allowed("")
+		}
+	}
+
+	return admission.Denied(err.Error())
+}
+
+// validateAWSMachineTemplate returns an error if the AWSMachineTemplate is
+// watched by the operator and has an IAM instance profile, but lacks the
+// cluster name label or has a role label the operator does not support.
+func validateAWSMachineTemplate(awsMachineTemplate *capa.AWSMachineTemplate) error {
+	if !key.HasCapiWatchLabel(awsMachineTemplate.Labels) || awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile == "" {
+		return nil
+	}
+
+	if awsMachineTemplate.Labels[key.ClusterNameLabel] == "" {
+		return fmt.Errorf("label %q is required for the IAM role of instance profile %q", key.ClusterNameLabel, awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile)
+	}
+
+	role, ok := awsMachineTemplate.Labels[key.ClusterRole]
+	if ok && role != iam.ControlPlaneRole && role != iam.BastionRole {
+		return fmt.Errorf("label %q must be %q or %q for the IAM role of instance profile %q, got %q", key.ClusterRole, iam.ControlPlaneRole, iam.BastionRole, awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile, role)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package webhooks_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	capa "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/giantswarm/capa-iam-operator/webhooks"
+)
+
+var _ = Describe("AWSMachineTemplateValidator", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(capa.AddToScheme(scheme)).To(Succeed())
+
+		server = httptest.NewServer(&webhook.Admission{
+			Handler: webhooks.NewAWSMachineTemplateValidator(scheme),
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newAWSMachineTemplate := func(instanceProfile string, labels map[string]string) *capa.AWSMachineTemplate {
+		return &capa.AWSMachineTemplate{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: capa.GroupVersion.String(),
+				Kind:       "AWSMachineTemplate",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+				Labels:    labels,
+			},
+			Spec: capa.AWSMachineTemplateSpec{
+				Template: capa.AWSMachineTemplateResource{
+					Spec: capa.AWSMachineSpec{
+						IAMInstanceProfile: instanceProfile,
+						InstanceType:       "m5.xlarge",
+					},
+				},
+			},
+		}
+	}
+
+	review := func(operation admissionv1.Operation, object, oldObject *capa.AWSMachineTemplate) *admissionv1.AdmissionResponse {
+		request := &admissionv1.AdmissionRequest{
+			UID:       types.UID("test"),
+			Kind:      metav1.GroupVersionKind{Group: capa.GroupVersion.Group, Version: capa.GroupVersion.Version, Kind: "AWSMachineTemplate"},
+			Operation: operation,
+		}
+		raw, err := json.Marshal(object)
+		Expect(err).NotTo(HaveOccurred())
+		request.Object = runtime.RawExtension{Raw: raw}
+		if oldObject != nil {
+			raw, err := json.Marshal(oldObject)
+			Expect(err).NotTo(HaveOccurred())
+			request.OldObject = runtime.RawExtension{Raw: raw}
+		}
+
+		body, err := json.Marshal(&admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: admissionv1.SchemeGroupVersion.String(),
+				Kind:       "AdmissionReview",
+			},
+			Request: request,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		admissionReview := &admissionv1.AdmissionReview{}
+		Expect(json.NewDecoder(resp.Body).Decode(admissionReview)).To(Succeed())
+		Expect(admissionReview.Response).NotTo(BeNil())
+		Expect(admissionReview.Response.UID).To(Equal(types.UID("test")))
+		return admissionReview.Response
+	}
+
+	validLabels := func() map[string]string {
+		return map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+			"cluster.x-k8s.io/role":         "control-plane",
+		}
+	}
+
+	DescribeTable("allows templates on creation",
+		func(instanceProfile string, labels map[string]string) {
+			response := review(admissionv1.Create, newAWSMachineTemplate(instanceProfile, labels), nil)
+			Expect(response.Allowed).To(BeTrue())
+		},
+		Entry("control plane template", "control-plane-test-cluster", validLabels()),
+		Entry("bastion template", "bastion-test-cluster", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+			"cluster.x-k8s.io/role":         "bastion",
+		}),
+		Entry("template without instance profile", "", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+		}),
+		Entry("template without watch filter label", "control-plane-test-cluster", map[string]string{
+			"cluster.x-k8s.io/role": "control-plane",
+		}),
+		Entry("template without role label", "nodes-test-cluster", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+		}),
+	)
+
+	DescribeTable("denies templates on creation",
+		func(labels map[string]string, message string) {
+			response := review(admissionv1.Create, newAWSMachineTemplate("control-plane-test-cluster", labels), nil)
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(ContainSubstring(message))
+		},
+		Entry("missing cluster name label", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/role":         "control-plane",
+		}, "cluster.x-k8s.io/cluster-name"),
+		Entry("unsupported role label", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+			"cluster.x-k8s.io/role":         "worker",
+		}, `got "worker"`),
+	)
+
+	It("denies updates removing a required label", func() {
+		oldTemplate := newAWSMachineTemplate("control-plane-test-cluster", validLabels())
+		template := newAWSMachineTemplate("control-plane-test-cluster", validLabels())
+		delete(template.Labels, "cluster.x-k8s.io/cluster-name")
+
+		response := review(admissionv1.Update, template, oldTemplate)
+		Expect(response.Allowed).To(BeFalse())
+	})
+
+	It("allows updates of templates which were already invalid", func() {
+		oldTemplate := newAWSMachineTemplate("control-plane-test-cluster", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+		})
+		template := newAWSMachineTemplate("control-plane-test-cluster", map[string]string{
+			"cluster.x-k8s.io/watch-filter": "capi",
+			"cluster.x-k8s.io/role":         "worker",
+		})
+
+		response := review(admissionv1.Update, template, oldTemplate)
+		Expect(response.Allowed).To(BeTrue())
+	})
+
+	It("allows updates of deleted templates", func() {
+		oldTemplate := newAWSMachineTemplate("control-plane-test-cluster", validLabels())
+		template := newAWSMachineTemplate("control-plane-test-cluster", validLabels())
+		delete(template.Labels, "cluster.x-k8s.io/cluster-name")
+		deletionTimestamp := metav1.NewTime(time.Now())
+		template.DeletionTimestamp = &deletionTimestamp
+
+		response := review(admissionv1.Update, template, oldTemplate)
+		Expect(response.Allowed).To(BeTrue())
+	})
+
+	It("returns an error for objects which cannot be decoded", func() {
+		template := newAWSMachineTemplate("control-plane-test-cluster", validLabels())
+		template.Kind = "Unknown"
+
+		response := review(admissionv1.Create, template, nil)
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Code).To(Equal(int32(http.StatusBadRequest)))
+	})
+})
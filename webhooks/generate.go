@@ -0,0 +1,3 @@
+package webhooks
+
+//go:generate ../tools/controller-gen webhook paths=./... output:webhook:artifacts:config=../config/webhook